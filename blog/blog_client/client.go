@@ -9,6 +9,7 @@ import (
 	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 )
 
 func main() {
@@ -66,8 +67,9 @@ func main() {
 		fmt.Printf("Blog was read: %v\n", readBloqRes)
 	}
 
-	// update Blog
+	// update Blog, only the author and the editors can change it
 	fmt.Println("Updating the blog")
+	authorCtx := metadata.AppendToOutgoingContext(context.Background(), "x-user-id", blog.GetAuthorId())
 
 	newBlog := &blogpb.Blog{
 		Id:       blogId,
@@ -75,7 +77,7 @@ func main() {
 		Title:    "My First Blog (edit)",
 		Content:  "Content of the first blog, with some awesome additions!",
 	}
-	updateRes, err := c.UpdateBlog(context.Background(), &blogpb.UpdateBlogRequest{Blog: newBlog})
+	updateRes, err := c.UpdateBlog(authorCtx, &blogpb.UpdateBlogRequest{Blog: newBlog})
 	if err != nil {
 		fmt.Printf("Error happened while updating: %v\n", err)
 	} else {
@@ -83,7 +85,7 @@ func main() {
 	}
 
 	// delete Blog
-	deleteRes, err := c.DeleteBlog(context.Background(), &blogpb.DeleteBlogRequest{BlogId: blogId})
+	deleteRes, err := c.DeleteBlog(authorCtx, &blogpb.DeleteBlogRequest{BlogId: blogId})
 	if err != nil {
 		fmt.Printf("Error happened while deleting: %v\n", err)
 	} else {
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata identifying the caller. Nothing authenticates them yet, they are
+// expected to be set by a trusted proxy.
+const (
+	userIdMetadata = "x-user-id"
+	groupsMetadata = "x-user-groups"
+)
+
+var visibilities = map[string]blogpb.Visibility{
+	"":                           blogpb.Visibility_PUBLIC,
+	blogstore.VisibilityUnlisted: blogpb.Visibility_UNLISTED,
+	blogstore.VisibilityPrivate:  blogpb.Visibility_PRIVATE,
+}
+
+var visibilityStates = map[blogpb.Visibility]string{
+	blogpb.Visibility_PUBLIC:   "",
+	blogpb.Visibility_UNLISTED: blogstore.VisibilityUnlisted,
+	blogpb.Visibility_PRIVATE:  blogstore.VisibilityPrivate,
+}
+
+var roles = map[string]blogpb.Role{
+	blogstore.RoleReader: blogpb.Role_READER,
+	blogstore.RoleEditor: blogpb.Role_EDITOR,
+}
+
+var roleNames = map[blogpb.Role]string{
+	blogpb.Role_READER: blogstore.RoleReader,
+	blogpb.Role_EDITOR: blogstore.RoleEditor,
+}
+
+// viewerFromContext returns the caller identity, anonymous callers have an
+// empty one
+func viewerFromContext(ctx context.Context) *blogstore.Viewer {
+	viewer := &blogstore.Viewer{}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return viewer
+	}
+	if ids := md.Get(userIdMetadata); len(ids) > 0 {
+		viewer.UserId = ids[0]
+	}
+	for _, groups := range md.Get(groupsMetadata) {
+		viewer.Groups = append(viewer.Groups, splitList(groups)...)
+	}
+	return viewer
+}
+
+// visibilityState validates the visibility of a blog about to be stored
+func visibilityState(visibility blogpb.Visibility) (string, error) {
+	state, ok := visibilityStates[visibility]
+	if !ok {
+		return "", status.Errorf(codes.InvalidArgument, "Unknown visibility: %v", visibility)
+	}
+	return state, nil
+}
+
+// canView reports whether the viewer can read the blog by its ID. Blogs
+// pending review or rejected are only shown to their author and editors.
+func canView(viewer *blogstore.Viewer, data *blogstore.Item) bool {
+	if data.ModerationStatus != "" && !viewer.IsEditor(data) {
+		return false
+	}
+	return viewer.CanRead(data)
+}
+
+// checkEdit hides the blogs the viewer cannot read
+func checkEdit(viewer *blogstore.Viewer, data *blogstore.Item) error {
+	switch {
+	case viewer.CanEdit(data):
+		return nil
+	case viewer.CanRead(data):
+		return status.Errorf(codes.PermissionDenied, "Only the author and the editors can change this blog")
+	default:
+		return blogstore.ErrNotFound
+	}
+}
+
+func aclToPb(acl []blogstore.ACLEntry) []*blogpb.AclEntry {
+	var entries []*blogpb.AclEntry
+	for _, entry := range acl {
+		entries = append(entries, &blogpb.AclEntry{Principal: entry.Principal, Role: roles[entry.Role]})
+	}
+	return entries
+}
+
+func aclFromPb(entries []*blogpb.AclEntry) ([]blogstore.ACLEntry, error) {
+	var acl []blogstore.ACLEntry
+	for _, entry := range entries {
+		role, ok := roleNames[entry.GetRole()]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "Unknown role: %v", entry.GetRole())
+		}
+		if !blogstore.ValidPrincipal(entry.GetPrincipal()) {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid principal %q, expected user:<id> or group:<name>", entry.GetPrincipal())
+		}
+		acl = append(acl, blogstore.ACLEntry{Principal: entry.GetPrincipal(), Role: role})
+	}
+	return acl, nil
+}
+
+func (s *server) ShareBlog(ctx context.Context, req *blogpb.ShareBlogRequest) (*blogpb.ShareBlogResponse, error) {
+	fmt.Println("Share blog request")
+
+	entries, err := aclFromPb([]*blogpb.AclEntry{req.GetEntry()})
+	if err != nil {
+		return nil, err
+	}
+	entry := entries[0]
+
+	data, err := s.changeACL(ctx, req.GetBlogId(), func(acl []blogstore.ACLEntry) []blogstore.ACLEntry {
+		for i := range acl {
+			if acl[i].Principal == entry.Principal {
+				acl[i].Role = entry.Role
+				return acl
+			}
+		}
+		return append(acl, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &blogpb.ShareBlogResponse{Blog: dataToBlogPb(data)}, nil
+}
+
+func (s *server) UnshareBlog(ctx context.Context, req *blogpb.UnshareBlogRequest) (*blogpb.UnshareBlogResponse, error) {
+	fmt.Println("Unshare blog request")
+
+	principal := req.GetPrincipal()
+	data, err := s.changeACL(ctx, req.GetBlogId(), func(acl []blogstore.ACLEntry) []blogstore.ACLEntry {
+		var kept []blogstore.ACLEntry
+		for _, entry := range acl {
+			if entry.Principal != principal {
+				kept = append(kept, entry)
+			}
+		}
+		return kept
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &blogpb.UnshareBlogResponse{Blog: dataToBlogPb(data)}, nil
+}
+
+// changeACL applies change to the ACL of a blog. Unlike updates, sharing
+// always needs the caller to be the author or an editor.
+func (s *server) changeACL(ctx context.Context, id string, change func([]blogstore.ACLEntry) []blogstore.ACLEntry) (*blogstore.Item, error) {
+	viewer := viewerFromContext(ctx)
+
+	var updated *blogstore.Item
+	err := s.store.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		data, err := tx.Read(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !viewer.CanRead(data) {
+			return blogstore.ErrNotFound
+		}
+		if !viewer.IsEditor(data) {
+			return status.Errorf(codes.PermissionDenied, "Only the author and the editors can share this blog")
+		}
+		updated, err = tx.SetACL(ctx, id, change(data.ACL))
+		return err
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, storeError(err)
+	}
+	indexBlog(s.related, updated)
+
+	return updated, nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"github.com/vmlellis/grpc-go-learning/blog/related"
+	"github.com/vmlellis/grpc-go-learning/blog/textstats"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type adminServer struct {
+	store     blogstore.Store
+	related   *related.Index
+	textStats textstats.Options
+}
+
+func (s *adminServer) ExportBlogs(req *blogpb.ExportBlogsRequest, stream blogpb.BlogAdminService_ExportBlogsServer) error {
+	fmt.Println("Export blogs request")
+
+	opts := blogstore.ListOptions{Moderation: blogstore.ModerationAny, Reactors: true}
+	err := s.store.List(stream.Context(), opts, func(data *blogstore.Item) error {
+		blog := dataToBlogPb(data)
+		blog.DefaultLocale = data.Locale
+		blog.Translations = translationsToPb(data.Translations)
+		blog.Reactors = reactorsToPb(data.Reactors)
+		return stream.Send(&blogpb.ExportBlogsResponse{Blog: blog})
+	})
+	if err == nil {
+		err = s.store.ListSeries(stream.Context(), func(data *blogstore.Series) error {
+			return stream.Send(&blogpb.ExportBlogsResponse{Series: seriesToPb(data)})
+		})
+	}
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return status.Errorf(codes.Internal, "Error while exporting blogs: %v", err)
+	}
+
+	return nil
+}
+
+func (s *adminServer) ImportBlogs(stream blogpb.BlogAdminService_ImportBlogsServer) error {
+	fmt.Println("Import blogs request")
+
+	ctx := stream.Context()
+	opts := &blogpb.ImportOptions{}
+	res := &blogpb.ImportBlogsResponse{}
+	first := true
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(res)
+		}
+		if err != nil {
+			return err
+		}
+
+		if o := req.GetOptions(); o != nil {
+			if !first {
+				return status.Errorf(codes.InvalidArgument, "Import options must be sent first")
+			}
+			opts = o
+			first = false
+			continue
+		}
+		first = false
+
+		if series := req.GetSeries(); series != nil {
+			data, err := seriesPbToData(series)
+			if err != nil {
+				return err
+			}
+			if err := s.importSeries(ctx, data, opts, res); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := blogPbToData(req.GetBlog())
+		if err != nil {
+			return err
+		}
+		// computed with the settings of this server, blogs exported
+		// before the statistics existed get them too
+		setTextStats(data, s.textStats)
+
+		if err := s.importBlog(ctx, data, opts, res); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *adminServer) importBlog(ctx context.Context, data *blogstore.Item, opts *blogpb.ImportOptions, res *blogpb.ImportBlogsResponse) error {
+	id := data.ID.Hex()
+
+	_, err := s.store.Read(ctx, id)
+	exists := err == nil
+	if err != nil && err != blogstore.ErrNotFound {
+		return storeError(err)
+	}
+
+	if exists {
+		res.Conflicts = append(res.Conflicts, id)
+		switch opts.GetOnConflict() {
+		case blogpb.ConflictPolicy_CONFLICT_SKIP:
+			res.Skipped++
+			return nil
+		case blogpb.ConflictPolicy_CONFLICT_FAIL:
+			if opts.GetDryRun() {
+				return nil
+			}
+			return status.Errorf(codes.AlreadyExists, "Blog %v already exists", id)
+		}
+	}
+
+	if !opts.GetDryRun() {
+		overwrite := opts.GetOnConflict() == blogpb.ConflictPolicy_CONFLICT_OVERWRITE
+		if err := s.store.Put(ctx, data, overwrite); err != nil {
+			if err == blogstore.ErrAlreadyExists {
+				return status.Errorf(codes.AlreadyExists, "Blog %v already exists", id)
+			}
+			return storeError(err)
+		}
+		indexBlog(s.related, data)
+	}
+
+	if exists {
+		res.Overwritten++
+	} else {
+		res.Created++
+	}
+	return nil
+}
+
+func (s *adminServer) importSeries(ctx context.Context, data *blogstore.Series, opts *blogpb.ImportOptions, res *blogpb.ImportBlogsResponse) error {
+	id := data.ID.Hex()
+
+	_, err := s.store.ReadSeries(ctx, id)
+	exists := err == nil
+	if err != nil && err != blogstore.ErrSeriesNotFound {
+		return storeError(err)
+	}
+
+	if exists {
+		res.Conflicts = append(res.Conflicts, id)
+		switch opts.GetOnConflict() {
+		case blogpb.ConflictPolicy_CONFLICT_SKIP:
+			res.SeriesSkipped++
+			return nil
+		case blogpb.ConflictPolicy_CONFLICT_FAIL:
+			if opts.GetDryRun() {
+				return nil
+			}
+			return status.Errorf(codes.AlreadyExists, "Series %v already exists", id)
+		}
+	}
+
+	if !opts.GetDryRun() {
+		overwrite := opts.GetOnConflict() == blogpb.ConflictPolicy_CONFLICT_OVERWRITE
+		if err := s.store.PutSeries(ctx, data, overwrite); err != nil {
+			if err == blogstore.ErrSeriesExists {
+				return status.Errorf(codes.AlreadyExists, "Series %v already exists", id)
+			}
+			if err == blogstore.ErrInSeries {
+				return status.Errorf(codes.FailedPrecondition, "A blog of series %v belongs to another series", id)
+			}
+			return storeError(err)
+		}
+	}
+
+	if exists {
+		res.SeriesOverwritten++
+	} else {
+		res.SeriesCreated++
+	}
+	return nil
+}
+
+// blogPbToData converts a complete blog, as produced by ExportBlogs
+func blogPbToData(blog *blogpb.Blog) (*blogstore.Item, error) {
+	oid, err := primitive.ObjectIDFromHex(blog.GetId())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot parse ID %q", blog.GetId())
+	}
+
+	data := &blogstore.Item{
+		ID:       oid,
+		AuthorId: blog.GetAuthorId(),
+		Title:    blog.GetTitle(),
+		Content:  blog.GetContent(),
+		Tags:     blog.GetTags(),
+		Views:    blog.GetViews(),
+
+		ModerationStatus: moderationStates[blog.GetModerationStatus()],
+		ModerationReason: blog.GetModerationReason(),
+	}
+	if data.Visibility, err = visibilityState(blog.GetVisibility()); err != nil {
+		return nil, err
+	}
+	if data.ACL, err = aclFromPb(blog.GetAcl()); err != nil {
+		return nil, err
+	}
+	if blog.GetDefaultLocale() != "" {
+		if data.Locale, err = parseLocale(blog.GetDefaultLocale()); err != nil {
+			return nil, err
+		}
+	}
+	if data.Translations, err = translationsFromPb(blog.GetTranslations()); err != nil {
+		return nil, err
+	}
+	if blog.GetLikes() != 0 || blog.GetClaps() != 0 {
+		data.Reactions = map[string]int64{
+			blogstore.ReactionLike: blog.GetLikes(),
+			blogstore.ReactionClap: blog.GetClaps(),
+		}
+	}
+	for reaction, reactors := range blog.GetReactors() {
+		if reaction != blogstore.ReactionLike && reaction != blogstore.ReactionClap {
+			return nil, status.Errorf(codes.InvalidArgument, "Unknown reaction %q for blog %v", reaction, blog.GetId())
+		}
+		if data.Reactors == nil {
+			data.Reactors = map[string][]string{}
+		}
+		data.Reactors[reaction] = reactors.GetUserIds()
+	}
+
+	if blog.GetCreatedAt() != nil {
+		if data.CreatedAt, err = ptypes.Timestamp(blog.GetCreatedAt()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid created_at for blog %v: %v", blog.GetId(), err)
+		}
+	}
+	if blog.GetUpdatedAt() != nil {
+		if data.UpdatedAt, err = ptypes.Timestamp(blog.GetUpdatedAt()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid updated_at for blog %v: %v", blog.GetId(), err)
+		}
+	}
+
+	return data, nil
+}
+
+// seriesPbToData converts a complete series, as produced by ExportBlogs
+func seriesPbToData(series *blogpb.Series) (*blogstore.Series, error) {
+	oid, err := primitive.ObjectIDFromHex(series.GetId())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot parse series ID %q", series.GetId())
+	}
+
+	data := &blogstore.Series{
+		ID:          oid,
+		AuthorId:    series.GetAuthorId(),
+		Title:       series.GetTitle(),
+		Description: series.GetDescription(),
+		BlogIDs:     series.GetBlogIds(),
+	}
+	if series.GetCreatedAt() != nil {
+		if data.CreatedAt, err = ptypes.Timestamp(series.GetCreatedAt()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid created_at for series %v: %v", series.GetId(), err)
+		}
+	}
+	if series.GetUpdatedAt() != nil {
+		if data.UpdatedAt, err = ptypes.Timestamp(series.GetUpdatedAt()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid updated_at for series %v: %v", series.GetId(), err)
+		}
+	}
+
+	return data, nil
+}
+
+func reactorsToPb(reactors map[string][]string) map[string]*blogpb.Reactors {
+	if len(reactors) == 0 {
+		return nil
+	}
+	result := make(map[string]*blogpb.Reactors, len(reactors))
+	for reaction, users := range reactors {
+		result[reaction] = &blogpb.Reactors{UserIds: users}
+	}
+	return result
+}
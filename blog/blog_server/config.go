@@ -0,0 +1,436 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"golang.org/x/text/language"
+)
+
+// envPrefix is prepended to the upper-cased flag name to build the
+// environment variable for a setting, e.g. -mongo-uri => BLOG_MONGO_URI
+const envPrefix = "BLOG_"
+
+type config struct {
+	ConfigFile  string
+	Backend     string
+	AutoMigrate bool
+	SQLitePath  string
+
+	MongoURI        string
+	MongoUsername   string
+	MongoPassword   string
+	MongoAuthSource string
+	Database        string
+	Collection      string
+
+	MaxPoolSize uint64
+	MinPoolSize uint64
+
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+	SocketTimeout          time.Duration
+	PingTimeout            time.Duration
+
+	ReadConcern   string
+	WriteConcern  string
+	WriteJournal  bool
+	WriteTimeout  time.Duration
+	ListenAddress string
+	AdminListen   string
+
+	ViewFlushInterval time.Duration
+
+	FeedListen  string
+	FeedTitle   string
+	FeedBaseURL string
+
+	MetricsListen string
+
+	BannedWords      string
+	BannedWordsFile  string
+	MaxLinks         int
+	MaxContentLength int
+
+	RelatedRebuildInterval time.Duration
+
+	RPCTimeout     time.Duration
+	MethodTimeouts string
+
+	ListStallTimeout time.Duration
+
+	DefaultLocale string
+
+	ExcerptLength  int
+	WordsPerMinute int
+}
+
+func defaultConfig() *config {
+	return &config{
+		Backend:                "mongo",
+		AutoMigrate:            true,
+		SQLitePath:             "blog.db",
+		MongoURI:               "mongodb://localhost:27017",
+		Database:               "mydb",
+		Collection:             "blog",
+		MaxPoolSize:            100,
+		ConnectTimeout:         10 * time.Second,
+		ServerSelectionTimeout: 10 * time.Second,
+		PingTimeout:            5 * time.Second,
+		ListenAddress:          "0.0.0.0:50051",
+		AdminListen:            "127.0.0.1:50052",
+		ViewFlushInterval:      10 * time.Second,
+		FeedListen:             "0.0.0.0:8080",
+		MetricsListen:          "127.0.0.1:8081",
+		FeedTitle:              "Blog",
+		FeedBaseURL:            "http://localhost:8080",
+		MaxLinks:               5,
+		MaxContentLength:       100000,
+		RelatedRebuildInterval: 10 * time.Minute,
+		RPCTimeout:             10 * time.Second,
+		MethodTimeouts:         "ListBlog=1m,ExportBlogs=0,ImportBlogs=0",
+		ListStallTimeout:       30 * time.Second,
+		DefaultLocale:          "en",
+		ExcerptLength:          200,
+		WordsPerMinute:         200,
+	}
+}
+
+func (cfg *config) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	fs.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "path to a JSON config file")
+	fs.StringVar(&cfg.ListenAddress, "listen", cfg.ListenAddress, "gRPC listen address")
+	fs.StringVar(&cfg.AdminListen, "admin-listen", cfg.AdminListen, "gRPC listen address of the admin service (backups and moderation), unauthenticated so keep it private (empty disables it)")
+	fs.StringVar(&cfg.Backend, "backend", cfg.Backend, "storage backend: mongo, sqlite or memory")
+	fs.BoolVar(&cfg.AutoMigrate, "auto-migrate", cfg.AutoMigrate, "apply pending schema migrations at startup")
+	fs.StringVar(&cfg.SQLitePath, "sqlite-path", cfg.SQLitePath, "SQLite database file, used by the sqlite backend")
+
+	fs.StringVar(&cfg.MongoURI, "mongo-uri", cfg.MongoURI, "MongoDB connection URI")
+	fs.StringVar(&cfg.MongoUsername, "mongo-username", cfg.MongoUsername, "MongoDB username (overrides the URI)")
+	fs.StringVar(&cfg.MongoPassword, "mongo-password", cfg.MongoPassword, "MongoDB password (overrides the URI)")
+	fs.StringVar(&cfg.MongoAuthSource, "mongo-auth-source", cfg.MongoAuthSource, "MongoDB authentication database")
+	fs.StringVar(&cfg.Database, "database", cfg.Database, "MongoDB database name")
+	fs.StringVar(&cfg.Collection, "collection", cfg.Collection, "MongoDB collection name for blogs")
+
+	fs.Uint64Var(&cfg.MaxPoolSize, "max-pool-size", cfg.MaxPoolSize, "maximum number of connections in the pool")
+	fs.Uint64Var(&cfg.MinPoolSize, "min-pool-size", cfg.MinPoolSize, "minimum number of connections in the pool")
+
+	fs.DurationVar(&cfg.ConnectTimeout, "connect-timeout", cfg.ConnectTimeout, "timeout for establishing a connection")
+	fs.DurationVar(&cfg.ServerSelectionTimeout, "server-selection-timeout", cfg.ServerSelectionTimeout, "timeout for selecting a server")
+	fs.DurationVar(&cfg.SocketTimeout, "socket-timeout", cfg.SocketTimeout, "timeout for socket reads and writes (0 means none)")
+	fs.DurationVar(&cfg.PingTimeout, "ping-timeout", cfg.PingTimeout, "timeout for the startup connectivity check")
+
+	fs.StringVar(&cfg.ReadConcern, "read-concern", cfg.ReadConcern, "read concern level (local, available, majority, linearizable, snapshot)")
+	fs.StringVar(&cfg.WriteConcern, "write-concern", cfg.WriteConcern, "write concern: a number of nodes, \"majority\" or a tag set name")
+	fs.BoolVar(&cfg.WriteJournal, "write-journal", cfg.WriteJournal, "require writes to be acknowledged by the journal")
+	fs.DurationVar(&cfg.WriteTimeout, "write-timeout", cfg.WriteTimeout, "write concern timeout")
+
+	fs.DurationVar(&cfg.ViewFlushInterval, "view-flush-interval", cfg.ViewFlushInterval, "how often buffered view counts are written")
+
+	fs.StringVar(&cfg.FeedListen, "feed-listen", cfg.FeedListen, "HTTP listen address for the RSS, Atom and JSON feeds (empty disables them)")
+	fs.StringVar(&cfg.FeedTitle, "feed-title", cfg.FeedTitle, "title of the feeds")
+	fs.StringVar(&cfg.FeedBaseURL, "feed-base-url", cfg.FeedBaseURL, "public URL the blog links in the feeds are built from")
+
+	fs.StringVar(&cfg.MetricsListen, "metrics-listen", cfg.MetricsListen, "HTTP listen address for the /debug/vars metrics, keep it private (empty disables them)")
+
+	fs.StringVar(&cfg.BannedWords, "banned-words", cfg.BannedWords, "comma separated words or phrases that get a blog rejected")
+	fs.StringVar(&cfg.BannedWordsFile, "banned-words-file", cfg.BannedWordsFile, "file with one banned word or phrase per line")
+	fs.IntVar(&cfg.MaxLinks, "max-links", cfg.MaxLinks, "blogs with more links are queued for review (0 disables the rule)")
+	fs.IntVar(&cfg.MaxContentLength, "max-content-length", cfg.MaxContentLength, "blogs with a longer content are rejected (0 disables the rule)")
+
+	fs.DurationVar(&cfg.RelatedRebuildInterval, "related-rebuild-interval", cfg.RelatedRebuildInterval, "how often the related blogs index is rebuilt from the store (0 disables it)")
+
+	fs.DurationVar(&cfg.RPCTimeout, "rpc-timeout", cfg.RPCTimeout, "deadline given to the RPCs the client sent without a shorter one (0 means none)")
+	fs.StringVar(&cfg.MethodTimeouts, "method-timeouts", cfg.MethodTimeouts, "comma separated Method=duration overrides of rpc-timeout")
+	fs.DurationVar(&cfg.ListStallTimeout, "list-stall-timeout", cfg.ListStallTimeout, "close the connections of clients not answering keepalive pings for this long, which ends their blocked ListBlog streams (0 means never)")
+
+	fs.StringVar(&cfg.DefaultLocale, "default-locale", cfg.DefaultLocale, "BCP-47 locale of the blogs created without one")
+
+	fs.IntVar(&cfg.ExcerptLength, "excerpt-length", cfg.ExcerptLength, "maximum length in characters of the blog excerpts (0 disables them)")
+	fs.IntVar(&cfg.WordsPerMinute, "words-per-minute", cfg.WordsPerMinute, "reading speed the reading time of the blogs is estimated with")
+
+	return fs
+}
+
+// loadConfig builds the configuration from, in increasing order of
+// precedence: built-in defaults, the config file, environment variables
+// and command line flags. Flags registered by extraFlags are only read
+// from the command line. The remaining positional arguments are returned.
+func loadConfig(name string, args []string, extraFlags func(fs *flag.FlagSet)) (*config, []string, error) {
+	cfg := defaultConfig()
+	fs := cfg.flagSet(name)
+
+	cmdOnly := map[string]bool{"config": true}
+	if extraFlags != nil {
+		known := map[string]bool{}
+		fs.VisitAll(func(f *flag.Flag) { known[f.Name] = true })
+		extraFlags(fs)
+		fs.VisitAll(func(f *flag.Flag) {
+			if !known[f.Name] {
+				cmdOnly[f.Name] = true
+			}
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if !explicit["config"] {
+		if path, ok := os.LookupEnv(envName("config")); ok {
+			cfg.ConfigFile = path
+		}
+	}
+
+	if cfg.ConfigFile != "" {
+		values, err := readConfigFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		for key, value := range values {
+			if fs.Lookup(key) == nil || cmdOnly[key] {
+				return nil, nil, fmt.Errorf("config file %v: unknown setting %q", cfg.ConfigFile, key)
+			}
+			if explicit[key] {
+				continue
+			}
+			if err := fs.Set(key, value); err != nil {
+				return nil, nil, fmt.Errorf("config file %v: invalid value for %q: %v", cfg.ConfigFile, key, err)
+			}
+		}
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if envErr != nil || explicit[f.Name] || cmdOnly[f.Name] {
+			return
+		}
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			envErr = fmt.Errorf("environment variable %v: %v", envName(f.Name), err)
+		}
+	})
+	if envErr != nil {
+		return nil, nil, envErr
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// readConfigFile reads a flat JSON object whose keys are the flag names.
+// Values may be strings, numbers or booleans, durations use Go syntax ("5s").
+func readConfigFile(path string) (map[string]string, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file: %v", err)
+	}
+
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("cannot parse config file %v: %v", path, err)
+	}
+
+	values := make(map[string]string, len(decoded))
+	for key, value := range decoded {
+		switch v := value.(type) {
+		case string:
+			values[key] = v
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("config file %v: setting %q must be a string, number or boolean", path, key)
+		}
+	}
+
+	return values, nil
+}
+
+func (cfg *config) validate() error {
+	switch cfg.Backend {
+	case "mongo":
+		if err := cfg.validateMongo(); err != nil {
+			return err
+		}
+	case "memory":
+	case "sqlite":
+		if cfg.SQLitePath == "" {
+			return fmt.Errorf("sqlite-path must not be empty")
+		}
+	default:
+		return fmt.Errorf("unknown backend %q", cfg.Backend)
+	}
+	if cfg.AdminListen != "" && cfg.AdminListen == cfg.ListenAddress {
+		return fmt.Errorf("admin-listen must differ from listen, the admin service is not authenticated")
+	}
+	if cfg.ViewFlushInterval <= 0 {
+		return fmt.Errorf("view-flush-interval must be positive")
+	}
+	if cfg.RPCTimeout < 0 {
+		return fmt.Errorf("rpc-timeout must not be negative")
+	}
+	if cfg.ListStallTimeout < 0 {
+		return fmt.Errorf("list-stall-timeout must not be negative")
+	}
+	if cfg.ListStallTimeout > 0 && cfg.ListStallTimeout < time.Second {
+		// gRPC raises the keepalive times below a second
+		return fmt.Errorf("list-stall-timeout must be at least 1s")
+	}
+	if _, err := language.Parse(cfg.DefaultLocale); err != nil {
+		return fmt.Errorf("invalid default-locale %q", cfg.DefaultLocale)
+	}
+	if cfg.ExcerptLength < 0 {
+		return fmt.Errorf("excerpt-length must not be negative")
+	}
+	if cfg.WordsPerMinute <= 0 {
+		return fmt.Errorf("words-per-minute must be positive")
+	}
+	if _, err := parseMethodTimeouts(cfg.MethodTimeouts); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateMongo checks the settings only used by the MongoDB backend
+func (cfg *config) validateMongo() error {
+	if cfg.MongoURI == "" {
+		return fmt.Errorf("mongo-uri must not be empty")
+	}
+	if cfg.Database == "" || cfg.Collection == "" {
+		return fmt.Errorf("database and collection must not be empty")
+	}
+	if cfg.MinPoolSize > cfg.MaxPoolSize && cfg.MaxPoolSize != 0 {
+		return fmt.Errorf("min-pool-size (%v) is greater than max-pool-size (%v)", cfg.MinPoolSize, cfg.MaxPoolSize)
+	}
+	if cfg.ConnectTimeout <= 0 {
+		return fmt.Errorf("connect-timeout must be positive")
+	}
+	if cfg.PingTimeout <= 0 {
+		return fmt.Errorf("ping-timeout must be positive")
+	}
+	switch cfg.ReadConcern {
+	case "", "local", "available", "majority", "linearizable", "snapshot":
+	default:
+		return fmt.Errorf("unknown read-concern %q", cfg.ReadConcern)
+	}
+	return nil
+}
+
+// clientOptions translates the configuration into MongoDB client options.
+// Explicit settings take precedence over the ones embedded in the URI.
+func (cfg *config) clientOptions() *options.ClientOptions {
+	opts := options.Client().ApplyURI(cfg.MongoURI)
+
+	if cfg.MongoUsername != "" {
+		cred := options.Credential{
+			Username:   cfg.MongoUsername,
+			Password:   cfg.MongoPassword,
+			AuthSource: cfg.MongoAuthSource,
+		}
+		if opts.Auth != nil {
+			cred.AuthMechanism = opts.Auth.AuthMechanism
+			if cred.AuthSource == "" {
+				cred.AuthSource = opts.Auth.AuthSource
+			}
+		}
+		opts.SetAuth(cred)
+	}
+
+	if cfg.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(cfg.MaxPoolSize)
+	}
+	if cfg.MinPoolSize > 0 {
+		opts.SetMinPoolSize(cfg.MinPoolSize)
+	}
+	if cfg.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(cfg.ConnectTimeout)
+	}
+	if cfg.ServerSelectionTimeout > 0 {
+		opts.SetServerSelectionTimeout(cfg.ServerSelectionTimeout)
+	}
+	if cfg.SocketTimeout > 0 {
+		opts.SetSocketTimeout(cfg.SocketTimeout)
+	}
+
+	if cfg.ReadConcern != "" {
+		opts.SetReadConcern(readconcern.New(readconcern.Level(cfg.ReadConcern)))
+	}
+	if wc := cfg.writeConcern(); wc != nil {
+		opts.SetWriteConcern(wc)
+	}
+
+	return opts
+}
+
+func (cfg *config) writeConcern() *writeconcern.WriteConcern {
+	var wcOpts []writeconcern.Option
+
+	switch {
+	case cfg.WriteConcern == "":
+	case cfg.WriteConcern == "majority":
+		wcOpts = append(wcOpts, writeconcern.WMajority())
+	default:
+		if n, err := strconv.Atoi(cfg.WriteConcern); err == nil {
+			wcOpts = append(wcOpts, writeconcern.W(n))
+		} else {
+			wcOpts = append(wcOpts, writeconcern.WTagSet(cfg.WriteConcern))
+		}
+	}
+	if cfg.WriteJournal {
+		wcOpts = append(wcOpts, writeconcern.J(true))
+	}
+	if cfg.WriteTimeout > 0 {
+		wcOpts = append(wcOpts, writeconcern.WTimeout(cfg.WriteTimeout))
+	}
+
+	if len(wcOpts) == 0 {
+		return nil
+	}
+	return writeconcern.New(wcOpts...)
+}
+
+// redactedURI returns the Mongo URI without the password so it can be logged.
+// It does not parse the URI, url.Parse rejects the multi-host ones. The
+// credentials end at the last "@", so a password holding one is still
+// hidden.
+func (cfg *config) redactedURI() string {
+	uri := cfg.MongoURI
+	prefix := ""
+	if i := strings.Index(uri, "://"); i >= 0 {
+		prefix, uri = uri[:i+3], uri[i+3:]
+	}
+	at := strings.LastIndex(uri, "@")
+	if at < 0 {
+		return cfg.MongoURI
+	}
+	user := uri[:at]
+	if i := strings.Index(user, ":"); i >= 0 {
+		user = user[:i] + ":xxxxx"
+	}
+	return prefix + user + uri[at:]
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// methodTimeouts holds the deadline given to each RPC, keyed by method name
+// (e.g. "ReadBlog"). A zero timeout leaves the RPC without deadline.
+type methodTimeouts struct {
+	fallback time.Duration
+	methods  map[string]time.Duration
+}
+
+// parseMethodTimeouts parses a comma separated Method=duration list
+func parseMethodTimeouts(list string) (map[string]time.Duration, error) {
+	methods := map[string]time.Duration{}
+	for _, item := range splitList(list) {
+		i := strings.Index(item, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("method-timeouts: %q is not Method=duration", item)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(item[i+1:]))
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("method-timeouts: invalid duration for %v", item[:i])
+		}
+		methods[strings.TrimSpace(item[:i])] = timeout
+	}
+	return methods, nil
+}
+
+func newMethodTimeouts(cfg *config) (*methodTimeouts, error) {
+	methods, err := parseMethodTimeouts(cfg.MethodTimeouts)
+	if err != nil {
+		return nil, err
+	}
+	return &methodTimeouts{fallback: cfg.RPCTimeout, methods: methods}, nil
+}
+
+// withTimeout applies the timeout of the method to ctx, unless the client
+// already asked for a shorter deadline
+func (t *methodTimeouts) withTimeout(ctx context.Context, fullMethod string) (context.Context, context.CancelFunc) {
+	timeout, ok := t.methods[path.Base(fullMethod)]
+	if !ok {
+		timeout = t.fallback
+	}
+	if timeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (t *methodTimeouts) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, cancel := t.withTimeout(ctx, info.FullMethod)
+	defer cancel()
+
+	res, err := handler(ctx, req)
+	return res, contextError(ctx, err)
+}
+
+func (t *methodTimeouts) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, cancel := t.withTimeout(ss.Context(), info.FullMethod)
+	defer cancel()
+
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	return contextError(ctx, err)
+}
+
+// contextStream replaces the context of a server stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// contextError reports the failures caused by the end of the RPC context as
+// CANCELED or DEADLINE_EXCEEDED, whatever error the store wrapped them in
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch ctx.Err() {
+	case context.Canceled:
+		return status.Errorf(codes.Canceled, "Request canceled")
+	case context.DeadlineExceeded:
+		return status.Errorf(codes.DeadlineExceeded, "Request deadline exceeded")
+	}
+	return err
+}
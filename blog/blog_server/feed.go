@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultFeedLimit = 20
+	maxFeedLimit     = 100
+)
+
+// feedConfig holds the feed wide metadata
+type feedConfig struct {
+	Title string
+	// BaseURL is used to build the links to the blogs: <BaseURL>/blogs/<id>
+	BaseURL string
+}
+
+type feedQuery struct {
+	Format   blogpb.FeedFormat
+	AuthorId string
+	Tag      string
+	Limit    int
+}
+
+type feed struct {
+	query feedQuery
+	items []*blogstore.Item
+	// updated is the newest update time of the items
+	updated time.Time
+	etag    string
+}
+
+func (s *server) GetFeed(ctx context.Context, req *blogpb.GetFeedRequest) (*blogpb.GetFeedResponse, error) {
+	fmt.Println("Get feed request")
+
+	q := feedQuery{
+		Format:   req.GetFormat(),
+		AuthorId: req.GetAuthorId(),
+		Tag:      req.GetTag(),
+		Limit:    int(req.GetLimit()),
+	}
+	f, err := s.loadFeed(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var ifModifiedSince time.Time
+	if req.GetIfModifiedSince() != nil {
+		if ifModifiedSince, err = ptypes.Timestamp(req.GetIfModifiedSince()); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid if_modified_since: %v", err)
+		}
+	}
+
+	res := &blogpb.GetFeedResponse{
+		Etag:         f.etag,
+		LastModified: timestampPb(f.updated),
+	}
+	if f.notModified(req.GetIfNoneMatch(), ifModifiedSince) {
+		res.NotModified = true
+		return res, nil
+	}
+
+	res.ContentType, res.Body, err = s.feeds.render(f)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Cannot render feed: %v", err)
+	}
+
+	return res, nil
+}
+
+// loadFeed lists the newest blogs matching the query
+func (s *server) loadFeed(ctx context.Context, q feedQuery) (*feed, error) {
+	if _, ok := blogpb.FeedFormat_name[int32(q.Format)]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown feed format: %v", q.Format)
+	}
+	switch {
+	case q.Limit < 0:
+		return nil, status.Errorf(codes.InvalidArgument, "Limit must not be negative")
+	case q.Limit == 0:
+		q.Limit = defaultFeedLimit
+	case q.Limit > maxFeedLimit:
+		q.Limit = maxFeedLimit
+	}
+	q.Tag = strings.ToLower(strings.TrimSpace(q.Tag))
+
+	f := &feed{query: q}
+	opts := blogstore.ListOptions{
+		AuthorId:   q.AuthorId,
+		Tag:        q.Tag,
+		Descending: true,
+		Limit:      q.Limit,
+		// feeds are public
+		Viewer: &blogstore.Viewer{},
+	}
+	err := s.store.List(ctx, opts, func(data *blogstore.Item) error {
+		f.items = append(f.items, data)
+		if data.UpdatedAt.After(f.updated) {
+			f.updated = data.UpdatedAt
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Cannot list blogs: %v", err)
+	}
+
+	f.etag = f.computeETag()
+	return f, nil
+}
+
+// computeETag hashes the query, the newest update time and the IDs, so that
+// deleting a blog also changes the ETag
+func (f *feed) computeETag() string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%v\n%v\n%v\n%v\n%v\n", f.query.Format, f.query.AuthorId, f.query.Tag, f.query.Limit, f.updated.UnixNano())
+	for _, data := range f.items {
+		fmt.Fprintln(h, data.ID.Hex())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// notModified evaluates the conditional request like HTTP does:
+// If-None-Match takes precedence over If-Modified-Since.
+func (f *feed) notModified(ifNoneMatch string, ifModifiedSince time.Time) bool {
+	if ifNoneMatch != "" {
+		for _, tag := range strings.Split(ifNoneMatch, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == f.etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince.IsZero() || f.updated.IsZero() {
+		return false
+	}
+	// HTTP dates have a precision of one second
+	return !f.updated.Truncate(time.Second).After(ifModifiedSince)
+}
+
+func (fc feedConfig) blogURL(data *blogstore.Item) string {
+	return strings.TrimRight(fc.BaseURL, "/") + "/blogs/" + data.ID.Hex()
+}
+
+func (fc feedConfig) title(q feedQuery) string {
+	title := fc.Title
+	if q.AuthorId != "" {
+		title += " by " + q.AuthorId
+	}
+	if q.Tag != "" {
+		title += " tagged " + q.Tag
+	}
+	return title
+}
+
+func (fc feedConfig) render(f *feed) (string, []byte, error) {
+	switch f.query.Format {
+	case blogpb.FeedFormat_ATOM:
+		body, err := fc.renderAtom(f)
+		return "application/atom+xml; charset=utf-8", body, err
+	case blogpb.FeedFormat_JSON_FEED:
+		body, err := fc.renderJSONFeed(f)
+		return "application/feed+json; charset=utf-8", body, err
+	default:
+		body, err := fc.renderRSS(f)
+		return "application/rss+xml; charset=utf-8", body, err
+	}
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (fc feedConfig) renderRSS(f *feed) ([]byte, error) {
+	title := fc.title(f.query)
+	rss := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       title,
+			Link:        fc.BaseURL,
+			Description: title,
+		},
+	}
+	if !f.updated.IsZero() {
+		rss.Channel.LastBuildDate = f.updated.Format(time.RFC1123Z)
+	}
+
+	for _, data := range f.items {
+		item := rssItem{
+			Title:       data.Title,
+			Link:        fc.blogURL(data),
+			Description: data.Content,
+			Categories:  data.Tags,
+			GUID:        rssGUID{IsPermaLink: true, Value: fc.blogURL(data)},
+		}
+		if !data.CreatedAt.IsZero() {
+			item.PubDate = data.CreatedAt.Format(time.RFC1123Z)
+		}
+		rss.Channel.Items = append(rss.Channel.Items, item)
+	}
+
+	return marshalXML(rss)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Author     atomPerson     `xml:"author"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (fc feedConfig) renderAtom(f *feed) ([]byte, error) {
+	atom := atomFeed{
+		ID:      fc.BaseURL,
+		Title:   fc.title(f.query),
+		Updated: atomTime(f.updated),
+		Link:    atomLink{Href: fc.BaseURL},
+	}
+
+	for _, data := range f.items {
+		entry := atomEntry{
+			ID:      fc.blogURL(data),
+			Title:   data.Title,
+			Updated: atomTime(data.UpdatedAt),
+			Author:  atomPerson{Name: data.AuthorId},
+			Link:    atomLink{Href: fc.blogURL(data), Rel: "alternate"},
+			Content: atomContent{Type: "text", Value: data.Content},
+		}
+		if !data.CreatedAt.IsZero() {
+			entry.Published = atomTime(data.CreatedAt)
+		}
+		for _, tag := range data.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	return marshalXML(atom)
+}
+
+// atomTime formats the time as RFC 3339, updated is mandatory in Atom so
+// unknown times are reported as the epoch
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// JSON Feed version 1.1, https://jsonfeed.org/version/1.1
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentText   string           `json:"content_text"`
+	DatePublished string           `json:"date_published,omitempty"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+func (fc feedConfig) renderJSONFeed(f *feed) ([]byte, error) {
+	jf := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       fc.title(f.query),
+		HomePageURL: fc.BaseURL,
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, data := range f.items {
+		item := jsonFeedItem{
+			ID:          data.ID.Hex(),
+			URL:         fc.blogURL(data),
+			Title:       data.Title,
+			ContentText: data.Content,
+			Tags:        data.Tags,
+		}
+		if !data.CreatedAt.IsZero() {
+			item.DatePublished = data.CreatedAt.UTC().Format(time.RFC3339)
+		}
+		if !data.UpdatedAt.IsZero() {
+			item.DateModified = data.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		if data.AuthorId != "" {
+			item.Authors = []jsonFeedAuthor{{Name: data.AuthorId}}
+		}
+		jf.Items = append(jf.Items, item)
+	}
+
+	return json.MarshalIndent(jf, "", "  ")
+}
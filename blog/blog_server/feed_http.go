@@ -0,0 +1,135 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var feedFormats = map[string]blogpb.FeedFormat{
+	"rss":  blogpb.FeedFormat_RSS,
+	"atom": blogpb.FeedFormat_ATOM,
+	"json": blogpb.FeedFormat_JSON_FEED,
+}
+
+// feedHandler serves /feeds/{rss,atom,json}?author=...&tag=...&limit=...
+// with conditional GET support.
+func (s *server) feedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		format, ok := feedFormats[strings.TrimPrefix(r.URL.Path, "/feeds/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		q := feedQuery{
+			Format:   format,
+			AuthorId: r.URL.Query().Get("author"),
+			Tag:      r.URL.Query().Get("tag"),
+		}
+		if limit := r.URL.Query().Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			q.Limit = n
+		}
+
+		f, err := s.loadFeed(r.Context(), q)
+		if err != nil {
+			http.Error(w, status.Convert(err).Message(), httpStatus(err))
+			return
+		}
+
+		w.Header().Set("ETag", f.etag)
+		if !f.updated.IsZero() {
+			w.Header().Set("Last-Modified", f.updated.UTC().Format(http.TimeFormat))
+		}
+
+		ifModifiedSince, _ := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if f.notModified(r.Header.Get("If-None-Match"), ifModifiedSince) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		contentType, body, err := s.feeds.render(f)
+		if err != nil {
+			http.Error(w, "cannot render feed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Write(body)
+	})
+}
+
+func httpStatus(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func newFeedServer(addr string, s *server) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/feeds/", s.feedHandler())
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// metricsHandler serves the blog_* expvar variables in the /debug/vars
+// format. The default handler also publishes cmdline, which holds the
+// flags and so the MongoDB password.
+func metricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprintf(w, "{\n")
+		first := true
+		expvar.Do(func(kv expvar.KeyValue) {
+			if !strings.HasPrefix(kv.Key, "blog_") {
+				return
+			}
+			if !first {
+				fmt.Fprintf(w, ",\n")
+			}
+			first = false
+			fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+		})
+		fmt.Fprintf(w, "\n}\n")
+	})
+}
+
+func newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", metricsHandler())
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+)
+
+func TestFeedNotModified(t *testing.T) {
+	updated := time.Date(2020, 7, 1, 12, 30, 15, 500, time.UTC)
+	f := &feed{updated: updated, etag: `"abc"`}
+
+	tests := []struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince time.Time
+		want            bool
+	}{
+		{"unconditional", "", time.Time{}, false},
+		{"same etag", `"abc"`, time.Time{}, true},
+		{"weak etag", `W/"abc"`, time.Time{}, true},
+		{"etag in a list", `"old", "abc"`, time.Time{}, true},
+		{"any etag", "*", time.Time{}, true},
+		{"other etag", `"old"`, time.Time{}, false},
+		{"etag wins over the date", `"old"`, updated.Add(time.Hour), false},
+		{"same second", "", updated.Truncate(time.Second), true},
+		{"modified since", "", updated.Add(-time.Second), false},
+		{"later date", "", updated.Add(time.Hour), true},
+	}
+	for _, tt := range tests {
+		if got := f.notModified(tt.ifNoneMatch, tt.ifModifiedSince); got != tt.want {
+			t.Errorf("%v: notModified(%q, %v) = %v, want %v", tt.name, tt.ifNoneMatch, tt.ifModifiedSince, got, tt.want)
+		}
+	}
+
+	empty := &feed{etag: `"abc"`}
+	if empty.notModified("", updated) {
+		t.Errorf("an empty feed is not modified since %v", updated)
+	}
+}
+
+func TestFeedHandler(t *testing.T) {
+	s := newFeedTestServer(t)
+	h := s.feedHandler()
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		code   int
+		// items is the number of entries, -1 when there is no body to check
+		items int
+	}{
+		{"rss", "GET", "/feeds/rss", http.StatusOK, 3},
+		{"atom", "GET", "/feeds/atom", http.StatusOK, 3},
+		{"json", "GET", "/feeds/json", http.StatusOK, 3},
+		{"by author", "GET", "/feeds/json?author=ana", http.StatusOK, 2},
+		{"by tag", "GET", "/feeds/rss?tag=%20Go%20", http.StatusOK, 1},
+		{"limit", "GET", "/feeds/atom?limit=1", http.StatusOK, 1},
+		{"head", "HEAD", "/feeds/rss", http.StatusOK, -1},
+		{"unknown format", "GET", "/feeds/html", http.StatusNotFound, -1},
+		{"invalid limit", "GET", "/feeds/rss?limit=ten", http.StatusBadRequest, -1},
+		{"negative limit", "GET", "/feeds/rss?limit=-1", http.StatusBadRequest, -1},
+		{"post", "POST", "/feeds/rss", http.StatusMethodNotAllowed, -1},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.url, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%v: %v %v = %v, want %v", tt.name, tt.method, tt.url, rec.Code, tt.code)
+			continue
+		}
+		if tt.items < 0 {
+			continue
+		}
+		if got := countFeedItems(t, rec.Header().Get("Content-Type"), rec.Body.Bytes()); got != tt.items {
+			t.Errorf("%v: %v has %v items, want %v", tt.name, tt.url, got, tt.items)
+		}
+	}
+}
+
+func TestFeedConditionalGet(t *testing.T) {
+	s := newFeedTestServer(t)
+	h := s.feedHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/feeds/rss", nil))
+	etag, lastModified := rec.Header().Get("ETag"), rec.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("feed has ETag %q and Last-Modified %q, want both", etag, lastModified)
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		value  string
+		code   int
+	}{
+		{"same etag", "/feeds/rss", "If-None-Match", etag, http.StatusNotModified},
+		{"other format", "/feeds/atom", "If-None-Match", etag, http.StatusOK},
+		{"other author", "/feeds/rss?author=ana", "If-None-Match", etag, http.StatusOK},
+		{"last modified", "/feeds/rss", "If-Modified-Since", lastModified, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", tt.url, nil)
+		req.Header.Set(tt.header, tt.value)
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%v: %v with %v = %v, want %v", tt.name, tt.url, tt.header, rec.Code, tt.code)
+		}
+	}
+
+	// deleting a blog changes the ETag even if the newest update is kept
+	var oldest *blogstore.Item
+	err := s.store.List(context.Background(), blogstore.ListOptions{Limit: 1}, func(data *blogstore.Item) error {
+		oldest = data
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Delete(context.Background(), oldest.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/feeds/rss", nil)
+	req.Header.Set("If-None-Match", etag)
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("after a delete = %v with ETag %v, want a new ETag", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+// newFeedTestServer serves two blogs of ana and one of bob, and a private
+// blog of bob that the feeds must skip
+func newFeedTestServer(t *testing.T) *server {
+	t.Helper()
+	s := &server{
+		store: blogstore.NewMemoryStore(),
+		feeds: feedConfig{Title: "Blogs", BaseURL: "https://blog.example.com/"},
+	}
+	blogs := []*blogstore.Item{
+		{AuthorId: "ana", Title: "first", Content: "one", Tags: []string{"go"}},
+		{AuthorId: "ana", Title: "second", Content: "two", Tags: []string{"grpc"}},
+		{AuthorId: "bob", Title: "third", Content: "three"},
+		{AuthorId: "bob", Title: "draft", Content: "four", Visibility: blogstore.VisibilityPrivate},
+	}
+	for _, blog := range blogs {
+		if _, err := s.store.Create(context.Background(), blog); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func countFeedItems(t *testing.T, contentType string, body []byte) int {
+	t.Helper()
+	switch contentType {
+	case "application/rss+xml; charset=utf-8":
+		var rss rssFeed
+		if err := xml.Unmarshal(body, &rss); err != nil {
+			t.Fatalf("invalid RSS: %v", err)
+		}
+		return len(rss.Channel.Items)
+	case "application/atom+xml; charset=utf-8":
+		var atom atomFeed
+		if err := xml.Unmarshal(body, &atom); err != nil {
+			t.Fatalf("invalid Atom: %v", err)
+		}
+		return len(atom.Entries)
+	case "application/feed+json; charset=utf-8":
+		var jf jsonFeed
+		if err := json.Unmarshal(body, &jf); err != nil {
+			t.Fatalf("invalid JSON Feed: %v", err)
+		}
+		return len(jf.Items)
+	}
+	t.Fatalf("unexpected content type %q", contentType)
+	return 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync/atomic"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+)
+
+// listBatchSize is the number of blogs ListBlog fetches from the store at
+// once. Send blocks while the HTTP/2 flow control window of the client is
+// full, so the cursor never gets more than a batch ahead of it.
+const listBatchSize = 100
+
+// ListBlog stream metrics, served on /debug/vars by the metrics server
+var (
+	// listStreams counts the streams started and how they ended
+	listStreams = expvar.NewMap("blog_list_streams")
+	// listBlogsSent counts the blogs sent by ListBlog
+	listBlogsSent = expvar.NewInt("blog_list_blogs_sent")
+)
+
+// errSendStalled is returned when the client does not read the stream
+var errSendStalled = errors.New("stream send stalled")
+
+// sendError wraps the errors of stream.Send, which end the stream
+type sendError struct {
+	err error
+}
+
+func (e *sendError) Error() string {
+	return "send failed: " + e.err.Error()
+}
+
+// stallWatch flags a stream whose sends made no progress for the stall
+// timeout, with a single timer per stream reset after each send
+type stallWatch struct {
+	timeout time.Duration
+	timer   *time.Timer
+	stalled int32
+}
+
+// newStallWatch returns a watch that never fires if timeout is 0
+func newStallWatch(timeout time.Duration) *stallWatch {
+	w := &stallWatch{timeout: timeout}
+	if timeout > 0 {
+		w.timer = time.AfterFunc(timeout, func() { atomic.StoreInt32(&w.stalled, 1) })
+	}
+	return w
+}
+
+// progress restarts the timer after a completed send
+func (w *stallWatch) progress() {
+	if w.timer != nil {
+		w.timer.Reset(w.timeout)
+		atomic.StoreInt32(&w.stalled, 0)
+	}
+}
+
+func (w *stallWatch) Stop() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// sendBlog sends a message from the handler goroutine, so the handler never
+// returns while a send is in progress. A send blocked on a client that does
+// not read ends with the stream, when the client cancels it or when the
+// keepalive closes the connection of a client that stopped answering
+// pings. The watch tells these stalls apart from the other send errors.
+func sendBlog(stream blogpb.BlogService_ListBlogServer, watch *stallWatch, res *blogpb.ListBlogResponse) error {
+	if err := stream.Send(res); err != nil {
+		if atomic.LoadInt32(&watch.stalled) != 0 {
+			return errSendStalled
+		}
+		return &sendError{err}
+	}
+	watch.progress()
+	listBlogsSent.Add(1)
+	return nil
+}
+
+// listOutcome names how a stream ended for the listStreams metric
+func listOutcome(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return "completed"
+	case err == errSendStalled:
+		// the stream of a stalled client ends with its context
+		return "aborted_stalled"
+	case ctx.Err() == context.Canceled:
+		return "aborted_canceled"
+	case ctx.Err() == context.DeadlineExceeded:
+		return "aborted_deadline"
+	}
+	if _, ok := err.(*sendError); ok {
+		return "aborted_send_error"
+	}
+	return "failed"
+}
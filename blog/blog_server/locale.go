@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"golang.org/x/text/language"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// acceptLanguageMetadata holds the locales preferred by the caller, in the
+// Accept-Language HTTP header format
+const acceptLanguageMetadata = "accept-language"
+
+// parseLocale returns the canonical form of a BCP-47 locale, e.g. pt-BR
+func parseLocale(locale string) (string, error) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "Invalid locale %q", locale)
+	}
+	return tag.String(), nil
+}
+
+// localePreferences returns the locales the caller prefers: the requested
+// one, or else the ones of the accept-language metadata
+func localePreferences(ctx context.Context, requested string) ([]language.Tag, error) {
+	if requested != "" {
+		tag, err := language.Parse(requested)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "Invalid locale %q", requested)
+		}
+		return []language.Tag{tag}, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var prefs []language.Tag
+	for _, header := range md.Get(acceptLanguageMetadata) {
+		// unknown locales are skipped, the valid ones are still returned
+		tags, _, _ := language.ParseAcceptLanguage(header)
+		prefs = append(prefs, tags...)
+	}
+	return prefs, nil
+}
+
+// blogLocales lists the default locale of the blog, then its translations
+func blogLocales(data *blogstore.Item, defaultLocale string) []string {
+	base := data.Locale
+	if base == "" {
+		// stored before blogs had a locale
+		base = defaultLocale
+	}
+	locales := []string{base}
+	for locale := range data.Translations {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales[1:])
+	return locales
+}
+
+// localize sets the locale fields of blog and picks its title and content
+// from the variant matching prefs best, falling back to the default one
+func localize(blog *blogpb.Blog, data *blogstore.Item, defaultLocale string, prefs []language.Tag) {
+	locales := blogLocales(data, defaultLocale)
+	blog.DefaultLocale = locales[0]
+	blog.Locale = locales[0]
+	blog.AvailableLocales = locales
+	if len(locales) == 1 || len(prefs) == 0 {
+		return
+	}
+
+	tags := make([]language.Tag, len(locales))
+	for i, locale := range locales {
+		tags[i] = language.Make(locale)
+	}
+	_, i, confidence := language.NewMatcher(tags).Match(prefs...)
+	if confidence == language.No || i == 0 {
+		return
+	}
+
+	translation := data.Translations[locales[i]]
+	blog.Locale = locales[i]
+	blog.Title = translation.Title
+	blog.Content = translation.Content
+	blog.WordCount = translation.WordCount
+	blog.ReadingTimeMinutes = translation.ReadingTimeMinutes
+	blog.Excerpt = translation.Excerpt
+}
+
+// promoteTranslation returns the translations of the blog once locale, one
+// of them, becomes its default locale: the updated content replaces that
+// translation, and the previous default title and content are kept as the
+// translation of base. promoted is false when locale has no translation.
+func promoteTranslation(data *blogstore.Item, base, locale string) (translations map[string]blogstore.Translation, promoted bool) {
+	if _, ok := data.Translations[locale]; !ok || locale == base {
+		return data.Translations, false
+	}
+
+	translations = make(map[string]blogstore.Translation, len(data.Translations))
+	for l, translation := range data.Translations {
+		if l != locale {
+			translations[l] = translation
+		}
+	}
+	translations[base] = blogstore.Translation{Title: data.Title, Content: data.Content, TextStats: data.TextStats}
+	return translations, true
+}
+
+func translationsToPb(translations map[string]blogstore.Translation) map[string]*blogpb.Translation {
+	if len(translations) == 0 {
+		return nil
+	}
+	result := make(map[string]*blogpb.Translation, len(translations))
+	for locale, translation := range translations {
+		result[locale] = &blogpb.Translation{Title: translation.Title, Content: translation.Content}
+	}
+	return result
+}
+
+func translationsFromPb(translations map[string]*blogpb.Translation) (map[string]blogstore.Translation, error) {
+	if len(translations) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]blogstore.Translation, len(translations))
+	for locale, translation := range translations {
+		canonical, err := parseLocale(locale)
+		if err != nil {
+			return nil, err
+		}
+		result[canonical] = blogstore.Translation{Title: translation.GetTitle(), Content: translation.GetContent()}
+	}
+	return result, nil
+}
+
+func (s *server) PutTranslation(ctx context.Context, req *blogpb.PutTranslationRequest) (*blogpb.PutTranslationResponse, error) {
+	fmt.Println("Put translation request")
+
+	locale, err := parseLocale(req.GetLocale())
+	if err != nil {
+		return nil, err
+	}
+	translation := blogstore.Translation{
+		Title:   req.GetTranslation().GetTitle(),
+		Content: req.GetTranslation().GetContent(),
+	}
+	translation.TextStats = textStats(translation.Content, s.textStats)
+
+	data, err := s.changeTranslations(ctx, req.GetBlogId(), func(ctx context.Context, tx blogstore.Tx, data *blogstore.Item) error {
+		if locale == blogLocales(data, s.defaultLocale)[0] {
+			return status.Errorf(codes.InvalidArgument, "%v is the default locale of the blog, use UpdateBlog", locale)
+		}
+
+		// the translation is held for review like the blog would be
+		moderated := &blogstore.Item{AuthorId: data.AuthorId, Title: translation.Title, Content: translation.Content, Tags: data.Tags}
+		if err := s.moderate(ctx, moderated, data); err != nil {
+			return err
+		}
+		if moderated.ModerationStatus != "" && data.ModerationStatus == "" {
+			if _, err := tx.SetModeration(ctx, data.ID.Hex(), moderated.ModerationStatus, moderated.ModerationReason); err != nil {
+				return err
+			}
+		}
+
+		if data.Translations == nil {
+			data.Translations = map[string]blogstore.Translation{}
+		}
+		data.Translations[locale] = translation
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blog := dataToBlogPb(data)
+	localize(blog, data, s.defaultLocale, nil)
+	return &blogpb.PutTranslationResponse{Blog: blog}, nil
+}
+
+func (s *server) DeleteTranslation(ctx context.Context, req *blogpb.DeleteTranslationRequest) (*blogpb.DeleteTranslationResponse, error) {
+	fmt.Println("Delete translation request")
+
+	locale, err := parseLocale(req.GetLocale())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.changeTranslations(ctx, req.GetBlogId(), func(ctx context.Context, tx blogstore.Tx, data *blogstore.Item) error {
+		if _, ok := data.Translations[locale]; !ok {
+			return status.Errorf(codes.NotFound, "Blog has no %v translation", locale)
+		}
+		delete(data.Translations, locale)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blog := dataToBlogPb(data)
+	localize(blog, data, s.defaultLocale, nil)
+	return &blogpb.DeleteTranslationResponse{Blog: blog}, nil
+}
+
+// changeTranslations reads a blog the caller can edit, changes its
+// translations and writes them in one transaction
+func (s *server) changeTranslations(ctx context.Context, id string, change func(ctx context.Context, tx blogstore.Tx, data *blogstore.Item) error) (*blogstore.Item, error) {
+	viewer := viewerFromContext(ctx)
+
+	var updated *blogstore.Item
+	err := s.store.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		data, err := tx.Read(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkEdit(viewer, data); err != nil {
+			return err
+		}
+		if err := change(ctx, tx, data); err != nil {
+			return err
+		}
+		updated, err = tx.SetTranslations(ctx, id, data.Translations)
+		return err
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, storeError(err)
+	}
+	indexBlog(s.related, updated)
+
+	return updated, nil
+}
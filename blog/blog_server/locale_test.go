@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"github.com/vmlellis/grpc-go-learning/blog/moderation"
+	"github.com/vmlellis/grpc-go-learning/blog/related"
+	"golang.org/x/text/language"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLocalize(t *testing.T) {
+	translations := map[string]blogstore.Translation{
+		"pt-BR": {Title: "Olá"},
+		"fr":    {Title: "Bonjour"},
+	}
+
+	tests := []struct {
+		name       string
+		locale     string
+		prefs      []string
+		wantLocale string
+		wantTitle  string
+	}{
+		{"no preference", "en", nil, "en", "Hello"},
+		{"default locale", "en", []string{"en-GB"}, "en", "Hello"},
+		{"translation", "en", []string{"pt-BR"}, "pt-BR", "Olá"},
+		{"same language", "en", []string{"pt"}, "pt-BR", "Olá"},
+		{"regional variant", "en", []string{"fr-CA"}, "fr", "Bonjour"},
+		{"second preference", "en", []string{"de", "fr"}, "fr", "Bonjour"},
+		{"unknown language", "en", []string{"de"}, "en", "Hello"},
+		// blogs stored before locales fall back to the server default
+		{"legacy blog", "", []string{"it"}, "it", "Hello"},
+	}
+	for _, tt := range tests {
+		data := &blogstore.Item{Title: "Hello", Locale: tt.locale, Translations: translations}
+		var prefs []language.Tag
+		for _, pref := range tt.prefs {
+			prefs = append(prefs, language.Make(pref))
+		}
+
+		blog := &blogpb.Blog{Title: data.Title}
+		localize(blog, data, "it", prefs)
+		if blog.Locale != tt.wantLocale || blog.Title != tt.wantTitle {
+			t.Errorf("%v: localize = %v %q, want %v %q", tt.name, blog.Locale, blog.Title, tt.wantLocale, tt.wantTitle)
+		}
+		wantAvailable := []string{blog.DefaultLocale, "fr", "pt-BR"}
+		if !reflect.DeepEqual(blog.AvailableLocales, wantAvailable) {
+			t.Errorf("%v: available locales = %v, want %v", tt.name, blog.AvailableLocales, wantAvailable)
+		}
+	}
+}
+
+func TestLocalePreferences(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		header    []string
+		want      []string
+		err       bool
+	}{
+		{"none", "", nil, nil, false},
+		{"requested", "pt-br", []string{"fr"}, []string{"pt-BR"}, false},
+		{"invalid request", "not a locale", nil, nil, true},
+		{"header", "", []string{"fr-CA, fr;q=0.9, en;q=0.5"}, []string{"fr-CA", "fr", "en"}, false},
+		{"invalid header", "", []string{"!!"}, nil, false},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.header != nil {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(acceptLanguageMetadata, tt.header[0]))
+		}
+		prefs, err := localePreferences(ctx, tt.requested)
+		if (err != nil) != tt.err {
+			t.Errorf("%v: localePreferences error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		var got []string
+		for _, pref := range prefs {
+			got = append(got, pref.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: localePreferences = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestUpdateDefaultLocale changes the default locale of a blog to the one
+// of its translation, which takes the place of the default variant
+func TestUpdateDefaultLocale(t *testing.T) {
+	s := &server{
+		store:         blogstore.NewMemoryStore(),
+		moderator:     moderation.NewPipeline(),
+		related:       related.NewIndex(),
+		defaultLocale: "en",
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(userIdMetadata, "ana"))
+
+	created, err := s.CreateBlog(ctx, &blogpb.CreateBlogRequest{Blog: &blogpb.Blog{AuthorId: "ana", Title: "Hello", Content: "English"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.GetBlog().GetId()
+	_, err = s.PutTranslation(ctx, &blogpb.PutTranslationRequest{
+		BlogId:      id,
+		Locale:      "pt-BR",
+		Translation: &blogpb.Translation{Title: "Olá", Content: "Português"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{
+		Id: id, Title: "Olá!", Content: "Português revisado", DefaultLocale: "pt-BR",
+	}})
+	if err != nil {
+		t.Fatalf("UpdateBlog: %v", err)
+	}
+	blog := updated.GetBlog()
+	if blog.DefaultLocale != "pt-BR" || blog.Title != "Olá!" || !reflect.DeepEqual(blog.AvailableLocales, []string{"pt-BR", "en"}) {
+		t.Errorf("UpdateBlog = %v %q %v, want the pt-BR default and an en translation", blog.DefaultLocale, blog.Title, blog.AvailableLocales)
+	}
+
+	data, err := s.store.Read(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]blogstore.Translation{
+		"en": {Title: "Hello", Content: "English", TextStats: textStats("English", s.textStats)},
+	}
+	if data.Locale != "pt-BR" || data.Content != "Português revisado" || !reflect.DeepEqual(data.Translations, want) {
+		t.Errorf("stored blog = %v %q %+v, want pt-BR with the translations %+v", data.Locale, data.Content, data.Translations, want)
+	}
+
+	// other locale changes do not touch the translations
+	updated, err = s.UpdateBlog(ctx, &blogpb.UpdateBlogRequest{Blog: &blogpb.Blog{
+		Id: id, Title: "Olá", Content: "Português", DefaultLocale: "pt-PT",
+	}})
+	if err != nil {
+		t.Fatalf("UpdateBlog: %v", err)
+	}
+	if got := updated.GetBlog().GetAvailableLocales(); !reflect.DeepEqual(got, []string{"pt-PT", "en"}) {
+		t.Errorf("available locales = %v, want [pt-PT en]", got)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+)
+
+// runMigrate implements the "migrate" subcommand:
+//
+//	blog_server migrate [flags] [up|down]
+//
+// "up" (the default) applies every pending migration, or the ones up to
+// -to. "down" rolls back to -to, which is then required. A target that
+// needs steps in the other direction is refused.
+func runMigrate(args []string) {
+	target := blogstore.LatestVersion
+	dryRun := false
+
+	cfg, rest, err := loadConfig("migrate", args, func(fs *flag.FlagSet) {
+		fs.IntVar(&target, "to", target, "schema version to migrate to (default: latest)")
+		fs.BoolVar(&dryRun, "dry-run", dryRun, "print the migrations that would run without running them")
+	})
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	direction := "up"
+	if len(rest) > 0 {
+		direction = rest[0]
+	}
+	switch {
+	case len(rest) > 1:
+		log.Fatalf("Unexpected arguments: %v", rest[1:])
+	case direction == "down" && target == blogstore.LatestVersion:
+		log.Fatalf("migrate down requires -to")
+	case direction != "up" && direction != "down":
+		log.Fatalf("Unknown migrate direction %q, expected up or down", direction)
+	}
+
+	ctx := context.Background()
+	store, err := openStore(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close(ctx)
+
+	steps, err := store.Migrate(ctx, blogstore.MigrateOptions{
+		Target:    target,
+		DryRun:    dryRun,
+		Direction: blogstore.Direction(direction),
+	})
+	printMigrationSteps(steps, dryRun)
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+}
+
+func printMigrationSteps(steps []blogstore.MigrationStep, dryRun bool) {
+	if len(steps) == 0 {
+		fmt.Println("Schema is up to date")
+		return
+	}
+
+	prefix := "Applied"
+	if dryRun {
+		prefix = "Would apply"
+	}
+	for _, step := range steps {
+		fmt.Printf("%v migration: %v\n", prefix, step)
+	}
+}
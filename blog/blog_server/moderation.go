@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"github.com/vmlellis/grpc-go-learning/blog/moderation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var moderationStatuses = map[string]blogpb.ModerationStatus{
+	"":                           blogpb.ModerationStatus_PUBLISHED,
+	blogstore.ModerationPending:  blogpb.ModerationStatus_PENDING_REVIEW,
+	blogstore.ModerationRejected: blogpb.ModerationStatus_REJECTED,
+}
+
+var moderationStates = map[blogpb.ModerationStatus]string{
+	blogpb.ModerationStatus_PUBLISHED:      "",
+	blogpb.ModerationStatus_PENDING_REVIEW: blogstore.ModerationPending,
+	blogpb.ModerationStatus_REJECTED:       blogstore.ModerationRejected,
+}
+
+// newModerationPipeline builds the built-in rules from the configuration.
+// Custom rules implement moderation.Rule and are added with Use.
+func newModerationPipeline(cfg *config) (*moderation.Pipeline, error) {
+	pipeline := moderation.NewPipeline()
+
+	words := splitList(cfg.BannedWords)
+	if cfg.BannedWordsFile != "" {
+		fileWords, err := readWordList(cfg.BannedWordsFile)
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, fileWords...)
+	}
+	if len(words) > 0 {
+		pipeline.Use(moderation.BannedWords(words))
+	}
+	if cfg.MaxContentLength > 0 {
+		pipeline.Use(moderation.MaxContentLength(cfg.MaxContentLength))
+	}
+	if cfg.MaxLinks > 0 {
+		pipeline.Use(moderation.MaxLinks(cfg.MaxLinks))
+	}
+
+	return pipeline, nil
+}
+
+// readWordList reads one word or phrase per line, ignoring blank lines and
+// # comments
+func readWordList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read banned words: %v", err)
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read banned words: %v", err)
+	}
+	return words, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// moderate runs the pipeline on the blog about to be stored and sets its
+// moderation state. previous is the stored blog when updating.
+func (s *server) moderate(ctx context.Context, data *blogstore.Item, previous *blogstore.Item) error {
+	post := &moderation.Post{
+		AuthorId: data.AuthorId,
+		Title:    data.Title,
+		Content:  data.Content,
+		Tags:     data.Tags,
+	}
+	if previous != nil {
+		post.ID = previous.ID.Hex()
+	}
+
+	verdict, err := s.moderator.Moderate(ctx, post)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Moderation failed: %v", err)
+	}
+
+	switch verdict.Decision {
+	case moderation.Reject:
+		return status.Errorf(codes.InvalidArgument, "Blog rejected by %v: %v", verdict.Rule, verdict.Reason)
+	case moderation.Review:
+		data.ModerationStatus = blogstore.ModerationPending
+		data.ModerationReason = fmt.Sprintf("%v: %v", verdict.Rule, verdict.Reason)
+	default:
+		data.ModerationStatus = ""
+		data.ModerationReason = ""
+		// a blog waiting for, or refused by, a moderator must not get
+		// published by editing it
+		if previous != nil && previous.ModerationStatus != "" {
+			data.ModerationStatus = blogstore.ModerationPending
+			data.ModerationReason = "edited while under moderation"
+		}
+	}
+
+	return nil
+}
+
+func (s *adminServer) ListModerationQueue(req *blogpb.ListModerationQueueRequest, stream blogpb.BlogAdminService_ListModerationQueueServer) error {
+	fmt.Println("List moderation queue request")
+
+	if req.GetLimit() < 0 {
+		return status.Errorf(codes.InvalidArgument, "Limit must not be negative")
+	}
+	opts := blogstore.ListOptions{
+		Moderation: blogstore.ModerationPending,
+		AfterID:    req.GetAfterId(),
+		Limit:      int(req.GetLimit()),
+	}
+
+	err := s.store.List(stream.Context(), opts, func(data *blogstore.Item) error {
+		return stream.Send(&blogpb.ListModerationQueueResponse{Blog: dataToBlogPb(data)})
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return storeError(err)
+	}
+
+	return nil
+}
+
+func (s *adminServer) ResolveModeration(ctx context.Context, req *blogpb.ResolveModerationRequest) (*blogpb.ResolveModerationResponse, error) {
+	fmt.Println("Resolve moderation request")
+
+	state, reason := "", ""
+	if !req.GetApprove() {
+		state, reason = blogstore.ModerationRejected, req.GetReason()
+	}
+
+	// an edit between the check and the write could requeue the blog
+	var data *blogstore.Item
+	err := s.store.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		previous, err := tx.Read(ctx, req.GetBlogId())
+		if err != nil {
+			return err
+		}
+		if previous.ModerationStatus != blogstore.ModerationPending {
+			return status.Errorf(codes.FailedPrecondition, "Blog is not pending review")
+		}
+		data, err = tx.SetModeration(ctx, req.GetBlogId(), state, reason)
+		return err
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, storeError(err)
+	}
+	indexBlog(s.related, data)
+
+	return &blogpb.ResolveModerationResponse{Blog: dataToBlogPb(data)}, nil
+}
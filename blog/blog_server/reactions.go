@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var reactionKinds = map[blogpb.Reaction]string{
+	blogpb.Reaction_LIKE: blogstore.ReactionLike,
+	blogpb.Reaction_CLAP: blogstore.ReactionClap,
+}
+
+func (s *server) ReactToBlog(ctx context.Context, req *blogpb.ReactToBlogRequest) (*blogpb.ReactToBlogResponse, error) {
+	fmt.Println("React to blog request")
+
+	reaction, ok := reactionKinds[req.GetReaction()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown reaction: %v", req.GetReaction())
+	}
+	// the reactions are deduplicated per caller, not per claimed user
+	viewer := viewerFromContext(ctx)
+	if viewer.UserId == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "Reacting requires a user ID")
+	}
+	if req.GetUserId() != "" && req.GetUserId() != viewer.UserId {
+		return nil, status.Errorf(codes.PermissionDenied, "Cannot react on behalf of another user")
+	}
+
+	data, err := s.store.Read(ctx, req.GetBlogId())
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if !canView(viewer, data) {
+		return nil, storeError(blogstore.ErrNotFound)
+	}
+
+	data, changed, err := s.store.React(ctx, req.GetBlogId(), viewer.UserId, reaction, req.GetRemove())
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	return &blogpb.ReactToBlogResponse{Blog: dataToBlogPb(data), Changed: changed}, nil
+}
+
+func (s *server) RecordView(ctx context.Context, req *blogpb.RecordViewRequest) (*blogpb.RecordViewResponse, error) {
+	// only the blogs the caller can read get counted, which also bounds
+	// the pending counts to existing blogs
+	data, err := s.store.Read(ctx, req.GetBlogId())
+	if err != nil {
+		return nil, storeError(err)
+	}
+	if !canView(viewerFromContext(ctx), data) {
+		return nil, storeError(blogstore.ErrNotFound)
+	}
+
+	if err := s.views.Add(data.ID.Hex()); err != nil {
+		return nil, storeError(err)
+	}
+	return &blogpb.RecordViewResponse{}, nil
+}
+
+// viewCounter buffers view counts in memory and writes them to the store
+// in one batch per interval, so that popular blogs do not cause a write
+// per view.
+type viewCounter struct {
+	store    blogstore.Store
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newViewCounter(store blogstore.Store, interval time.Duration) *viewCounter {
+	return &viewCounter{
+		store:    store,
+		interval: interval,
+		pending:  map[string]int64{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Add counts a view. Only the ID format is checked, views of blogs deleted
+// in the meantime are dropped when flushing.
+func (vc *viewCounter) Add(id string) error {
+	if _, err := blogstore.ParseID(id); err != nil {
+		return err
+	}
+
+	vc.mu.Lock()
+	vc.pending[id]++
+	vc.mu.Unlock()
+	return nil
+}
+
+// Run flushes the counts periodically until Stop is called
+func (vc *viewCounter) Run() {
+	defer close(vc.done)
+
+	ticker := time.NewTicker(vc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			vc.flush()
+		case <-vc.stop:
+			vc.flush()
+			return
+		}
+	}
+}
+
+// Stop writes the remaining counts and waits for Run to return
+func (vc *viewCounter) Stop() {
+	close(vc.stop)
+	<-vc.done
+}
+
+func (vc *viewCounter) flush() {
+	vc.mu.Lock()
+	batch := vc.pending
+	vc.pending = map[string]int64{}
+	vc.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), vc.interval)
+	defer cancel()
+
+	if err := vc.store.AddViews(ctx, batch); err != nil {
+		log.Printf("Cannot flush %v view counters, retrying later: %v", len(batch), err)
+		vc.mu.Lock()
+		for id, n := range batch {
+			vc.pending[id] += n
+		}
+		vc.mu.Unlock()
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"github.com/vmlellis/grpc-go-learning/blog/related"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRelatedLimit = 10
+	maxRelatedLimit     = 50
+)
+
+func (s *server) ListRelatedBlogs(ctx context.Context, req *blogpb.ListRelatedBlogsRequest) (*blogpb.ListRelatedBlogsResponse, error) {
+	fmt.Println("List related blogs request")
+
+	limit := int(req.GetLimit())
+	switch {
+	case limit < 0:
+		return nil, status.Errorf(codes.InvalidArgument, "Limit must not be negative")
+	case limit == 0:
+		limit = defaultRelatedLimit
+	case limit > maxRelatedLimit:
+		limit = maxRelatedLimit
+	}
+
+	id := req.GetBlogId()
+	if _, err := blogstore.ParseID(id); err != nil {
+		return nil, storeError(err)
+	}
+
+	viewer := viewerFromContext(ctx)
+	scored, ok := s.related.Related(id, limit)
+	if !ok {
+		// not indexed: unknown, not public, not published or written by
+		// another server
+		data, err := s.store.Read(ctx, id)
+		if err != nil {
+			return nil, storeError(err)
+		}
+		if !canView(viewer, data) {
+			return nil, storeError(blogstore.ErrNotFound)
+		}
+		indexBlog(s.related, data)
+		scored, _ = s.related.Related(id, limit)
+	}
+
+	res := &blogpb.ListRelatedBlogsResponse{}
+	for _, sc := range scored {
+		data, err := s.store.Read(ctx, sc.ID)
+		if err == blogstore.ErrNotFound {
+			s.related.Remove(sc.ID)
+			continue
+		}
+		if err != nil {
+			return nil, storeError(err)
+		}
+		if !canView(viewer, data) {
+			// changed since it was indexed, through another server
+			indexBlog(s.related, data)
+			continue
+		}
+		res.Related = append(res.Related, &blogpb.RelatedBlog{Blog: dataToBlogPb(data), Score: sc.Score})
+	}
+
+	return res, nil
+}
+
+// indexBlog keeps the index in sync with a stored blog, only published
+// public blogs are recommended
+func indexBlog(index *related.Index, data *blogstore.Item) {
+	if data.ModerationStatus != "" || data.Visibility != "" {
+		index.Remove(data.ID.Hex())
+		return
+	}
+	index.Upsert(related.Doc{
+		ID:       data.ID.Hex(),
+		AuthorId: data.AuthorId,
+		Title:    data.Title,
+		Content:  data.Content,
+		Tags:     data.Tags,
+	})
+}
+
+// buildRelatedIndex indexes every published blog of the store
+func buildRelatedIndex(ctx context.Context, store blogstore.Store) (*related.Index, error) {
+	index := related.NewIndex()
+	err := store.List(ctx, blogstore.ListOptions{}, func(data *blogstore.Item) error {
+		indexBlog(index, data)
+		return nil
+	})
+	return index, err
+}
+
+// rebuildRelatedIndex periodically rebuilds the index from the store, to
+// pick up the changes made through other server instances
+func rebuildRelatedIndex(store blogstore.Store, index *related.Index, interval time.Duration) {
+	for range time.Tick(interval) {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		rebuilt, err := buildRelatedIndex(ctx, store)
+		cancel()
+		if err != nil {
+			log.Printf("Cannot rebuild the related blogs index: %v", err)
+			continue
+		}
+		index.Replace(rebuilt)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// seriesServer implements SeriesService. Series are public but only their
+// author can change them, and the visibility of their blogs is enforced
+// when reading them.
+type seriesServer struct {
+	store blogstore.Store
+}
+
+func (s *seriesServer) CreateSeries(ctx context.Context, req *blogpb.CreateSeriesRequest) (*blogpb.CreateSeriesResponse, error) {
+	fmt.Println("Create series request")
+	series := req.GetSeries()
+
+	viewer := viewerFromContext(ctx)
+	var blogIDs []string
+	seen := map[string]bool{}
+	for _, id := range series.GetBlogIds() {
+		blog, err := readEditableBlog(ctx, s.store, viewer, id)
+		if err != nil {
+			return nil, seriesError(err)
+		}
+		id = blog.ID.Hex()
+		if seen[id] {
+			return nil, status.Errorf(codes.InvalidArgument, "Blog %v is listed twice", id)
+		}
+		seen[id] = true
+		blogIDs = append(blogIDs, id)
+	}
+
+	data, err := s.store.CreateSeries(ctx, &blogstore.Series{
+		AuthorId:    series.GetAuthorId(),
+		Title:       series.GetTitle(),
+		Description: series.GetDescription(),
+		BlogIDs:     blogIDs,
+	})
+	if err != nil {
+		return nil, seriesError(err)
+	}
+
+	return &blogpb.CreateSeriesResponse{Series: seriesToPb(data)}, nil
+}
+
+func (s *seriesServer) GetSeries(ctx context.Context, req *blogpb.GetSeriesRequest) (*blogpb.GetSeriesResponse, error) {
+	fmt.Println("Get series request")
+
+	data, err := s.store.ReadSeries(ctx, req.GetSeriesId())
+	if err != nil {
+		return nil, seriesError(err)
+	}
+
+	blogs, err := readableBlogs(ctx, s.store, viewerFromContext(ctx), data)
+	if err != nil {
+		return nil, storeError(err)
+	}
+
+	res := &blogpb.GetSeriesResponse{Series: seriesToPb(data)}
+	res.Series.BlogIds = nil
+	for _, blog := range blogs {
+		res.Series.BlogIds = append(res.Series.BlogIds, blog.ID.Hex())
+		res.Blogs = append(res.Blogs, dataToBlogPb(blog))
+	}
+
+	return res, nil
+}
+
+func (s *seriesServer) UpdateSeries(ctx context.Context, req *blogpb.UpdateSeriesRequest) (*blogpb.UpdateSeriesResponse, error) {
+	fmt.Println("Update series request")
+	series := req.GetSeries()
+
+	data, err := s.changeSeries(ctx, series.GetId(), func(ctx context.Context, tx blogstore.Tx, data *blogstore.Series) error {
+		data.Title = series.GetTitle()
+		data.Description = series.GetDescription()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &blogpb.UpdateSeriesResponse{Series: seriesToPb(data)}, nil
+}
+
+func (s *seriesServer) DeleteSeries(ctx context.Context, req *blogpb.DeleteSeriesRequest) (*blogpb.DeleteSeriesResponse, error) {
+	fmt.Println("Delete series request")
+
+	// the author of a series never changes, no need for a transaction
+	data, err := s.store.ReadSeries(ctx, req.GetSeriesId())
+	if err != nil {
+		return nil, seriesError(err)
+	}
+	if err := checkSeriesOwner(viewerFromContext(ctx), data); err != nil {
+		return nil, err
+	}
+	if err := s.store.DeleteSeries(ctx, req.GetSeriesId()); err != nil {
+		return nil, seriesError(err)
+	}
+
+	return &blogpb.DeleteSeriesResponse{SeriesId: req.GetSeriesId()}, nil
+}
+
+func (s *seriesServer) AddToSeries(ctx context.Context, req *blogpb.AddToSeriesRequest) (*blogpb.AddToSeriesResponse, error) {
+	fmt.Println("Add to series request")
+
+	viewer := viewerFromContext(ctx)
+	data, err := s.changeSeries(ctx, req.GetSeriesId(), func(ctx context.Context, tx blogstore.Tx, data *blogstore.Series) error {
+		position := int(req.GetPosition())
+		if position < 0 || position > len(data.BlogIDs)+1 {
+			return status.Errorf(codes.InvalidArgument, "Position must be between 1 and %v, or 0 to append", len(data.BlogIDs)+1)
+		}
+		blog, err := readEditableBlog(ctx, tx, viewer, req.GetBlogId())
+		if err != nil {
+			return err
+		}
+		blogID := blog.ID.Hex()
+		if data.Position(blogID) >= 0 {
+			return status.Errorf(codes.AlreadyExists, "Blog is already in the series")
+		}
+
+		if position == 0 {
+			data.BlogIDs = append(data.BlogIDs, blogID)
+			return nil
+		}
+		data.BlogIDs = append(data.BlogIDs, "")
+		copy(data.BlogIDs[position:], data.BlogIDs[position-1:])
+		data.BlogIDs[position-1] = blogID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &blogpb.AddToSeriesResponse{Series: seriesToPb(data)}, nil
+}
+
+func (s *seriesServer) RemoveFromSeries(ctx context.Context, req *blogpb.RemoveFromSeriesRequest) (*blogpb.RemoveFromSeriesResponse, error) {
+	fmt.Println("Remove from series request")
+
+	data, err := s.changeSeries(ctx, req.GetSeriesId(), func(ctx context.Context, tx blogstore.Tx, data *blogstore.Series) error {
+		i := data.Position(req.GetBlogId())
+		if i < 0 {
+			return status.Errorf(codes.NotFound, "Blog is not in the series")
+		}
+		data.BlogIDs = append(data.BlogIDs[:i], data.BlogIDs[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &blogpb.RemoveFromSeriesResponse{Series: seriesToPb(data)}, nil
+}
+
+func (s *seriesServer) ReorderSeries(ctx context.Context, req *blogpb.ReorderSeriesRequest) (*blogpb.ReorderSeriesResponse, error) {
+	fmt.Println("Reorder series request")
+
+	order := req.GetBlogIds()
+	data, err := s.changeSeries(ctx, req.GetSeriesId(), func(ctx context.Context, tx blogstore.Tx, data *blogstore.Series) error {
+		if !isPermutation(order, data.BlogIDs) {
+			return status.Errorf(codes.InvalidArgument, "The new order must list each blog of the series once")
+		}
+		data.BlogIDs = order
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &blogpb.ReorderSeriesResponse{Series: seriesToPb(data)}, nil
+}
+
+// changeSeries reads, changes and writes a series in one transaction, if
+// the caller is its author
+func (s *seriesServer) changeSeries(ctx context.Context, id string, change func(ctx context.Context, tx blogstore.Tx, data *blogstore.Series) error) (*blogstore.Series, error) {
+	viewer := viewerFromContext(ctx)
+	var updated *blogstore.Series
+	err := s.store.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		data, err := tx.ReadSeries(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkSeriesOwner(viewer, data); err != nil {
+			return err
+		}
+		if err := change(ctx, tx, data); err != nil {
+			return err
+		}
+		updated, err = tx.UpdateSeries(ctx, id, data)
+		return err
+	})
+	if err != nil {
+		return nil, seriesError(err)
+	}
+	return updated, nil
+}
+
+// checkSeriesOwner lets the author change the series. Series without an
+// author stay open to anyone, like the legacy blogs without one.
+func checkSeriesOwner(viewer *blogstore.Viewer, data *blogstore.Series) error {
+	if data.AuthorId == "" || data.AuthorId == viewer.UserId {
+		return nil
+	}
+	return status.Errorf(codes.PermissionDenied, "Only the author can change this series")
+}
+
+// readableBlogs returns the blogs of the series the viewer can read, in
+// reading order
+func readableBlogs(ctx context.Context, store blogReader, viewer *blogstore.Viewer, series *blogstore.Series) ([]*blogstore.Item, error) {
+	var blogs []*blogstore.Item
+	for _, id := range series.BlogIDs {
+		blog, err := store.Read(ctx, id)
+		if err == blogstore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if canView(viewer, blog) {
+			blogs = append(blogs, blog)
+		}
+	}
+	return blogs, nil
+}
+
+// blogReader is implemented by both blogstore.Store and blogstore.Tx
+type blogReader interface {
+	Read(ctx context.Context, id string) (*blogstore.Item, error)
+}
+
+// readEditableBlog reads a blog the caller is about to add to a series
+func readEditableBlog(ctx context.Context, store blogReader, viewer *blogstore.Viewer, id string) (*blogstore.Item, error) {
+	data, err := store.Read(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEdit(viewer, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func isPermutation(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := map[string]int{}
+	for _, id := range b {
+		counts[id]++
+	}
+	for _, id := range a {
+		if counts[id] == 0 {
+			return false
+		}
+		counts[id]--
+	}
+	return true
+}
+
+// seriesLink locates a blog in its series for ReadBlog, among the blogs
+// the viewer can read. It is nil when the blog is in no series.
+func seriesLink(ctx context.Context, store blogstore.Store, viewer *blogstore.Viewer, blogID string) (*blogpb.SeriesLink, error) {
+	series, err := store.FindSeries(ctx, blogID)
+	if err == blogstore.ErrSeriesNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	blogs, err := readableBlogs(ctx, store, viewer, series)
+	if err != nil {
+		return nil, err
+	}
+
+	i := -1
+	for j, blog := range blogs {
+		if blog.ID.Hex() == blogID {
+			i = j
+		}
+	}
+	if i < 0 {
+		return nil, nil
+	}
+	link := &blogpb.SeriesLink{
+		SeriesId:    series.ID.Hex(),
+		SeriesTitle: series.Title,
+		Position:    int32(i + 1),
+		Total:       int32(len(blogs)),
+	}
+	if i > 0 {
+		link.PreviousBlogId = blogs[i-1].ID.Hex()
+	}
+	if i+1 < len(blogs) {
+		link.NextBlogId = blogs[i+1].ID.Hex()
+	}
+	return link, nil
+}
+
+// seriesError converts store errors like storeError, keeping the statuses
+// returned from transactions
+func seriesError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch err {
+	case blogstore.ErrSeriesNotFound:
+		return status.Errorf(codes.NotFound, "Cannot find series with specified ID")
+	case blogstore.ErrInSeries:
+		return status.Errorf(codes.FailedPrecondition, "Blog already belongs to another series")
+	default:
+		return storeError(err)
+	}
+}
+
+func seriesToPb(data *blogstore.Series) *blogpb.Series {
+	return &blogpb.Series{
+		Id:          data.ID.Hex(),
+		AuthorId:    data.AuthorId,
+		Title:       data.Title,
+		Description: data.Description,
+		BlogIds:     data.BlogIDs,
+		CreatedAt:   timestampPb(data.CreatedAt),
+		UpdatedAt:   timestampPb(data.UpdatedAt),
+	}
+}
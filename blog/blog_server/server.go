@@ -5,225 +5,410 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"time"
 
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"github.com/vmlellis/grpc-go-learning/blog/moderation"
+	"github.com/vmlellis/grpc-go-learning/blog/related"
+	"github.com/vmlellis/grpc-go-learning/blog/textstats"
+	"golang.org/x/text/language"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 )
 
-var collection *mongo.Collection
-
-type blogItem struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty"`
-	AuthorId string             `bson:"author_id"`
-	Content  string             `bson:"content"`
-	Title    string             `bson:"title"`
+type server struct {
+	store     blogstore.Store
+	views     *viewCounter
+	feeds     feedConfig
+	moderator *moderation.Pipeline
+	related   *related.Index
+	// stallTimeout is how long ListBlog waits for a send before it counts
+	// the client as stalled
+	stallTimeout time.Duration
+	// defaultLocale is the locale of the blogs created without one
+	defaultLocale string
+	textStats     textstats.Options
 }
 
-type server struct{}
-
-func (*server) CreateBlog(ctx context.Context, req *blogpb.CreateBlogRequest) (*blogpb.CreateBlogResponse, error) {
+func (s *server) CreateBlog(ctx context.Context, req *blogpb.CreateBlogRequest) (*blogpb.CreateBlogResponse, error) {
 	fmt.Println("Create blog request")
 	blog := req.GetBlog()
 
-	data := blogItem{
-		AuthorId: blog.GetAuthorId(),
-		Title:    blog.GetTitle(),
-		Content:  blog.GetContent(),
+	tags, err := normalizeTags(blog.GetTags())
+	if err != nil {
+		return nil, err
 	}
 
-	res, err := collection.InsertOne(context.Background(), data)
+	visibility, err := visibilityState(blog.GetVisibility())
 	if err != nil {
-		return nil, status.Errorf(
-			codes.Internal,
-			fmt.Sprintf("Internal error: %v", err),
-		)
+		return nil, err
+	}
+	locale := s.defaultLocale
+	if blog.GetDefaultLocale() != "" {
+		if locale, err = parseLocale(blog.GetDefaultLocale()); err != nil {
+			return nil, err
+		}
+	}
+
+	data := &blogstore.Item{
+		AuthorId:   blog.GetAuthorId(),
+		Title:      blog.GetTitle(),
+		Content:    blog.GetContent(),
+		Tags:       tags,
+		Visibility: visibility,
+		Locale:     locale,
+		TextStats:  textStats(blog.GetContent(), s.textStats),
+	}
+	if err := s.moderate(ctx, data, nil); err != nil {
+		return nil, err
 	}
 
-	oid, ok := res.InsertedID.(primitive.ObjectID)
-	if !ok {
-		return nil, status.Errorf(
-			codes.Internal,
-			fmt.Sprintf("Cannot convert to OID"),
-		)
+	data, err = s.store.Create(ctx, data)
+	if err != nil {
+		return nil, storeError(err)
 	}
+	indexBlog(s.related, data)
 
 	result := &blogpb.CreateBlogResponse{
-		Blog: &blogpb.Blog{
-			Id:       oid.Hex(),
-			AuthorId: blog.GetAuthorId(),
-			Title:    blog.GetTitle(),
-			Content:  blog.GetContent(),
-		},
+		Blog: dataToBlogPb(data),
 	}
+	localize(result.Blog, data, s.defaultLocale, nil)
 
 	return result, nil
 }
 
-func (*server) ReadBlog(ctx context.Context, req *blogpb.ReadBlogRequest) (*blogpb.ReadBlogResponse, error) {
+func (s *server) ReadBlog(ctx context.Context, req *blogpb.ReadBlogRequest) (*blogpb.ReadBlogResponse, error) {
 	fmt.Println("Read blog request")
 
-	blogId := req.GetBlogId()
-	oid, err := primitive.ObjectIDFromHex(blogId)
+	prefs, err := localePreferences(ctx, req.GetLocale())
 	if err != nil {
-		return nil, status.Errorf(
-			codes.InvalidArgument,
-			fmt.Sprintf("Cannot parse ID"),
-		)
+		return nil, err
 	}
 
-	// create an empty struct
-	data := &blogItem{}
-	filter := primitive.M{"_id": oid}
-
-	res := collection.FindOne(context.Background(), filter)
-	if err := res.Decode(data); err != nil {
-		return nil, status.Errorf(
-			codes.NotFound,
-			fmt.Sprintf("Cannot find blog with specified ID: %v", err),
-		)
+	data, err := s.store.Read(ctx, req.GetBlogId())
+	if err != nil {
+		return nil, storeError(err)
+	}
+	viewer := viewerFromContext(ctx)
+	if !canView(viewer, data) {
+		return nil, storeError(blogstore.ErrNotFound)
 	}
 
 	response := &blogpb.ReadBlogResponse{
 		Blog: dataToBlogPb(data),
 	}
+	localize(response.Blog, data, s.defaultLocale, prefs)
+	response.Blog.Series, err = seriesLink(ctx, s.store, viewer, data.ID.Hex())
+	if err != nil {
+		return nil, storeError(err)
+	}
 
 	return response, nil
 }
 
-func (*server) UpdateBlog(ctx context.Context, req *blogpb.UpdateBlogRequest) (*blogpb.UpdateBlogResponse, error) {
+func (s *server) UpdateBlog(ctx context.Context, req *blogpb.UpdateBlogRequest) (*blogpb.UpdateBlogResponse, error) {
 	fmt.Println("Update blog request")
 
 	blog := req.GetBlog()
-	oid, err := primitive.ObjectIDFromHex(blog.GetId())
+	tags, err := normalizeTags(blog.GetTags())
 	if err != nil {
-		return nil, status.Errorf(
-			codes.InvalidArgument,
-			fmt.Sprintf("Cannot parse ID"),
-		)
+		return nil, err
 	}
 
-	data := &blogItem{}
-	filter := primitive.M{"_id": oid}
-
-	update := primitive.D{
-		{Key: "$set", Value: primitive.D{{Key: "title", Value: blog.GetTitle()}}},
-		{Key: "$set", Value: primitive.D{{Key: "content", Value: blog.GetContent()}}},
-		{Key: "$set", Value: primitive.D{{Key: "author_id", Value: blog.GetAuthorId()}}},
+	visibility, err := visibilityState(blog.GetVisibility())
+	if err != nil {
+		return nil, err
+	}
+	var locale string
+	if blog.GetDefaultLocale() != "" {
+		if locale, err = parseLocale(blog.GetDefaultLocale()); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := collection.FindOneAndUpdate(ctx, filter, update).Decode(&data); err != nil {
-		return nil, status.Errorf(codes.Internal, "Error while replacing data: %v", err)
+	data := &blogstore.Item{
+		Title:      blog.GetTitle(),
+		Content:    blog.GetContent(),
+		Tags:       tags,
+		Visibility: visibility,
+		Locale:     locale,
+		TextStats:  textStats(blog.GetContent(), s.textStats),
 	}
 
-	data.AuthorId = blog.GetAuthorId()
-	data.Content = blog.GetContent()
-	data.Title = blog.GetTitle()
+	// the access check and the moderation decision depend on the previous
+	// state, read and write it together
+	viewer := viewerFromContext(ctx)
+	var updated *blogstore.Item
+	err = s.store.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		previous, err := tx.Read(ctx, blog.GetId())
+		if err != nil {
+			return err
+		}
+		if err := checkEdit(viewer, previous); err != nil {
+			return err
+		}
+		// the author never changes, the visibility only when requested
+		data.AuthorId = previous.AuthorId
+		if !req.GetUpdateVisibility() {
+			data.Visibility = previous.Visibility
+		}
+		base := blogLocales(previous, s.defaultLocale)[0]
+		if data.Locale == "" {
+			data.Locale = base
+		}
+		translations, promoted := promoteTranslation(previous, base, data.Locale)
+		if err := s.moderate(ctx, data, previous); err != nil {
+			return err
+		}
+		updated, err = tx.Update(ctx, blog.GetId(), data)
+		if err != nil || !promoted {
+			return err
+		}
+		updated, err = tx.SetTranslations(ctx, blog.GetId(), translations)
+		return err
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, storeError(err)
+	}
+	indexBlog(s.related, updated)
 
 	response := &blogpb.UpdateBlogResponse{
-		Blog: dataToBlogPb(data),
+		Blog: dataToBlogPb(updated),
 	}
+	localize(response.Blog, updated, s.defaultLocale, nil)
 
 	return response, nil
 }
 
-func (*server) DeleteBlog(ctx context.Context, req *blogpb.DeleteBlogRequest) (*blogpb.DeleteBlogResponse, error) {
+func (s *server) DeleteBlog(ctx context.Context, req *blogpb.DeleteBlogRequest) (*blogpb.DeleteBlogResponse, error) {
 	fmt.Println("Delete blog request")
 
 	blogId := req.GetBlogId()
-	oid, err := primitive.ObjectIDFromHex(blogId)
-	if err != nil {
-		return nil, status.Errorf(
-			codes.InvalidArgument,
-			fmt.Sprintf("Cannot parse ID"),
-		)
-	}
+	viewer := viewerFromContext(ctx)
+	// the writes cascading from the deletion belong to this transaction
+	err := s.store.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		data, err := tx.Read(ctx, blogId)
+		if err != nil {
+			return err
+		}
+		if err := checkEdit(viewer, data); err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, blogId); err != nil {
+			return err
+		}
 
-	filter := primitive.M{"_id": oid}
-	res, err := collection.DeleteOne(context.Background(), filter)
+		series, err := tx.FindSeries(ctx, data.ID.Hex())
+		if err == blogstore.ErrSeriesNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		i := series.Position(data.ID.Hex())
+		series.BlogIDs = append(series.BlogIDs[:i], series.BlogIDs[i+1:]...)
+		_, err = tx.UpdateSeries(ctx, series.ID.Hex(), series)
+		return err
+	})
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Error while deleting object in MongoDDB: %v", err)
-	}
-
-	if res.DeletedCount == 0 {
-		return nil, status.Errorf(codes.NotFound, "Cannot find blog in MongoDDB: %v", err)
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		return nil, storeError(err)
 	}
+	s.related.Remove(blogId)
 
 	return &blogpb.DeleteBlogResponse{BlogId: blogId}, nil
 }
 
-func (*server) ListBlog(req *blogpb.ListBlogRequest, stream blogpb.BlogService_ListBlogServer) error {
+func (s *server) ListBlog(req *blogpb.ListBlogRequest, stream blogpb.BlogService_ListBlogServer) error {
 	fmt.Println("List blog request")
 
-	ctx := context.Background()
-	cur, err := collection.Find(ctx, primitive.D{})
+	if req.GetLimit() < 0 {
+		return status.Errorf(codes.InvalidArgument, "Limit must not be negative")
+	}
+	prefs, err := localePreferences(stream.Context(), req.GetLocale())
 	if err != nil {
-		return status.Errorf(codes.Internal, "Unknown internal error: %v", err)
+		return err
+	}
+	opts := blogstore.ListOptions{
+		AuthorId: req.GetAuthorId(),
+		Tag:      strings.ToLower(strings.TrimSpace(req.GetTag())),
+		AfterID:  req.GetAfterId(),
+		Limit:    int(req.GetLimit()),
+		Viewer:   viewerFromContext(stream.Context()),
+		// do not fetch far ahead of what the client reads
+		BatchSize: listBatchSize,
 	}
-	defer cur.Close(ctx)
 
-	for cur.Next(ctx) {
-		data := &blogItem{}
-		err := cur.Decode(data)
-		if err != nil {
-			return status.Errorf(codes.Internal, "Error while decoding data from Mongo DB: %v", err)
+	listStreams.Add("started", 1)
+	watch := newStallWatch(s.stallTimeout)
+	err = s.store.List(stream.Context(), opts, func(data *blogstore.Item) error {
+		blog := dataToBlogPb(data)
+		localize(blog, data, s.defaultLocale, prefs)
+		if req.GetOmitContent() {
+			blog.Content = ""
 		}
+		return sendBlog(stream, watch, &blogpb.ListBlogResponse{Blog: blog})
+	})
+	watch.Stop()
+	listStreams.Add(listOutcome(stream.Context(), err), 1)
+
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case *sendError:
+		return err.err
+	default:
+		if err == errSendStalled {
+			return status.Errorf(codes.DeadlineExceeded, "Client stopped reading the stream")
+		}
+		return storeError(err)
+	}
+}
 
-		stream.Send(&blogpb.ListBlogResponse{Blog: dataToBlogPb(data)})
+// storeError converts the errors returned by the store into gRPC statuses
+func storeError(err error) error {
+	switch err {
+	case blogstore.ErrInvalidID:
+		return status.Errorf(codes.InvalidArgument, "Cannot parse ID")
+	case blogstore.ErrNotFound:
+		return status.Errorf(codes.NotFound, "Cannot find blog with specified ID")
+	case blogstore.ErrInvalidReaction:
+		return status.Errorf(codes.InvalidArgument, "Unknown reaction")
+	case context.Canceled:
+		return status.Errorf(codes.Canceled, "Request canceled")
+	case context.DeadlineExceeded:
+		return status.Errorf(codes.DeadlineExceeded, "Request deadline exceeded")
+	default:
+		return status.Errorf(codes.Internal, "Internal error: %v", err)
 	}
+}
+
+// normalizeTags lower-cases and trims the tags, dropping empty and
+// duplicated ones
+func normalizeTags(tags []string) ([]string, error) {
+	var result []string
+	seen := map[string]bool{}
 
-	if err := cur.Err(); err != nil {
-		return status.Errorf(codes.Internal, "Unknown internal error: %v", err)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if strings.Contains(tag, ",") {
+			return nil, status.Errorf(codes.InvalidArgument, "Tags cannot contain commas: %q", tag)
+		}
+		seen[tag] = true
+		result = append(result, tag)
 	}
 
-	return nil
+	return result, nil
 }
 
-func dataToBlogPb(data *blogItem) *blogpb.Blog {
+func dataToBlogPb(data *blogstore.Item) *blogpb.Blog {
 	return &blogpb.Blog{
-		Id:       data.ID.Hex(),
-		AuthorId: data.AuthorId,
-		Content:  data.Content,
-		Title:    data.Title,
+		Id:        data.ID.Hex(),
+		AuthorId:  data.AuthorId,
+		Content:   data.Content,
+		Title:     data.Title,
+		CreatedAt: timestampPb(data.CreatedAt),
+		UpdatedAt: timestampPb(data.UpdatedAt),
+		Likes:     data.Reactions[blogstore.ReactionLike],
+		Claps:     data.Reactions[blogstore.ReactionClap],
+		Views:     data.Views,
+		Tags:      data.Tags,
+
+		ModerationStatus: moderationStatuses[data.ModerationStatus],
+		ModerationReason: data.ModerationReason,
+		Visibility:       visibilities[data.Visibility],
+		Acl:              aclToPb(data.ACL),
+
+		WordCount:          data.WordCount,
+		ReadingTimeMinutes: data.ReadingTimeMinutes,
+		Excerpt:            data.Excerpt,
 	}
 }
 
+// timestampPb leaves unset times (e.g. documents not migrated yet) empty
+func timestampPb(t time.Time) *timestamp.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	ts, err := ptypes.TimestampProto(t)
+	if err != nil {
+		return nil
+	}
+	return ts
+}
+
 func main() {
 	// If we crash the code, we get the file name and line number
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	fmt.Println("Connecting to MongoDB")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
 
-	// Connect to MongoDB
-	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	cfg, _, err := loadConfig(os.Args[0], os.Args[1:], nil)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("Invalid configuration: %v", err)
 	}
-	ctx := context.TODO()
-	err = client.Connect(ctx)
+
+	ctx := context.Background()
+	store, err := openStore(ctx, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	collection = client.Database("mydb").Collection("blog")
+	if cfg.AutoMigrate {
+		steps, err := store.Migrate(ctx, blogstore.MigrateOptions{Target: blogstore.LatestVersion})
+		printMigrationSteps(steps, false)
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	}
 
 	fmt.Println("Blog Service Started")
 
-	lis, err := net.Listen("tcp", "0.0.0.0:50051")
+	lis, err := net.Listen("tcp", cfg.ListenAddress)
 	if err != nil {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
+	timeouts, err := newMethodTimeouts(cfg)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	tls := false
-	opts := []grpc.ServerOption{}
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(timeouts.unaryInterceptor),
+		grpc.StreamInterceptor(timeouts.streamInterceptor),
+	}
+	if cfg.ListStallTimeout > 0 {
+		// the connections of clients that stop answering pings are closed,
+		// which ends the sends blocked on them
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    cfg.ListStallTimeout,
+			Timeout: cfg.ListStallTimeout,
+		}))
+	}
 
 	if tls {
 		certFile := "ssl/server.crt"
@@ -238,7 +423,36 @@ func main() {
 	}
 
 	s := grpc.NewServer(opts...)
-	blogpb.RegisterBlogServiceServer(s, &server{})
+	views := newViewCounter(store, cfg.ViewFlushInterval)
+	go views.Run()
+
+	moderator, err := newModerationPipeline(cfg)
+	if err != nil {
+		log.Fatalf("Invalid moderation rules: %v", err)
+	}
+
+	relatedIndex, err := buildRelatedIndex(ctx, store)
+	if err != nil {
+		log.Fatalf("Cannot build the related blogs index: %v", err)
+	}
+	fmt.Printf("Indexed %v blogs for recommendations\n", relatedIndex.Len())
+	if cfg.RelatedRebuildInterval > 0 {
+		go rebuildRelatedIndex(store, relatedIndex, cfg.RelatedRebuildInterval)
+	}
+
+	blogServer := &server{
+		store:     store,
+		views:     views,
+		feeds:     feedConfig{Title: cfg.FeedTitle, BaseURL: cfg.FeedBaseURL},
+		moderator: moderator,
+		related:   relatedIndex,
+
+		stallTimeout:  cfg.ListStallTimeout,
+		defaultLocale: language.Make(cfg.DefaultLocale).String(),
+		textStats:     textstats.Options{ExcerptLength: cfg.ExcerptLength, WordsPerMinute: cfg.WordsPerMinute},
+	}
+	blogpb.RegisterBlogServiceServer(s, blogServer)
+	blogpb.RegisterSeriesServiceServer(s, &seriesServer{store: store})
 
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
@@ -250,6 +464,48 @@ func main() {
 		}
 	}()
 
+	// The admin service has no authentication, it is only served on its
+	// own listener, private by default
+	var admin *grpc.Server
+	if cfg.AdminListen != "" {
+		adminLis, err := net.Listen("tcp", cfg.AdminListen)
+		if err != nil {
+			log.Fatalf("Failed to listen for the admin service: %v", err)
+		}
+		admin = grpc.NewServer(opts...)
+		blogpb.RegisterBlogAdminServiceServer(admin, &adminServer{store: store, related: relatedIndex, textStats: blogServer.textStats})
+		reflection.Register(admin)
+
+		go func() {
+			fmt.Printf("Serving the admin service on %v\n", cfg.AdminListen)
+			if err := admin.Serve(adminLis); err != nil {
+				log.Fatalf("Failed to serve the admin service: %v", err)
+			}
+		}()
+	}
+
+	var feedServer *http.Server
+	if cfg.FeedListen != "" {
+		feedServer = newFeedServer(cfg.FeedListen, blogServer)
+		go func() {
+			fmt.Printf("Serving feeds on http://%v/feeds/\n", cfg.FeedListen)
+			if err := feedServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to serve feeds: %v", err)
+			}
+		}()
+	}
+
+	var metricsServer *http.Server
+	if cfg.MetricsListen != "" {
+		metricsServer = newMetricsServer(cfg.MetricsListen)
+		go func() {
+			fmt.Printf("Serving metrics on http://%v/debug/vars\n", cfg.MetricsListen)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to serve metrics: %v", err)
+			}
+		}()
+	}
+
 	// Wait for Control C to exit
 	ch := make(chan os.Signal, 1)
 	signal.Notify(ch, os.Interrupt)
@@ -257,10 +513,21 @@ func main() {
 	// Block until a signal is received
 	<-ch
 	fmt.Println("Stopping the server...")
+	if feedServer != nil {
+		feedServer.Shutdown(ctx)
+	}
+	if metricsServer != nil {
+		metricsServer.Shutdown(ctx)
+	}
+	if admin != nil {
+		admin.GracefulStop()
+	}
 	s.GracefulStop()
 	fmt.Println("Closing the listener...")
 	lis.Close()
-	fmt.Println("Closing MongoDB Connection")
-	client.Disconnect(ctx)
+	fmt.Println("Flushing view counters")
+	views.Stop()
+	fmt.Println("Closing the store")
+	store.Close(ctx)
 	fmt.Println("End of Program")
 }
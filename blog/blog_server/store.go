@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// openStore creates the configured storage backend. For MongoDB it fails
+// fast when the server cannot be reached within the ping timeout, and warns
+// when it does not support transactions.
+func openStore(ctx context.Context, cfg *config) (blogstore.Store, error) {
+	switch cfg.Backend {
+	case "memory":
+		fmt.Println("Using the in-memory store")
+		return blogstore.NewMemoryStore(), nil
+	case "sqlite":
+		fmt.Printf("Opening SQLite database %v\n", cfg.SQLitePath)
+		store, err := blogstore.NewSQLiteStore(cfg.SQLitePath)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open SQLite database %v: %v", cfg.SQLitePath, err)
+		}
+		return store, nil
+	}
+
+	fmt.Printf("Connecting to MongoDB at %v\n", cfg.redactedURI())
+
+	client, err := mongo.NewClient(cfg.clientOptions())
+	if err != nil {
+		return nil, fmt.Errorf("invalid MongoDB client options: %v", err)
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	err = client.Connect(connectCtx)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to MongoDB at %v: %v", cfg.redactedURI(), err)
+	}
+
+	// Connect is lazy, so make sure the server is actually reachable
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.PingTimeout)
+	err = client.Ping(pingCtx, readpref.Primary())
+	cancel()
+	if err != nil {
+		client.Disconnect(ctx)
+		return nil, fmt.Errorf("MongoDB at %v did not answer within %v: %v", cfg.redactedURI(), cfg.PingTimeout, err)
+	}
+
+	transactions, err := supportsTransactions(ctx, client, cfg.PingTimeout)
+	if err != nil {
+		client.Disconnect(ctx)
+		return nil, fmt.Errorf("MongoDB at %v: %v", cfg.redactedURI(), err)
+	}
+
+	collection := client.Database(cfg.Database).Collection(cfg.Collection)
+	if !transactions {
+		fmt.Println("Warning: standalone MongoDB servers do not support transactions, the writes spanning several documents are not atomic." +
+			" Run mongod as a replica set (--replSet, then rs.initiate()) to make them atomic.")
+		return blogstore.NewStandaloneMongoStore(client, collection), nil
+	}
+	return blogstore.NewMongoStore(client, collection), nil
+}
+
+// supportsTransactions reports whether the server is a replica set member or
+// a mongos, standalone servers do not support transactions
+func supportsTransactions(ctx context.Context, client *mongo.Client, timeout time.Duration) (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&hello); err != nil {
+		return false, fmt.Errorf("cannot read the server topology: %v", err)
+	}
+
+	// mongos answers "isdbgrid", replica set members their set name
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
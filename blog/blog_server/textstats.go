@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"github.com/vmlellis/grpc-go-learning/blog/textstats"
+)
+
+// textStats computes the statistics stored along a content
+func textStats(content string, opts textstats.Options) blogstore.TextStats {
+	stats := textstats.Analyze(content, opts)
+	return blogstore.TextStats{
+		WordCount:          int64(stats.Words),
+		ReadingTimeMinutes: int64(stats.ReadingTimeMinutes),
+		Excerpt:            stats.Excerpt,
+	}
+}
+
+// setTextStats computes the statistics of the blog and its translations
+func setTextStats(data *blogstore.Item, opts textstats.Options) {
+	data.TextStats = textStats(data.Content, opts)
+	for locale, translation := range data.Translations {
+		translation.TextStats = textStats(translation.Content, opts)
+		data.Translations[locale] = translation
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// The archive is a gzip compressed stream of JSON lines: a header, one
+// record per blog, one record per series and a trailer holding the number
+// of records, which lets restore detect truncated files before writing
+// anything.
+const (
+	archiveFormat  = "blogctl-backup"
+	archiveVersion = 1
+)
+
+type archiveHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type archiveRecord struct {
+	Kind   string          `json:"kind"`
+	Blog   json.RawMessage `json:"blog,omitempty"`
+	Series json.RawMessage `json:"series,omitempty"`
+	// in the trailer, archives without series leave SeriesCount out
+	Count       int64 `json:"count,omitempty"`
+	SeriesCount int64 `json:"series_count,omitempty"`
+}
+
+const (
+	kindBlog   = "blog"
+	kindSeries = "series"
+	kindEnd    = "end"
+)
+
+// archiveCounts is the number of records of each kind
+type archiveCounts struct {
+	Blogs  int64
+	Series int64
+}
+
+type archiveWriter struct {
+	gz    *gzip.Writer
+	enc   *json.Encoder
+	count archiveCounts
+}
+
+func newArchiveWriter(w io.Writer) (*archiveWriter, error) {
+	gz := gzip.NewWriter(w)
+	aw := &archiveWriter{gz: gz, enc: json.NewEncoder(gz)}
+
+	header := archiveHeader{Format: archiveFormat, Version: archiveVersion, CreatedAt: time.Now().UTC()}
+	if err := aw.enc.Encode(header); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+func (aw *archiveWriter) WriteBlog(blog *blogpb.Blog) error {
+	raw, err := protojson.Marshal(blog)
+	if err != nil {
+		return err
+	}
+	aw.count.Blogs++
+	return aw.enc.Encode(archiveRecord{Kind: kindBlog, Blog: raw})
+}
+
+// WriteSeries must be called after the blogs of the series are written
+func (aw *archiveWriter) WriteSeries(series *blogpb.Series) error {
+	raw, err := protojson.Marshal(series)
+	if err != nil {
+		return err
+	}
+	aw.count.Series++
+	return aw.enc.Encode(archiveRecord{Kind: kindSeries, Series: raw})
+}
+
+// Close writes the trailer and flushes the compressed stream
+func (aw *archiveWriter) Close() error {
+	trailer := archiveRecord{Kind: kindEnd, Count: aw.count.Blogs, SeriesCount: aw.count.Series}
+	if err := aw.enc.Encode(trailer); err != nil {
+		return err
+	}
+	return aw.gz.Close()
+}
+
+// archiveVisitor receives the records of an archive, in order
+type archiveVisitor struct {
+	blog   func(*blogpb.Blog) error
+	series func(*blogpb.Series) error
+}
+
+// readArchive calls the visitor for every record of the archive. It returns
+// an error if the archive is not complete, possibly after some calls.
+func readArchive(r io.Reader, visit archiveVisitor) (archiveCounts, error) {
+	count := archiveCounts{}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return count, fmt.Errorf("not a backup archive: %v", err)
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(gz))
+
+	header := archiveHeader{}
+	if err := dec.Decode(&header); err != nil || header.Format != archiveFormat {
+		return count, fmt.Errorf("not a backup archive")
+	}
+	if header.Version != archiveVersion {
+		return count, fmt.Errorf("unsupported archive version %v", header.Version)
+	}
+
+	for {
+		record := archiveRecord{}
+		if err := dec.Decode(&record); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return count, fmt.Errorf("archive is truncated or corrupted: %v", err)
+		}
+
+		switch record.Kind {
+		case kindBlog:
+			blog := &blogpb.Blog{}
+			if err := protojson.Unmarshal(record.Blog, blog); err != nil {
+				return count, fmt.Errorf("invalid blog record %v: %v", count.Blogs+1, err)
+			}
+			count.Blogs++
+			if err := visit.blog(blog); err != nil {
+				return count, err
+			}
+		case kindSeries:
+			series := &blogpb.Series{}
+			if err := protojson.Unmarshal(record.Series, series); err != nil {
+				return count, fmt.Errorf("invalid series record %v: %v", count.Series+1, err)
+			}
+			count.Series++
+			if err := visit.series(series); err != nil {
+				return count, err
+			}
+		case kindEnd:
+			if record.Count != count.Blogs {
+				return count, fmt.Errorf("archive holds %v blogs, the trailer expects %v", count.Blogs, record.Count)
+			}
+			if record.SeriesCount != count.Series {
+				return count, fmt.Errorf("archive holds %v series, the trailer expects %v", count.Series, record.SeriesCount)
+			}
+			return count, nil
+		default:
+			return count, fmt.Errorf("unknown record kind %q", record.Kind)
+		}
+	}
+}
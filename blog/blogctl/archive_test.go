@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	testBlogs = []*blogpb.Blog{
+		{Id: "5f0000000000000000000001", AuthorId: "ana", Title: "first", Content: "one", Tags: []string{"go"}},
+		{Id: "5f0000000000000000000002", AuthorId: "bob", Title: "second", Content: "two",
+			Reactors: map[string]*blogpb.Reactors{"like": {UserIds: []string{"ana"}}}},
+	}
+	testSeries = []*blogpb.Series{
+		{Id: "5f0000000000000000000003", AuthorId: "ana", Title: "both", BlogIds: []string{"5f0000000000000000000001", "5f0000000000000000000002"}},
+	}
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeTestArchive(t, buf, testBlogs, testSeries)
+
+	var blogs []*blogpb.Blog
+	var series []*blogpb.Series
+	count, err := readArchive(bytes.NewReader(buf.Bytes()), archiveVisitor{
+		blog:   func(blog *blogpb.Blog) error { blogs = append(blogs, blog); return nil },
+		series: func(s *blogpb.Series) error { series = append(series, s); return nil },
+	})
+	if err != nil {
+		t.Fatalf("readArchive: %v", err)
+	}
+	if count != (archiveCounts{Blogs: 2, Series: 1}) {
+		t.Errorf("readArchive counted %+v, want 2 blogs and 1 series", count)
+	}
+	if len(blogs) != len(testBlogs) || len(series) != len(testSeries) {
+		t.Fatalf("readArchive read %v blogs and %v series, want %v and %v", len(blogs), len(series), len(testBlogs), len(testSeries))
+	}
+	for i := range blogs {
+		if !proto.Equal(blogs[i], testBlogs[i]) {
+			t.Errorf("blog %v = %v, want %v", i, blogs[i], testBlogs[i])
+		}
+	}
+	if !proto.Equal(series[0], testSeries[0]) {
+		t.Errorf("series = %v, want %v", series[0], testSeries[0])
+	}
+}
+
+func TestArchiveErrors(t *testing.T) {
+	complete := &bytes.Buffer{}
+	writeTestArchive(t, complete, testBlogs, testSeries)
+
+	tests := []struct {
+		name    string
+		archive func() []byte
+		want    string
+	}{
+		{"not gzip", func() []byte { return []byte("blogs") }, "not a backup archive"},
+		{"other format", func() []byte {
+			return gzipLines(t, archiveHeader{Format: "tar", Version: archiveVersion})
+		}, "not a backup archive"},
+		{"newer version", func() []byte {
+			return gzipLines(t, archiveHeader{Format: archiveFormat, Version: archiveVersion + 1})
+		}, "unsupported archive version 2"},
+		{"missing trailer", func() []byte {
+			buf := &bytes.Buffer{}
+			aw, err := newArchiveWriter(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			aw.WriteBlog(testBlogs[0])
+			// flush without the trailer
+			aw.gz.Close()
+			return buf.Bytes()
+		}, "archive is truncated or corrupted"},
+		{"cut short", func() []byte { return complete.Bytes()[:complete.Len()/2] }, "archive is truncated or corrupted"},
+		{"trailer expects more blogs", func() []byte {
+			return gzipLines(t,
+				archiveHeader{Format: archiveFormat, Version: archiveVersion},
+				archiveRecord{Kind: kindBlog, Blog: json.RawMessage(`{"id":"5f0000000000000000000001"}`)},
+				archiveRecord{Kind: kindEnd, Count: 2})
+		}, "archive holds 1 blogs, the trailer expects 2"},
+		{"trailer expects series", func() []byte {
+			return gzipLines(t,
+				archiveHeader{Format: archiveFormat, Version: archiveVersion},
+				archiveRecord{Kind: kindEnd, SeriesCount: 1})
+		}, "archive holds 0 series, the trailer expects 1"},
+		{"unknown record", func() []byte {
+			return gzipLines(t,
+				archiveHeader{Format: archiveFormat, Version: archiveVersion},
+				archiveRecord{Kind: "comment"})
+		}, `unknown record kind "comment"`},
+	}
+	for _, tt := range tests {
+		_, err := readArchive(bytes.NewReader(tt.archive()), archiveVisitor{
+			blog:   func(*blogpb.Blog) error { return nil },
+			series: func(*blogpb.Series) error { return nil },
+		})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: readArchive error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// TestBackupAndRestore backs up through a fake admin service, then
+// restores the archive with -dry-run, which must not write anything
+func TestBackupAndRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blogctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blogs.backup")
+
+	admin := &fakeAdmin{}
+	for _, blog := range testBlogs {
+		admin.export = append(admin.export, &blogpb.ExportBlogsResponse{Blog: blog})
+	}
+	for _, series := range testSeries {
+		admin.export = append(admin.export, &blogpb.ExportBlogsResponse{Series: series})
+	}
+	if err := runBackup(admin, []string{"-o", path}); err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+
+	if err := runRestore(admin, []string{"-i", path, "-dry-run", "-on-conflict", "skip"}); err != nil {
+		t.Fatalf("restore -dry-run: %v", err)
+	}
+	if len(admin.imports) != 1 || !admin.imports[0].options.GetDryRun() {
+		t.Fatalf("restore -dry-run made %v imports, want a single dry run", len(admin.imports))
+	}
+	got := admin.imports[0]
+	if len(got.blogs) != len(testBlogs) || len(got.series) != len(testSeries) {
+		t.Errorf("restore sent %v blogs and %v series, want %v and %v", len(got.blogs), len(got.series), len(testBlogs), len(testSeries))
+	}
+	for i := range got.blogs {
+		if !proto.Equal(got.blogs[i], testBlogs[i]) {
+			t.Errorf("restored blog %v = %v, want %v", i, got.blogs[i], testBlogs[i])
+		}
+	}
+
+	// the fail policy stops on the conflicts found by its dry run
+	admin.imports = nil
+	admin.conflicts = []string{testBlogs[0].Id}
+	if err := runRestore(admin, []string{"-i", path}); err == nil {
+		t.Errorf("restore with conflicts succeeded")
+	}
+	if len(admin.imports) != 1 || !admin.imports[0].options.GetDryRun() {
+		t.Errorf("restore with conflicts made %v imports, want a single dry run", len(admin.imports))
+	}
+
+	admin.imports = nil
+	admin.conflicts = nil
+	if err := runRestore(admin, []string{"-i", path}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if len(admin.imports) != 2 || admin.imports[1].options.GetDryRun() {
+		t.Errorf("restore made %v imports, want a dry run then the import", len(admin.imports))
+	}
+}
+
+func writeTestArchive(t *testing.T, w io.Writer, blogs []*blogpb.Blog, series []*blogpb.Series) {
+	t.Helper()
+	aw, err := newArchiveWriter(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, blog := range blogs {
+		if err := aw.WriteBlog(blog); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range series {
+		if err := aw.WriteSeries(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// gzipLines returns the gzip compressed JSON lines of the values
+func gzipLines(t *testing.T, values ...interface{}) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	enc := json.NewEncoder(gz)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// fakeAdmin serves export from memory and records the imports, the other
+// methods are not implemented
+type fakeAdmin struct {
+	blogpb.BlogAdminServiceClient
+	export    []*blogpb.ExportBlogsResponse
+	imports   []*fakeImport
+	conflicts []string
+}
+
+func (a *fakeAdmin) ExportBlogs(ctx context.Context, in *blogpb.ExportBlogsRequest, opts ...grpc.CallOption) (blogpb.BlogAdminService_ExportBlogsClient, error) {
+	return &fakeExport{responses: a.export}, nil
+}
+
+func (a *fakeAdmin) ImportBlogs(ctx context.Context, opts ...grpc.CallOption) (blogpb.BlogAdminService_ImportBlogsClient, error) {
+	imp := &fakeImport{admin: a}
+	a.imports = append(a.imports, imp)
+	return imp, nil
+}
+
+type fakeExport struct {
+	grpc.ClientStream
+	responses []*blogpb.ExportBlogsResponse
+}
+
+func (e *fakeExport) Recv() (*blogpb.ExportBlogsResponse, error) {
+	if len(e.responses) == 0 {
+		return nil, io.EOF
+	}
+	res := e.responses[0]
+	e.responses = e.responses[1:]
+	return res, nil
+}
+
+type fakeImport struct {
+	grpc.ClientStream
+	admin   *fakeAdmin
+	options *blogpb.ImportOptions
+	blogs   []*blogpb.Blog
+	series  []*blogpb.Series
+}
+
+func (i *fakeImport) Send(req *blogpb.ImportBlogsRequest) error {
+	switch item := req.GetItem().(type) {
+	case *blogpb.ImportBlogsRequest_Options:
+		i.options = item.Options
+	case *blogpb.ImportBlogsRequest_Blog:
+		i.blogs = append(i.blogs, item.Blog)
+	case *blogpb.ImportBlogsRequest_Series:
+		i.series = append(i.series, item.Series)
+	}
+	return nil
+}
+
+func (i *fakeImport) CloseAndRecv() (*blogpb.ImportBlogsResponse, error) {
+	res := &blogpb.ImportBlogsResponse{Conflicts: i.admin.conflicts}
+	if !i.options.GetDryRun() {
+		res.Created = int64(len(i.blogs))
+		res.SeriesCreated = int64(len(i.series))
+	}
+	return res, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+)
+
+func runBackup(c blogpb.BlogAdminServiceClient, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	output := fs.String("o", "", "archive file to write")
+	fs.Parse(args)
+
+	if *output == "" {
+		return fmt.Errorf("-o is required")
+	}
+
+	// Write to a temporary file first so a failed backup never replaces a
+	// good archive
+	tmp, err := ioutil.TempFile(filepath.Dir(*output), ".blogctl-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	aw, err := newArchiveWriter(tmp)
+	if err != nil {
+		return err
+	}
+
+	stream, err := c.ExportBlogs(context.Background(), &blogpb.ExportBlogsRequest{})
+	if err != nil {
+		return fmt.Errorf("error while calling ExportBlogs RPC: %v", err)
+	}
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error while reading stream: %v", err)
+		}
+		if series := res.GetSeries(); series != nil {
+			err = aw.WriteSeries(series)
+		} else {
+			err = aw.WriteBlog(res.GetBlog())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := aw.Close(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), *output); err != nil {
+		return err
+	}
+
+	fmt.Printf("Backed up %v blogs and %v series to %v\n", aw.count.Blogs, aw.count.Series, *output)
+	return nil
+}
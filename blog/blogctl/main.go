@@ -0,0 +1,71 @@
+// Command blogctl performs administrative tasks against a running blog
+// server, such as backing up and restoring its blogs and series. It talks
+// to the admin service, served on the -admin-listen address of the server.
+// A backup is only an exact copy when no blog or series is written while
+// it runs, the server does not read them from a single snapshot.
+//
+//	blogctl [-addr host:port] [-tls] backup -o blogs.backup
+//	blogctl [-addr host:port] [-tls] restore -i blogs.backup [-on-conflict fail|skip|overwrite]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func main() {
+	log.SetFlags(0)
+
+	addr := flag.String("addr", "localhost:50052", "blog server admin address")
+	tls := flag.Bool("tls", false, "connect using TLS")
+	caFile := flag.String("ca-file", "ssl/ca.crt", "Certificate Authority trust certificate, used with -tls")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	opts := grpc.WithInsecure()
+	if *tls {
+		creds, sslErr := credentials.NewClientTLSFromFile(*caFile, "")
+		if sslErr != nil {
+			log.Fatalf("Failed when loading CA trust certificate: %v", sslErr)
+		}
+		opts = grpc.WithTransportCredentials(creds)
+	}
+
+	cc, err := grpc.Dial(*addr, opts)
+	if err != nil {
+		log.Fatalf("could not connect: %v", err)
+	}
+	defer cc.Close()
+
+	c := blogpb.NewBlogAdminServiceClient(cc)
+
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	switch cmd {
+	case "backup":
+		err = runBackup(c, args)
+	case "restore":
+		err = runRestore(c, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%v failed: %v", cmd, err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: blogctl [flags] backup|restore [command flags]\n\n")
+	flag.PrintDefaults()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogpb"
+)
+
+var conflictPolicies = map[string]blogpb.ConflictPolicy{
+	"fail":      blogpb.ConflictPolicy_CONFLICT_FAIL,
+	"skip":      blogpb.ConflictPolicy_CONFLICT_SKIP,
+	"overwrite": blogpb.ConflictPolicy_CONFLICT_OVERWRITE,
+}
+
+func runRestore(c blogpb.BlogAdminServiceClient, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	input := fs.String("i", "", "archive file to read")
+	onConflict := fs.String("on-conflict", "fail", "what to do with blogs that already exist: fail, skip or overwrite")
+	dryRun := fs.Bool("dry-run", false, "only report what would be restored")
+	fs.Parse(args)
+
+	if *input == "" {
+		return fmt.Errorf("-i is required")
+	}
+	policy, ok := conflictPolicies[*onConflict]
+	if !ok {
+		return fmt.Errorf("unknown conflict policy %q", *onConflict)
+	}
+
+	// Validate the whole archive before touching the server
+	count, err := validateArchive(*input)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Archive %v holds %v blogs and %v series\n", *input, count.Blogs, count.Series)
+
+	// With the fail policy, look for conflicts first so that nothing is
+	// written when the restore would be aborted halfway
+	if policy == blogpb.ConflictPolicy_CONFLICT_FAIL || *dryRun {
+		opts := &blogpb.ImportOptions{OnConflict: policy, DryRun: true}
+		res, err := importArchive(c, *input, opts)
+		if err != nil {
+			return err
+		}
+		if len(res.GetConflicts()) > 0 && policy == blogpb.ConflictPolicy_CONFLICT_FAIL {
+			return fmt.Errorf("%v blogs or series already exist, e.g. %v; use -on-conflict skip or overwrite", len(res.GetConflicts()), res.GetConflicts()[0])
+		}
+		if *dryRun {
+			printImportResult("Would restore", res)
+			return nil
+		}
+	}
+
+	res, err := importArchive(c, *input, &blogpb.ImportOptions{OnConflict: policy})
+	if err != nil {
+		return err
+	}
+	printImportResult("Restored", res)
+	return nil
+}
+
+// validateArchive reads the whole archive and returns its number of records
+func validateArchive(path string) (archiveCounts, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return archiveCounts{}, err
+	}
+	defer f.Close()
+
+	return readArchive(f, archiveVisitor{
+		blog:   func(*blogpb.Blog) error { return nil },
+		series: func(*blogpb.Series) error { return nil },
+	})
+}
+
+// importArchive streams the archive to ImportBlogs
+func importArchive(c blogpb.BlogAdminServiceClient, path string, opts *blogpb.ImportOptions) (*blogpb.ImportBlogsResponse, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.ImportBlogs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error while calling ImportBlogs RPC: %v", err)
+	}
+	if err := stream.Send(&blogpb.ImportBlogsRequest{Item: &blogpb.ImportBlogsRequest_Options{Options: opts}}); err != nil {
+		_, err = stream.CloseAndRecv()
+		return nil, err
+	}
+
+	_, err = readArchive(f, archiveVisitor{
+		blog: func(blog *blogpb.Blog) error {
+			return stream.Send(&blogpb.ImportBlogsRequest{Item: &blogpb.ImportBlogsRequest_Blog{Blog: blog}})
+		},
+		series: func(series *blogpb.Series) error {
+			return stream.Send(&blogpb.ImportBlogsRequest{Item: &blogpb.ImportBlogsRequest_Series{Series: series}})
+		},
+	})
+	if err != nil {
+		// the server aborted the stream, its status explains why
+		if _, recvErr := stream.CloseAndRecv(); recvErr != nil {
+			return nil, recvErr
+		}
+		return nil, err
+	}
+
+	return stream.CloseAndRecv()
+}
+
+func printImportResult(prefix string, res *blogpb.ImportBlogsResponse) {
+	fmt.Printf("%v: %v created, %v overwritten, %v skipped\n", prefix, res.GetCreated(), res.GetOverwritten(), res.GetSkipped())
+	fmt.Printf("%v series: %v created, %v overwritten, %v skipped\n", prefix, res.GetSeriesCreated(), res.GetSeriesOverwritten(), res.GetSeriesSkipped())
+}
@@ -9,6 +9,7 @@ package blogpb
 import (
 	context "context"
 	proto "github.com/golang/protobuf/proto"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -29,6 +30,306 @@ const (
 // of the legacy proto package is being used.
 const _ = proto.ProtoPackageIsVersion4
 
+type ModerationStatus int32
+
+const (
+	ModerationStatus_PUBLISHED      ModerationStatus = 0
+	ModerationStatus_PENDING_REVIEW ModerationStatus = 1
+	ModerationStatus_REJECTED       ModerationStatus = 2
+)
+
+// Enum value maps for ModerationStatus.
+var (
+	ModerationStatus_name = map[int32]string{
+		0: "PUBLISHED",
+		1: "PENDING_REVIEW",
+		2: "REJECTED",
+	}
+	ModerationStatus_value = map[string]int32{
+		"PUBLISHED":      0,
+		"PENDING_REVIEW": 1,
+		"REJECTED":       2,
+	}
+)
+
+func (x ModerationStatus) Enum() *ModerationStatus {
+	p := new(ModerationStatus)
+	*p = x
+	return p
+}
+
+func (x ModerationStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ModerationStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_blog_blogpb_blog_proto_enumTypes[0].Descriptor()
+}
+
+func (ModerationStatus) Type() protoreflect.EnumType {
+	return &file_blog_blogpb_blog_proto_enumTypes[0]
+}
+
+func (x ModerationStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ModerationStatus.Descriptor instead.
+func (ModerationStatus) EnumDescriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{0}
+}
+
+type Visibility int32
+
+const (
+	Visibility_PUBLIC Visibility = 0
+	// readable with the ID, only listed to the author and the ACL principals
+	Visibility_UNLISTED Visibility = 1
+	// only readable by the author and the ACL principals
+	Visibility_PRIVATE Visibility = 2
+)
+
+// Enum value maps for Visibility.
+var (
+	Visibility_name = map[int32]string{
+		0: "PUBLIC",
+		1: "UNLISTED",
+		2: "PRIVATE",
+	}
+	Visibility_value = map[string]int32{
+		"PUBLIC":   0,
+		"UNLISTED": 1,
+		"PRIVATE":  2,
+	}
+)
+
+func (x Visibility) Enum() *Visibility {
+	p := new(Visibility)
+	*p = x
+	return p
+}
+
+func (x Visibility) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Visibility) Descriptor() protoreflect.EnumDescriptor {
+	return file_blog_blogpb_blog_proto_enumTypes[1].Descriptor()
+}
+
+func (Visibility) Type() protoreflect.EnumType {
+	return &file_blog_blogpb_blog_proto_enumTypes[1]
+}
+
+func (x Visibility) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Visibility.Descriptor instead.
+func (Visibility) EnumDescriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{1}
+}
+
+type Role int32
+
+const (
+	Role_ROLE_UNSPECIFIED Role = 0
+	Role_READER           Role = 1
+	// can also update the blog and manage its ACL
+	Role_EDITOR Role = 2
+)
+
+// Enum value maps for Role.
+var (
+	Role_name = map[int32]string{
+		0: "ROLE_UNSPECIFIED",
+		1: "READER",
+		2: "EDITOR",
+	}
+	Role_value = map[string]int32{
+		"ROLE_UNSPECIFIED": 0,
+		"READER":           1,
+		"EDITOR":           2,
+	}
+)
+
+func (x Role) Enum() *Role {
+	p := new(Role)
+	*p = x
+	return p
+}
+
+func (x Role) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Role) Descriptor() protoreflect.EnumDescriptor {
+	return file_blog_blogpb_blog_proto_enumTypes[2].Descriptor()
+}
+
+func (Role) Type() protoreflect.EnumType {
+	return &file_blog_blogpb_blog_proto_enumTypes[2]
+}
+
+func (x Role) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Role.Descriptor instead.
+func (Role) EnumDescriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{2}
+}
+
+type Reaction int32
+
+const (
+	Reaction_REACTION_UNSPECIFIED Reaction = 0
+	Reaction_LIKE                 Reaction = 1
+	Reaction_CLAP                 Reaction = 2
+)
+
+// Enum value maps for Reaction.
+var (
+	Reaction_name = map[int32]string{
+		0: "REACTION_UNSPECIFIED",
+		1: "LIKE",
+		2: "CLAP",
+	}
+	Reaction_value = map[string]int32{
+		"REACTION_UNSPECIFIED": 0,
+		"LIKE":                 1,
+		"CLAP":                 2,
+	}
+)
+
+func (x Reaction) Enum() *Reaction {
+	p := new(Reaction)
+	*p = x
+	return p
+}
+
+func (x Reaction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Reaction) Descriptor() protoreflect.EnumDescriptor {
+	return file_blog_blogpb_blog_proto_enumTypes[3].Descriptor()
+}
+
+func (Reaction) Type() protoreflect.EnumType {
+	return &file_blog_blogpb_blog_proto_enumTypes[3]
+}
+
+func (x Reaction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Reaction.Descriptor instead.
+func (Reaction) EnumDescriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{3}
+}
+
+type FeedFormat int32
+
+const (
+	FeedFormat_RSS       FeedFormat = 0
+	FeedFormat_ATOM      FeedFormat = 1
+	FeedFormat_JSON_FEED FeedFormat = 2
+)
+
+// Enum value maps for FeedFormat.
+var (
+	FeedFormat_name = map[int32]string{
+		0: "RSS",
+		1: "ATOM",
+		2: "JSON_FEED",
+	}
+	FeedFormat_value = map[string]int32{
+		"RSS":       0,
+		"ATOM":      1,
+		"JSON_FEED": 2,
+	}
+)
+
+func (x FeedFormat) Enum() *FeedFormat {
+	p := new(FeedFormat)
+	*p = x
+	return p
+}
+
+func (x FeedFormat) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (FeedFormat) Descriptor() protoreflect.EnumDescriptor {
+	return file_blog_blogpb_blog_proto_enumTypes[4].Descriptor()
+}
+
+func (FeedFormat) Type() protoreflect.EnumType {
+	return &file_blog_blogpb_blog_proto_enumTypes[4]
+}
+
+func (x FeedFormat) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use FeedFormat.Descriptor instead.
+func (FeedFormat) EnumDescriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{4}
+}
+
+type ConflictPolicy int32
+
+const (
+	// abort the import when a blog with the same ID already exists
+	ConflictPolicy_CONFLICT_FAIL ConflictPolicy = 0
+	// keep the existing blog
+	ConflictPolicy_CONFLICT_SKIP ConflictPolicy = 1
+	// replace the existing blog with the imported one
+	ConflictPolicy_CONFLICT_OVERWRITE ConflictPolicy = 2
+)
+
+// Enum value maps for ConflictPolicy.
+var (
+	ConflictPolicy_name = map[int32]string{
+		0: "CONFLICT_FAIL",
+		1: "CONFLICT_SKIP",
+		2: "CONFLICT_OVERWRITE",
+	}
+	ConflictPolicy_value = map[string]int32{
+		"CONFLICT_FAIL":      0,
+		"CONFLICT_SKIP":      1,
+		"CONFLICT_OVERWRITE": 2,
+	}
+)
+
+func (x ConflictPolicy) Enum() *ConflictPolicy {
+	p := new(ConflictPolicy)
+	*p = x
+	return p
+}
+
+func (x ConflictPolicy) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ConflictPolicy) Descriptor() protoreflect.EnumDescriptor {
+	return file_blog_blogpb_blog_proto_enumTypes[5].Descriptor()
+}
+
+func (ConflictPolicy) Type() protoreflect.EnumType {
+	return &file_blog_blogpb_blog_proto_enumTypes[5]
+}
+
+func (x ConflictPolicy) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ConflictPolicy.Descriptor instead.
+func (ConflictPolicy) EnumDescriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{5}
+}
+
 type Blog struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -38,6 +339,42 @@ type Blog struct {
 	AuthorId string `protobuf:"bytes,2,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
 	Title    string `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
 	Content  string `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	// set by the server, ignored on create and update
+	CreatedAt *timestamp.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt *timestamp.Timestamp `protobuf:"bytes,6,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	// counters maintained by the server, ignored on create and update
+	Likes int64 `protobuf:"varint,7,opt,name=likes,proto3" json:"likes,omitempty"`
+	Claps int64 `protobuf:"varint,8,opt,name=claps,proto3" json:"claps,omitempty"`
+	Views int64 `protobuf:"varint,9,opt,name=views,proto3" json:"views,omitempty"`
+	// lower-cased, without duplicates
+	Tags []string `protobuf:"bytes,10,rep,name=tags,proto3" json:"tags,omitempty"`
+	// set by the moderation pipeline on create and update
+	ModerationStatus ModerationStatus `protobuf:"varint,11,opt,name=moderation_status,json=moderationStatus,proto3,enum=blog.ModerationStatus" json:"moderation_status,omitempty"`
+	ModerationReason string           `protobuf:"bytes,12,opt,name=moderation_reason,json=moderationReason,proto3" json:"moderation_reason,omitempty"`
+	Visibility       Visibility       `protobuf:"varint,13,opt,name=visibility,proto3,enum=blog.Visibility" json:"visibility,omitempty"`
+	// managed with ShareBlog and UnshareBlog, ignored on create and update
+	Acl []*AclEntry `protobuf:"bytes,14,rep,name=acl,proto3" json:"acl,omitempty"`
+	// only set by ReadBlog, when the blog belongs to a series
+	Series *SeriesLink `protobuf:"bytes,15,opt,name=series,proto3" json:"series,omitempty"`
+	// BCP-47 locale title and content are written in, the server default
+	// when empty on create
+	DefaultLocale string `protobuf:"bytes,16,opt,name=default_locale,json=defaultLocale,proto3" json:"default_locale,omitempty"`
+	// locale of the returned title and content, picked by ReadBlog and
+	// ListBlog among the available ones
+	Locale string `protobuf:"bytes,17,opt,name=locale,proto3" json:"locale,omitempty"`
+	// the default locale first, then the translations
+	AvailableLocales []string `protobuf:"bytes,18,rep,name=available_locales,json=availableLocales,proto3" json:"available_locales,omitempty"`
+	// managed with PutTranslation and DeleteTranslation, only filled by
+	// ExportBlogs
+	Translations map[string]*Translation `protobuf:"bytes,19,rep,name=translations,proto3" json:"translations,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// computed by the server from the returned content, Markdown removed,
+	// ignored on create and update
+	WordCount          int64  `protobuf:"varint,20,opt,name=word_count,json=wordCount,proto3" json:"word_count,omitempty"`
+	ReadingTimeMinutes int64  `protobuf:"varint,21,opt,name=reading_time_minutes,json=readingTimeMinutes,proto3" json:"reading_time_minutes,omitempty"`
+	Excerpt            string `protobuf:"bytes,22,opt,name=excerpt,proto3" json:"excerpt,omitempty"`
+	// users who reacted per reaction kind, e.g. "like", only filled by
+	// ExportBlogs
+	Reactors map[string]*Reactors `protobuf:"bytes,23,rep,name=reactors,proto3" json:"reactors,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 }
 
 func (x *Blog) Reset() {
@@ -100,16 +437,150 @@ func (x *Blog) GetContent() string {
 	return ""
 }
 
-type CreateBlogRequest struct {
+func (x *Blog) GetCreatedAt() *timestamp.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Blog) GetUpdatedAt() *timestamp.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Blog) GetLikes() int64 {
+	if x != nil {
+		return x.Likes
+	}
+	return 0
+}
+
+func (x *Blog) GetClaps() int64 {
+	if x != nil {
+		return x.Claps
+	}
+	return 0
+}
+
+func (x *Blog) GetViews() int64 {
+	if x != nil {
+		return x.Views
+	}
+	return 0
+}
+
+func (x *Blog) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Blog) GetModerationStatus() ModerationStatus {
+	if x != nil {
+		return x.ModerationStatus
+	}
+	return ModerationStatus_PUBLISHED
+}
+
+func (x *Blog) GetModerationReason() string {
+	if x != nil {
+		return x.ModerationReason
+	}
+	return ""
+}
+
+func (x *Blog) GetVisibility() Visibility {
+	if x != nil {
+		return x.Visibility
+	}
+	return Visibility_PUBLIC
+}
+
+func (x *Blog) GetAcl() []*AclEntry {
+	if x != nil {
+		return x.Acl
+	}
+	return nil
+}
+
+func (x *Blog) GetSeries() *SeriesLink {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+func (x *Blog) GetDefaultLocale() string {
+	if x != nil {
+		return x.DefaultLocale
+	}
+	return ""
+}
+
+func (x *Blog) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *Blog) GetAvailableLocales() []string {
+	if x != nil {
+		return x.AvailableLocales
+	}
+	return nil
+}
+
+func (x *Blog) GetTranslations() map[string]*Translation {
+	if x != nil {
+		return x.Translations
+	}
+	return nil
+}
+
+func (x *Blog) GetWordCount() int64 {
+	if x != nil {
+		return x.WordCount
+	}
+	return 0
+}
+
+func (x *Blog) GetReadingTimeMinutes() int64 {
+	if x != nil {
+		return x.ReadingTimeMinutes
+	}
+	return 0
+}
+
+func (x *Blog) GetExcerpt() string {
+	if x != nil {
+		return x.Excerpt
+	}
+	return ""
+}
+
+func (x *Blog) GetReactors() map[string]*Reactors {
+	if x != nil {
+		return x.Reactors
+	}
+	return nil
+}
+
+type Translation struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+	Title   string `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Content string `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
 }
 
-func (x *CreateBlogRequest) Reset() {
-	*x = CreateBlogRequest{}
+func (x *Translation) Reset() {
+	*x = Translation{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_blog_blogpb_blog_proto_msgTypes[1]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -117,13 +588,13 @@ func (x *CreateBlogRequest) Reset() {
 	}
 }
 
-func (x *CreateBlogRequest) String() string {
+func (x *Translation) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreateBlogRequest) ProtoMessage() {}
+func (*Translation) ProtoMessage() {}
 
-func (x *CreateBlogRequest) ProtoReflect() protoreflect.Message {
+func (x *Translation) ProtoReflect() protoreflect.Message {
 	mi := &file_blog_blogpb_blog_proto_msgTypes[1]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -135,28 +606,35 @@ func (x *CreateBlogRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreateBlogRequest.ProtoReflect.Descriptor instead.
-func (*CreateBlogRequest) Descriptor() ([]byte, []int) {
+// Deprecated: Use Translation.ProtoReflect.Descriptor instead.
+func (*Translation) Descriptor() ([]byte, []int) {
 	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{1}
 }
 
-func (x *CreateBlogRequest) GetBlog() *Blog {
+func (x *Translation) GetTitle() string {
 	if x != nil {
-		return x.Blog
+		return x.Title
 	}
-	return nil
+	return ""
 }
 
-type CreateBlogResponse struct {
+func (x *Translation) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+type Reactors struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"` // will have a blog_id
+	UserIds []string `protobuf:"bytes,1,rep,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
 }
 
-func (x *CreateBlogResponse) Reset() {
-	*x = CreateBlogResponse{}
+func (x *Reactors) Reset() {
+	*x = Reactors{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_blog_blogpb_blog_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -164,13 +642,13 @@ func (x *CreateBlogResponse) Reset() {
 	}
 }
 
-func (x *CreateBlogResponse) String() string {
+func (x *Reactors) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*CreateBlogResponse) ProtoMessage() {}
+func (*Reactors) ProtoMessage() {}
 
-func (x *CreateBlogResponse) ProtoReflect() protoreflect.Message {
+func (x *Reactors) ProtoReflect() protoreflect.Message {
 	mi := &file_blog_blogpb_blog_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -182,28 +660,37 @@ func (x *CreateBlogResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use CreateBlogResponse.ProtoReflect.Descriptor instead.
-func (*CreateBlogResponse) Descriptor() ([]byte, []int) {
+// Deprecated: Use Reactors.ProtoReflect.Descriptor instead.
+func (*Reactors) Descriptor() ([]byte, []int) {
 	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *CreateBlogResponse) GetBlog() *Blog {
+func (x *Reactors) GetUserIds() []string {
 	if x != nil {
-		return x.Blog
+		return x.UserIds
 	}
 	return nil
 }
 
-type ReadBlogRequest struct {
+// SeriesLink locates a blog in its series, among the blogs the caller can
+// read
+type SeriesLink struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	BlogId string `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	SeriesId    string `protobuf:"bytes,1,opt,name=series_id,json=seriesId,proto3" json:"series_id,omitempty"`
+	SeriesTitle string `protobuf:"bytes,2,opt,name=series_title,json=seriesTitle,proto3" json:"series_title,omitempty"`
+	// 1-based position of the blog in the series
+	Position int32 `protobuf:"varint,3,opt,name=position,proto3" json:"position,omitempty"`
+	Total    int32 `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	// empty at the ends of the series
+	PreviousBlogId string `protobuf:"bytes,5,opt,name=previous_blog_id,json=previousBlogId,proto3" json:"previous_blog_id,omitempty"`
+	NextBlogId     string `protobuf:"bytes,6,opt,name=next_blog_id,json=nextBlogId,proto3" json:"next_blog_id,omitempty"`
 }
 
-func (x *ReadBlogRequest) Reset() {
-	*x = ReadBlogRequest{}
+func (x *SeriesLink) Reset() {
+	*x = SeriesLink{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_blog_blogpb_blog_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -211,14 +698,248 @@ func (x *ReadBlogRequest) Reset() {
 	}
 }
 
-func (x *ReadBlogRequest) String() string {
+func (x *SeriesLink) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SeriesLink) ProtoMessage() {}
+
+func (x *SeriesLink) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SeriesLink.ProtoReflect.Descriptor instead.
+func (*SeriesLink) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SeriesLink) GetSeriesId() string {
+	if x != nil {
+		return x.SeriesId
+	}
+	return ""
+}
+
+func (x *SeriesLink) GetSeriesTitle() string {
+	if x != nil {
+		return x.SeriesTitle
+	}
+	return ""
+}
+
+func (x *SeriesLink) GetPosition() int32 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *SeriesLink) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *SeriesLink) GetPreviousBlogId() string {
+	if x != nil {
+		return x.PreviousBlogId
+	}
+	return ""
+}
+
+func (x *SeriesLink) GetNextBlogId() string {
+	if x != nil {
+		return x.NextBlogId
+	}
+	return ""
+}
+
+type AclEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// "user:<id>" or "group:<name>"
+	Principal string `protobuf:"bytes,1,opt,name=principal,proto3" json:"principal,omitempty"`
+	Role      Role   `protobuf:"varint,2,opt,name=role,proto3,enum=blog.Role" json:"role,omitempty"`
+}
+
+func (x *AclEntry) Reset() {
+	*x = AclEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AclEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AclEntry) ProtoMessage() {}
+
+func (x *AclEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AclEntry.ProtoReflect.Descriptor instead.
+func (*AclEntry) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *AclEntry) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *AclEntry) GetRole() Role {
+	if x != nil {
+		return x.Role
+	}
+	return Role_ROLE_UNSPECIFIED
+}
+
+type CreateBlogRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+}
+
+func (x *CreateBlogRequest) Reset() {
+	*x = CreateBlogRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateBlogRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateBlogRequest) ProtoMessage() {}
+
+func (x *CreateBlogRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateBlogRequest.ProtoReflect.Descriptor instead.
+func (*CreateBlogRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateBlogRequest) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+type CreateBlogResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"` // will have a blog_id
+}
+
+func (x *CreateBlogResponse) Reset() {
+	*x = CreateBlogResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateBlogResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateBlogResponse) ProtoMessage() {}
+
+func (x *CreateBlogResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateBlogResponse.ProtoReflect.Descriptor instead.
+func (*CreateBlogResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateBlogResponse) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+type ReadBlogRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlogId string `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	// preferred BCP-47 locale, the accept-language metadata is used when empty
+	Locale string `protobuf:"bytes,2,opt,name=locale,proto3" json:"locale,omitempty"`
+}
+
+func (x *ReadBlogRequest) Reset() {
+	*x = ReadBlogRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReadBlogRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
 func (*ReadBlogRequest) ProtoMessage() {}
 
 func (x *ReadBlogRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_blog_blogpb_blog_proto_msgTypes[3]
+	mi := &file_blog_blogpb_blog_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -231,7 +952,7 @@ func (x *ReadBlogRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReadBlogRequest.ProtoReflect.Descriptor instead.
 func (*ReadBlogRequest) Descriptor() ([]byte, []int) {
-	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{3}
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *ReadBlogRequest) GetBlogId() string {
@@ -241,6 +962,13 @@ func (x *ReadBlogRequest) GetBlogId() string {
 	return ""
 }
 
+func (x *ReadBlogRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
 type ReadBlogResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -252,7 +980,7 @@ type ReadBlogResponse struct {
 func (x *ReadBlogResponse) Reset() {
 	*x = ReadBlogResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_blog_blogpb_blog_proto_msgTypes[4]
+		mi := &file_blog_blogpb_blog_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -265,7 +993,7 @@ func (x *ReadBlogResponse) String() string {
 func (*ReadBlogResponse) ProtoMessage() {}
 
 func (x *ReadBlogResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_blog_blogpb_blog_proto_msgTypes[4]
+	mi := &file_blog_blogpb_blog_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -278,7 +1006,7 @@ func (x *ReadBlogResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReadBlogResponse.ProtoReflect.Descriptor instead.
 func (*ReadBlogResponse) Descriptor() ([]byte, []int) {
-	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{4}
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *ReadBlogResponse) GetBlog() *Blog {
@@ -293,13 +1021,18 @@ type UpdateBlogRequest struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
+	// author_id is ignored, the author of a blog does not change. Changing
+	// default_locale to a translated locale replaces that translation, the
+	// previous default title and content become a translation.
 	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+	// change the visibility to blog.visibility, it is kept otherwise
+	UpdateVisibility bool `protobuf:"varint,2,opt,name=update_visibility,json=updateVisibility,proto3" json:"update_visibility,omitempty"`
 }
 
 func (x *UpdateBlogRequest) Reset() {
 	*x = UpdateBlogRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_blog_blogpb_blog_proto_msgTypes[5]
+		mi := &file_blog_blogpb_blog_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -312,7 +1045,7 @@ func (x *UpdateBlogRequest) String() string {
 func (*UpdateBlogRequest) ProtoMessage() {}
 
 func (x *UpdateBlogRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_blog_blogpb_blog_proto_msgTypes[5]
+	mi := &file_blog_blogpb_blog_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -325,7 +1058,7 @@ func (x *UpdateBlogRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateBlogRequest.ProtoReflect.Descriptor instead.
 func (*UpdateBlogRequest) Descriptor() ([]byte, []int) {
-	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{5}
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *UpdateBlogRequest) GetBlog() *Blog {
@@ -335,6 +1068,13 @@ func (x *UpdateBlogRequest) GetBlog() *Blog {
 	return nil
 }
 
+func (x *UpdateBlogRequest) GetUpdateVisibility() bool {
+	if x != nil {
+		return x.UpdateVisibility
+	}
+	return false
+}
+
 type UpdateBlogResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -346,7 +1086,7 @@ type UpdateBlogResponse struct {
 func (x *UpdateBlogResponse) Reset() {
 	*x = UpdateBlogResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_blog_blogpb_blog_proto_msgTypes[6]
+		mi := &file_blog_blogpb_blog_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -359,7 +1099,7 @@ func (x *UpdateBlogResponse) String() string {
 func (*UpdateBlogResponse) ProtoMessage() {}
 
 func (x *UpdateBlogResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_blog_blogpb_blog_proto_msgTypes[6]
+	mi := &file_blog_blogpb_blog_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -372,7 +1112,7 @@ func (x *UpdateBlogResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateBlogResponse.ProtoReflect.Descriptor instead.
 func (*UpdateBlogResponse) Descriptor() ([]byte, []int) {
-	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{6}
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *UpdateBlogResponse) GetBlog() *Blog {
@@ -393,7 +1133,7 @@ type DeleteBlogRequest struct {
 func (x *DeleteBlogRequest) Reset() {
 	*x = DeleteBlogRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_blog_blogpb_blog_proto_msgTypes[7]
+		mi := &file_blog_blogpb_blog_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -406,7 +1146,7 @@ func (x *DeleteBlogRequest) String() string {
 func (*DeleteBlogRequest) ProtoMessage() {}
 
 func (x *DeleteBlogRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_blog_blogpb_blog_proto_msgTypes[7]
+	mi := &file_blog_blogpb_blog_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -419,7 +1159,7 @@ func (x *DeleteBlogRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteBlogRequest.ProtoReflect.Descriptor instead.
 func (*DeleteBlogRequest) Descriptor() ([]byte, []int) {
-	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{7}
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *DeleteBlogRequest) GetBlogId() string {
@@ -440,7 +1180,7 @@ type DeleteBlogResponse struct {
 func (x *DeleteBlogResponse) Reset() {
 	*x = DeleteBlogResponse{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_blog_blogpb_blog_proto_msgTypes[8]
+		mi := &file_blog_blogpb_blog_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -453,7 +1193,7 @@ func (x *DeleteBlogResponse) String() string {
 func (*DeleteBlogResponse) ProtoMessage() {}
 
 func (x *DeleteBlogResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_blog_blogpb_blog_proto_msgTypes[8]
+	mi := &file_blog_blogpb_blog_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -466,7 +1206,7 @@ func (x *DeleteBlogResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteBlogResponse.ProtoReflect.Descriptor instead.
 func (*DeleteBlogResponse) Descriptor() ([]byte, []int) {
-	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{8}
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *DeleteBlogResponse) GetBlogId() string {
@@ -480,12 +1220,26 @@ type ListBlogRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
+
+	// only list the blogs of this author
+	AuthorId string `protobuf:"bytes,1,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	// blogs are listed by ascending ID, to get the next page pass the ID of
+	// the last blog received
+	AfterId string `protobuf:"bytes,2,opt,name=after_id,json=afterId,proto3" json:"after_id,omitempty"`
+	// maximum number of blogs to return, 0 means no limit
+	Limit int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	// only list the blogs having this tag
+	Tag string `protobuf:"bytes,4,opt,name=tag,proto3" json:"tag,omitempty"`
+	// preferred BCP-47 locale, the accept-language metadata is used when empty
+	Locale string `protobuf:"bytes,5,opt,name=locale,proto3" json:"locale,omitempty"`
+	// leave out the content of the blogs, the excerpt is still returned
+	OmitContent bool `protobuf:"varint,6,opt,name=omit_content,json=omitContent,proto3" json:"omit_content,omitempty"`
 }
 
 func (x *ListBlogRequest) Reset() {
 	*x = ListBlogRequest{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_blog_blogpb_blog_proto_msgTypes[9]
+		mi := &file_blog_blogpb_blog_proto_msgTypes[13]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -498,7 +1252,7 @@ func (x *ListBlogRequest) String() string {
 func (*ListBlogRequest) ProtoMessage() {}
 
 func (x *ListBlogRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_blog_blogpb_blog_proto_msgTypes[9]
+	mi := &file_blog_blogpb_blog_proto_msgTypes[13]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -511,23 +1265,65 @@ func (x *ListBlogRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListBlogRequest.ProtoReflect.Descriptor instead.
 func (*ListBlogRequest) Descriptor() ([]byte, []int) {
-	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{9}
-}
-
-type ListBlogResponse struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{13}
 }
 
-func (x *ListBlogResponse) Reset() {
-	*x = ListBlogResponse{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_blog_blogpb_blog_proto_msgTypes[10]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
+func (x *ListBlogRequest) GetAuthorId() string {
+	if x != nil {
+		return x.AuthorId
+	}
+	return ""
+}
+
+func (x *ListBlogRequest) GetAfterId() string {
+	if x != nil {
+		return x.AfterId
+	}
+	return ""
+}
+
+func (x *ListBlogRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListBlogRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *ListBlogRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *ListBlogRequest) GetOmitContent() bool {
+	if x != nil {
+		return x.OmitContent
+	}
+	return false
+}
+
+type ListBlogResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+}
+
+func (x *ListBlogResponse) Reset() {
+	*x = ListBlogResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
 }
 
@@ -538,7 +1334,7 @@ func (x *ListBlogResponse) String() string {
 func (*ListBlogResponse) ProtoMessage() {}
 
 func (x *ListBlogResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_blog_blogpb_blog_proto_msgTypes[10]
+	mi := &file_blog_blogpb_blog_proto_msgTypes[14]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -551,7 +1347,7 @@ func (x *ListBlogResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListBlogResponse.ProtoReflect.Descriptor instead.
 func (*ListBlogResponse) Descriptor() ([]byte, []int) {
-	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{10}
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *ListBlogResponse) GetBlog() *Blog {
@@ -561,351 +1357,4738 @@ func (x *ListBlogResponse) GetBlog() *Blog {
 	return nil
 }
 
-var File_blog_blogpb_blog_proto protoreflect.FileDescriptor
+type ReactToBlogRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-var file_blog_blogpb_blog_proto_rawDesc = []byte{
-	0x0a, 0x16, 0x62, 0x6c, 0x6f, 0x67, 0x2f, 0x62, 0x6c, 0x6f, 0x67, 0x70, 0x62, 0x2f, 0x62, 0x6c,
-	0x6f, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x63,
-	0x0a, 0x04, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72,
-	0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x61, 0x75, 0x74, 0x68, 0x6f,
-	0x72, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e,
-	0x74, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74,
-	0x65, 0x6e, 0x74, 0x22, 0x33, 0x0a, 0x11, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f,
-	0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c,
-	0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x34, 0x0a, 0x12, 0x43, 0x72, 0x65, 0x61,
-	0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e,
-	0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62,
-	0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x2a,
-	0x0a, 0x0f, 0x52, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x12, 0x17, 0x0a, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x22, 0x32, 0x0a, 0x10, 0x52, 0x65,
-	0x61, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e,
-	0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62,
-	0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x33,
-	0x0a, 0x11, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75,
-	0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62,
-	0x6c, 0x6f, 0x67, 0x22, 0x34, 0x0a, 0x12, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f,
-	0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f,
-	0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42,
-	0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x2c, 0x0a, 0x11, 0x44, 0x65, 0x6c,
-	0x65, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17,
-	0x0a, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x06, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x22, 0x2d, 0x0a, 0x12, 0x44, 0x65, 0x6c, 0x65, 0x74,
-	0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x17, 0x0a,
-	0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
-	0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x22, 0x11, 0x0a, 0x0f, 0x4c, 0x69, 0x73, 0x74, 0x42, 0x6c,
-	0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x32, 0x0a, 0x10, 0x4c, 0x69, 0x73,
-	0x74, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a,
-	0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c,
-	0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x32, 0xc8, 0x02,
-	0x0a, 0x0b, 0x42, 0x6c, 0x6f, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x3f, 0x0a,
-	0x0a, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x17, 0x2e, 0x62, 0x6c,
-	0x6f, 0x67, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x43, 0x72, 0x65, 0x61,
-	0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x39,
-	0x0a, 0x08, 0x52, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x15, 0x2e, 0x62, 0x6c, 0x6f,
-	0x67, 0x2e, 0x52, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x1a, 0x16, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f,
-	0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3f, 0x0a, 0x0a, 0x55, 0x70, 0x64,
-	0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x55,
-	0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
-	0x1a, 0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x6c,
-	0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3f, 0x0a, 0x0a, 0x44, 0x65,
-	0x6c, 0x65, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e,
-	0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
-	0x74, 0x1a, 0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x42,
-	0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3b, 0x0a, 0x08, 0x4c,
-	0x69, 0x73, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x15, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x4c,
-	0x69, 0x73, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16,
-	0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x0d, 0x5a, 0x0b, 0x62, 0x6c, 0x6f, 0x67,
-	0x2f, 0x62, 0x6c, 0x6f, 0x67, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	BlogId string `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	// optional, must be the caller when set
+	UserId   string   `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Reaction Reaction `protobuf:"varint,3,opt,name=reaction,proto3,enum=blog.Reaction" json:"reaction,omitempty"`
+	// withdraw a previous reaction instead of adding one
+	Remove bool `protobuf:"varint,4,opt,name=remove,proto3" json:"remove,omitempty"`
 }
 
-var (
-	file_blog_blogpb_blog_proto_rawDescOnce sync.Once
-	file_blog_blogpb_blog_proto_rawDescData = file_blog_blogpb_blog_proto_rawDesc
-)
+func (x *ReactToBlogRequest) Reset() {
+	*x = ReactToBlogRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
 
-func file_blog_blogpb_blog_proto_rawDescGZIP() []byte {
-	file_blog_blogpb_blog_proto_rawDescOnce.Do(func() {
-		file_blog_blogpb_blog_proto_rawDescData = protoimpl.X.CompressGZIP(file_blog_blogpb_blog_proto_rawDescData)
-	})
-	return file_blog_blogpb_blog_proto_rawDescData
+func (x *ReactToBlogRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var file_blog_blogpb_blog_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
-var file_blog_blogpb_blog_proto_goTypes = []interface{}{
-	(*Blog)(nil),               // 0: blog.Blog
-	(*CreateBlogRequest)(nil),  // 1: blog.CreateBlogRequest
-	(*CreateBlogResponse)(nil), // 2: blog.CreateBlogResponse
-	(*ReadBlogRequest)(nil),    // 3: blog.ReadBlogRequest
-	(*ReadBlogResponse)(nil),   // 4: blog.ReadBlogResponse
-	(*UpdateBlogRequest)(nil),  // 5: blog.UpdateBlogRequest
-	(*UpdateBlogResponse)(nil), // 6: blog.UpdateBlogResponse
-	(*DeleteBlogRequest)(nil),  // 7: blog.DeleteBlogRequest
-	(*DeleteBlogResponse)(nil), // 8: blog.DeleteBlogResponse
-	(*ListBlogRequest)(nil),    // 9: blog.ListBlogRequest
-	(*ListBlogResponse)(nil),   // 10: blog.ListBlogResponse
+func (*ReactToBlogRequest) ProtoMessage() {}
+
+func (x *ReactToBlogRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
-var file_blog_blogpb_blog_proto_depIdxs = []int32{
-	0,  // 0: blog.CreateBlogRequest.blog:type_name -> blog.Blog
-	0,  // 1: blog.CreateBlogResponse.blog:type_name -> blog.Blog
-	0,  // 2: blog.ReadBlogResponse.blog:type_name -> blog.Blog
-	0,  // 3: blog.UpdateBlogRequest.blog:type_name -> blog.Blog
-	0,  // 4: blog.UpdateBlogResponse.blog:type_name -> blog.Blog
-	0,  // 5: blog.ListBlogResponse.blog:type_name -> blog.Blog
-	1,  // 6: blog.BlogService.CreateBlog:input_type -> blog.CreateBlogRequest
-	3,  // 7: blog.BlogService.ReadBlog:input_type -> blog.ReadBlogRequest
-	5,  // 8: blog.BlogService.UpdateBlog:input_type -> blog.UpdateBlogRequest
-	7,  // 9: blog.BlogService.DeleteBlog:input_type -> blog.DeleteBlogRequest
-	9,  // 10: blog.BlogService.ListBlog:input_type -> blog.ListBlogRequest
-	2,  // 11: blog.BlogService.CreateBlog:output_type -> blog.CreateBlogResponse
-	4,  // 12: blog.BlogService.ReadBlog:output_type -> blog.ReadBlogResponse
-	6,  // 13: blog.BlogService.UpdateBlog:output_type -> blog.UpdateBlogResponse
-	8,  // 14: blog.BlogService.DeleteBlog:output_type -> blog.DeleteBlogResponse
-	10, // 15: blog.BlogService.ListBlog:output_type -> blog.ListBlogResponse
-	11, // [11:16] is the sub-list for method output_type
-	6,  // [6:11] is the sub-list for method input_type
-	6,  // [6:6] is the sub-list for extension type_name
-	6,  // [6:6] is the sub-list for extension extendee
-	0,  // [0:6] is the sub-list for field type_name
+
+// Deprecated: Use ReactToBlogRequest.ProtoReflect.Descriptor instead.
+func (*ReactToBlogRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{15}
 }
 
-func init() { file_blog_blogpb_blog_proto_init() }
-func file_blog_blogpb_blog_proto_init() {
-	if File_blog_blogpb_blog_proto != nil {
-		return
+func (x *ReactToBlogRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_blog_blogpb_blog_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Blog); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_blog_blogpb_blog_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CreateBlogRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+	return ""
+}
+
+func (x *ReactToBlogRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ReactToBlogRequest) GetReaction() Reaction {
+	if x != nil {
+		return x.Reaction
+	}
+	return Reaction_REACTION_UNSPECIFIED
+}
+
+func (x *ReactToBlogRequest) GetRemove() bool {
+	if x != nil {
+		return x.Remove
+	}
+	return false
+}
+
+type ReactToBlogResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+	// false when the user had already reacted (or had not, on remove)
+	Changed bool `protobuf:"varint,2,opt,name=changed,proto3" json:"changed,omitempty"`
+}
+
+func (x *ReactToBlogResponse) Reset() {
+	*x = ReactToBlogResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReactToBlogResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReactToBlogResponse) ProtoMessage() {}
+
+func (x *ReactToBlogResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_blog_blogpb_blog_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CreateBlogResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReactToBlogResponse.ProtoReflect.Descriptor instead.
+func (*ReactToBlogResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ReactToBlogResponse) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+func (x *ReactToBlogResponse) GetChanged() bool {
+	if x != nil {
+		return x.Changed
+	}
+	return false
+}
+
+type RecordViewRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlogId string `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+}
+
+func (x *RecordViewRequest) Reset() {
+	*x = RecordViewRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RecordViewRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordViewRequest) ProtoMessage() {}
+
+func (x *RecordViewRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_blog_blogpb_blog_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ReadBlogRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordViewRequest.ProtoReflect.Descriptor instead.
+func (*RecordViewRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *RecordViewRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
+	}
+	return ""
+}
+
+type RecordViewResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *RecordViewResponse) Reset() {
+	*x = RecordViewResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RecordViewResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordViewResponse) ProtoMessage() {}
+
+func (x *RecordViewResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_blog_blogpb_blog_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ReadBlogResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_blog_blogpb_blog_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*UpdateBlogRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_blog_blogpb_blog_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*UpdateBlogResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_blog_blogpb_blog_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DeleteBlogRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordViewResponse.ProtoReflect.Descriptor instead.
+func (*RecordViewResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{18}
+}
+
+type GetFeedRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Format FeedFormat `protobuf:"varint,1,opt,name=format,proto3,enum=blog.FeedFormat" json:"format,omitempty"`
+	// only include the blogs of this author and/or having this tag
+	AuthorId string `protobuf:"bytes,2,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	Tag      string `protobuf:"bytes,3,opt,name=tag,proto3" json:"tag,omitempty"`
+	// number of blogs, 20 by default and at most 100
+	Limit int32 `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	// conditional request, same semantics as the HTTP headers
+	IfNoneMatch     string               `protobuf:"bytes,5,opt,name=if_none_match,json=ifNoneMatch,proto3" json:"if_none_match,omitempty"`
+	IfModifiedSince *timestamp.Timestamp `protobuf:"bytes,6,opt,name=if_modified_since,json=ifModifiedSince,proto3" json:"if_modified_since,omitempty"`
+}
+
+func (x *GetFeedRequest) Reset() {
+	*x = GetFeedRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetFeedRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFeedRequest) ProtoMessage() {}
+
+func (x *GetFeedRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_blog_blogpb_blog_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DeleteBlogResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFeedRequest.ProtoReflect.Descriptor instead.
+func (*GetFeedRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GetFeedRequest) GetFormat() FeedFormat {
+	if x != nil {
+		return x.Format
+	}
+	return FeedFormat_RSS
+}
+
+func (x *GetFeedRequest) GetAuthorId() string {
+	if x != nil {
+		return x.AuthorId
+	}
+	return ""
+}
+
+func (x *GetFeedRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *GetFeedRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetFeedRequest) GetIfNoneMatch() string {
+	if x != nil {
+		return x.IfNoneMatch
+	}
+	return ""
+}
+
+func (x *GetFeedRequest) GetIfModifiedSince() *timestamp.Timestamp {
+	if x != nil {
+		return x.IfModifiedSince
+	}
+	return nil
+}
+
+type GetFeedResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the feed did not change according to the conditions, body is empty
+	NotModified bool   `protobuf:"varint,1,opt,name=not_modified,json=notModified,proto3" json:"not_modified,omitempty"`
+	ContentType string `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Body        []byte `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	Etag        string `protobuf:"bytes,4,opt,name=etag,proto3" json:"etag,omitempty"`
+	// the newest update time of the blogs in the feed
+	LastModified *timestamp.Timestamp `protobuf:"bytes,5,opt,name=last_modified,json=lastModified,proto3" json:"last_modified,omitempty"`
+}
+
+func (x *GetFeedResponse) Reset() {
+	*x = GetFeedResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetFeedResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetFeedResponse) ProtoMessage() {}
+
+func (x *GetFeedResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_blog_blogpb_blog_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ListBlogRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetFeedResponse.ProtoReflect.Descriptor instead.
+func (*GetFeedResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *GetFeedResponse) GetNotModified() bool {
+	if x != nil {
+		return x.NotModified
+	}
+	return false
+}
+
+func (x *GetFeedResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *GetFeedResponse) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+func (x *GetFeedResponse) GetEtag() string {
+	if x != nil {
+		return x.Etag
+	}
+	return ""
+}
+
+func (x *GetFeedResponse) GetLastModified() *timestamp.Timestamp {
+	if x != nil {
+		return x.LastModified
+	}
+	return nil
+}
+
+type ListRelatedBlogsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlogId string `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	// 10 by default and at most 50
+	Limit int32 `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (x *ListRelatedBlogsRequest) Reset() {
+	*x = ListRelatedBlogsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListRelatedBlogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRelatedBlogsRequest) ProtoMessage() {}
+
+func (x *ListRelatedBlogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
-		file_blog_blogpb_blog_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ListBlogResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRelatedBlogsRequest.ProtoReflect.Descriptor instead.
+func (*ListRelatedBlogsRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *ListRelatedBlogsRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
+	}
+	return ""
+}
+
+func (x *ListRelatedBlogsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type RelatedBlog struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+	// between 0 and 1, higher is more similar
+	Score float64 `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+}
+
+func (x *RelatedBlog) Reset() {
+	*x = RelatedBlog{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RelatedBlog) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelatedBlog) ProtoMessage() {}
+
+func (x *RelatedBlog) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
 		}
+		return ms
 	}
-	type x struct{}
-	out := protoimpl.TypeBuilder{
-		File: protoimpl.DescBuilder{
-			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
-			RawDescriptor: file_blog_blogpb_blog_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   11,
-			NumExtensions: 0,
-			NumServices:   1,
-		},
-		GoTypes:           file_blog_blogpb_blog_proto_goTypes,
-		DependencyIndexes: file_blog_blogpb_blog_proto_depIdxs,
-		MessageInfos:      file_blog_blogpb_blog_proto_msgTypes,
-	}.Build()
-	File_blog_blogpb_blog_proto = out.File
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelatedBlog.ProtoReflect.Descriptor instead.
+func (*RelatedBlog) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *RelatedBlog) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+func (x *RelatedBlog) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+type ListRelatedBlogsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// best match first
+	Related []*RelatedBlog `protobuf:"bytes,1,rep,name=related,proto3" json:"related,omitempty"`
+}
+
+func (x *ListRelatedBlogsResponse) Reset() {
+	*x = ListRelatedBlogsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListRelatedBlogsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRelatedBlogsResponse) ProtoMessage() {}
+
+func (x *ListRelatedBlogsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRelatedBlogsResponse.ProtoReflect.Descriptor instead.
+func (*ListRelatedBlogsResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ListRelatedBlogsResponse) GetRelated() []*RelatedBlog {
+	if x != nil {
+		return x.Related
+	}
+	return nil
+}
+
+type PutTranslationRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlogId string `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	// BCP-47 locale, other than the default one of the blog
+	Locale      string       `protobuf:"bytes,2,opt,name=locale,proto3" json:"locale,omitempty"`
+	Translation *Translation `protobuf:"bytes,3,opt,name=translation,proto3" json:"translation,omitempty"`
+}
+
+func (x *PutTranslationRequest) Reset() {
+	*x = PutTranslationRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PutTranslationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PutTranslationRequest) ProtoMessage() {}
+
+func (x *PutTranslationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PutTranslationRequest.ProtoReflect.Descriptor instead.
+func (*PutTranslationRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *PutTranslationRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
+	}
+	return ""
+}
+
+func (x *PutTranslationRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+func (x *PutTranslationRequest) GetTranslation() *Translation {
+	if x != nil {
+		return x.Translation
+	}
+	return nil
+}
+
+type PutTranslationResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+}
+
+func (x *PutTranslationResponse) Reset() {
+	*x = PutTranslationResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *PutTranslationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PutTranslationResponse) ProtoMessage() {}
+
+func (x *PutTranslationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PutTranslationResponse.ProtoReflect.Descriptor instead.
+func (*PutTranslationResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *PutTranslationResponse) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+type DeleteTranslationRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlogId string `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	Locale string `protobuf:"bytes,2,opt,name=locale,proto3" json:"locale,omitempty"`
+}
+
+func (x *DeleteTranslationRequest) Reset() {
+	*x = DeleteTranslationRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteTranslationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteTranslationRequest) ProtoMessage() {}
+
+func (x *DeleteTranslationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteTranslationRequest.ProtoReflect.Descriptor instead.
+func (*DeleteTranslationRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *DeleteTranslationRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
+	}
+	return ""
+}
+
+func (x *DeleteTranslationRequest) GetLocale() string {
+	if x != nil {
+		return x.Locale
+	}
+	return ""
+}
+
+type DeleteTranslationResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+}
+
+func (x *DeleteTranslationResponse) Reset() {
+	*x = DeleteTranslationResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteTranslationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteTranslationResponse) ProtoMessage() {}
+
+func (x *DeleteTranslationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteTranslationResponse.ProtoReflect.Descriptor instead.
+func (*DeleteTranslationResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *DeleteTranslationResponse) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+type ShareBlogRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlogId string    `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	Entry  *AclEntry `protobuf:"bytes,2,opt,name=entry,proto3" json:"entry,omitempty"`
+}
+
+func (x *ShareBlogRequest) Reset() {
+	*x = ShareBlogRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShareBlogRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareBlogRequest) ProtoMessage() {}
+
+func (x *ShareBlogRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareBlogRequest.ProtoReflect.Descriptor instead.
+func (*ShareBlogRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *ShareBlogRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
+	}
+	return ""
+}
+
+func (x *ShareBlogRequest) GetEntry() *AclEntry {
+	if x != nil {
+		return x.Entry
+	}
+	return nil
+}
+
+type ShareBlogResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+}
+
+func (x *ShareBlogResponse) Reset() {
+	*x = ShareBlogResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShareBlogResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareBlogResponse) ProtoMessage() {}
+
+func (x *ShareBlogResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareBlogResponse.ProtoReflect.Descriptor instead.
+func (*ShareBlogResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *ShareBlogResponse) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+type UnshareBlogRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlogId    string `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	Principal string `protobuf:"bytes,2,opt,name=principal,proto3" json:"principal,omitempty"`
+}
+
+func (x *UnshareBlogRequest) Reset() {
+	*x = UnshareBlogRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UnshareBlogRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnshareBlogRequest) ProtoMessage() {}
+
+func (x *UnshareBlogRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnshareBlogRequest.ProtoReflect.Descriptor instead.
+func (*UnshareBlogRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *UnshareBlogRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
+	}
+	return ""
+}
+
+func (x *UnshareBlogRequest) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+type UnshareBlogResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+}
+
+func (x *UnshareBlogResponse) Reset() {
+	*x = UnshareBlogResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[31]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UnshareBlogResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnshareBlogResponse) ProtoMessage() {}
+
+func (x *UnshareBlogResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[31]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnshareBlogResponse.ProtoReflect.Descriptor instead.
+func (*UnshareBlogResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *UnshareBlogResponse) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+type Series struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id          string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	AuthorId    string `protobuf:"bytes,2,opt,name=author_id,json=authorId,proto3" json:"author_id,omitempty"`
+	Title       string `protobuf:"bytes,3,opt,name=title,proto3" json:"title,omitempty"`
+	Description string `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	// in reading order, a blog belongs to at most one series
+	BlogIds []string `protobuf:"bytes,5,rep,name=blog_ids,json=blogIds,proto3" json:"blog_ids,omitempty"`
+	// set by the server
+	CreatedAt *timestamp.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt *timestamp.Timestamp `protobuf:"bytes,7,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+}
+
+func (x *Series) Reset() {
+	*x = Series{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[32]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Series) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Series) ProtoMessage() {}
+
+func (x *Series) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[32]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Series.ProtoReflect.Descriptor instead.
+func (*Series) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *Series) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Series) GetAuthorId() string {
+	if x != nil {
+		return x.AuthorId
+	}
+	return ""
+}
+
+func (x *Series) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Series) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Series) GetBlogIds() []string {
+	if x != nil {
+		return x.BlogIds
+	}
+	return nil
+}
+
+func (x *Series) GetCreatedAt() *timestamp.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Series) GetUpdatedAt() *timestamp.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type CreateSeriesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Series *Series `protobuf:"bytes,1,opt,name=series,proto3" json:"series,omitempty"`
+}
+
+func (x *CreateSeriesRequest) Reset() {
+	*x = CreateSeriesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[33]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateSeriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSeriesRequest) ProtoMessage() {}
+
+func (x *CreateSeriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[33]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSeriesRequest.ProtoReflect.Descriptor instead.
+func (*CreateSeriesRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *CreateSeriesRequest) GetSeries() *Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+type CreateSeriesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Series *Series `protobuf:"bytes,1,opt,name=series,proto3" json:"series,omitempty"`
+}
+
+func (x *CreateSeriesResponse) Reset() {
+	*x = CreateSeriesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[34]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateSeriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSeriesResponse) ProtoMessage() {}
+
+func (x *CreateSeriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[34]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSeriesResponse.ProtoReflect.Descriptor instead.
+func (*CreateSeriesResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *CreateSeriesResponse) GetSeries() *Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+type GetSeriesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SeriesId string `protobuf:"bytes,1,opt,name=series_id,json=seriesId,proto3" json:"series_id,omitempty"`
+}
+
+func (x *GetSeriesRequest) Reset() {
+	*x = GetSeriesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[35]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetSeriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSeriesRequest) ProtoMessage() {}
+
+func (x *GetSeriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[35]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSeriesRequest.ProtoReflect.Descriptor instead.
+func (*GetSeriesRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *GetSeriesRequest) GetSeriesId() string {
+	if x != nil {
+		return x.SeriesId
+	}
+	return ""
+}
+
+type GetSeriesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// blog_ids leaves out the blogs the caller cannot read
+	Series *Series `protobuf:"bytes,1,opt,name=series,proto3" json:"series,omitempty"`
+	// in reading order, without the blogs the caller cannot read
+	Blogs []*Blog `protobuf:"bytes,2,rep,name=blogs,proto3" json:"blogs,omitempty"`
+}
+
+func (x *GetSeriesResponse) Reset() {
+	*x = GetSeriesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[36]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetSeriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSeriesResponse) ProtoMessage() {}
+
+func (x *GetSeriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[36]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSeriesResponse.ProtoReflect.Descriptor instead.
+func (*GetSeriesResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *GetSeriesResponse) GetSeries() *Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+func (x *GetSeriesResponse) GetBlogs() []*Blog {
+	if x != nil {
+		return x.Blogs
+	}
+	return nil
+}
+
+type UpdateSeriesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the blogs are changed with the dedicated RPCs, blog_ids is ignored
+	Series *Series `protobuf:"bytes,1,opt,name=series,proto3" json:"series,omitempty"`
+}
+
+func (x *UpdateSeriesRequest) Reset() {
+	*x = UpdateSeriesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[37]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateSeriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateSeriesRequest) ProtoMessage() {}
+
+func (x *UpdateSeriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[37]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateSeriesRequest.ProtoReflect.Descriptor instead.
+func (*UpdateSeriesRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *UpdateSeriesRequest) GetSeries() *Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+type UpdateSeriesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Series *Series `protobuf:"bytes,1,opt,name=series,proto3" json:"series,omitempty"`
+}
+
+func (x *UpdateSeriesResponse) Reset() {
+	*x = UpdateSeriesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *UpdateSeriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateSeriesResponse) ProtoMessage() {}
+
+func (x *UpdateSeriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateSeriesResponse.ProtoReflect.Descriptor instead.
+func (*UpdateSeriesResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *UpdateSeriesResponse) GetSeries() *Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+type DeleteSeriesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SeriesId string `protobuf:"bytes,1,opt,name=series_id,json=seriesId,proto3" json:"series_id,omitempty"`
+}
+
+func (x *DeleteSeriesRequest) Reset() {
+	*x = DeleteSeriesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteSeriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSeriesRequest) ProtoMessage() {}
+
+func (x *DeleteSeriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSeriesRequest.ProtoReflect.Descriptor instead.
+func (*DeleteSeriesRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *DeleteSeriesRequest) GetSeriesId() string {
+	if x != nil {
+		return x.SeriesId
+	}
+	return ""
+}
+
+type DeleteSeriesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SeriesId string `protobuf:"bytes,1,opt,name=series_id,json=seriesId,proto3" json:"series_id,omitempty"`
+}
+
+func (x *DeleteSeriesResponse) Reset() {
+	*x = DeleteSeriesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeleteSeriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSeriesResponse) ProtoMessage() {}
+
+func (x *DeleteSeriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSeriesResponse.ProtoReflect.Descriptor instead.
+func (*DeleteSeriesResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *DeleteSeriesResponse) GetSeriesId() string {
+	if x != nil {
+		return x.SeriesId
+	}
+	return ""
+}
+
+type AddToSeriesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SeriesId string `protobuf:"bytes,1,opt,name=series_id,json=seriesId,proto3" json:"series_id,omitempty"`
+	BlogId   string `protobuf:"bytes,2,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	// 1-based position to insert the blog at, 0 appends it
+	Position int32 `protobuf:"varint,3,opt,name=position,proto3" json:"position,omitempty"`
+}
+
+func (x *AddToSeriesRequest) Reset() {
+	*x = AddToSeriesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[41]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddToSeriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddToSeriesRequest) ProtoMessage() {}
+
+func (x *AddToSeriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[41]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddToSeriesRequest.ProtoReflect.Descriptor instead.
+func (*AddToSeriesRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *AddToSeriesRequest) GetSeriesId() string {
+	if x != nil {
+		return x.SeriesId
+	}
+	return ""
+}
+
+func (x *AddToSeriesRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
+	}
+	return ""
+}
+
+func (x *AddToSeriesRequest) GetPosition() int32 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+type AddToSeriesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Series *Series `protobuf:"bytes,1,opt,name=series,proto3" json:"series,omitempty"`
+}
+
+func (x *AddToSeriesResponse) Reset() {
+	*x = AddToSeriesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[42]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AddToSeriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddToSeriesResponse) ProtoMessage() {}
+
+func (x *AddToSeriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[42]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddToSeriesResponse.ProtoReflect.Descriptor instead.
+func (*AddToSeriesResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *AddToSeriesResponse) GetSeries() *Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+type RemoveFromSeriesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SeriesId string `protobuf:"bytes,1,opt,name=series_id,json=seriesId,proto3" json:"series_id,omitempty"`
+	BlogId   string `protobuf:"bytes,2,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+}
+
+func (x *RemoveFromSeriesRequest) Reset() {
+	*x = RemoveFromSeriesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[43]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RemoveFromSeriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveFromSeriesRequest) ProtoMessage() {}
+
+func (x *RemoveFromSeriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[43]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveFromSeriesRequest.ProtoReflect.Descriptor instead.
+func (*RemoveFromSeriesRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *RemoveFromSeriesRequest) GetSeriesId() string {
+	if x != nil {
+		return x.SeriesId
+	}
+	return ""
+}
+
+func (x *RemoveFromSeriesRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
+	}
+	return ""
+}
+
+type RemoveFromSeriesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Series *Series `protobuf:"bytes,1,opt,name=series,proto3" json:"series,omitempty"`
+}
+
+func (x *RemoveFromSeriesResponse) Reset() {
+	*x = RemoveFromSeriesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[44]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RemoveFromSeriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveFromSeriesResponse) ProtoMessage() {}
+
+func (x *RemoveFromSeriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[44]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveFromSeriesResponse.ProtoReflect.Descriptor instead.
+func (*RemoveFromSeriesResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *RemoveFromSeriesResponse) GetSeries() *Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+type ReorderSeriesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SeriesId string `protobuf:"bytes,1,opt,name=series_id,json=seriesId,proto3" json:"series_id,omitempty"`
+	// the new order, listing every blog of the series exactly once
+	BlogIds []string `protobuf:"bytes,2,rep,name=blog_ids,json=blogIds,proto3" json:"blog_ids,omitempty"`
+}
+
+func (x *ReorderSeriesRequest) Reset() {
+	*x = ReorderSeriesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[45]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReorderSeriesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReorderSeriesRequest) ProtoMessage() {}
+
+func (x *ReorderSeriesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[45]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReorderSeriesRequest.ProtoReflect.Descriptor instead.
+func (*ReorderSeriesRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *ReorderSeriesRequest) GetSeriesId() string {
+	if x != nil {
+		return x.SeriesId
+	}
+	return ""
+}
+
+func (x *ReorderSeriesRequest) GetBlogIds() []string {
+	if x != nil {
+		return x.BlogIds
+	}
+	return nil
+}
+
+type ReorderSeriesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Series *Series `protobuf:"bytes,1,opt,name=series,proto3" json:"series,omitempty"`
+}
+
+func (x *ReorderSeriesResponse) Reset() {
+	*x = ReorderSeriesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[46]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ReorderSeriesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReorderSeriesResponse) ProtoMessage() {}
+
+func (x *ReorderSeriesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[46]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReorderSeriesResponse.ProtoReflect.Descriptor instead.
+func (*ReorderSeriesResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *ReorderSeriesResponse) GetSeries() *Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+type ExportBlogsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ExportBlogsRequest) Reset() {
+	*x = ExportBlogsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[47]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExportBlogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportBlogsRequest) ProtoMessage() {}
+
+func (x *ExportBlogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[47]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportBlogsRequest.ProtoReflect.Descriptor instead.
+func (*ExportBlogsRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{47}
+}
+
+type ExportBlogsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// one of them is set, the series come after all the blogs
+	Blog   *Blog   `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+	Series *Series `protobuf:"bytes,2,opt,name=series,proto3" json:"series,omitempty"`
+}
+
+func (x *ExportBlogsResponse) Reset() {
+	*x = ExportBlogsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[48]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExportBlogsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExportBlogsResponse) ProtoMessage() {}
+
+func (x *ExportBlogsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[48]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExportBlogsResponse.ProtoReflect.Descriptor instead.
+func (*ExportBlogsResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *ExportBlogsResponse) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+func (x *ExportBlogsResponse) GetSeries() *Series {
+	if x != nil {
+		return x.Series
+	}
+	return nil
+}
+
+type ImportOptions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	OnConflict ConflictPolicy `protobuf:"varint,1,opt,name=on_conflict,json=onConflict,proto3,enum=blog.ConflictPolicy" json:"on_conflict,omitempty"`
+	// only report what would happen, nothing is written
+	DryRun bool `protobuf:"varint,2,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+}
+
+func (x *ImportOptions) Reset() {
+	*x = ImportOptions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[49]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportOptions) ProtoMessage() {}
+
+func (x *ImportOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[49]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportOptions.ProtoReflect.Descriptor instead.
+func (*ImportOptions) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *ImportOptions) GetOnConflict() ConflictPolicy {
+	if x != nil {
+		return x.OnConflict
+	}
+	return ConflictPolicy_CONFLICT_FAIL
+}
+
+func (x *ImportOptions) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+type ImportBlogsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Item:
+	//	*ImportBlogsRequest_Options
+	//	*ImportBlogsRequest_Blog
+	//	*ImportBlogsRequest_Series
+	Item isImportBlogsRequest_Item `protobuf_oneof:"item"`
+}
+
+func (x *ImportBlogsRequest) Reset() {
+	*x = ImportBlogsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[50]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportBlogsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportBlogsRequest) ProtoMessage() {}
+
+func (x *ImportBlogsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[50]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportBlogsRequest.ProtoReflect.Descriptor instead.
+func (*ImportBlogsRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{50}
+}
+
+func (m *ImportBlogsRequest) GetItem() isImportBlogsRequest_Item {
+	if m != nil {
+		return m.Item
+	}
+	return nil
+}
+
+func (x *ImportBlogsRequest) GetOptions() *ImportOptions {
+	if x, ok := x.GetItem().(*ImportBlogsRequest_Options); ok {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *ImportBlogsRequest) GetBlog() *Blog {
+	if x, ok := x.GetItem().(*ImportBlogsRequest_Blog); ok {
+		return x.Blog
+	}
+	return nil
+}
+
+func (x *ImportBlogsRequest) GetSeries() *Series {
+	if x, ok := x.GetItem().(*ImportBlogsRequest_Series); ok {
+		return x.Series
+	}
+	return nil
+}
+
+type isImportBlogsRequest_Item interface {
+	isImportBlogsRequest_Item()
+}
+
+type ImportBlogsRequest_Options struct {
+	// must be the first message of the stream, if sent
+	Options *ImportOptions `protobuf:"bytes,1,opt,name=options,proto3,oneof"`
+}
+
+type ImportBlogsRequest_Blog struct {
+	// imported as is, keeping its ID and timestamps
+	Blog *Blog `protobuf:"bytes,2,opt,name=blog,proto3,oneof"`
+}
+
+type ImportBlogsRequest_Series struct {
+	// same as blogs, sent after the blogs it holds
+	Series *Series `protobuf:"bytes,3,opt,name=series,proto3,oneof"`
+}
+
+func (*ImportBlogsRequest_Options) isImportBlogsRequest_Item() {}
+
+func (*ImportBlogsRequest_Blog) isImportBlogsRequest_Item() {}
+
+func (*ImportBlogsRequest_Series) isImportBlogsRequest_Item() {}
+
+type ImportBlogsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Created     int64 `protobuf:"varint,1,opt,name=created,proto3" json:"created,omitempty"`
+	Overwritten int64 `protobuf:"varint,2,opt,name=overwritten,proto3" json:"overwritten,omitempty"`
+	Skipped     int64 `protobuf:"varint,3,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	// IDs of the blogs and series that already existed
+	Conflicts         []string `protobuf:"bytes,4,rep,name=conflicts,proto3" json:"conflicts,omitempty"`
+	SeriesCreated     int64    `protobuf:"varint,5,opt,name=series_created,json=seriesCreated,proto3" json:"series_created,omitempty"`
+	SeriesOverwritten int64    `protobuf:"varint,6,opt,name=series_overwritten,json=seriesOverwritten,proto3" json:"series_overwritten,omitempty"`
+	SeriesSkipped     int64    `protobuf:"varint,7,opt,name=series_skipped,json=seriesSkipped,proto3" json:"series_skipped,omitempty"`
+}
+
+func (x *ImportBlogsResponse) Reset() {
+	*x = ImportBlogsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[51]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImportBlogsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportBlogsResponse) ProtoMessage() {}
+
+func (x *ImportBlogsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[51]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportBlogsResponse.ProtoReflect.Descriptor instead.
+func (*ImportBlogsResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *ImportBlogsResponse) GetCreated() int64 {
+	if x != nil {
+		return x.Created
+	}
+	return 0
+}
+
+func (x *ImportBlogsResponse) GetOverwritten() int64 {
+	if x != nil {
+		return x.Overwritten
+	}
+	return 0
+}
+
+func (x *ImportBlogsResponse) GetSkipped() int64 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *ImportBlogsResponse) GetConflicts() []string {
+	if x != nil {
+		return x.Conflicts
+	}
+	return nil
+}
+
+func (x *ImportBlogsResponse) GetSeriesCreated() int64 {
+	if x != nil {
+		return x.SeriesCreated
+	}
+	return 0
+}
+
+func (x *ImportBlogsResponse) GetSeriesOverwritten() int64 {
+	if x != nil {
+		return x.SeriesOverwritten
+	}
+	return 0
+}
+
+func (x *ImportBlogsResponse) GetSeriesSkipped() int64 {
+	if x != nil {
+		return x.SeriesSkipped
+	}
+	return 0
+}
+
+type ListModerationQueueRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// same pagination as ListBlog
+	AfterId string `protobuf:"bytes,1,opt,name=after_id,json=afterId,proto3" json:"after_id,omitempty"`
+	Limit   int32  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (x *ListModerationQueueRequest) Reset() {
+	*x = ListModerationQueueRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[52]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListModerationQueueRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListModerationQueueRequest) ProtoMessage() {}
+
+func (x *ListModerationQueueRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[52]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListModerationQueueRequest.ProtoReflect.Descriptor instead.
+func (*ListModerationQueueRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *ListModerationQueueRequest) GetAfterId() string {
+	if x != nil {
+		return x.AfterId
+	}
+	return ""
+}
+
+func (x *ListModerationQueueRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListModerationQueueResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+}
+
+func (x *ListModerationQueueResponse) Reset() {
+	*x = ListModerationQueueResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[53]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListModerationQueueResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListModerationQueueResponse) ProtoMessage() {}
+
+func (x *ListModerationQueueResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[53]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListModerationQueueResponse.ProtoReflect.Descriptor instead.
+func (*ListModerationQueueResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *ListModerationQueueResponse) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+type ResolveModerationRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlogId string `protobuf:"bytes,1,opt,name=blog_id,json=blogId,proto3" json:"blog_id,omitempty"`
+	// publish the blog, or reject it
+	Approve bool `protobuf:"varint,2,opt,name=approve,proto3" json:"approve,omitempty"`
+	// shown to the author when rejecting
+	Reason string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (x *ResolveModerationRequest) Reset() {
+	*x = ResolveModerationRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[54]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResolveModerationRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveModerationRequest) ProtoMessage() {}
+
+func (x *ResolveModerationRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[54]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveModerationRequest.ProtoReflect.Descriptor instead.
+func (*ResolveModerationRequest) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *ResolveModerationRequest) GetBlogId() string {
+	if x != nil {
+		return x.BlogId
+	}
+	return ""
+}
+
+func (x *ResolveModerationRequest) GetApprove() bool {
+	if x != nil {
+		return x.Approve
+	}
+	return false
+}
+
+func (x *ResolveModerationRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type ResolveModerationResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Blog *Blog `protobuf:"bytes,1,opt,name=blog,proto3" json:"blog,omitempty"`
+}
+
+func (x *ResolveModerationResponse) Reset() {
+	*x = ResolveModerationResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_blog_blogpb_blog_proto_msgTypes[55]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ResolveModerationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveModerationResponse) ProtoMessage() {}
+
+func (x *ResolveModerationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_blog_blogpb_blog_proto_msgTypes[55]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveModerationResponse.ProtoReflect.Descriptor instead.
+func (*ResolveModerationResponse) Descriptor() ([]byte, []int) {
+	return file_blog_blogpb_blog_proto_rawDescGZIP(), []int{55}
+}
+
+func (x *ResolveModerationResponse) GetBlog() *Blog {
+	if x != nil {
+		return x.Blog
+	}
+	return nil
+}
+
+var File_blog_blogpb_blog_proto protoreflect.FileDescriptor
+
+var file_blog_blogpb_blog_proto_rawDesc = []byte{
+	0x0a, 0x16, 0x62, 0x6c, 0x6f, 0x67, 0x2f, 0x62, 0x6c, 0x6f, 0x67, 0x70, 0x62, 0x2f, 0x62, 0x6c,
+	0x6f, 0x67, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x1a, 0x1f,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f,
+	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
+	0x8f, 0x08, 0x0a, 0x04, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x1b, 0x0a, 0x09, 0x61, 0x75, 0x74, 0x68,
+	0x6f, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x61, 0x75, 0x74,
+	0x68, 0x6f, 0x72, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63,
+	0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f,
+	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64,
+	0x5f, 0x61, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x74,
+	0x12, 0x39, 0x0a, 0x0a, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x09, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x6c,
+	0x69, 0x6b, 0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x6c, 0x69, 0x6b, 0x65,
+	0x73, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6c, 0x61, 0x70, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x05, 0x63, 0x6c, 0x61, 0x70, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x69, 0x65, 0x77, 0x73,
+	0x18, 0x09, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76, 0x69, 0x65, 0x77, 0x73, 0x12, 0x12, 0x0a,
+	0x04, 0x74, 0x61, 0x67, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x74, 0x61, 0x67,
+	0x73, 0x12, 0x43, 0x0a, 0x11, 0x6d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f,
+	0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e, 0x62,
+	0x6c, 0x6f, 0x67, 0x2e, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x52, 0x10, 0x6d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x2b, 0x0a, 0x11, 0x6d, 0x6f, 0x64, 0x65, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x0c, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x10, 0x6d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x61,
+	0x73, 0x6f, 0x6e, 0x12, 0x30, 0x0a, 0x0a, 0x76, 0x69, 0x73, 0x69, 0x62, 0x69, 0x6c, 0x69, 0x74,
+	0x79, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x56,
+	0x69, 0x73, 0x69, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x52, 0x0a, 0x76, 0x69, 0x73, 0x69, 0x62,
+	0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x20, 0x0a, 0x03, 0x61, 0x63, 0x6c, 0x18, 0x0e, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x41, 0x63, 0x6c, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x52, 0x03, 0x61, 0x63, 0x6c, 0x12, 0x28, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53,
+	0x65, 0x72, 0x69, 0x65, 0x73, 0x4c, 0x69, 0x6e, 0x6b, 0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x12, 0x25, 0x0a, 0x0e, 0x64, 0x65, 0x66, 0x61, 0x75, 0x6c, 0x74, 0x5f, 0x6c, 0x6f, 0x63,
+	0x61, 0x6c, 0x65, 0x18, 0x10, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x64, 0x65, 0x66, 0x61, 0x75,
+	0x6c, 0x74, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x6f, 0x63, 0x61,
+	0x6c, 0x65, 0x18, 0x11, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65,
+	0x12, 0x2b, 0x0a, 0x11, 0x61, 0x76, 0x61, 0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x6c, 0x6f,
+	0x63, 0x61, 0x6c, 0x65, 0x73, 0x18, 0x12, 0x20, 0x03, 0x28, 0x09, 0x52, 0x10, 0x61, 0x76, 0x61,
+	0x69, 0x6c, 0x61, 0x62, 0x6c, 0x65, 0x4c, 0x6f, 0x63, 0x61, 0x6c, 0x65, 0x73, 0x12, 0x40, 0x0a,
+	0x0c, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x13, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x2e,
+	0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x52, 0x0c, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12,
+	0x1d, 0x0a, 0x0a, 0x77, 0x6f, 0x72, 0x64, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x14, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x09, 0x77, 0x6f, 0x72, 0x64, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x30,
+	0x0a, 0x14, 0x72, 0x65, 0x61, 0x64, 0x69, 0x6e, 0x67, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x6d,
+	0x69, 0x6e, 0x75, 0x74, 0x65, 0x73, 0x18, 0x15, 0x20, 0x01, 0x28, 0x03, 0x52, 0x12, 0x72, 0x65,
+	0x61, 0x64, 0x69, 0x6e, 0x67, 0x54, 0x69, 0x6d, 0x65, 0x4d, 0x69, 0x6e, 0x75, 0x74, 0x65, 0x73,
+	0x12, 0x18, 0x0a, 0x07, 0x65, 0x78, 0x63, 0x65, 0x72, 0x70, 0x74, 0x18, 0x16, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x65, 0x78, 0x63, 0x65, 0x72, 0x70, 0x74, 0x12, 0x34, 0x0a, 0x08, 0x72, 0x65,
+	0x61, 0x63, 0x74, 0x6f, 0x72, 0x73, 0x18, 0x17, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x62,
+	0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x61, 0x63, 0x74, 0x6f, 0x72,
+	0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x72, 0x65, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x73,
+	0x1a, 0x52, 0x0a, 0x11, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x27, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x54, 0x72,
+	0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x3a, 0x02, 0x38, 0x01, 0x1a, 0x4b, 0x0a, 0x0d, 0x52, 0x65, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x24, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65,
+	0x61, 0x63, 0x74, 0x6f, 0x72, 0x73, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x22, 0x3d, 0x0a, 0x0b, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x12, 0x14, 0x0a, 0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x74, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+	0x22, 0x25, 0x0a, 0x08, 0x52, 0x65, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x19, 0x0a, 0x08,
+	0x75, 0x73, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07,
+	0x75, 0x73, 0x65, 0x72, 0x49, 0x64, 0x73, 0x22, 0xca, 0x01, 0x0a, 0x0a, 0x53, 0x65, 0x72, 0x69,
+	0x65, 0x73, 0x4c, 0x69, 0x6e, 0x6b, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x49, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x5f, 0x74, 0x69,
+	0x74, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x54, 0x69, 0x74, 0x6c, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x12, 0x28, 0x0a, 0x10, 0x70, 0x72, 0x65, 0x76,
+	0x69, 0x6f, 0x75, 0x73, 0x5f, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0e, 0x70, 0x72, 0x65, 0x76, 0x69, 0x6f, 0x75, 0x73, 0x42, 0x6c, 0x6f, 0x67,
+	0x49, 0x64, 0x12, 0x20, 0x0a, 0x0c, 0x6e, 0x65, 0x78, 0x74, 0x5f, 0x62, 0x6c, 0x6f, 0x67, 0x5f,
+	0x69, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6e, 0x65, 0x78, 0x74, 0x42, 0x6c,
+	0x6f, 0x67, 0x49, 0x64, 0x22, 0x48, 0x0a, 0x08, 0x41, 0x63, 0x6c, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x1c, 0x0a, 0x09, 0x70, 0x72, 0x69, 0x6e, 0x63, 0x69, 0x70, 0x61, 0x6c, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x69, 0x6e, 0x63, 0x69, 0x70, 0x61, 0x6c, 0x12, 0x1e,
+	0x0a, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0a, 0x2e, 0x62,
+	0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x6f, 0x6c, 0x65, 0x52, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x22, 0x33,
+	0x0a, 0x11, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62,
+	0x6c, 0x6f, 0x67, 0x22, 0x34, 0x0a, 0x12, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f,
+	0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f,
+	0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42,
+	0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x42, 0x0a, 0x0f, 0x52, 0x65, 0x61,
+	0x64, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07,
+	0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62,
+	0x6c, 0x6f, 0x67, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65, 0x22, 0x32, 0x0a,
+	0x10, 0x52, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f,
+	0x67, 0x22, 0x60, 0x0a, 0x11, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67,
+	0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x12, 0x2b, 0x0a, 0x11, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x5f, 0x76, 0x69, 0x73, 0x69, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x08, 0x52, 0x10, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x56, 0x69, 0x73, 0x69, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x79, 0x22, 0x34, 0x0a, 0x12, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f,
+	0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f,
+	0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42,
+	0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x2c, 0x0a, 0x11, 0x44, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17,
+	0x0a, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x22, 0x2d, 0x0a, 0x12, 0x44, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x17, 0x0a,
+	0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x22, 0xac, 0x01, 0x0a, 0x0f, 0x4c, 0x69, 0x73, 0x74, 0x42,
+	0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x61, 0x75,
+	0x74, 0x68, 0x6f, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x61,
+	0x75, 0x74, 0x68, 0x6f, 0x72, 0x49, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x61, 0x66, 0x74, 0x65, 0x72,
+	0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x66, 0x74, 0x65, 0x72,
+	0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x61, 0x67, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74, 0x61, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x6f,
+	0x63, 0x61, 0x6c, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6c, 0x6f, 0x63, 0x61,
+	0x6c, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x6f, 0x6d, 0x69, 0x74, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0b, 0x6f, 0x6d, 0x69, 0x74, 0x43, 0x6f,
+	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22, 0x32, 0x0a, 0x10, 0x4c, 0x69, 0x73, 0x74, 0x42, 0x6c, 0x6f,
+	0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f,
+	0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42,
+	0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x8a, 0x01, 0x0a, 0x12, 0x52, 0x65,
+	0x61, 0x63, 0x74, 0x54, 0x6f, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x17, 0x0a, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x75, 0x73, 0x65,
+	0x72, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x75, 0x73, 0x65, 0x72,
+	0x49, 0x64, 0x12, 0x2a, 0x0a, 0x08, 0x72, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x0e, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x61, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x72, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16,
+	0x0a, 0x06, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06,
+	0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x22, 0x4f, 0x0a, 0x13, 0x52, 0x65, 0x61, 0x63, 0x74, 0x54,
+	0x6f, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a,
+	0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c,
+	0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x12, 0x18, 0x0a,
+	0x07, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
+	0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x22, 0x2c, 0x0a, 0x11, 0x52, 0x65, 0x63, 0x6f, 0x72,
+	0x64, 0x56, 0x69, 0x65, 0x77, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07,
+	0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62,
+	0x6c, 0x6f, 0x67, 0x49, 0x64, 0x22, 0x14, 0x0a, 0x12, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x56,
+	0x69, 0x65, 0x77, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0xeb, 0x01, 0x0a, 0x0e,
+	0x47, 0x65, 0x74, 0x46, 0x65, 0x65, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x28,
+	0x0a, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x10,
+	0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x46, 0x65, 0x65, 0x64, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74,
+	0x52, 0x06, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x61, 0x75, 0x74, 0x68,
+	0x6f, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x61, 0x75, 0x74,
+	0x68, 0x6f, 0x72, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x61, 0x67, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x74, 0x61, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x12, 0x22, 0x0a,
+	0x0d, 0x69, 0x66, 0x5f, 0x6e, 0x6f, 0x6e, 0x65, 0x5f, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x69, 0x66, 0x4e, 0x6f, 0x6e, 0x65, 0x4d, 0x61, 0x74, 0x63,
+	0x68, 0x12, 0x46, 0x0a, 0x11, 0x69, 0x66, 0x5f, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64,
+	0x5f, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0f, 0x69, 0x66, 0x4d, 0x6f, 0x64, 0x69,
+	0x66, 0x69, 0x65, 0x64, 0x53, 0x69, 0x6e, 0x63, 0x65, 0x22, 0xc0, 0x01, 0x0a, 0x0f, 0x47, 0x65,
+	0x74, 0x46, 0x65, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x21, 0x0a,
+	0x0c, 0x6e, 0x6f, 0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0b, 0x6e, 0x6f, 0x74, 0x4d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64,
+	0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x79, 0x70, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x54,
+	0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x04, 0x62, 0x6f, 0x64, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x65, 0x74, 0x61, 0x67, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x65, 0x74, 0x61, 0x67, 0x12, 0x3f, 0x0a, 0x0d, 0x6c,
+	0x61, 0x73, 0x74, 0x5f, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0c,
+	0x6c, 0x61, 0x73, 0x74, 0x4d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x65, 0x64, 0x22, 0x48, 0x0a, 0x17,
+	0x4c, 0x69, 0x73, 0x74, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x65, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64,
+	0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0x43, 0x0a, 0x0b, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x65,
+	0x64, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52,
+	0x04, 0x62, 0x6c, 0x6f, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x73, 0x63, 0x6f, 0x72, 0x65, 0x22, 0x47, 0x0a, 0x18, 0x4c,
+	0x69, 0x73, 0x74, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x65, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2b, 0x0a, 0x07, 0x72, 0x65, 0x6c, 0x61, 0x74,
+	0x65, 0x64, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e,
+	0x52, 0x65, 0x6c, 0x61, 0x74, 0x65, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x07, 0x72, 0x65, 0x6c,
+	0x61, 0x74, 0x65, 0x64, 0x22, 0x7d, 0x0a, 0x15, 0x50, 0x75, 0x74, 0x54, 0x72, 0x61, 0x6e, 0x73,
+	0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a,
+	0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65, 0x12, 0x33,
+	0x0a, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73,
+	0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x22, 0x38, 0x0a, 0x16, 0x50, 0x75, 0x74, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a,
+	0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c,
+	0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x4b, 0x0a,
+	0x18, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x62, 0x6c, 0x6f,
+	0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x67,
+	0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x6c, 0x6f, 0x63, 0x61, 0x6c, 0x65, 0x22, 0x3b, 0x0a, 0x19, 0x44, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f,
+	0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x51, 0x0a, 0x10, 0x53, 0x68, 0x61, 0x72, 0x65,
+	0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x62,
+	0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x6c,
+	0x6f, 0x67, 0x49, 0x64, 0x12, 0x24, 0x0a, 0x05, 0x65, 0x6e, 0x74, 0x72, 0x79, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0e, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x41, 0x63, 0x6c, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x05, 0x65, 0x6e, 0x74, 0x72, 0x79, 0x22, 0x33, 0x0a, 0x11, 0x53, 0x68,
+	0x61, 0x72, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e,
+	0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22,
+	0x4b, 0x0a, 0x12, 0x55, 0x6e, 0x73, 0x68, 0x61, 0x72, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x12, 0x1c,
+	0x0a, 0x09, 0x70, 0x72, 0x69, 0x6e, 0x63, 0x69, 0x70, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x09, 0x70, 0x72, 0x69, 0x6e, 0x63, 0x69, 0x70, 0x61, 0x6c, 0x22, 0x35, 0x0a, 0x13,
+	0x55, 0x6e, 0x73, 0x68, 0x61, 0x72, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62,
+	0x6c, 0x6f, 0x67, 0x22, 0xfe, 0x01, 0x0a, 0x06, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x12, 0x0e,
+	0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x1b,
+	0x0a, 0x09, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x08, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x74,
+	0x69, 0x74, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x69, 0x74, 0x6c,
+	0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x19, 0x0a, 0x08, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x73, 0x18,
+	0x05, 0x20, 0x03, 0x28, 0x09, 0x52, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x73, 0x12, 0x39,
+	0x0a, 0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09,
+	0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12, 0x39, 0x0a, 0x0a, 0x75, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x75, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x64, 0x41, 0x74, 0x22, 0x3b, 0x0a, 0x13, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65,
+	0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x24, 0x0a, 0x06, 0x73,
+	0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x62, 0x6c,
+	0x6f, 0x67, 0x2e, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x22, 0x3c, 0x0a, 0x14, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x24, 0x0a, 0x06, 0x73, 0x65, 0x72,
+	0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x62, 0x6c, 0x6f, 0x67,
+	0x2e, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x22,
+	0x2f, 0x0a, 0x10, 0x47, 0x65, 0x74, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x5f, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x49, 0x64,
+	0x22, 0x5b, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x24, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53, 0x65, 0x72,
+	0x69, 0x65, 0x73, 0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x12, 0x20, 0x0a, 0x05, 0x62,
+	0x6c, 0x6f, 0x67, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f,
+	0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x05, 0x62, 0x6c, 0x6f, 0x67, 0x73, 0x22, 0x3b, 0x0a,
+	0x13, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x24, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53, 0x65, 0x72, 0x69,
+	0x65, 0x73, 0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x22, 0x3c, 0x0a, 0x14, 0x55, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x24, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73,
+	0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x22, 0x32, 0x0a, 0x13, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x1b, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x49, 0x64, 0x22, 0x33, 0x0a, 0x14,
+	0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x5f, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x49,
+	0x64, 0x22, 0x66, 0x0a, 0x12, 0x41, 0x64, 0x64, 0x54, 0x6f, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x72, 0x69,
+	0x65, 0x73, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x08, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x3b, 0x0a, 0x13, 0x41, 0x64, 0x64,
+	0x54, 0x6f, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x24, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x0c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x06,
+	0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x22, 0x4f, 0x0a, 0x17, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65,
+	0x46, 0x72, 0x6f, 0x6d, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x49, 0x64, 0x12, 0x17,
+	0x0a, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x22, 0x40, 0x0a, 0x18, 0x52, 0x65, 0x6d, 0x6f, 0x76,
+	0x65, 0x46, 0x72, 0x6f, 0x6d, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x24, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x22, 0x4e, 0x0a, 0x14, 0x52, 0x65, 0x6f,
+	0x72, 0x64, 0x65, 0x72, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x49, 0x64, 0x12, 0x19,
+	0x0a, 0x08, 0x62, 0x6c, 0x6f, 0x67, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x07, 0x62, 0x6c, 0x6f, 0x67, 0x49, 0x64, 0x73, 0x22, 0x3d, 0x0a, 0x15, 0x52, 0x65, 0x6f,
+	0x72, 0x64, 0x65, 0x72, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x24, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73,
+	0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x22, 0x14, 0x0a, 0x12, 0x45, 0x78, 0x70, 0x6f,
+	0x72, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x5b,
+	0x0a, 0x13, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52,
+	0x04, 0x62, 0x6c, 0x6f, 0x67, 0x12, 0x24, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53, 0x65, 0x72,
+	0x69, 0x65, 0x73, 0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x22, 0x5f, 0x0a, 0x0d, 0x49,
+	0x6d, 0x70, 0x6f, 0x72, 0x74, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x35, 0x0a, 0x0b,
+	0x6f, 0x6e, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x14, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63,
+	0x74, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x52, 0x0a, 0x6f, 0x6e, 0x43, 0x6f, 0x6e, 0x66, 0x6c,
+	0x69, 0x63, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x64, 0x72, 0x79, 0x5f, 0x72, 0x75, 0x6e, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x22, 0x97, 0x01, 0x0a,
+	0x12, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x2f, 0x0a, 0x07, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x49, 0x6d, 0x70, 0x6f,
+	0x72, 0x74, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x48, 0x00, 0x52, 0x07, 0x6f, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x12, 0x20, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x48, 0x00,
+	0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x12, 0x26, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53, 0x65,
+	0x72, 0x69, 0x65, 0x73, 0x48, 0x00, 0x52, 0x06, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x42, 0x06,
+	0x0a, 0x04, 0x69, 0x74, 0x65, 0x6d, 0x22, 0x86, 0x02, 0x0a, 0x13, 0x49, 0x6d, 0x70, 0x6f, 0x72,
+	0x74, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18,
+	0x0a, 0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x12, 0x20, 0x0a, 0x0b, 0x6f, 0x76, 0x65, 0x72,
+	0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x6f,
+	0x76, 0x65, 0x72, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x6b,
+	0x69, 0x70, 0x70, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x73, 0x6b, 0x69,
+	0x70, 0x70, 0x65, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74,
+	0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x63, 0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63,
+	0x74, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x5f, 0x63, 0x72, 0x65,
+	0x61, 0x74, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x73, 0x65, 0x72, 0x69,
+	0x65, 0x73, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x12, 0x2d, 0x0a, 0x12, 0x73, 0x65, 0x72,
+	0x69, 0x65, 0x73, 0x5f, 0x6f, 0x76, 0x65, 0x72, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x11, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x4f, 0x76, 0x65,
+	0x72, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x12, 0x25, 0x0a, 0x0e, 0x73, 0x65, 0x72, 0x69,
+	0x65, 0x73, 0x5f, 0x73, 0x6b, 0x69, 0x70, 0x70, 0x65, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0d, 0x73, 0x65, 0x72, 0x69, 0x65, 0x73, 0x53, 0x6b, 0x69, 0x70, 0x70, 0x65, 0x64, 0x22,
+	0x4d, 0x0a, 0x1a, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x51, 0x75, 0x65, 0x75, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x19, 0x0a,
+	0x08, 0x61, 0x66, 0x74, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x61, 0x66, 0x74, 0x65, 0x72, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x22, 0x3d,
+	0x0a, 0x1b, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x51, 0x75, 0x65, 0x75, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1e, 0x0a,
+	0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x62, 0x6c,
+	0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x22, 0x65, 0x0a,
+	0x18, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x17, 0x0a, 0x07, 0x62, 0x6c, 0x6f,
+	0x67, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x67,
+	0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x07, 0x61, 0x70, 0x70, 0x72, 0x6f, 0x76, 0x65, 0x12, 0x16, 0x0a, 0x06,
+	0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65,
+	0x61, 0x73, 0x6f, 0x6e, 0x22, 0x3b, 0x0a, 0x19, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x4d,
+	0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x1e, 0x0a, 0x04, 0x62, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x04, 0x62, 0x6c, 0x6f,
+	0x67, 0x2a, 0x43, 0x0a, 0x10, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x53,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x0d, 0x0a, 0x09, 0x50, 0x55, 0x42, 0x4c, 0x49, 0x53, 0x48,
+	0x45, 0x44, 0x10, 0x00, 0x12, 0x12, 0x0a, 0x0e, 0x50, 0x45, 0x4e, 0x44, 0x49, 0x4e, 0x47, 0x5f,
+	0x52, 0x45, 0x56, 0x49, 0x45, 0x57, 0x10, 0x01, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x45, 0x4a, 0x45,
+	0x43, 0x54, 0x45, 0x44, 0x10, 0x02, 0x2a, 0x33, 0x0a, 0x0a, 0x56, 0x69, 0x73, 0x69, 0x62, 0x69,
+	0x6c, 0x69, 0x74, 0x79, 0x12, 0x0a, 0x0a, 0x06, 0x50, 0x55, 0x42, 0x4c, 0x49, 0x43, 0x10, 0x00,
+	0x12, 0x0c, 0x0a, 0x08, 0x55, 0x4e, 0x4c, 0x49, 0x53, 0x54, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0b,
+	0x0a, 0x07, 0x50, 0x52, 0x49, 0x56, 0x41, 0x54, 0x45, 0x10, 0x02, 0x2a, 0x34, 0x0a, 0x04, 0x52,
+	0x6f, 0x6c, 0x65, 0x12, 0x14, 0x0a, 0x10, 0x52, 0x4f, 0x4c, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50,
+	0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x52, 0x45, 0x41,
+	0x44, 0x45, 0x52, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x45, 0x44, 0x49, 0x54, 0x4f, 0x52, 0x10,
+	0x02, 0x2a, 0x38, 0x0a, 0x08, 0x52, 0x65, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a,
+	0x14, 0x52, 0x45, 0x41, 0x43, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43,
+	0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x4c, 0x49, 0x4b, 0x45, 0x10,
+	0x01, 0x12, 0x08, 0x0a, 0x04, 0x43, 0x4c, 0x41, 0x50, 0x10, 0x02, 0x2a, 0x2e, 0x0a, 0x0a, 0x46,
+	0x65, 0x65, 0x64, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x07, 0x0a, 0x03, 0x52, 0x53, 0x53,
+	0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x41, 0x54, 0x4f, 0x4d, 0x10, 0x01, 0x12, 0x0d, 0x0a, 0x09,
+	0x4a, 0x53, 0x4f, 0x4e, 0x5f, 0x46, 0x45, 0x45, 0x44, 0x10, 0x02, 0x2a, 0x4e, 0x0a, 0x0e, 0x43,
+	0x6f, 0x6e, 0x66, 0x6c, 0x69, 0x63, 0x74, 0x50, 0x6f, 0x6c, 0x69, 0x63, 0x79, 0x12, 0x11, 0x0a,
+	0x0d, 0x43, 0x4f, 0x4e, 0x46, 0x4c, 0x49, 0x43, 0x54, 0x5f, 0x46, 0x41, 0x49, 0x4c, 0x10, 0x00,
+	0x12, 0x11, 0x0a, 0x0d, 0x43, 0x4f, 0x4e, 0x46, 0x4c, 0x49, 0x43, 0x54, 0x5f, 0x53, 0x4b, 0x49,
+	0x50, 0x10, 0x01, 0x12, 0x16, 0x0a, 0x12, 0x43, 0x4f, 0x4e, 0x46, 0x4c, 0x49, 0x43, 0x54, 0x5f,
+	0x4f, 0x56, 0x45, 0x52, 0x57, 0x52, 0x49, 0x54, 0x45, 0x10, 0x02, 0x32, 0xfd, 0x06, 0x0a, 0x0b,
+	0x42, 0x6c, 0x6f, 0x67, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x3f, 0x0a, 0x0a, 0x43,
+	0x72, 0x65, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x67,
+	0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65,
+	0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x39, 0x0a, 0x08,
+	0x52, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x15, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e,
+	0x52, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x16, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3f, 0x0a, 0x0a, 0x55, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18,
+	0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3f, 0x0a, 0x0a, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x44, 0x65,
+	0x6c, 0x65, 0x74, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x42, 0x6c, 0x6f,
+	0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3b, 0x0a, 0x08, 0x4c, 0x69, 0x73,
+	0x74, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x15, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x4c, 0x69, 0x73,
+	0x74, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x16, 0x2e, 0x62,
+	0x6c, 0x6f, 0x67, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x42, 0x0a, 0x0b, 0x52, 0x65, 0x61, 0x63, 0x74, 0x54,
+	0x6f, 0x42, 0x6c, 0x6f, 0x67, 0x12, 0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x61,
+	0x63, 0x74, 0x54, 0x6f, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x19, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x61, 0x63, 0x74, 0x54, 0x6f, 0x42, 0x6c,
+	0x6f, 0x67, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3f, 0x0a, 0x0a, 0x52, 0x65,
+	0x63, 0x6f, 0x72, 0x64, 0x56, 0x69, 0x65, 0x77, 0x12, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e,
+	0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x56, 0x69, 0x65, 0x77, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x56,
+	0x69, 0x65, 0x77, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x36, 0x0a, 0x07, 0x47,
+	0x65, 0x74, 0x46, 0x65, 0x65, 0x64, 0x12, 0x14, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x47, 0x65,
+	0x74, 0x46, 0x65, 0x65, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x15, 0x2e, 0x62,
+	0x6c, 0x6f, 0x67, 0x2e, 0x47, 0x65, 0x74, 0x46, 0x65, 0x65, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x12, 0x51, 0x0a, 0x10, 0x4c, 0x69, 0x73, 0x74, 0x52, 0x65, 0x6c, 0x61, 0x74,
+	0x65, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x12, 0x1d, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x4c,
+	0x69, 0x73, 0x74, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x65, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x4c, 0x69,
+	0x73, 0x74, 0x52, 0x65, 0x6c, 0x61, 0x74, 0x65, 0x64, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3c, 0x0a, 0x09, 0x53, 0x68, 0x61, 0x72, 0x65, 0x42,
+	0x6c, 0x6f, 0x67, 0x12, 0x16, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x65,
+	0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x62, 0x6c,
+	0x6f, 0x67, 0x2e, 0x53, 0x68, 0x61, 0x72, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x42, 0x0a, 0x0b, 0x55, 0x6e, 0x73, 0x68, 0x61, 0x72, 0x65, 0x42,
+	0x6c, 0x6f, 0x67, 0x12, 0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x55, 0x6e, 0x73, 0x68, 0x61,
+	0x72, 0x65, 0x42, 0x6c, 0x6f, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e,
+	0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x55, 0x6e, 0x73, 0x68, 0x61, 0x72, 0x65, 0x42, 0x6c, 0x6f, 0x67,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4b, 0x0a, 0x0e, 0x50, 0x75, 0x74, 0x54,
+	0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1b, 0x2e, 0x62, 0x6c, 0x6f,
+	0x67, 0x2e, 0x50, 0x75, 0x74, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x50,
+	0x75, 0x74, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x54, 0x0a, 0x11, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54,
+	0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1e, 0x2e, 0x62, 0x6c, 0x6f,
+	0x67, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x62, 0x6c, 0x6f,
+	0x67, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x6c, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0x83, 0x04, 0x0a, 0x0d,
+	0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x45, 0x0a,
+	0x0c, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x12, 0x19, 0x2e,
+	0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e,
+	0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x3c, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x53, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x12, 0x16, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x47, 0x65, 0x74, 0x53, 0x65, 0x72, 0x69,
+	0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x62, 0x6c, 0x6f, 0x67,
+	0x2e, 0x47, 0x65, 0x74, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x45, 0x0a, 0x0c, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69,
+	0x65, 0x73, 0x12, 0x19, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e,
+	0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65,
+	0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x45, 0x0a, 0x0c, 0x44, 0x65, 0x6c,
+	0x65, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x12, 0x19, 0x2e, 0x62, 0x6c, 0x6f, 0x67,
+	0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x44, 0x65, 0x6c, 0x65,
+	0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x42, 0x0a, 0x0b, 0x41, 0x64, 0x64, 0x54, 0x6f, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x12,
+	0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x41, 0x64, 0x64, 0x54, 0x6f, 0x53, 0x65, 0x72, 0x69,
+	0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x62, 0x6c, 0x6f, 0x67,
+	0x2e, 0x41, 0x64, 0x64, 0x54, 0x6f, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x51, 0x0a, 0x10, 0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x46, 0x72,
+	0x6f, 0x6d, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x12, 0x1d, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e,
+	0x52, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x46, 0x72, 0x6f, 0x6d, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52,
+	0x65, 0x6d, 0x6f, 0x76, 0x65, 0x46, 0x72, 0x6f, 0x6d, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x48, 0x0a, 0x0d, 0x52, 0x65, 0x6f, 0x72, 0x64,
+	0x65, 0x72, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x12, 0x1a, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e,
+	0x52, 0x65, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x6f, 0x72,
+	0x64, 0x65, 0x72, 0x53, 0x65, 0x72, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x32, 0xd2, 0x02, 0x0a, 0x10, 0x42, 0x6c, 0x6f, 0x67, 0x41, 0x64, 0x6d, 0x69, 0x6e, 0x53,
+	0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x44, 0x0a, 0x0b, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74,
+	0x42, 0x6c, 0x6f, 0x67, 0x73, 0x12, 0x18, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x45, 0x78, 0x70,
+	0x6f, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x19, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x42, 0x6c, 0x6f,
+	0x67, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x12, 0x44, 0x0a, 0x0b,
+	0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x12, 0x18, 0x2e, 0x62, 0x6c,
+	0x6f, 0x67, 0x2e, 0x49, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x49, 0x6d, 0x70,
+	0x6f, 0x72, 0x74, 0x42, 0x6c, 0x6f, 0x67, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x28, 0x01, 0x12, 0x5c, 0x0a, 0x13, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x51, 0x75, 0x65, 0x75, 0x65, 0x12, 0x20, 0x2e, 0x62, 0x6c, 0x6f, 0x67,
+	0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x51,
+	0x75, 0x65, 0x75, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x62, 0x6c,
+	0x6f, 0x67, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x51, 0x75, 0x65, 0x75, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01,
+	0x12, 0x54, 0x0a, 0x11, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x76, 0x65, 0x4d, 0x6f, 0x64, 0x65, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1e, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x73,
+	0x6f, 0x6c, 0x76, 0x65, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x62, 0x6c, 0x6f, 0x67, 0x2e, 0x52, 0x65, 0x73,
+	0x6f, 0x6c, 0x76, 0x65, 0x4d, 0x6f, 0x64, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x0d, 0x5a, 0x0b, 0x62, 0x6c, 0x6f, 0x67, 0x2f, 0x62,
+	0x6c, 0x6f, 0x67, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_blog_blogpb_blog_proto_rawDescOnce sync.Once
+	file_blog_blogpb_blog_proto_rawDescData = file_blog_blogpb_blog_proto_rawDesc
+)
+
+func file_blog_blogpb_blog_proto_rawDescGZIP() []byte {
+	file_blog_blogpb_blog_proto_rawDescOnce.Do(func() {
+		file_blog_blogpb_blog_proto_rawDescData = protoimpl.X.CompressGZIP(file_blog_blogpb_blog_proto_rawDescData)
+	})
+	return file_blog_blogpb_blog_proto_rawDescData
+}
+
+var file_blog_blogpb_blog_proto_enumTypes = make([]protoimpl.EnumInfo, 6)
+var file_blog_blogpb_blog_proto_msgTypes = make([]protoimpl.MessageInfo, 58)
+var file_blog_blogpb_blog_proto_goTypes = []interface{}{
+	(ModerationStatus)(0),               // 0: blog.ModerationStatus
+	(Visibility)(0),                     // 1: blog.Visibility
+	(Role)(0),                           // 2: blog.Role
+	(Reaction)(0),                       // 3: blog.Reaction
+	(FeedFormat)(0),                     // 4: blog.FeedFormat
+	(ConflictPolicy)(0),                 // 5: blog.ConflictPolicy
+	(*Blog)(nil),                        // 6: blog.Blog
+	(*Translation)(nil),                 // 7: blog.Translation
+	(*Reactors)(nil),                    // 8: blog.Reactors
+	(*SeriesLink)(nil),                  // 9: blog.SeriesLink
+	(*AclEntry)(nil),                    // 10: blog.AclEntry
+	(*CreateBlogRequest)(nil),           // 11: blog.CreateBlogRequest
+	(*CreateBlogResponse)(nil),          // 12: blog.CreateBlogResponse
+	(*ReadBlogRequest)(nil),             // 13: blog.ReadBlogRequest
+	(*ReadBlogResponse)(nil),            // 14: blog.ReadBlogResponse
+	(*UpdateBlogRequest)(nil),           // 15: blog.UpdateBlogRequest
+	(*UpdateBlogResponse)(nil),          // 16: blog.UpdateBlogResponse
+	(*DeleteBlogRequest)(nil),           // 17: blog.DeleteBlogRequest
+	(*DeleteBlogResponse)(nil),          // 18: blog.DeleteBlogResponse
+	(*ListBlogRequest)(nil),             // 19: blog.ListBlogRequest
+	(*ListBlogResponse)(nil),            // 20: blog.ListBlogResponse
+	(*ReactToBlogRequest)(nil),          // 21: blog.ReactToBlogRequest
+	(*ReactToBlogResponse)(nil),         // 22: blog.ReactToBlogResponse
+	(*RecordViewRequest)(nil),           // 23: blog.RecordViewRequest
+	(*RecordViewResponse)(nil),          // 24: blog.RecordViewResponse
+	(*GetFeedRequest)(nil),              // 25: blog.GetFeedRequest
+	(*GetFeedResponse)(nil),             // 26: blog.GetFeedResponse
+	(*ListRelatedBlogsRequest)(nil),     // 27: blog.ListRelatedBlogsRequest
+	(*RelatedBlog)(nil),                 // 28: blog.RelatedBlog
+	(*ListRelatedBlogsResponse)(nil),    // 29: blog.ListRelatedBlogsResponse
+	(*PutTranslationRequest)(nil),       // 30: blog.PutTranslationRequest
+	(*PutTranslationResponse)(nil),      // 31: blog.PutTranslationResponse
+	(*DeleteTranslationRequest)(nil),    // 32: blog.DeleteTranslationRequest
+	(*DeleteTranslationResponse)(nil),   // 33: blog.DeleteTranslationResponse
+	(*ShareBlogRequest)(nil),            // 34: blog.ShareBlogRequest
+	(*ShareBlogResponse)(nil),           // 35: blog.ShareBlogResponse
+	(*UnshareBlogRequest)(nil),          // 36: blog.UnshareBlogRequest
+	(*UnshareBlogResponse)(nil),         // 37: blog.UnshareBlogResponse
+	(*Series)(nil),                      // 38: blog.Series
+	(*CreateSeriesRequest)(nil),         // 39: blog.CreateSeriesRequest
+	(*CreateSeriesResponse)(nil),        // 40: blog.CreateSeriesResponse
+	(*GetSeriesRequest)(nil),            // 41: blog.GetSeriesRequest
+	(*GetSeriesResponse)(nil),           // 42: blog.GetSeriesResponse
+	(*UpdateSeriesRequest)(nil),         // 43: blog.UpdateSeriesRequest
+	(*UpdateSeriesResponse)(nil),        // 44: blog.UpdateSeriesResponse
+	(*DeleteSeriesRequest)(nil),         // 45: blog.DeleteSeriesRequest
+	(*DeleteSeriesResponse)(nil),        // 46: blog.DeleteSeriesResponse
+	(*AddToSeriesRequest)(nil),          // 47: blog.AddToSeriesRequest
+	(*AddToSeriesResponse)(nil),         // 48: blog.AddToSeriesResponse
+	(*RemoveFromSeriesRequest)(nil),     // 49: blog.RemoveFromSeriesRequest
+	(*RemoveFromSeriesResponse)(nil),    // 50: blog.RemoveFromSeriesResponse
+	(*ReorderSeriesRequest)(nil),        // 51: blog.ReorderSeriesRequest
+	(*ReorderSeriesResponse)(nil),       // 52: blog.ReorderSeriesResponse
+	(*ExportBlogsRequest)(nil),          // 53: blog.ExportBlogsRequest
+	(*ExportBlogsResponse)(nil),         // 54: blog.ExportBlogsResponse
+	(*ImportOptions)(nil),               // 55: blog.ImportOptions
+	(*ImportBlogsRequest)(nil),          // 56: blog.ImportBlogsRequest
+	(*ImportBlogsResponse)(nil),         // 57: blog.ImportBlogsResponse
+	(*ListModerationQueueRequest)(nil),  // 58: blog.ListModerationQueueRequest
+	(*ListModerationQueueResponse)(nil), // 59: blog.ListModerationQueueResponse
+	(*ResolveModerationRequest)(nil),    // 60: blog.ResolveModerationRequest
+	(*ResolveModerationResponse)(nil),   // 61: blog.ResolveModerationResponse
+	nil,                                 // 62: blog.Blog.TranslationsEntry
+	nil,                                 // 63: blog.Blog.ReactorsEntry
+	(*timestamp.Timestamp)(nil),         // 64: google.protobuf.Timestamp
+}
+var file_blog_blogpb_blog_proto_depIdxs = []int32{
+	64, // 0: blog.Blog.created_at:type_name -> google.protobuf.Timestamp
+	64, // 1: blog.Blog.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 2: blog.Blog.moderation_status:type_name -> blog.ModerationStatus
+	1,  // 3: blog.Blog.visibility:type_name -> blog.Visibility
+	10, // 4: blog.Blog.acl:type_name -> blog.AclEntry
+	9,  // 5: blog.Blog.series:type_name -> blog.SeriesLink
+	62, // 6: blog.Blog.translations:type_name -> blog.Blog.TranslationsEntry
+	63, // 7: blog.Blog.reactors:type_name -> blog.Blog.ReactorsEntry
+	2,  // 8: blog.AclEntry.role:type_name -> blog.Role
+	6,  // 9: blog.CreateBlogRequest.blog:type_name -> blog.Blog
+	6,  // 10: blog.CreateBlogResponse.blog:type_name -> blog.Blog
+	6,  // 11: blog.ReadBlogResponse.blog:type_name -> blog.Blog
+	6,  // 12: blog.UpdateBlogRequest.blog:type_name -> blog.Blog
+	6,  // 13: blog.UpdateBlogResponse.blog:type_name -> blog.Blog
+	6,  // 14: blog.ListBlogResponse.blog:type_name -> blog.Blog
+	3,  // 15: blog.ReactToBlogRequest.reaction:type_name -> blog.Reaction
+	6,  // 16: blog.ReactToBlogResponse.blog:type_name -> blog.Blog
+	4,  // 17: blog.GetFeedRequest.format:type_name -> blog.FeedFormat
+	64, // 18: blog.GetFeedRequest.if_modified_since:type_name -> google.protobuf.Timestamp
+	64, // 19: blog.GetFeedResponse.last_modified:type_name -> google.protobuf.Timestamp
+	6,  // 20: blog.RelatedBlog.blog:type_name -> blog.Blog
+	28, // 21: blog.ListRelatedBlogsResponse.related:type_name -> blog.RelatedBlog
+	7,  // 22: blog.PutTranslationRequest.translation:type_name -> blog.Translation
+	6,  // 23: blog.PutTranslationResponse.blog:type_name -> blog.Blog
+	6,  // 24: blog.DeleteTranslationResponse.blog:type_name -> blog.Blog
+	10, // 25: blog.ShareBlogRequest.entry:type_name -> blog.AclEntry
+	6,  // 26: blog.ShareBlogResponse.blog:type_name -> blog.Blog
+	6,  // 27: blog.UnshareBlogResponse.blog:type_name -> blog.Blog
+	64, // 28: blog.Series.created_at:type_name -> google.protobuf.Timestamp
+	64, // 29: blog.Series.updated_at:type_name -> google.protobuf.Timestamp
+	38, // 30: blog.CreateSeriesRequest.series:type_name -> blog.Series
+	38, // 31: blog.CreateSeriesResponse.series:type_name -> blog.Series
+	38, // 32: blog.GetSeriesResponse.series:type_name -> blog.Series
+	6,  // 33: blog.GetSeriesResponse.blogs:type_name -> blog.Blog
+	38, // 34: blog.UpdateSeriesRequest.series:type_name -> blog.Series
+	38, // 35: blog.UpdateSeriesResponse.series:type_name -> blog.Series
+	38, // 36: blog.AddToSeriesResponse.series:type_name -> blog.Series
+	38, // 37: blog.RemoveFromSeriesResponse.series:type_name -> blog.Series
+	38, // 38: blog.ReorderSeriesResponse.series:type_name -> blog.Series
+	6,  // 39: blog.ExportBlogsResponse.blog:type_name -> blog.Blog
+	38, // 40: blog.ExportBlogsResponse.series:type_name -> blog.Series
+	5,  // 41: blog.ImportOptions.on_conflict:type_name -> blog.ConflictPolicy
+	55, // 42: blog.ImportBlogsRequest.options:type_name -> blog.ImportOptions
+	6,  // 43: blog.ImportBlogsRequest.blog:type_name -> blog.Blog
+	38, // 44: blog.ImportBlogsRequest.series:type_name -> blog.Series
+	6,  // 45: blog.ListModerationQueueResponse.blog:type_name -> blog.Blog
+	6,  // 46: blog.ResolveModerationResponse.blog:type_name -> blog.Blog
+	7,  // 47: blog.Blog.TranslationsEntry.value:type_name -> blog.Translation
+	8,  // 48: blog.Blog.ReactorsEntry.value:type_name -> blog.Reactors
+	11, // 49: blog.BlogService.CreateBlog:input_type -> blog.CreateBlogRequest
+	13, // 50: blog.BlogService.ReadBlog:input_type -> blog.ReadBlogRequest
+	15, // 51: blog.BlogService.UpdateBlog:input_type -> blog.UpdateBlogRequest
+	17, // 52: blog.BlogService.DeleteBlog:input_type -> blog.DeleteBlogRequest
+	19, // 53: blog.BlogService.ListBlog:input_type -> blog.ListBlogRequest
+	21, // 54: blog.BlogService.ReactToBlog:input_type -> blog.ReactToBlogRequest
+	23, // 55: blog.BlogService.RecordView:input_type -> blog.RecordViewRequest
+	25, // 56: blog.BlogService.GetFeed:input_type -> blog.GetFeedRequest
+	27, // 57: blog.BlogService.ListRelatedBlogs:input_type -> blog.ListRelatedBlogsRequest
+	34, // 58: blog.BlogService.ShareBlog:input_type -> blog.ShareBlogRequest
+	36, // 59: blog.BlogService.UnshareBlog:input_type -> blog.UnshareBlogRequest
+	30, // 60: blog.BlogService.PutTranslation:input_type -> blog.PutTranslationRequest
+	32, // 61: blog.BlogService.DeleteTranslation:input_type -> blog.DeleteTranslationRequest
+	39, // 62: blog.SeriesService.CreateSeries:input_type -> blog.CreateSeriesRequest
+	41, // 63: blog.SeriesService.GetSeries:input_type -> blog.GetSeriesRequest
+	43, // 64: blog.SeriesService.UpdateSeries:input_type -> blog.UpdateSeriesRequest
+	45, // 65: blog.SeriesService.DeleteSeries:input_type -> blog.DeleteSeriesRequest
+	47, // 66: blog.SeriesService.AddToSeries:input_type -> blog.AddToSeriesRequest
+	49, // 67: blog.SeriesService.RemoveFromSeries:input_type -> blog.RemoveFromSeriesRequest
+	51, // 68: blog.SeriesService.ReorderSeries:input_type -> blog.ReorderSeriesRequest
+	53, // 69: blog.BlogAdminService.ExportBlogs:input_type -> blog.ExportBlogsRequest
+	56, // 70: blog.BlogAdminService.ImportBlogs:input_type -> blog.ImportBlogsRequest
+	58, // 71: blog.BlogAdminService.ListModerationQueue:input_type -> blog.ListModerationQueueRequest
+	60, // 72: blog.BlogAdminService.ResolveModeration:input_type -> blog.ResolveModerationRequest
+	12, // 73: blog.BlogService.CreateBlog:output_type -> blog.CreateBlogResponse
+	14, // 74: blog.BlogService.ReadBlog:output_type -> blog.ReadBlogResponse
+	16, // 75: blog.BlogService.UpdateBlog:output_type -> blog.UpdateBlogResponse
+	18, // 76: blog.BlogService.DeleteBlog:output_type -> blog.DeleteBlogResponse
+	20, // 77: blog.BlogService.ListBlog:output_type -> blog.ListBlogResponse
+	22, // 78: blog.BlogService.ReactToBlog:output_type -> blog.ReactToBlogResponse
+	24, // 79: blog.BlogService.RecordView:output_type -> blog.RecordViewResponse
+	26, // 80: blog.BlogService.GetFeed:output_type -> blog.GetFeedResponse
+	29, // 81: blog.BlogService.ListRelatedBlogs:output_type -> blog.ListRelatedBlogsResponse
+	35, // 82: blog.BlogService.ShareBlog:output_type -> blog.ShareBlogResponse
+	37, // 83: blog.BlogService.UnshareBlog:output_type -> blog.UnshareBlogResponse
+	31, // 84: blog.BlogService.PutTranslation:output_type -> blog.PutTranslationResponse
+	33, // 85: blog.BlogService.DeleteTranslation:output_type -> blog.DeleteTranslationResponse
+	40, // 86: blog.SeriesService.CreateSeries:output_type -> blog.CreateSeriesResponse
+	42, // 87: blog.SeriesService.GetSeries:output_type -> blog.GetSeriesResponse
+	44, // 88: blog.SeriesService.UpdateSeries:output_type -> blog.UpdateSeriesResponse
+	46, // 89: blog.SeriesService.DeleteSeries:output_type -> blog.DeleteSeriesResponse
+	48, // 90: blog.SeriesService.AddToSeries:output_type -> blog.AddToSeriesResponse
+	50, // 91: blog.SeriesService.RemoveFromSeries:output_type -> blog.RemoveFromSeriesResponse
+	52, // 92: blog.SeriesService.ReorderSeries:output_type -> blog.ReorderSeriesResponse
+	54, // 93: blog.BlogAdminService.ExportBlogs:output_type -> blog.ExportBlogsResponse
+	57, // 94: blog.BlogAdminService.ImportBlogs:output_type -> blog.ImportBlogsResponse
+	59, // 95: blog.BlogAdminService.ListModerationQueue:output_type -> blog.ListModerationQueueResponse
+	61, // 96: blog.BlogAdminService.ResolveModeration:output_type -> blog.ResolveModerationResponse
+	73, // [73:97] is the sub-list for method output_type
+	49, // [49:73] is the sub-list for method input_type
+	49, // [49:49] is the sub-list for extension type_name
+	49, // [49:49] is the sub-list for extension extendee
+	0,  // [0:49] is the sub-list for field type_name
+}
+
+func init() { file_blog_blogpb_blog_proto_init() }
+func file_blog_blogpb_blog_proto_init() {
+	if File_blog_blogpb_blog_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_blog_blogpb_blog_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Blog); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Translation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Reactors); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SeriesLink); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AclEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateBlogRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateBlogResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReadBlogRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReadBlogResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateBlogRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateBlogResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteBlogRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteBlogResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListBlogRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListBlogResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReactToBlogRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReactToBlogResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RecordViewRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RecordViewResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetFeedRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetFeedResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListRelatedBlogsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RelatedBlog); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListRelatedBlogsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PutTranslationRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PutTranslationResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteTranslationRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteTranslationResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShareBlogRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ShareBlogResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[30].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UnshareBlogRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[31].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UnshareBlogResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[32].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Series); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[33].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateSeriesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[34].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateSeriesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[35].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetSeriesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[36].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetSeriesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[37].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateSeriesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[38].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*UpdateSeriesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[39].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteSeriesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[40].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeleteSeriesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[41].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AddToSeriesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[42].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AddToSeriesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[43].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveFromSeriesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[44].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RemoveFromSeriesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[45].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReorderSeriesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[46].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReorderSeriesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[47].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExportBlogsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[48].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExportBlogsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[49].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImportOptions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[50].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImportBlogsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[51].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImportBlogsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[52].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListModerationQueueRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[53].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListModerationQueueResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[54].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ResolveModerationRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_blog_blogpb_blog_proto_msgTypes[55].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ResolveModerationResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_blog_blogpb_blog_proto_msgTypes[50].OneofWrappers = []interface{}{
+		(*ImportBlogsRequest_Options)(nil),
+		(*ImportBlogsRequest_Blog)(nil),
+		(*ImportBlogsRequest_Series)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_blog_blogpb_blog_proto_rawDesc,
+			NumEnums:      6,
+			NumMessages:   58,
+			NumExtensions: 0,
+			NumServices:   3,
+		},
+		GoTypes:           file_blog_blogpb_blog_proto_goTypes,
+		DependencyIndexes: file_blog_blogpb_blog_proto_depIdxs,
+		EnumInfos:         file_blog_blogpb_blog_proto_enumTypes,
+		MessageInfos:      file_blog_blogpb_blog_proto_msgTypes,
+	}.Build()
+	File_blog_blogpb_blog_proto = out.File
 	file_blog_blogpb_blog_proto_rawDesc = nil
 	file_blog_blogpb_blog_proto_goTypes = nil
 	file_blog_blogpb_blog_proto_depIdxs = nil
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConnInterface
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// BlogServiceClient is the client API for BlogService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type BlogServiceClient interface {
+	// Blogs go through moderation: they may be rejected with INVALID_ARGUMENT
+	// or stored as PENDING_REVIEW, hidden from lists and feeds
+	CreateBlog(ctx context.Context, in *CreateBlogRequest, opts ...grpc.CallOption) (*CreateBlogResponse, error)
+	// Return NOT_FOUND if not found, or if pending review or rejected and the
+	// caller is not the author or an editor
+	ReadBlog(ctx context.Context, in *ReadBlogRequest, opts ...grpc.CallOption) (*ReadBlogResponse, error)
+	// Return NOT_FOUND if not found
+	// Return PERMISSION_DENIED if the caller is not the author or an editor,
+	// public blogs without author nor ACL can be changed by anyone
+	// Updated blogs are moderated again, like on create
+	UpdateBlog(ctx context.Context, in *UpdateBlogRequest, opts ...grpc.CallOption) (*UpdateBlogResponse, error)
+	// Return PERMISSION_DENIED if the caller is not the author or an editor,
+	// public blogs without author nor ACL can be deleted by anyone
+	DeleteBlog(ctx context.Context, in *DeleteBlogRequest, opts ...grpc.CallOption) (*DeleteBlogResponse, error)
+	// Lists the public blogs and the ones shared with the caller
+	ListBlog(ctx context.Context, in *ListBlogRequest, opts ...grpc.CallOption) (BlogService_ListBlogClient, error)
+	// Each user reacts at most once per reaction type, the user is the caller
+	// Return NOT_FOUND if not found
+	// Return UNAUTHENTICATED if the caller has no user ID
+	// Return PERMISSION_DENIED if user_id is not the caller
+	ReactToBlog(ctx context.Context, in *ReactToBlogRequest, opts ...grpc.CallOption) (*ReactToBlogResponse, error)
+	// Views are counted in batches, so they show up in the blog with a delay
+	// Return NOT_FOUND if not found
+	RecordView(ctx context.Context, in *RecordViewRequest, opts ...grpc.CallOption) (*RecordViewResponse, error)
+	// Renders the newest blogs as RSS 2.0, Atom or JSON Feed. The same feeds
+	// are served over HTTP by blog_server.
+	GetFeed(ctx context.Context, in *GetFeedRequest, opts ...grpc.CallOption) (*GetFeedResponse, error)
+	// Scores the published blogs by shared tags, same author and text
+	// similarity of the title and content
+	// Return NOT_FOUND if not found
+	ListRelatedBlogs(ctx context.Context, in *ListRelatedBlogsRequest, opts ...grpc.CallOption) (*ListRelatedBlogsResponse, error)
+	// Grants a role to a principal, replacing the role it had. Only the
+	// author and the editors can share a blog.
+	// Return NOT_FOUND if not found
+	// Return PERMISSION_DENIED if the caller cannot edit the blog
+	ShareBlog(ctx context.Context, in *ShareBlogRequest, opts ...grpc.CallOption) (*ShareBlogResponse, error)
+	// Return NOT_FOUND if not found
+	// Return PERMISSION_DENIED if the caller cannot edit the blog
+	UnshareBlog(ctx context.Context, in *UnshareBlogRequest, opts ...grpc.CallOption) (*UnshareBlogResponse, error)
+	// Adds or replaces a translation, which is moderated like the blog
+	// Return NOT_FOUND if not found
+	// Return PERMISSION_DENIED if the caller cannot edit the blog
+	PutTranslation(ctx context.Context, in *PutTranslationRequest, opts ...grpc.CallOption) (*PutTranslationResponse, error)
+	// Return NOT_FOUND if the blog or the translation is not found
+	// Return PERMISSION_DENIED if the caller cannot edit the blog
+	DeleteTranslation(ctx context.Context, in *DeleteTranslationRequest, opts ...grpc.CallOption) (*DeleteTranslationResponse, error)
+}
+
+type blogServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBlogServiceClient(cc grpc.ClientConnInterface) BlogServiceClient {
+	return &blogServiceClient{cc}
+}
+
+func (c *blogServiceClient) CreateBlog(ctx context.Context, in *CreateBlogRequest, opts ...grpc.CallOption) (*CreateBlogResponse, error) {
+	out := new(CreateBlogResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/CreateBlog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) ReadBlog(ctx context.Context, in *ReadBlogRequest, opts ...grpc.CallOption) (*ReadBlogResponse, error) {
+	out := new(ReadBlogResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/ReadBlog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) UpdateBlog(ctx context.Context, in *UpdateBlogRequest, opts ...grpc.CallOption) (*UpdateBlogResponse, error) {
+	out := new(UpdateBlogResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/UpdateBlog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) DeleteBlog(ctx context.Context, in *DeleteBlogRequest, opts ...grpc.CallOption) (*DeleteBlogResponse, error) {
+	out := new(DeleteBlogResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/DeleteBlog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) ListBlog(ctx context.Context, in *ListBlogRequest, opts ...grpc.CallOption) (BlogService_ListBlogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_BlogService_serviceDesc.Streams[0], "/blog.BlogService/ListBlog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &blogServiceListBlogClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type BlogService_ListBlogClient interface {
+	Recv() (*ListBlogResponse, error)
+	grpc.ClientStream
+}
+
+type blogServiceListBlogClient struct {
+	grpc.ClientStream
+}
+
+func (x *blogServiceListBlogClient) Recv() (*ListBlogResponse, error) {
+	m := new(ListBlogResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *blogServiceClient) ReactToBlog(ctx context.Context, in *ReactToBlogRequest, opts ...grpc.CallOption) (*ReactToBlogResponse, error) {
+	out := new(ReactToBlogResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/ReactToBlog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) RecordView(ctx context.Context, in *RecordViewRequest, opts ...grpc.CallOption) (*RecordViewResponse, error) {
+	out := new(RecordViewResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/RecordView", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) GetFeed(ctx context.Context, in *GetFeedRequest, opts ...grpc.CallOption) (*GetFeedResponse, error) {
+	out := new(GetFeedResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/GetFeed", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) ListRelatedBlogs(ctx context.Context, in *ListRelatedBlogsRequest, opts ...grpc.CallOption) (*ListRelatedBlogsResponse, error) {
+	out := new(ListRelatedBlogsResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/ListRelatedBlogs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) ShareBlog(ctx context.Context, in *ShareBlogRequest, opts ...grpc.CallOption) (*ShareBlogResponse, error) {
+	out := new(ShareBlogResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/ShareBlog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) UnshareBlog(ctx context.Context, in *UnshareBlogRequest, opts ...grpc.CallOption) (*UnshareBlogResponse, error) {
+	out := new(UnshareBlogResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/UnshareBlog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) PutTranslation(ctx context.Context, in *PutTranslationRequest, opts ...grpc.CallOption) (*PutTranslationResponse, error) {
+	out := new(PutTranslationResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/PutTranslation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blogServiceClient) DeleteTranslation(ctx context.Context, in *DeleteTranslationRequest, opts ...grpc.CallOption) (*DeleteTranslationResponse, error) {
+	out := new(DeleteTranslationResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogService/DeleteTranslation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BlogServiceServer is the server API for BlogService service.
+type BlogServiceServer interface {
+	// Blogs go through moderation: they may be rejected with INVALID_ARGUMENT
+	// or stored as PENDING_REVIEW, hidden from lists and feeds
+	CreateBlog(context.Context, *CreateBlogRequest) (*CreateBlogResponse, error)
+	// Return NOT_FOUND if not found, or if pending review or rejected and the
+	// caller is not the author or an editor
+	ReadBlog(context.Context, *ReadBlogRequest) (*ReadBlogResponse, error)
+	// Return NOT_FOUND if not found
+	// Return PERMISSION_DENIED if the caller is not the author or an editor,
+	// public blogs without author nor ACL can be changed by anyone
+	// Updated blogs are moderated again, like on create
+	UpdateBlog(context.Context, *UpdateBlogRequest) (*UpdateBlogResponse, error)
+	// Return PERMISSION_DENIED if the caller is not the author or an editor,
+	// public blogs without author nor ACL can be deleted by anyone
+	DeleteBlog(context.Context, *DeleteBlogRequest) (*DeleteBlogResponse, error)
+	// Lists the public blogs and the ones shared with the caller
+	ListBlog(*ListBlogRequest, BlogService_ListBlogServer) error
+	// Each user reacts at most once per reaction type, the user is the caller
+	// Return NOT_FOUND if not found
+	// Return UNAUTHENTICATED if the caller has no user ID
+	// Return PERMISSION_DENIED if user_id is not the caller
+	ReactToBlog(context.Context, *ReactToBlogRequest) (*ReactToBlogResponse, error)
+	// Views are counted in batches, so they show up in the blog with a delay
+	// Return NOT_FOUND if not found
+	RecordView(context.Context, *RecordViewRequest) (*RecordViewResponse, error)
+	// Renders the newest blogs as RSS 2.0, Atom or JSON Feed. The same feeds
+	// are served over HTTP by blog_server.
+	GetFeed(context.Context, *GetFeedRequest) (*GetFeedResponse, error)
+	// Scores the published blogs by shared tags, same author and text
+	// similarity of the title and content
+	// Return NOT_FOUND if not found
+	ListRelatedBlogs(context.Context, *ListRelatedBlogsRequest) (*ListRelatedBlogsResponse, error)
+	// Grants a role to a principal, replacing the role it had. Only the
+	// author and the editors can share a blog.
+	// Return NOT_FOUND if not found
+	// Return PERMISSION_DENIED if the caller cannot edit the blog
+	ShareBlog(context.Context, *ShareBlogRequest) (*ShareBlogResponse, error)
+	// Return NOT_FOUND if not found
+	// Return PERMISSION_DENIED if the caller cannot edit the blog
+	UnshareBlog(context.Context, *UnshareBlogRequest) (*UnshareBlogResponse, error)
+	// Adds or replaces a translation, which is moderated like the blog
+	// Return NOT_FOUND if not found
+	// Return PERMISSION_DENIED if the caller cannot edit the blog
+	PutTranslation(context.Context, *PutTranslationRequest) (*PutTranslationResponse, error)
+	// Return NOT_FOUND if the blog or the translation is not found
+	// Return PERMISSION_DENIED if the caller cannot edit the blog
+	DeleteTranslation(context.Context, *DeleteTranslationRequest) (*DeleteTranslationResponse, error)
+}
+
+// UnimplementedBlogServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedBlogServiceServer struct {
+}
+
+func (*UnimplementedBlogServiceServer) CreateBlog(context.Context, *CreateBlogRequest) (*CreateBlogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateBlog not implemented")
+}
+func (*UnimplementedBlogServiceServer) ReadBlog(context.Context, *ReadBlogRequest) (*ReadBlogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReadBlog not implemented")
+}
+func (*UnimplementedBlogServiceServer) UpdateBlog(context.Context, *UpdateBlogRequest) (*UpdateBlogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateBlog not implemented")
+}
+func (*UnimplementedBlogServiceServer) DeleteBlog(context.Context, *DeleteBlogRequest) (*DeleteBlogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteBlog not implemented")
+}
+func (*UnimplementedBlogServiceServer) ListBlog(*ListBlogRequest, BlogService_ListBlogServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListBlog not implemented")
+}
+func (*UnimplementedBlogServiceServer) ReactToBlog(context.Context, *ReactToBlogRequest) (*ReactToBlogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReactToBlog not implemented")
+}
+func (*UnimplementedBlogServiceServer) RecordView(context.Context, *RecordViewRequest) (*RecordViewResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RecordView not implemented")
+}
+func (*UnimplementedBlogServiceServer) GetFeed(context.Context, *GetFeedRequest) (*GetFeedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFeed not implemented")
+}
+func (*UnimplementedBlogServiceServer) ListRelatedBlogs(context.Context, *ListRelatedBlogsRequest) (*ListRelatedBlogsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRelatedBlogs not implemented")
+}
+func (*UnimplementedBlogServiceServer) ShareBlog(context.Context, *ShareBlogRequest) (*ShareBlogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShareBlog not implemented")
+}
+func (*UnimplementedBlogServiceServer) UnshareBlog(context.Context, *UnshareBlogRequest) (*UnshareBlogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnshareBlog not implemented")
+}
+func (*UnimplementedBlogServiceServer) PutTranslation(context.Context, *PutTranslationRequest) (*PutTranslationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutTranslation not implemented")
+}
+func (*UnimplementedBlogServiceServer) DeleteTranslation(context.Context, *DeleteTranslationRequest) (*DeleteTranslationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTranslation not implemented")
+}
+
+func RegisterBlogServiceServer(s *grpc.Server, srv BlogServiceServer) {
+	s.RegisterService(&_BlogService_serviceDesc, srv)
+}
+
+func _BlogService_CreateBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateBlogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).CreateBlog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/CreateBlog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).CreateBlog(ctx, req.(*CreateBlogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_ReadBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReadBlogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).ReadBlog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/ReadBlog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).ReadBlog(ctx, req.(*ReadBlogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_UpdateBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateBlogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).UpdateBlog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/UpdateBlog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).UpdateBlog(ctx, req.(*UpdateBlogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_DeleteBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteBlogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).DeleteBlog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/DeleteBlog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).DeleteBlog(ctx, req.(*DeleteBlogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_ListBlog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListBlogRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BlogServiceServer).ListBlog(m, &blogServiceListBlogServer{stream})
+}
+
+type BlogService_ListBlogServer interface {
+	Send(*ListBlogResponse) error
+	grpc.ServerStream
+}
+
+type blogServiceListBlogServer struct {
+	grpc.ServerStream
+}
+
+func (x *blogServiceListBlogServer) Send(m *ListBlogResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _BlogService_ReactToBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReactToBlogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).ReactToBlog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/ReactToBlog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).ReactToBlog(ctx, req.(*ReactToBlogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_RecordView_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RecordViewRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).RecordView(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/RecordView",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).RecordView(ctx, req.(*RecordViewRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_GetFeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).GetFeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/GetFeed",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).GetFeed(ctx, req.(*GetFeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_ListRelatedBlogs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRelatedBlogsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).ListRelatedBlogs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/ListRelatedBlogs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).ListRelatedBlogs(ctx, req.(*ListRelatedBlogsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_ShareBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShareBlogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).ShareBlog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/ShareBlog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).ShareBlog(ctx, req.(*ShareBlogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_UnshareBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnshareBlogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).UnshareBlog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/UnshareBlog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).UnshareBlog(ctx, req.(*UnshareBlogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_PutTranslation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutTranslationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).PutTranslation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/PutTranslation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).PutTranslation(ctx, req.(*PutTranslationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlogService_DeleteTranslation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTranslationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogServiceServer).DeleteTranslation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogService/DeleteTranslation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogServiceServer).DeleteTranslation(ctx, req.(*DeleteTranslationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _BlogService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "blog.BlogService",
+	HandlerType: (*BlogServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateBlog",
+			Handler:    _BlogService_CreateBlog_Handler,
+		},
+		{
+			MethodName: "ReadBlog",
+			Handler:    _BlogService_ReadBlog_Handler,
+		},
+		{
+			MethodName: "UpdateBlog",
+			Handler:    _BlogService_UpdateBlog_Handler,
+		},
+		{
+			MethodName: "DeleteBlog",
+			Handler:    _BlogService_DeleteBlog_Handler,
+		},
+		{
+			MethodName: "ReactToBlog",
+			Handler:    _BlogService_ReactToBlog_Handler,
+		},
+		{
+			MethodName: "RecordView",
+			Handler:    _BlogService_RecordView_Handler,
+		},
+		{
+			MethodName: "GetFeed",
+			Handler:    _BlogService_GetFeed_Handler,
+		},
+		{
+			MethodName: "ListRelatedBlogs",
+			Handler:    _BlogService_ListRelatedBlogs_Handler,
+		},
+		{
+			MethodName: "ShareBlog",
+			Handler:    _BlogService_ShareBlog_Handler,
+		},
+		{
+			MethodName: "UnshareBlog",
+			Handler:    _BlogService_UnshareBlog_Handler,
+		},
+		{
+			MethodName: "PutTranslation",
+			Handler:    _BlogService_PutTranslation_Handler,
+		},
+		{
+			MethodName: "DeleteTranslation",
+			Handler:    _BlogService_DeleteTranslation_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListBlog",
+			Handler:       _BlogService_ListBlog_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "blog/blogpb/blog.proto",
+}
+
+// SeriesServiceClient is the client API for SeriesService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type SeriesServiceClient interface {
+	// Return FAILED_PRECONDITION if a blog already belongs to another series
+	CreateSeries(ctx context.Context, in *CreateSeriesRequest, opts ...grpc.CallOption) (*CreateSeriesResponse, error)
+	// Return NOT_FOUND if not found
+	GetSeries(ctx context.Context, in *GetSeriesRequest, opts ...grpc.CallOption) (*GetSeriesResponse, error)
+	// Changes the title and description
+	// Return NOT_FOUND if not found
+	UpdateSeries(ctx context.Context, in *UpdateSeriesRequest, opts ...grpc.CallOption) (*UpdateSeriesResponse, error)
+	// The blogs are kept, only the series is deleted
+	// Return NOT_FOUND if not found
+	DeleteSeries(ctx context.Context, in *DeleteSeriesRequest, opts ...grpc.CallOption) (*DeleteSeriesResponse, error)
+	// Return NOT_FOUND if the series or the blog is not found
+	// Return ALREADY_EXISTS if the blog is already in this series
+	// Return FAILED_PRECONDITION if the blog belongs to another series
+	AddToSeries(ctx context.Context, in *AddToSeriesRequest, opts ...grpc.CallOption) (*AddToSeriesResponse, error)
+	// Return NOT_FOUND if not found or the blog is not in the series
+	RemoveFromSeries(ctx context.Context, in *RemoveFromSeriesRequest, opts ...grpc.CallOption) (*RemoveFromSeriesResponse, error)
+	// Return INVALID_ARGUMENT if blog_ids is not a permutation of the blogs
+	ReorderSeries(ctx context.Context, in *ReorderSeriesRequest, opts ...grpc.CallOption) (*ReorderSeriesResponse, error)
+}
+
+type seriesServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSeriesServiceClient(cc grpc.ClientConnInterface) SeriesServiceClient {
+	return &seriesServiceClient{cc}
+}
+
+func (c *seriesServiceClient) CreateSeries(ctx context.Context, in *CreateSeriesRequest, opts ...grpc.CallOption) (*CreateSeriesResponse, error) {
+	out := new(CreateSeriesResponse)
+	err := c.cc.Invoke(ctx, "/blog.SeriesService/CreateSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seriesServiceClient) GetSeries(ctx context.Context, in *GetSeriesRequest, opts ...grpc.CallOption) (*GetSeriesResponse, error) {
+	out := new(GetSeriesResponse)
+	err := c.cc.Invoke(ctx, "/blog.SeriesService/GetSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seriesServiceClient) UpdateSeries(ctx context.Context, in *UpdateSeriesRequest, opts ...grpc.CallOption) (*UpdateSeriesResponse, error) {
+	out := new(UpdateSeriesResponse)
+	err := c.cc.Invoke(ctx, "/blog.SeriesService/UpdateSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seriesServiceClient) DeleteSeries(ctx context.Context, in *DeleteSeriesRequest, opts ...grpc.CallOption) (*DeleteSeriesResponse, error) {
+	out := new(DeleteSeriesResponse)
+	err := c.cc.Invoke(ctx, "/blog.SeriesService/DeleteSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seriesServiceClient) AddToSeries(ctx context.Context, in *AddToSeriesRequest, opts ...grpc.CallOption) (*AddToSeriesResponse, error) {
+	out := new(AddToSeriesResponse)
+	err := c.cc.Invoke(ctx, "/blog.SeriesService/AddToSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seriesServiceClient) RemoveFromSeries(ctx context.Context, in *RemoveFromSeriesRequest, opts ...grpc.CallOption) (*RemoveFromSeriesResponse, error) {
+	out := new(RemoveFromSeriesResponse)
+	err := c.cc.Invoke(ctx, "/blog.SeriesService/RemoveFromSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *seriesServiceClient) ReorderSeries(ctx context.Context, in *ReorderSeriesRequest, opts ...grpc.CallOption) (*ReorderSeriesResponse, error) {
+	out := new(ReorderSeriesResponse)
+	err := c.cc.Invoke(ctx, "/blog.SeriesService/ReorderSeries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SeriesServiceServer is the server API for SeriesService service.
+type SeriesServiceServer interface {
+	// Return FAILED_PRECONDITION if a blog already belongs to another series
+	CreateSeries(context.Context, *CreateSeriesRequest) (*CreateSeriesResponse, error)
+	// Return NOT_FOUND if not found
+	GetSeries(context.Context, *GetSeriesRequest) (*GetSeriesResponse, error)
+	// Changes the title and description
+	// Return NOT_FOUND if not found
+	UpdateSeries(context.Context, *UpdateSeriesRequest) (*UpdateSeriesResponse, error)
+	// The blogs are kept, only the series is deleted
+	// Return NOT_FOUND if not found
+	DeleteSeries(context.Context, *DeleteSeriesRequest) (*DeleteSeriesResponse, error)
+	// Return NOT_FOUND if the series or the blog is not found
+	// Return ALREADY_EXISTS if the blog is already in this series
+	// Return FAILED_PRECONDITION if the blog belongs to another series
+	AddToSeries(context.Context, *AddToSeriesRequest) (*AddToSeriesResponse, error)
+	// Return NOT_FOUND if not found or the blog is not in the series
+	RemoveFromSeries(context.Context, *RemoveFromSeriesRequest) (*RemoveFromSeriesResponse, error)
+	// Return INVALID_ARGUMENT if blog_ids is not a permutation of the blogs
+	ReorderSeries(context.Context, *ReorderSeriesRequest) (*ReorderSeriesResponse, error)
+}
+
+// UnimplementedSeriesServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedSeriesServiceServer struct {
+}
+
+func (*UnimplementedSeriesServiceServer) CreateSeries(context.Context, *CreateSeriesRequest) (*CreateSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSeries not implemented")
+}
+func (*UnimplementedSeriesServiceServer) GetSeries(context.Context, *GetSeriesRequest) (*GetSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSeries not implemented")
+}
+func (*UnimplementedSeriesServiceServer) UpdateSeries(context.Context, *UpdateSeriesRequest) (*UpdateSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSeries not implemented")
+}
+func (*UnimplementedSeriesServiceServer) DeleteSeries(context.Context, *DeleteSeriesRequest) (*DeleteSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSeries not implemented")
+}
+func (*UnimplementedSeriesServiceServer) AddToSeries(context.Context, *AddToSeriesRequest) (*AddToSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddToSeries not implemented")
+}
+func (*UnimplementedSeriesServiceServer) RemoveFromSeries(context.Context, *RemoveFromSeriesRequest) (*RemoveFromSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveFromSeries not implemented")
+}
+func (*UnimplementedSeriesServiceServer) ReorderSeries(context.Context, *ReorderSeriesRequest) (*ReorderSeriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReorderSeries not implemented")
+}
+
+func RegisterSeriesServiceServer(s *grpc.Server, srv SeriesServiceServer) {
+	s.RegisterService(&_SeriesService_serviceDesc, srv)
+}
+
+func _SeriesService_CreateSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeriesServiceServer).CreateSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.SeriesService/CreateSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeriesServiceServer).CreateSeries(ctx, req.(*CreateSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeriesService_GetSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeriesServiceServer).GetSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.SeriesService/GetSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeriesServiceServer).GetSeries(ctx, req.(*GetSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeriesService_UpdateSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeriesServiceServer).UpdateSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.SeriesService/UpdateSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeriesServiceServer).UpdateSeries(ctx, req.(*UpdateSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeriesService_DeleteSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeriesServiceServer).DeleteSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.SeriesService/DeleteSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeriesServiceServer).DeleteSeries(ctx, req.(*DeleteSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeriesService_AddToSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddToSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeriesServiceServer).AddToSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.SeriesService/AddToSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeriesServiceServer).AddToSeries(ctx, req.(*AddToSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeriesService_RemoveFromSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveFromSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeriesServiceServer).RemoveFromSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.SeriesService/RemoveFromSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeriesServiceServer).RemoveFromSeries(ctx, req.(*RemoveFromSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SeriesService_ReorderSeries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReorderSeriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SeriesServiceServer).ReorderSeries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.SeriesService/ReorderSeries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SeriesServiceServer).ReorderSeries(ctx, req.(*ReorderSeriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion6
+var _SeriesService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "blog.SeriesService",
+	HandlerType: (*SeriesServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSeries",
+			Handler:    _SeriesService_CreateSeries_Handler,
+		},
+		{
+			MethodName: "GetSeries",
+			Handler:    _SeriesService_GetSeries_Handler,
+		},
+		{
+			MethodName: "UpdateSeries",
+			Handler:    _SeriesService_UpdateSeries_Handler,
+		},
+		{
+			MethodName: "DeleteSeries",
+			Handler:    _SeriesService_DeleteSeries_Handler,
+		},
+		{
+			MethodName: "AddToSeries",
+			Handler:    _SeriesService_AddToSeries_Handler,
+		},
+		{
+			MethodName: "RemoveFromSeries",
+			Handler:    _SeriesService_RemoveFromSeries_Handler,
+		},
+		{
+			MethodName: "ReorderSeries",
+			Handler:    _SeriesService_ReorderSeries_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "blog/blogpb/blog.proto",
+}
 
-// BlogServiceClient is the client API for BlogService service.
+// BlogAdminServiceClient is the client API for BlogAdminService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type BlogServiceClient interface {
-	CreateBlog(ctx context.Context, in *CreateBlogRequest, opts ...grpc.CallOption) (*CreateBlogResponse, error)
+type BlogAdminServiceClient interface {
+	// Streams every blog ordered by ID, including the server-side timestamps
+	// and the reactors, then every series. The blogs and the series are not
+	// read from a single snapshot: a write made during the export may be
+	// missing from the archive or, for a series, refer to a blog exported
+	// before it changed. Stop the writes for an exact copy.
+	ExportBlogs(ctx context.Context, in *ExportBlogsRequest, opts ...grpc.CallOption) (BlogAdminService_ExportBlogsClient, error)
+	// Return ALREADY_EXISTS on the first conflict when on_conflict is CONFLICT_FAIL
+	// Return FAILED_PRECONDITION if a blog of a series belongs to another one
+	ImportBlogs(ctx context.Context, opts ...grpc.CallOption) (BlogAdminService_ImportBlogsClient, error)
+	// Streams the blogs waiting for a review, oldest first
+	ListModerationQueue(ctx context.Context, in *ListModerationQueueRequest, opts ...grpc.CallOption) (BlogAdminService_ListModerationQueueClient, error)
 	// Return NOT_FOUND if not found
-	ReadBlog(ctx context.Context, in *ReadBlogRequest, opts ...grpc.CallOption) (*ReadBlogResponse, error)
-	// Return NOT_FOUND if not found
-	UpdateBlog(ctx context.Context, in *UpdateBlogRequest, opts ...grpc.CallOption) (*UpdateBlogResponse, error)
-	DeleteBlog(ctx context.Context, in *DeleteBlogRequest, opts ...grpc.CallOption) (*DeleteBlogResponse, error)
-	ListBlog(ctx context.Context, in *ListBlogRequest, opts ...grpc.CallOption) (BlogService_ListBlogClient, error)
+	// Return FAILED_PRECONDITION if the blog is not pending review
+	ResolveModeration(ctx context.Context, in *ResolveModerationRequest, opts ...grpc.CallOption) (*ResolveModerationResponse, error)
 }
 
-type blogServiceClient struct {
+type blogAdminServiceClient struct {
 	cc grpc.ClientConnInterface
 }
 
-func NewBlogServiceClient(cc grpc.ClientConnInterface) BlogServiceClient {
-	return &blogServiceClient{cc}
+func NewBlogAdminServiceClient(cc grpc.ClientConnInterface) BlogAdminServiceClient {
+	return &blogAdminServiceClient{cc}
 }
 
-func (c *blogServiceClient) CreateBlog(ctx context.Context, in *CreateBlogRequest, opts ...grpc.CallOption) (*CreateBlogResponse, error) {
-	out := new(CreateBlogResponse)
-	err := c.cc.Invoke(ctx, "/blog.BlogService/CreateBlog", in, out, opts...)
+func (c *blogAdminServiceClient) ExportBlogs(ctx context.Context, in *ExportBlogsRequest, opts ...grpc.CallOption) (BlogAdminService_ExportBlogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_BlogAdminService_serviceDesc.Streams[0], "/blog.BlogAdminService/ExportBlogs", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &blogAdminServiceExportBlogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
 }
 
-func (c *blogServiceClient) ReadBlog(ctx context.Context, in *ReadBlogRequest, opts ...grpc.CallOption) (*ReadBlogResponse, error) {
-	out := new(ReadBlogResponse)
-	err := c.cc.Invoke(ctx, "/blog.BlogService/ReadBlog", in, out, opts...)
-	if err != nil {
+type BlogAdminService_ExportBlogsClient interface {
+	Recv() (*ExportBlogsResponse, error)
+	grpc.ClientStream
+}
+
+type blogAdminServiceExportBlogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *blogAdminServiceExportBlogsClient) Recv() (*ExportBlogsResponse, error) {
+	m := new(ExportBlogsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	return out, nil
+	return m, nil
 }
 
-func (c *blogServiceClient) UpdateBlog(ctx context.Context, in *UpdateBlogRequest, opts ...grpc.CallOption) (*UpdateBlogResponse, error) {
-	out := new(UpdateBlogResponse)
-	err := c.cc.Invoke(ctx, "/blog.BlogService/UpdateBlog", in, out, opts...)
+func (c *blogAdminServiceClient) ImportBlogs(ctx context.Context, opts ...grpc.CallOption) (BlogAdminService_ImportBlogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_BlogAdminService_serviceDesc.Streams[1], "/blog.BlogAdminService/ImportBlogs", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &blogAdminServiceImportBlogsClient{stream}
+	return x, nil
 }
 
-func (c *blogServiceClient) DeleteBlog(ctx context.Context, in *DeleteBlogRequest, opts ...grpc.CallOption) (*DeleteBlogResponse, error) {
-	out := new(DeleteBlogResponse)
-	err := c.cc.Invoke(ctx, "/blog.BlogService/DeleteBlog", in, out, opts...)
-	if err != nil {
+type BlogAdminService_ImportBlogsClient interface {
+	Send(*ImportBlogsRequest) error
+	CloseAndRecv() (*ImportBlogsResponse, error)
+	grpc.ClientStream
+}
+
+type blogAdminServiceImportBlogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *blogAdminServiceImportBlogsClient) Send(m *ImportBlogsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *blogAdminServiceImportBlogsClient) CloseAndRecv() (*ImportBlogsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
 		return nil, err
 	}
-	return out, nil
+	m := new(ImportBlogsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-func (c *blogServiceClient) ListBlog(ctx context.Context, in *ListBlogRequest, opts ...grpc.CallOption) (BlogService_ListBlogClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_BlogService_serviceDesc.Streams[0], "/blog.BlogService/ListBlog", opts...)
+func (c *blogAdminServiceClient) ListModerationQueue(ctx context.Context, in *ListModerationQueueRequest, opts ...grpc.CallOption) (BlogAdminService_ListModerationQueueClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_BlogAdminService_serviceDesc.Streams[2], "/blog.BlogAdminService/ListModerationQueue", opts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &blogServiceListBlogClient{stream}
+	x := &blogAdminServiceListModerationQueueClient{stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -915,176 +6098,180 @@ func (c *blogServiceClient) ListBlog(ctx context.Context, in *ListBlogRequest, o
 	return x, nil
 }
 
-type BlogService_ListBlogClient interface {
-	Recv() (*ListBlogResponse, error)
+type BlogAdminService_ListModerationQueueClient interface {
+	Recv() (*ListModerationQueueResponse, error)
 	grpc.ClientStream
 }
 
-type blogServiceListBlogClient struct {
+type blogAdminServiceListModerationQueueClient struct {
 	grpc.ClientStream
 }
 
-func (x *blogServiceListBlogClient) Recv() (*ListBlogResponse, error) {
-	m := new(ListBlogResponse)
+func (x *blogAdminServiceListModerationQueueClient) Recv() (*ListModerationQueueResponse, error) {
+	m := new(ListModerationQueueResponse)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-// BlogServiceServer is the server API for BlogService service.
-type BlogServiceServer interface {
-	CreateBlog(context.Context, *CreateBlogRequest) (*CreateBlogResponse, error)
-	// Return NOT_FOUND if not found
-	ReadBlog(context.Context, *ReadBlogRequest) (*ReadBlogResponse, error)
-	// Return NOT_FOUND if not found
-	UpdateBlog(context.Context, *UpdateBlogRequest) (*UpdateBlogResponse, error)
-	DeleteBlog(context.Context, *DeleteBlogRequest) (*DeleteBlogResponse, error)
-	ListBlog(*ListBlogRequest, BlogService_ListBlogServer) error
+func (c *blogAdminServiceClient) ResolveModeration(ctx context.Context, in *ResolveModerationRequest, opts ...grpc.CallOption) (*ResolveModerationResponse, error) {
+	out := new(ResolveModerationResponse)
+	err := c.cc.Invoke(ctx, "/blog.BlogAdminService/ResolveModeration", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
-// UnimplementedBlogServiceServer can be embedded to have forward compatible implementations.
-type UnimplementedBlogServiceServer struct {
+// BlogAdminServiceServer is the server API for BlogAdminService service.
+type BlogAdminServiceServer interface {
+	// Streams every blog ordered by ID, including the server-side timestamps
+	// and the reactors, then every series. The blogs and the series are not
+	// read from a single snapshot: a write made during the export may be
+	// missing from the archive or, for a series, refer to a blog exported
+	// before it changed. Stop the writes for an exact copy.
+	ExportBlogs(*ExportBlogsRequest, BlogAdminService_ExportBlogsServer) error
+	// Return ALREADY_EXISTS on the first conflict when on_conflict is CONFLICT_FAIL
+	// Return FAILED_PRECONDITION if a blog of a series belongs to another one
+	ImportBlogs(BlogAdminService_ImportBlogsServer) error
+	// Streams the blogs waiting for a review, oldest first
+	ListModerationQueue(*ListModerationQueueRequest, BlogAdminService_ListModerationQueueServer) error
+	// Return NOT_FOUND if not found
+	// Return FAILED_PRECONDITION if the blog is not pending review
+	ResolveModeration(context.Context, *ResolveModerationRequest) (*ResolveModerationResponse, error)
 }
 
-func (*UnimplementedBlogServiceServer) CreateBlog(context.Context, *CreateBlogRequest) (*CreateBlogResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method CreateBlog not implemented")
+// UnimplementedBlogAdminServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedBlogAdminServiceServer struct {
 }
-func (*UnimplementedBlogServiceServer) ReadBlog(context.Context, *ReadBlogRequest) (*ReadBlogResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method ReadBlog not implemented")
+
+func (*UnimplementedBlogAdminServiceServer) ExportBlogs(*ExportBlogsRequest, BlogAdminService_ExportBlogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportBlogs not implemented")
 }
-func (*UnimplementedBlogServiceServer) UpdateBlog(context.Context, *UpdateBlogRequest) (*UpdateBlogResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method UpdateBlog not implemented")
+func (*UnimplementedBlogAdminServiceServer) ImportBlogs(BlogAdminService_ImportBlogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportBlogs not implemented")
 }
-func (*UnimplementedBlogServiceServer) DeleteBlog(context.Context, *DeleteBlogRequest) (*DeleteBlogResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method DeleteBlog not implemented")
+func (*UnimplementedBlogAdminServiceServer) ListModerationQueue(*ListModerationQueueRequest, BlogAdminService_ListModerationQueueServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListModerationQueue not implemented")
 }
-func (*UnimplementedBlogServiceServer) ListBlog(*ListBlogRequest, BlogService_ListBlogServer) error {
-	return status.Errorf(codes.Unimplemented, "method ListBlog not implemented")
+func (*UnimplementedBlogAdminServiceServer) ResolveModeration(context.Context, *ResolveModerationRequest) (*ResolveModerationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResolveModeration not implemented")
 }
 
-func RegisterBlogServiceServer(s *grpc.Server, srv BlogServiceServer) {
-	s.RegisterService(&_BlogService_serviceDesc, srv)
+func RegisterBlogAdminServiceServer(s *grpc.Server, srv BlogAdminServiceServer) {
+	s.RegisterService(&_BlogAdminService_serviceDesc, srv)
 }
 
-func _BlogService_CreateBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CreateBlogRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(BlogServiceServer).CreateBlog(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/blog.BlogService/CreateBlog",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(BlogServiceServer).CreateBlog(ctx, req.(*CreateBlogRequest))
+func _BlogAdminService_ExportBlogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportBlogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
 	}
-	return interceptor(ctx, in, info, handler)
+	return srv.(BlogAdminServiceServer).ExportBlogs(m, &blogAdminServiceExportBlogsServer{stream})
 }
 
-func _BlogService_ReadBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ReadBlogRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(BlogServiceServer).ReadBlog(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/blog.BlogService/ReadBlog",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(BlogServiceServer).ReadBlog(ctx, req.(*ReadBlogRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+type BlogAdminService_ExportBlogsServer interface {
+	Send(*ExportBlogsResponse) error
+	grpc.ServerStream
 }
 
-func _BlogService_UpdateBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(UpdateBlogRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(BlogServiceServer).UpdateBlog(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/blog.BlogService/UpdateBlog",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(BlogServiceServer).UpdateBlog(ctx, req.(*UpdateBlogRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+type blogAdminServiceExportBlogsServer struct {
+	grpc.ServerStream
 }
 
-func _BlogService_DeleteBlog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteBlogRequest)
-	if err := dec(in); err != nil {
+func (x *blogAdminServiceExportBlogsServer) Send(m *ExportBlogsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _BlogAdminService_ImportBlogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(BlogAdminServiceServer).ImportBlogs(&blogAdminServiceImportBlogsServer{stream})
+}
+
+type BlogAdminService_ImportBlogsServer interface {
+	SendAndClose(*ImportBlogsResponse) error
+	Recv() (*ImportBlogsRequest, error)
+	grpc.ServerStream
+}
+
+type blogAdminServiceImportBlogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *blogAdminServiceImportBlogsServer) SendAndClose(m *ImportBlogsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *blogAdminServiceImportBlogsServer) Recv() (*ImportBlogsRequest, error) {
+	m := new(ImportBlogsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(BlogServiceServer).DeleteBlog(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/blog.BlogService/DeleteBlog",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(BlogServiceServer).DeleteBlog(ctx, req.(*DeleteBlogRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+	return m, nil
 }
 
-func _BlogService_ListBlog_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(ListBlogRequest)
+func _BlogAdminService_ListModerationQueue_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListModerationQueueRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(BlogServiceServer).ListBlog(m, &blogServiceListBlogServer{stream})
+	return srv.(BlogAdminServiceServer).ListModerationQueue(m, &blogAdminServiceListModerationQueueServer{stream})
 }
 
-type BlogService_ListBlogServer interface {
-	Send(*ListBlogResponse) error
+type BlogAdminService_ListModerationQueueServer interface {
+	Send(*ListModerationQueueResponse) error
 	grpc.ServerStream
 }
 
-type blogServiceListBlogServer struct {
+type blogAdminServiceListModerationQueueServer struct {
 	grpc.ServerStream
 }
 
-func (x *blogServiceListBlogServer) Send(m *ListBlogResponse) error {
+func (x *blogAdminServiceListModerationQueueServer) Send(m *ListModerationQueueResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-var _BlogService_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "blog.BlogService",
-	HandlerType: (*BlogServiceServer)(nil),
+func _BlogAdminService_ResolveModeration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResolveModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlogAdminServiceServer).ResolveModeration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/blog.BlogAdminService/ResolveModeration",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlogAdminServiceServer).ResolveModeration(ctx, req.(*ResolveModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _BlogAdminService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "blog.BlogAdminService",
+	HandlerType: (*BlogAdminServiceServer)(nil),
 	Methods: []grpc.MethodDesc{
 		{
-			MethodName: "CreateBlog",
-			Handler:    _BlogService_CreateBlog_Handler,
-		},
-		{
-			MethodName: "ReadBlog",
-			Handler:    _BlogService_ReadBlog_Handler,
+			MethodName: "ResolveModeration",
+			Handler:    _BlogAdminService_ResolveModeration_Handler,
 		},
+	},
+	Streams: []grpc.StreamDesc{
 		{
-			MethodName: "UpdateBlog",
-			Handler:    _BlogService_UpdateBlog_Handler,
+			StreamName:    "ExportBlogs",
+			Handler:       _BlogAdminService_ExportBlogs_Handler,
+			ServerStreams: true,
 		},
 		{
-			MethodName: "DeleteBlog",
-			Handler:    _BlogService_DeleteBlog_Handler,
+			StreamName:    "ImportBlogs",
+			Handler:       _BlogAdminService_ImportBlogs_Handler,
+			ClientStreams: true,
 		},
-	},
-	Streams: []grpc.StreamDesc{
 		{
-			StreamName:    "ListBlog",
-			Handler:       _BlogService_ListBlog_Handler,
+			StreamName:    "ListModerationQueue",
+			Handler:       _BlogAdminService_ListModerationQueue_Handler,
 			ServerStreams: true,
 		},
 	},
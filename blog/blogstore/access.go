@@ -0,0 +1,113 @@
+package blogstore
+
+import "strings"
+
+// Visibilities. Public blogs have an empty visibility, so that blogs stored
+// before visibilities existed stay public.
+const (
+	// VisibilityUnlisted blogs can be read by anyone knowing their ID but
+	// are only listed to the author and the principals of their ACL
+	VisibilityUnlisted = "unlisted"
+	// VisibilityPrivate blogs are only visible to the author and the
+	// principals of their ACL
+	VisibilityPrivate = "private"
+)
+
+// Roles granted by an ACL entry
+const (
+	RoleReader = "reader"
+	// RoleEditor can also update the blog and manage its ACL
+	RoleEditor = "editor"
+)
+
+// Principal prefixes, e.g. "user:alice" or "group:staff"
+const (
+	UserPrincipal  = "user:"
+	GroupPrincipal = "group:"
+)
+
+// ACLEntry grants a role on a blog to a principal
+type ACLEntry struct {
+	Principal string `bson:"principal"`
+	Role      string `bson:"role"`
+}
+
+// Viewer is the identity blogs are read with
+type Viewer struct {
+	// UserId is empty for anonymous callers
+	UserId string
+	Groups []string
+}
+
+// Principals returns the principals the viewer matches in an ACL
+func (v *Viewer) Principals() []string {
+	var principals []string
+	if v.UserId != "" {
+		principals = append(principals, UserPrincipal+v.UserId)
+	}
+	for _, group := range v.Groups {
+		principals = append(principals, GroupPrincipal+group)
+	}
+	return principals
+}
+
+// role returns the best role the viewer has on the item, the author being
+// an editor
+func (v *Viewer) role(item *Item) string {
+	if v.UserId != "" && item.AuthorId == v.UserId {
+		return RoleEditor
+	}
+	role := ""
+	for _, principal := range v.Principals() {
+		for _, entry := range item.ACL {
+			if entry.Principal != principal {
+				continue
+			}
+			if entry.Role == RoleEditor {
+				return RoleEditor
+			}
+			role = entry.Role
+		}
+	}
+	return role
+}
+
+// CanList reports whether the item is listed to the viewer
+func (v *Viewer) CanList(item *Item) bool {
+	return item.Visibility == "" || v.role(item) != ""
+}
+
+// CanRead reports whether the viewer can read the item by its ID
+func (v *Viewer) CanRead(item *Item) bool {
+	return item.Visibility != VisibilityPrivate || v.role(item) != ""
+}
+
+// CanEdit reports whether the viewer can update the item: its author and
+// editors. Legacy blogs, public and stored without an author nor an ACL,
+// cannot be claimed by anyone so they stay editable by every caller.
+func (v *Viewer) CanEdit(item *Item) bool {
+	if item.AuthorId == "" && item.Visibility == "" && len(item.ACL) == 0 {
+		return true
+	}
+	return v.IsEditor(item)
+}
+
+// IsEditor reports whether the viewer is the author or an editor of the item
+func (v *Viewer) IsEditor(item *Item) bool {
+	return v.role(item) == RoleEditor
+}
+
+// ValidPrincipal reports whether the principal is a user or a group with a
+// name usable in the stores
+func ValidPrincipal(principal string) bool {
+	var name string
+	switch {
+	case strings.HasPrefix(principal, UserPrincipal):
+		name = strings.TrimPrefix(principal, UserPrincipal)
+	case strings.HasPrefix(principal, GroupPrincipal):
+		name = strings.TrimPrefix(principal, GroupPrincipal)
+	default:
+		return false
+	}
+	return name != "" && !strings.ContainsAny(name, ",=")
+}
@@ -0,0 +1,62 @@
+package blogstore_test
+
+import (
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+)
+
+func TestViewerAccess(t *testing.T) {
+	acl := []blogstore.ACLEntry{
+		{Principal: "user:rita", Role: blogstore.RoleReader},
+		{Principal: "user:eve", Role: blogstore.RoleEditor},
+		{Principal: "group:staff", Role: blogstore.RoleEditor},
+	}
+	var (
+		anonymous = &blogstore.Viewer{}
+		author    = &blogstore.Viewer{UserId: "ana"}
+		reader    = &blogstore.Viewer{UserId: "rita"}
+		editor    = &blogstore.Viewer{UserId: "eve"}
+		staff     = &blogstore.Viewer{UserId: "sam", Groups: []string{"staff"}}
+		stranger  = &blogstore.Viewer{UserId: "bob"}
+	)
+
+	tests := []struct {
+		name   string
+		item   blogstore.Item
+		viewer *blogstore.Viewer
+		list   bool
+		read   bool
+		edit   bool
+	}{
+		{"public, author", blogstore.Item{AuthorId: "ana"}, author, true, true, true},
+		{"public, stranger", blogstore.Item{AuthorId: "ana"}, stranger, true, true, false},
+		{"public, anonymous", blogstore.Item{AuthorId: "ana"}, anonymous, true, true, false},
+		{"public, reader", blogstore.Item{AuthorId: "ana", ACL: acl}, reader, true, true, false},
+		{"public, editor", blogstore.Item{AuthorId: "ana", ACL: acl}, editor, true, true, true},
+		{"public, group editor", blogstore.Item{AuthorId: "ana", ACL: acl}, staff, true, true, true},
+		{"unlisted, stranger", blogstore.Item{AuthorId: "ana", Visibility: blogstore.VisibilityUnlisted}, stranger, false, true, false},
+		{"unlisted, reader", blogstore.Item{AuthorId: "ana", Visibility: blogstore.VisibilityUnlisted, ACL: acl}, reader, true, true, false},
+		{"private, stranger", blogstore.Item{AuthorId: "ana", Visibility: blogstore.VisibilityPrivate}, stranger, false, false, false},
+		{"private, author", blogstore.Item{AuthorId: "ana", Visibility: blogstore.VisibilityPrivate}, author, true, true, true},
+		{"private, reader", blogstore.Item{AuthorId: "ana", Visibility: blogstore.VisibilityPrivate, ACL: acl}, reader, true, true, false},
+		{"private, editor", blogstore.Item{AuthorId: "ana", Visibility: blogstore.VisibilityPrivate, ACL: acl}, editor, true, true, true},
+		// an anonymous caller is not the author of the blogs without one
+		{"private without an author, anonymous", blogstore.Item{Visibility: blogstore.VisibilityPrivate}, anonymous, false, false, false},
+		{"legacy, anonymous", blogstore.Item{}, anonymous, true, true, true},
+		{"legacy, stranger", blogstore.Item{}, stranger, true, true, true},
+		{"no author with an ACL, stranger", blogstore.Item{ACL: acl}, stranger, true, true, false},
+		{"no author with an ACL, editor", blogstore.Item{ACL: acl}, editor, true, true, true},
+	}
+	for _, tt := range tests {
+		if got := tt.viewer.CanList(&tt.item); got != tt.list {
+			t.Errorf("%v: CanList = %v, want %v", tt.name, got, tt.list)
+		}
+		if got := tt.viewer.CanRead(&tt.item); got != tt.read {
+			t.Errorf("%v: CanRead = %v, want %v", tt.name, got, tt.read)
+		}
+		if got := tt.viewer.CanEdit(&tt.item); got != tt.edit {
+			t.Errorf("%v: CanEdit = %v, want %v", tt.name, got, tt.edit)
+		}
+	}
+}
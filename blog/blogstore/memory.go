@@ -0,0 +1,660 @@
+package blogstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryStore struct {
+	mu    sync.RWMutex
+	items map[primitive.ObjectID]*Item
+	// reactors holds the users who reacted, per blog and reaction kind
+	reactors map[primitive.ObjectID]map[string]map[string]bool
+	series   map[primitive.ObjectID]*Series
+}
+
+// NewMemoryStore returns a Store that keeps the blogs in memory. It is
+// meant for development and tests, data is lost when the process exits.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		items:    map[primitive.ObjectID]*Item{},
+		reactors: map[primitive.ObjectID]map[string]map[string]bool{},
+		series:   map[primitive.ObjectID]*Series{},
+	}
+}
+
+func (s *memoryStore) Create(ctx context.Context, item *Item) (*Item, error) {
+	data := item.clone()
+	data.ID = primitive.NewObjectID()
+	data.CreatedAt = now()
+	data.UpdatedAt = data.CreatedAt
+
+	s.mu.Lock()
+	s.items[data.ID] = data
+	s.mu.Unlock()
+
+	return data.clone(), nil
+}
+
+func (s *memoryStore) Read(ctx context.Context, id string) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.items[oid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data.clone(), nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, id string, item *Item) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.items[oid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	updateItem(data, item)
+
+	return data.clone(), nil
+}
+
+// updateItem copies the fields replaced by Update
+func updateItem(data, item *Item) {
+	data.AuthorId = item.AuthorId
+	data.Title = item.Title
+	data.Content = item.Content
+	data.TextStats = item.TextStats
+	data.Tags = append([]string(nil), item.Tags...)
+	data.ModerationStatus = item.ModerationStatus
+	data.ModerationReason = item.ModerationReason
+	data.Visibility = item.Visibility
+	data.Locale = item.Locale
+	data.UpdatedAt = now()
+}
+
+func copyTranslations(translations map[string]Translation) map[string]Translation {
+	if len(translations) == 0 {
+		return nil
+	}
+	result := make(map[string]Translation, len(translations))
+	for k, v := range translations {
+		result[k] = v
+	}
+	return result
+}
+
+func (s *memoryStore) SetModeration(ctx context.Context, id, status, reason string) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.items[oid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data.ModerationStatus = status
+	data.ModerationReason = reason
+
+	return data.clone(), nil
+}
+
+func (s *memoryStore) SetACL(ctx context.Context, id string, acl []ACLEntry) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.items[oid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data.ACL = append([]ACLEntry(nil), acl...)
+
+	return data.clone(), nil
+}
+
+func (s *memoryStore) SetTranslations(ctx context.Context, id string, translations map[string]Translation) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.items[oid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data.Translations = copyTranslations(translations)
+	data.UpdatedAt = now()
+
+	return data.clone(), nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, id string) error {
+	oid, err := ParseID(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[oid]; !ok {
+		return ErrNotFound
+	}
+	delete(s.items, oid)
+	delete(s.reactors, oid)
+	return nil
+}
+
+func (s *memoryStore) Put(ctx context.Context, item *Item, overwrite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[item.ID]; ok && !overwrite {
+		return ErrAlreadyExists
+	}
+	data := item.clone()
+	data.Reactors = nil
+	s.items[item.ID] = data
+	s.reactors[item.ID] = reactorSets(item.Reactors)
+	return nil
+}
+
+// reactorSets converts Item.Reactors to the sets kept by the store
+func reactorSets(reactors map[string][]string) map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(reactors))
+	for reaction, users := range reactors {
+		sets[reaction] = make(map[string]bool, len(users))
+		for _, user := range users {
+			sets[reaction][user] = true
+		}
+	}
+	return sets
+}
+
+// reactorLists converts the sets kept by the store to Item.Reactors
+func reactorLists(sets map[string]map[string]bool) map[string][]string {
+	var reactors map[string][]string
+	for reaction, users := range sets {
+		if len(users) == 0 {
+			continue
+		}
+		if reactors == nil {
+			reactors = map[string][]string{}
+		}
+		list := make([]string, 0, len(users))
+		for user := range users {
+			list = append(list, user)
+		}
+		sort.Strings(list)
+		reactors[reaction] = list
+	}
+	return reactors
+}
+
+func (s *memoryStore) List(ctx context.Context, opts ListOptions, fn func(*Item) error) error {
+	var after string
+	if opts.AfterID != "" {
+		oid, err := ParseID(opts.AfterID)
+		if err != nil {
+			return err
+		}
+		after = oid.Hex()
+	}
+
+	items := s.snapshot(opts.Reactors)
+	if opts.Descending {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	count := 0
+	for _, data := range items {
+		if opts.Limit > 0 && count >= opts.Limit {
+			break
+		}
+		if id := data.ID.Hex(); after != "" && ((!opts.Descending && id <= after) || (opts.Descending && id >= after)) {
+			continue
+		}
+		if opts.AuthorId != "" && data.AuthorId != opts.AuthorId {
+			continue
+		}
+		if !matchesModeration(data, opts.Moderation) {
+			continue
+		}
+		if opts.Tag != "" && !hasTag(data, opts.Tag) {
+			continue
+		}
+		if !matchesViewer(data, opts.Viewer) {
+			continue
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+		count++
+	}
+	return nil
+}
+
+// snapshot copies the items sorted by ID so callbacks run without the
+// lock, with their reactors if requested
+func (s *memoryStore) snapshot(reactors bool) []*Item {
+	s.mu.RLock()
+	items := make([]*Item, 0, len(s.items))
+	for oid, data := range s.items {
+		data = data.clone()
+		if reactors {
+			data.Reactors = reactorLists(s.reactors[oid])
+		}
+		items = append(items, data)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID.Hex() < items[j].ID.Hex()
+	})
+	return items
+}
+
+func (s *memoryStore) React(ctx context.Context, id, user, reaction string, remove bool) (*Item, bool, error) {
+	if !validReaction(reaction) {
+		return nil, false, ErrInvalidReaction
+	}
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.items[oid]
+	if !ok {
+		return nil, false, ErrNotFound
+	}
+
+	if s.reactors[oid] == nil {
+		s.reactors[oid] = map[string]map[string]bool{}
+	}
+	users := s.reactors[oid][reaction]
+	if users == nil {
+		users = map[string]bool{}
+		s.reactors[oid][reaction] = users
+	}
+
+	if users[user] != remove {
+		return data.clone(), false, nil
+	}
+
+	if data.Reactions == nil {
+		data.Reactions = map[string]int64{}
+	}
+	if remove {
+		delete(users, user)
+		data.Reactions[reaction]--
+	} else {
+		users[user] = true
+		data.Reactions[reaction]++
+	}
+
+	return data.clone(), true, nil
+}
+
+func (s *memoryStore) AddViews(ctx context.Context, views map[string]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, n := range views {
+		oid, err := ParseID(id)
+		if err != nil {
+			continue
+		}
+		if data, ok := s.items[oid]; ok {
+			data.Views += n
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) CreateSeries(ctx context.Context, series *Series) (*Series, error) {
+	data := series.clone()
+	data.ID = primitive.NewObjectID()
+	data.CreatedAt = now()
+	data.UpdatedAt = data.CreatedAt
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := checkSeriesBlogs(s.series, data); err != nil {
+		return nil, err
+	}
+	s.series[data.ID] = data
+	return data.clone(), nil
+}
+
+func (s *memoryStore) ReadSeries(ctx context.Context, id string) (*Series, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readSeries(s.series, id)
+}
+
+func (s *memoryStore) UpdateSeries(ctx context.Context, id string, series *Series) (*Series, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return updateSeries(s.series, id, series)
+}
+
+func (s *memoryStore) DeleteSeries(ctx context.Context, id string) error {
+	oid, err := ParseID(id)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.series[oid]; !ok {
+		return ErrSeriesNotFound
+	}
+	delete(s.series, oid)
+	return nil
+}
+
+func (s *memoryStore) FindSeries(ctx context.Context, blogID string) (*Series, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return findSeries(s.series, blogID)
+}
+
+func (s *memoryStore) ListSeries(ctx context.Context, fn func(*Series) error) error {
+	s.mu.RLock()
+	all := make([]*Series, 0, len(s.series))
+	for _, data := range s.series {
+		all = append(all, data.clone())
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ID.Hex() < all[j].ID.Hex()
+	})
+	for _, data := range all {
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) PutSeries(ctx context.Context, series *Series, overwrite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.series[series.ID]; ok && !overwrite {
+		return ErrSeriesExists
+	}
+	if err := checkSeriesBlogs(s.series, series); err != nil {
+		return err
+	}
+	s.series[series.ID] = series.clone()
+	return nil
+}
+
+func readSeries(all map[primitive.ObjectID]*Series, id string) (*Series, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := all[oid]
+	if !ok {
+		return nil, ErrSeriesNotFound
+	}
+	return data.clone(), nil
+}
+
+// updateSeries modifies the series in place, the map must be writable
+func updateSeries(all map[primitive.ObjectID]*Series, id string, series *Series) (*Series, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := all[oid]
+	if !ok {
+		return nil, ErrSeriesNotFound
+	}
+	updated := series.clone()
+	updated.ID = oid
+	if err := checkSeriesBlogs(all, updated); err != nil {
+		return nil, err
+	}
+
+	data.Title = updated.Title
+	data.Description = updated.Description
+	data.BlogIDs = updated.BlogIDs
+	data.UpdatedAt = now()
+	return data.clone(), nil
+}
+
+func findSeries(all map[primitive.ObjectID]*Series, blogID string) (*Series, error) {
+	for _, data := range all {
+		if data.Position(blogID) >= 0 {
+			return data.clone(), nil
+		}
+	}
+	return nil, ErrSeriesNotFound
+}
+
+// checkSeriesBlogs fails if another series holds one of the blogs
+func checkSeriesBlogs(all map[primitive.ObjectID]*Series, series *Series) error {
+	for oid, data := range all {
+		if oid == series.ID {
+			continue
+		}
+		for _, id := range series.BlogIDs {
+			if data.Position(id) >= 0 {
+				return ErrInSeries
+			}
+		}
+	}
+	return nil
+}
+
+// RunInTx holds the store lock while fn runs, so transactions are
+// serialized with every other call. The writes are staged and only copied
+// to the store when fn succeeds.
+func (s *memoryStore) RunInTx(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memoryTx{
+		store:    s,
+		staged:   map[primitive.ObjectID]*Item{},
+		reactors: map[primitive.ObjectID]map[string]map[string]bool{},
+	}
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+
+	if tx.series != nil {
+		s.series = tx.series
+	}
+	for oid, data := range tx.staged {
+		if data == nil {
+			delete(s.items, oid)
+			delete(s.reactors, oid)
+			continue
+		}
+		s.items[oid] = data
+		if reactors, ok := tx.reactors[oid]; ok {
+			s.reactors[oid] = reactors
+		}
+	}
+	return nil
+}
+
+// memoryTx reads through its staged writes, a nil item marks a deletion.
+// Series are few, the first write copies all of them.
+type memoryTx struct {
+	store  *memoryStore
+	staged map[primitive.ObjectID]*Item
+	// reactors holds the reactors replaced by Put
+	reactors map[primitive.ObjectID]map[string]map[string]bool
+	series   map[primitive.ObjectID]*Series
+}
+
+func (tx *memoryTx) allSeries() map[primitive.ObjectID]*Series {
+	if tx.series != nil {
+		return tx.series
+	}
+	return tx.store.series
+}
+
+func (tx *memoryTx) ReadSeries(ctx context.Context, id string) (*Series, error) {
+	return readSeries(tx.allSeries(), id)
+}
+
+func (tx *memoryTx) UpdateSeries(ctx context.Context, id string, series *Series) (*Series, error) {
+	if tx.series == nil {
+		tx.series = make(map[primitive.ObjectID]*Series, len(tx.store.series))
+		for oid, data := range tx.store.series {
+			tx.series[oid] = data.clone()
+		}
+	}
+	return updateSeries(tx.series, id, series)
+}
+
+func (tx *memoryTx) FindSeries(ctx context.Context, blogID string) (*Series, error) {
+	return findSeries(tx.allSeries(), blogID)
+}
+
+func (tx *memoryTx) get(oid primitive.ObjectID) (*Item, bool) {
+	if data, ok := tx.staged[oid]; ok {
+		return data, data != nil
+	}
+	data, ok := tx.store.items[oid]
+	return data, ok
+}
+
+// stage returns a copy of the item the transaction can modify
+func (tx *memoryTx) stage(id string) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := tx.get(oid)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if _, ok := tx.staged[oid]; !ok {
+		data = data.clone()
+		tx.staged[oid] = data
+	}
+	return data, nil
+}
+
+func (tx *memoryTx) Read(ctx context.Context, id string) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := tx.get(oid)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data.clone(), nil
+}
+
+func (tx *memoryTx) Update(ctx context.Context, id string, item *Item) (*Item, error) {
+	data, err := tx.stage(id)
+	if err != nil {
+		return nil, err
+	}
+	updateItem(data, item)
+	return data.clone(), nil
+}
+
+func (tx *memoryTx) SetModeration(ctx context.Context, id, status, reason string) (*Item, error) {
+	data, err := tx.stage(id)
+	if err != nil {
+		return nil, err
+	}
+	data.ModerationStatus = status
+	data.ModerationReason = reason
+	return data.clone(), nil
+}
+
+func (tx *memoryTx) SetACL(ctx context.Context, id string, acl []ACLEntry) (*Item, error) {
+	data, err := tx.stage(id)
+	if err != nil {
+		return nil, err
+	}
+	data.ACL = append([]ACLEntry(nil), acl...)
+	return data.clone(), nil
+}
+
+func (tx *memoryTx) SetTranslations(ctx context.Context, id string, translations map[string]Translation) (*Item, error) {
+	data, err := tx.stage(id)
+	if err != nil {
+		return nil, err
+	}
+	data.Translations = copyTranslations(translations)
+	data.UpdatedAt = now()
+	return data.clone(), nil
+}
+
+func (tx *memoryTx) Delete(ctx context.Context, id string) error {
+	oid, err := ParseID(id)
+	if err != nil {
+		return err
+	}
+	if _, ok := tx.get(oid); !ok {
+		return ErrNotFound
+	}
+	tx.staged[oid] = nil
+	return nil
+}
+
+func (tx *memoryTx) Put(ctx context.Context, item *Item, overwrite bool) error {
+	if _, ok := tx.get(item.ID); ok && !overwrite {
+		return ErrAlreadyExists
+	}
+	data := item.clone()
+	data.Reactors = nil
+	tx.staged[item.ID] = data
+	tx.reactors[item.ID] = reactorSets(item.Reactors)
+	return nil
+}
+
+// Migrate is a no-op, there is no persisted schema to upgrade
+func (s *memoryStore) Migrate(ctx context.Context, opts MigrateOptions) ([]MigrationStep, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) Close(ctx context.Context) error {
+	return nil
+}
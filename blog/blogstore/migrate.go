@@ -0,0 +1,189 @@
+package blogstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LatestVersion targets the newest known migration
+const LatestVersion = -1
+
+// Migration is a reversible change to the blog collection. Versions start at
+// 1 and must be strictly increasing in the migrations list.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, collection *mongo.Collection) error
+	Down        func(ctx context.Context, collection *mongo.Collection) error
+}
+
+// MigrateOptions controls a migration run
+type MigrateOptions struct {
+	// Target is the schema version to end up at. Migrations above it are
+	// rolled back, pending ones up to it are applied.
+	Target int
+	// DryRun only reports the steps that would run
+	DryRun bool
+	// Direction, when set, rejects a target that needs steps in the other
+	// direction
+	Direction Direction
+}
+
+// Direction tells whether a step applies or rolls back a migration
+type Direction string
+
+const (
+	Up   Direction = "up"
+	Down Direction = "down"
+)
+
+// MigrationStep describes one migration executed (or planned) by Migrate
+type MigrationStep struct {
+	Version     int
+	Description string
+	Direction   Direction
+}
+
+func (s MigrationStep) String() string {
+	return fmt.Sprintf("%v %04d %v", s.Direction, s.Version, s.Description)
+}
+
+type migrationRecord struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+func (s *mongoStore) Migrate(ctx context.Context, opts MigrateOptions) ([]MigrationStep, error) {
+	target := opts.Target
+	if target == LatestVersion {
+		target = latestMigration()
+	}
+	if target < 0 || target > latestMigration() {
+		return nil, fmt.Errorf("unknown schema version %v, latest is %v", target, latestMigration())
+	}
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read applied migrations: %v", err)
+	}
+
+	plan := planMigrations(applied, target)
+	if opts.Direction != "" {
+		for _, p := range plan {
+			if p.direction != opts.Direction {
+				return nil, fmt.Errorf("migrating %v to version %v would run %v", opts.Direction, target, p.step())
+			}
+		}
+	}
+	if opts.DryRun {
+		steps := make([]MigrationStep, 0, len(plan))
+		for _, p := range plan {
+			steps = append(steps, p.step())
+		}
+		return steps, nil
+	}
+
+	var done []MigrationStep
+	for _, p := range plan {
+		if err := s.runMigration(ctx, p); err != nil {
+			return done, fmt.Errorf("migration %v failed: %v", p.step(), err)
+		}
+		done = append(done, p.step())
+	}
+
+	return done, nil
+}
+
+type plannedMigration struct {
+	migration Migration
+	direction Direction
+}
+
+func (p plannedMigration) step() MigrationStep {
+	return MigrationStep{
+		Version:     p.migration.Version,
+		Description: p.migration.Description,
+		Direction:   p.direction,
+	}
+}
+
+// planMigrations rolls back applied migrations above the target, newest
+// first, then applies the missing ones up to the target, oldest first.
+func planMigrations(applied map[int]bool, target int) []plannedMigration {
+	var plan []plannedMigration
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > target && applied[m.Version] {
+			plan = append(plan, plannedMigration{migration: m, direction: Down})
+		}
+	}
+	for _, m := range migrations {
+		if m.Version <= target && !applied[m.Version] {
+			plan = append(plan, plannedMigration{migration: m, direction: Up})
+		}
+	}
+
+	return plan
+}
+
+func (s *mongoStore) appliedMigrations(ctx context.Context) (map[int]bool, error) {
+	cur, err := s.migrations.Find(ctx, primitive.D{}, options.Find().SetProjection(primitive.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	applied := map[int]bool{}
+	for cur.Next(ctx) {
+		record := &migrationRecord{}
+		if err := cur.Decode(record); err != nil {
+			return nil, err
+		}
+		applied[record.Version] = true
+	}
+
+	return applied, cur.Err()
+}
+
+// runMigration records the step before running it, so a second server
+// starting at the same time fails on the duplicate key instead of running
+// the same migration twice.
+func (s *mongoStore) runMigration(ctx context.Context, p plannedMigration) error {
+	m := p.migration
+
+	if p.direction == Up {
+		record := migrationRecord{Version: m.Version, Description: m.Description, AppliedAt: now()}
+		if _, err := s.migrations.InsertOne(ctx, record); err != nil {
+			return fmt.Errorf("cannot record migration: %v", err)
+		}
+		if err := m.Up(ctx, s.collection); err != nil {
+			if _, delErr := s.migrations.DeleteOne(ctx, primitive.M{"_id": m.Version}); delErr != nil {
+				return fmt.Errorf("%v (and the migration record could not be removed: %v)", err, delErr)
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := m.Down(ctx, s.collection); err != nil {
+		return err
+	}
+	if _, err := s.migrations.DeleteOne(ctx, primitive.M{"_id": m.Version}); err != nil {
+		return fmt.Errorf("cannot remove migration record: %v", err)
+	}
+	return nil
+}
+
+func latestMigration() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
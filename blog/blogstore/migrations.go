@@ -0,0 +1,137 @@
+package blogstore
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// backfilledField marks the blogs whose times were set by migration 2
+const backfilledField = "times_backfilled"
+
+// migrations is the ordered list of schema changes. Never edit or remove a
+// released migration, add a new one instead.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "index blogs by author",
+		Up: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    primitive.D{{Key: "author_id", Value: 1}},
+				Options: options.Index().SetName("author_id_1"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Indexes().DropOne(ctx, "author_id_1")
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "backfill created_at and updated_at from the ObjectID",
+		Up: func(ctx context.Context, collection *mongo.Collection) error {
+			filter := primitive.M{"created_at": primitive.M{"$exists": false}}
+			cur, err := collection.Find(ctx, filter, options.Find().SetProjection(primitive.M{"_id": 1}))
+			if err != nil {
+				return err
+			}
+			defer cur.Close(ctx)
+
+			for cur.Next(ctx) {
+				var doc struct {
+					ID primitive.ObjectID `bson:"_id"`
+				}
+				if err := cur.Decode(&doc); err != nil {
+					return err
+				}
+				created := doc.ID.Timestamp().UTC()
+				update := primitive.M{"$set": primitive.M{"created_at": created, "updated_at": created, backfilledField: true}}
+				if _, err := collection.UpdateOne(ctx, primitive.M{"_id": doc.ID}, update); err != nil {
+					return err
+				}
+			}
+			return cur.Err()
+		},
+		// Down only clears the times Up wrote, the blogs created since and
+		// the updates made since keep theirs
+		Down: func(ctx context.Context, collection *mongo.Collection) error {
+			unchanged := primitive.M{
+				backfilledField: true,
+				"$expr":         primitive.M{"$eq": primitive.A{"$updated_at", "$created_at"}},
+			}
+			if _, err := collection.UpdateMany(ctx, unchanged, primitive.M{"$unset": primitive.M{"updated_at": ""}}); err != nil {
+				return err
+			}
+			update := primitive.M{"$unset": primitive.M{"created_at": "", backfilledField: ""}}
+			_, err := collection.UpdateMany(ctx, primitive.M{backfilledField: true}, update)
+			return err
+		},
+	},
+	{
+		Version:     3,
+		Description: "index blogs by tag",
+		Up: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    primitive.D{{Key: "tags", Value: 1}},
+				Options: options.Index().SetName("tags_1"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Indexes().DropOne(ctx, "tags_1")
+			return err
+		},
+	},
+	{
+		Version:     4,
+		Description: "index blogs by moderation status",
+		Up: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    primitive.D{{Key: "moderation_status", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("moderation_status_1__id_1"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Indexes().DropOne(ctx, "moderation_status_1__id_1")
+			return err
+		},
+	},
+	{
+		Version:     5,
+		Description: "index blogs by ACL principal",
+		Up: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    primitive.D{{Key: "acl.principal", Value: 1}},
+				Options: options.Index().SetName("acl.principal_1"),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Indexes().DropOne(ctx, "acl.principal_1")
+			return err
+		},
+	},
+	{
+		Version:     6,
+		Description: "keep a blog in a single series",
+		Up: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Database().Collection(SeriesCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys: primitive.D{{Key: "blog_ids", Value: 1}},
+				// empty series would otherwise collide on the missing key
+				Options: options.Index().
+					SetName("blog_ids_1").
+					SetUnique(true).
+					SetPartialFilterExpression(primitive.M{"blog_ids.0": primitive.M{"$exists": true}}),
+			})
+			return err
+		},
+		Down: func(ctx context.Context, collection *mongo.Collection) error {
+			_, err := collection.Database().Collection(SeriesCollection).Indexes().DropOne(ctx, "blog_ids_1")
+			return err
+		},
+	},
+}
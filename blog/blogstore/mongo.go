@@ -0,0 +1,569 @@
+package blogstore
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationsCollection stores one document per applied migration
+const MigrationsCollection = "schema_migrations"
+
+// SeriesCollection stores the blog series, next to the blogs
+const SeriesCollection = "blog_series"
+
+// itemProjection leaves out the lists of users who reacted, which can be
+// large and are only needed to deduplicate reactions and for exports
+var itemProjection = primitive.M{"reactors": 0}
+
+type mongoStore struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+	migrations *mongo.Collection
+	series     *mongo.Collection
+	// standalone servers have no transactions, RunInTx then runs its
+	// function directly
+	noTransactions bool
+}
+
+// NewMongoStore returns a Store backed by the given collection. Closing the
+// store disconnects the client.
+func NewMongoStore(client *mongo.Client, collection *mongo.Collection) Store {
+	return &mongoStore{
+		client:     client,
+		collection: collection,
+		migrations: collection.Database().Collection(MigrationsCollection),
+		series:     collection.Database().Collection(SeriesCollection),
+	}
+}
+
+// NewStandaloneMongoStore is NewMongoStore for standalone servers, which do
+// not support transactions. The writes made in RunInTx are not atomic: when
+// fn fails, the writes it already made are kept.
+func NewStandaloneMongoStore(client *mongo.Client, collection *mongo.Collection) Store {
+	s := NewMongoStore(client, collection).(*mongoStore)
+	s.noTransactions = true
+	return s
+}
+
+func (s *mongoStore) Create(ctx context.Context, item *Item) (*Item, error) {
+	data := *item
+	data.ID = primitive.NewObjectID()
+	data.CreatedAt = now()
+	data.UpdatedAt = data.CreatedAt
+
+	if _, err := s.collection.InsertOne(ctx, data); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func (s *mongoStore) Read(ctx context.Context, id string) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Item{}
+	filter := primitive.M{"_id": oid}
+	opts := options.FindOne().SetProjection(itemProjection)
+	if err := s.collection.FindOne(ctx, filter, opts).Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *mongoStore) Update(ctx context.Context, id string, item *Item) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := primitive.M{"_id": oid}
+	update := primitive.D{
+		{Key: "$set", Value: primitive.D{
+			{Key: "title", Value: item.Title},
+			{Key: "content", Value: item.Content},
+			{Key: "word_count", Value: item.WordCount},
+			{Key: "reading_time_minutes", Value: item.ReadingTimeMinutes},
+			{Key: "excerpt", Value: item.Excerpt},
+			{Key: "author_id", Value: item.AuthorId},
+			{Key: "tags", Value: item.Tags},
+			{Key: "moderation_status", Value: item.ModerationStatus},
+			{Key: "moderation_reason", Value: item.ModerationReason},
+			{Key: "visibility", Value: item.Visibility},
+			{Key: "locale", Value: item.Locale},
+			{Key: "updated_at", Value: now()},
+		}},
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(itemProjection)
+
+	data := &Item{}
+	if err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *mongoStore) SetModeration(ctx context.Context, id, status, reason string) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	update := primitive.M{"$set": primitive.M{"moderation_status": status, "moderation_reason": reason}}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(itemProjection)
+
+	data := &Item{}
+	if err := s.collection.FindOneAndUpdate(ctx, primitive.M{"_id": oid}, update, opts).Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *mongoStore) SetACL(ctx context.Context, id string, acl []ACLEntry) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	update := primitive.M{"$set": primitive.M{"acl": acl}}
+	if len(acl) == 0 {
+		update = primitive.M{"$unset": primitive.M{"acl": ""}}
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(itemProjection)
+
+	data := &Item{}
+	if err := s.collection.FindOneAndUpdate(ctx, primitive.M{"_id": oid}, update, opts).Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *mongoStore) SetTranslations(ctx context.Context, id string, translations map[string]Translation) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	update := primitive.M{"$set": primitive.M{"translations": translations, "updated_at": now()}}
+	if len(translations) == 0 {
+		update = primitive.M{"$unset": primitive.M{"translations": ""}, "$set": primitive.M{"updated_at": now()}}
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(itemProjection)
+
+	data := &Item{}
+	if err := s.collection.FindOneAndUpdate(ctx, primitive.M{"_id": oid}, update, opts).Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *mongoStore) Delete(ctx context.Context, id string) error {
+	oid, err := ParseID(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.collection.DeleteOne(ctx, primitive.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *mongoStore) Put(ctx context.Context, item *Item, overwrite bool) error {
+	if !overwrite {
+		_, err := s.collection.InsertOne(ctx, item)
+		if isDuplicateKey(err) {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+
+	// the document is replaced as a whole, reactors included
+	opts := options.Replace().SetUpsert(true)
+	_, err := s.collection.ReplaceOne(ctx, primitive.M{"_id": item.ID}, item, opts)
+	return err
+}
+
+func (s *mongoStore) List(ctx context.Context, opts ListOptions, fn func(*Item) error) error {
+	filter := primitive.M{}
+	if opts.AuthorId != "" {
+		filter["author_id"] = opts.AuthorId
+	}
+	if opts.Tag != "" {
+		filter["tags"] = opts.Tag
+	}
+	switch opts.Moderation {
+	case ModerationAny:
+	case "":
+		// published blogs may not have the field at all
+		filter["moderation_status"] = primitive.M{"$in": primitive.A{nil, ""}}
+	default:
+		filter["moderation_status"] = opts.Moderation
+	}
+	if opts.Viewer != nil {
+		// public blogs may not have the field at all
+		visible := primitive.A{primitive.M{"visibility": primitive.M{"$in": primitive.A{nil, ""}}}}
+		if opts.Viewer.UserId != "" {
+			visible = append(visible, primitive.M{"author_id": opts.Viewer.UserId})
+		}
+		if principals := opts.Viewer.Principals(); len(principals) > 0 {
+			visible = append(visible, primitive.M{"acl.principal": primitive.M{"$in": principals}})
+		}
+		filter["$or"] = visible
+	}
+	order, after := 1, "$gt"
+	if opts.Descending {
+		order, after = -1, "$lt"
+	}
+	if opts.AfterID != "" {
+		oid, err := ParseID(opts.AfterID)
+		if err != nil {
+			return err
+		}
+		filter["_id"] = primitive.M{after: oid}
+	}
+
+	findOpts := options.Find().SetSort(primitive.D{{Key: "_id", Value: order}})
+	if !opts.Reactors {
+		findOpts.SetProjection(itemProjection)
+	}
+	if opts.Limit > 0 {
+		findOpts.SetLimit(int64(opts.Limit))
+	}
+	if opts.BatchSize > 0 {
+		findOpts.SetBatchSize(int32(opts.BatchSize))
+	}
+
+	cur, err := s.collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		data := &Item{}
+		if err := cur.Decode(data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+// React updates the reactors list and the counter with a single conditional
+// update, so concurrent reactions of the same user are counted once.
+func (s *mongoStore) React(ctx context.Context, id, user, reaction string, remove bool) (*Item, bool, error) {
+	if !validReaction(reaction) {
+		return nil, false, ErrInvalidReaction
+	}
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	counter := "reactions." + reaction
+	reactors := "reactors." + reaction
+
+	var filter, update primitive.M
+	if remove {
+		filter = primitive.M{"_id": oid, reactors: user}
+		update = primitive.M{
+			"$pull": primitive.M{reactors: user},
+			"$inc":  primitive.M{counter: -1},
+		}
+	} else {
+		filter = primitive.M{"_id": oid, reactors: primitive.M{"$ne": user}}
+		update = primitive.M{
+			"$addToSet": primitive.M{reactors: user},
+			"$inc":      primitive.M{counter: 1},
+		}
+	}
+	opts := options.FindOneAndUpdate().
+		SetReturnDocument(options.After).
+		SetProjection(itemProjection)
+
+	data := &Item{}
+	err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(data)
+	if err == mongo.ErrNoDocuments {
+		// either the blog does not exist or there was nothing to change
+		data, err = s.Read(ctx, id)
+		return data, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+func (s *mongoStore) AddViews(ctx context.Context, views map[string]int64) error {
+	models := make([]mongo.WriteModel, 0, len(views))
+	for id, n := range views {
+		oid, err := ParseID(id)
+		if err != nil {
+			continue
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(primitive.M{"_id": oid}).
+			SetUpdate(primitive.M{"$inc": primitive.M{"views": n}}))
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	_, err := s.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (s *mongoStore) CreateSeries(ctx context.Context, series *Series) (*Series, error) {
+	data := series.clone()
+	data.ID = primitive.NewObjectID()
+	data.CreatedAt = now()
+	data.UpdatedAt = data.CreatedAt
+
+	if err := s.checkSeriesBlogs(ctx, data.ID, data.BlogIDs); err != nil {
+		return nil, err
+	}
+	if _, err := s.series.InsertOne(ctx, data); err != nil {
+		if isDuplicateKey(err) {
+			return nil, ErrInSeries
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// checkSeriesBlogs fails if another series holds one of the blogs. The
+// unique index on blog_ids also catches concurrent additions.
+func (s *mongoStore) checkSeriesBlogs(ctx context.Context, oid primitive.ObjectID, blogIDs []string) error {
+	if len(blogIDs) == 0 {
+		return nil
+	}
+	filter := primitive.M{"_id": primitive.M{"$ne": oid}, "blog_ids": primitive.M{"$in": blogIDs}}
+	err := s.series.FindOne(ctx, filter).Err()
+	switch err {
+	case nil:
+		return ErrInSeries
+	case mongo.ErrNoDocuments:
+		return nil
+	default:
+		return err
+	}
+}
+
+func (s *mongoStore) ReadSeries(ctx context.Context, id string) (*Series, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Series{}
+	if err := s.series.FindOne(ctx, primitive.M{"_id": oid}).Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *mongoStore) UpdateSeries(ctx context.Context, id string, series *Series) (*Series, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkSeriesBlogs(ctx, oid, series.BlogIDs); err != nil {
+		return nil, err
+	}
+
+	blogIDs := series.BlogIDs
+	if blogIDs == nil {
+		blogIDs = []string{}
+	}
+	update := primitive.M{"$set": primitive.M{
+		"title":       series.Title,
+		"description": series.Description,
+		"blog_ids":    blogIDs,
+		"updated_at":  now(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	data := &Series{}
+	if err := s.series.FindOneAndUpdate(ctx, primitive.M{"_id": oid}, update, opts).Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSeriesNotFound
+		}
+		if isDuplicateKey(err) {
+			return nil, ErrInSeries
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *mongoStore) DeleteSeries(ctx context.Context, id string) error {
+	oid, err := ParseID(id)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.series.DeleteOne(ctx, primitive.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrSeriesNotFound
+	}
+	return nil
+}
+
+func (s *mongoStore) FindSeries(ctx context.Context, blogID string) (*Series, error) {
+	data := &Series{}
+	if err := s.series.FindOne(ctx, primitive.M{"blog_ids": blogID}).Decode(data); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *mongoStore) ListSeries(ctx context.Context, fn func(*Series) error) error {
+	opts := options.Find().SetSort(primitive.D{{Key: "_id", Value: 1}})
+	cur, err := s.series.Find(ctx, primitive.M{}, opts)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		data := &Series{}
+		if err := cur.Decode(data); err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+func (s *mongoStore) PutSeries(ctx context.Context, series *Series, overwrite bool) error {
+	if err := s.checkSeriesBlogs(ctx, series.ID, series.BlogIDs); err != nil {
+		return err
+	}
+
+	data := series.clone()
+	if data.BlogIDs == nil {
+		data.BlogIDs = []string{}
+	}
+	if !overwrite {
+		_, err := s.series.InsertOne(ctx, data)
+		if isDuplicateKey(err) {
+			// either the ID or, through the unique index, a blog is taken
+			if _, err := s.ReadSeries(ctx, data.ID.Hex()); err == nil {
+				return ErrSeriesExists
+			}
+			return ErrInSeries
+		}
+		return err
+	}
+
+	opts := options.Replace().SetUpsert(true)
+	_, err := s.series.ReplaceOne(ctx, primitive.M{"_id": data.ID}, data, opts)
+	if isDuplicateKey(err) {
+		return ErrInSeries
+	}
+	return err
+}
+
+// RunInTx runs fn in a multi-document transaction, which needs a replica set
+// or a sharded cluster. fn is retried on transient errors, so it must not
+// have side effects outside of tx. On standalone servers fn runs once,
+// outside of any transaction.
+func (s *mongoStore) RunInTx(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error {
+	if s.noTransactions {
+		return fn(ctx, s)
+	}
+
+	session, err := s.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		// the store methods run in the transaction as they pass sc along
+		return nil, fn(sc, s)
+	})
+	return err
+}
+
+func (s *mongoStore) Close(ctx context.Context) error {
+	return s.client.Disconnect(ctx)
+}
+
+// isDuplicateKey reports whether err is a unique index violation. Inserts
+// and replacements report it as a write exception, commands such as
+// findAndModify as a command error.
+func isDuplicateKey(err error) bool {
+	const duplicateKey = 11000
+
+	switch e := err.(type) {
+	case mongo.WriteException:
+		for _, we := range e.WriteErrors {
+			if we.Code == duplicateKey {
+				return true
+			}
+		}
+	case mongo.CommandError:
+		return e.Code == duplicateKey
+	}
+	return false
+}
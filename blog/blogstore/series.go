@@ -0,0 +1,48 @@
+package blogstore
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrSeriesNotFound is returned when no series matches the given ID, or
+	// by FindSeries when the blog is in no series
+	ErrSeriesNotFound = errors.New("series not found")
+	// ErrInSeries is returned when adding a blog which already belongs to
+	// another series
+	ErrInSeries = errors.New("blog already in a series")
+	// ErrSeriesExists is returned by PutSeries when the ID is already taken
+	ErrSeriesExists = errors.New("series already exists")
+)
+
+// Series is an ordered collection of blogs, a blog belongs to at most one
+// series
+type Series struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	AuthorId    string             `bson:"author_id"`
+	Title       string             `bson:"title"`
+	Description string             `bson:"description"`
+	// BlogIDs lists the blogs in reading order
+	BlogIDs   []string  `bson:"blog_ids"`
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+func (series *Series) clone() *Series {
+	data := *series
+	data.BlogIDs = append([]string(nil), series.BlogIDs...)
+	return &data
+}
+
+// Position returns the index of the blog in the series, -1 if absent
+func (series *Series) Position(blogID string) int {
+	for i, id := range series.BlogIDs {
+		if id == blogID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,915 @@
+package blogstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	// registers the pure Go "sqlite" database/sql driver
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteSchema lists the statements creating each schema version, the
+// version reached is kept in PRAGMA user_version. Only append to it.
+var sqliteSchema = []string{
+	`
+	CREATE TABLE IF NOT EXISTS blogs (
+		id         TEXT PRIMARY KEY,
+		author_id  TEXT NOT NULL,
+		title      TEXT NOT NULL,
+		content    TEXT NOT NULL,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS blogs_author_id ON blogs (author_id);
+	`,
+	`
+	ALTER TABLE blogs ADD COLUMN likes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE blogs ADD COLUMN claps INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE blogs ADD COLUMN views INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE blog_reactions (
+		blog_id  TEXT NOT NULL,
+		reaction TEXT NOT NULL,
+		user_id  TEXT NOT NULL,
+		PRIMARY KEY (blog_id, reaction, user_id)
+	);
+	`,
+	`
+	ALTER TABLE blogs ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	ALTER TABLE blogs ADD COLUMN moderation_status TEXT NOT NULL DEFAULT '';
+	ALTER TABLE blogs ADD COLUMN moderation_reason TEXT NOT NULL DEFAULT '';
+	CREATE INDEX blogs_moderation_status ON blogs (moderation_status, id);
+	`,
+	`
+	ALTER TABLE blogs ADD COLUMN visibility TEXT NOT NULL DEFAULT '';
+	ALTER TABLE blogs ADD COLUMN acl TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	CREATE TABLE series (
+		id          TEXT PRIMARY KEY,
+		author_id   TEXT NOT NULL,
+		title       TEXT NOT NULL,
+		description TEXT NOT NULL,
+		created_at  INTEGER NOT NULL,
+		updated_at  INTEGER NOT NULL
+	);
+	CREATE TABLE series_blogs (
+		blog_id   TEXT PRIMARY KEY,
+		series_id TEXT NOT NULL,
+		position  INTEGER NOT NULL
+	);
+	CREATE INDEX series_blogs_series_id ON series_blogs (series_id, position);
+	`,
+	`
+	ALTER TABLE blogs ADD COLUMN locale TEXT NOT NULL DEFAULT '';
+	ALTER TABLE blogs ADD COLUMN translations TEXT NOT NULL DEFAULT '';
+	`,
+	`
+	ALTER TABLE blogs ADD COLUMN word_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE blogs ADD COLUMN reading_time_minutes INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE blogs ADD COLUMN excerpt TEXT NOT NULL DEFAULT '';
+	`,
+}
+
+// sqliteCounters maps the reaction kinds to their column in blogs
+var sqliteCounters = map[string]string{
+	ReactionLike: "likes",
+	ReactionClap: "claps",
+}
+
+type sqliteStore struct {
+	db *sql.DB
+	// tx is set on the store handed to the RunInTx functions
+	tx *sql.Tx
+}
+
+// sqliteConn is implemented by both *sql.DB and *sql.Tx
+type sqliteConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn returns the running transaction if any, the database otherwise
+func (s *sqliteStore) conn() sqliteConn {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// detach hides the cancellation of ctx from the driver, which interrupts
+// the connection from a goroutine that can run after the query returned,
+// aborting a later query or crashing once the connection is closed. A ctx
+// already done is passed as is, database/sql then fails the statement
+// before it reaches the driver: the cancellations and deadlines are
+// enforced before each statement, and a started statement runs to its
+// end.
+func detach(ctx context.Context) context.Context {
+	if ctx.Err() != nil {
+		return ctx
+	}
+	return detachedContext{ctx}
+}
+
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// inTx runs fn in a transaction, joining the one of RunInTx if any
+func (s *sqliteStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	tx, err := s.db.BeginTx(detach(ctx), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NewSQLiteStore opens (or creates) the SQLite database at path. The schema
+// mirrors the Mongo documents: IDs are ObjectID hex strings and times are
+// stored as milliseconds since the Unix epoch.
+func NewSQLiteStore(path string) (Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, serialize access instead of failing
+	// with "database is locked"
+	db.SetMaxOpenConns(1)
+
+	if err := upgradeSQLiteSchema(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func upgradeSQLiteSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+
+	for ; version < len(sqliteSchema); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteSchema[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("cannot create schema version %v: %v", version+1, err)
+		}
+		// PRAGMA does not support placeholders
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqliteStore) Create(ctx context.Context, item *Item) (*Item, error) {
+	data := *item
+	data.ID = primitive.NewObjectID()
+	data.CreatedAt = now()
+	data.UpdatedAt = data.CreatedAt
+
+	if err := s.insert(ctx, &data); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+func (s *sqliteStore) insert(ctx context.Context, data *Item) error {
+	_, err := s.conn().ExecContext(detach(ctx), `INSERT INTO blogs (`+sqliteColumns+`) VALUES (`+sqlitePlaceholders+`)`, itemValues(data)...)
+	return err
+}
+
+func (s *sqliteStore) Read(ctx context.Context, id string) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.conn().QueryRowContext(detach(ctx), `SELECT `+sqliteColumns+` FROM blogs WHERE id = ?`, oid.Hex())
+	data, err := scanItem(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *sqliteStore) Update(ctx context.Context, id string, item *Item) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.conn().ExecContext(detach(ctx),
+		`UPDATE blogs SET author_id = ?, title = ?, content = ?, word_count = ?, reading_time_minutes = ?, excerpt = ?, tags = ?, moderation_status = ?, moderation_reason = ?, visibility = ?, locale = ?, updated_at = ? WHERE id = ?`,
+		item.AuthorId, item.Title, item.Content, item.WordCount, item.ReadingTimeMinutes, item.Excerpt, joinTags(item.Tags), item.ModerationStatus, item.ModerationReason, item.Visibility, item.Locale, toMillis(now()), oid.Hex(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+
+	return s.Read(ctx, id)
+}
+
+func (s *sqliteStore) SetModeration(ctx context.Context, id, status, reason string) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.conn().ExecContext(detach(ctx), `UPDATE blogs SET moderation_status = ?, moderation_reason = ? WHERE id = ?`, status, reason, oid.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+
+	return s.Read(ctx, id)
+}
+
+func (s *sqliteStore) SetACL(ctx context.Context, id string, acl []ACLEntry) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.conn().ExecContext(detach(ctx), `UPDATE blogs SET acl = ? WHERE id = ?`, joinACL(acl), oid.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+
+	return s.Read(ctx, id)
+}
+
+func (s *sqliteStore) SetTranslations(ctx context.Context, id string, translations map[string]Translation) (*Item, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.conn().ExecContext(detach(ctx), `UPDATE blogs SET translations = ?, updated_at = ? WHERE id = ?`,
+		marshalTranslations(translations), toMillis(now()), oid.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrNotFound
+	}
+
+	return s.Read(ctx, id)
+}
+
+func (s *sqliteStore) Delete(ctx context.Context, id string) error {
+	oid, err := ParseID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(detach(ctx), `DELETE FROM blogs WHERE id = ?`, oid.Hex())
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrNotFound
+		}
+		_, err = tx.ExecContext(detach(ctx), `DELETE FROM blog_reactions WHERE blog_id = ?`, oid.Hex())
+		return err
+	})
+}
+
+func (s *sqliteStore) Put(ctx context.Context, item *Item, overwrite bool) error {
+	insert := `INSERT INTO blogs`
+	if overwrite {
+		insert = `INSERT OR REPLACE INTO blogs`
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(detach(ctx), insert+` (`+sqliteColumns+`) VALUES (`+sqlitePlaceholders+`)`, itemValues(item)...)
+		if isConstraintError(err) {
+			return ErrAlreadyExists
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(detach(ctx), `DELETE FROM blog_reactions WHERE blog_id = ?`, item.ID.Hex()); err != nil {
+			return err
+		}
+		for reaction, users := range item.Reactors {
+			for _, user := range users {
+				_, err := tx.ExecContext(detach(ctx), `INSERT OR IGNORE INTO blog_reactions (blog_id, reaction, user_id) VALUES (?, ?, ?)`, item.ID.Hex(), reaction, user)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// readReactors returns the users who reacted to the blog per reaction
+func (s *sqliteStore) readReactors(ctx context.Context, oid primitive.ObjectID) (map[string][]string, error) {
+	rows, err := s.conn().QueryContext(detach(ctx), `SELECT reaction, user_id FROM blog_reactions WHERE blog_id = ? ORDER BY reaction, user_id`, oid.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reactors map[string][]string
+	for rows.Next() {
+		var reaction, user string
+		if err := rows.Scan(&reaction, &user); err != nil {
+			return nil, err
+		}
+		if reactors == nil {
+			reactors = map[string][]string{}
+		}
+		reactors[reaction] = append(reactors[reaction], user)
+	}
+	return reactors, rows.Err()
+}
+
+// sqliteListPage is the number of blogs List reads at a time when
+// ListOptions.BatchSize is not set
+const sqliteListPage = 100
+
+// List reads the blogs page by page and releases the connection before
+// calling fn, so that a slow caller does not block the other requests
+func (s *sqliteStore) List(ctx context.Context, opts ListOptions, fn func(*Item) error) error {
+	page := opts.BatchSize
+	if page <= 0 {
+		page = sqliteListPage
+	}
+
+	for count := 0; opts.Limit <= 0 || count < opts.Limit; {
+		pageOpts := opts
+		pageOpts.Limit = page
+		if opts.Limit > 0 && opts.Limit-count < page {
+			pageOpts.Limit = opts.Limit - count
+		}
+		items, err := s.listPage(ctx, pageOpts)
+		if err != nil {
+			return err
+		}
+		if opts.Reactors {
+			for _, data := range items {
+				if data.Reactors, err = s.readReactors(ctx, data.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, data := range items {
+			if err := fn(data); err != nil {
+				return err
+			}
+		}
+		count += len(items)
+		if len(items) < pageOpts.Limit {
+			return nil
+		}
+		opts.AfterID = items[len(items)-1].ID.Hex()
+	}
+	return nil
+}
+
+// listPage returns the blogs matching opts, opts.Limit must be set
+func (s *sqliteStore) listPage(ctx context.Context, opts ListOptions) ([]*Item, error) {
+	query := `SELECT ` + sqliteColumns + ` FROM blogs WHERE 1 = 1`
+	var args []interface{}
+
+	if opts.AuthorId != "" {
+		query += ` AND author_id = ?`
+		args = append(args, opts.AuthorId)
+	}
+	if opts.Moderation != ModerationAny {
+		query += ` AND moderation_status = ?`
+		args = append(args, opts.Moderation)
+	}
+	if opts.Tag != "" {
+		query += ` AND instr(tags, ?) > 0`
+		args = append(args, joinTags([]string{opts.Tag}))
+	}
+	if opts.Viewer != nil {
+		visible := ` AND (visibility = ''`
+		if opts.Viewer.UserId != "" {
+			visible += ` OR author_id = ?`
+			args = append(args, opts.Viewer.UserId)
+		}
+		for _, principal := range opts.Viewer.Principals() {
+			visible += ` OR instr(acl, ?) > 0`
+			args = append(args, ","+principal+"=")
+		}
+		query += visible + `)`
+	}
+	after, order := ` AND id > ?`, ` ORDER BY id`
+	if opts.Descending {
+		after, order = ` AND id < ?`, ` ORDER BY id DESC`
+	}
+	if opts.AfterID != "" {
+		oid, err := ParseID(opts.AfterID)
+		if err != nil {
+			return nil, err
+		}
+		query += after
+		args = append(args, oid.Hex())
+	}
+	query += order + ` LIMIT ?`
+	args = append(args, opts.Limit)
+
+	rows, err := s.conn().QueryContext(detach(ctx), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*Item
+	for rows.Next() {
+		data, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, data)
+	}
+
+	return items, rows.Err()
+}
+
+// React records the user in blog_reactions and updates the counter in the
+// same transaction, the primary key deduplicates the reactions.
+func (s *sqliteStore) React(ctx context.Context, id, user, reaction string, remove bool) (*Item, bool, error) {
+	column, ok := sqliteCounters[reaction]
+	if !ok {
+		return nil, false, ErrInvalidReaction
+	}
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx, err := s.db.BeginTx(detach(ctx), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := scanItem(tx.QueryRowContext(detach(ctx), `SELECT `+sqliteColumns+` FROM blogs WHERE id = ?`, oid.Hex())); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, false, ErrNotFound
+		}
+		return nil, false, err
+	}
+
+	var res sql.Result
+	delta := 1
+	if remove {
+		delta = -1
+		res, err = tx.ExecContext(detach(ctx), `DELETE FROM blog_reactions WHERE blog_id = ? AND reaction = ? AND user_id = ?`, oid.Hex(), reaction, user)
+	} else {
+		res, err = tx.ExecContext(detach(ctx), `INSERT OR IGNORE INTO blog_reactions (blog_id, reaction, user_id) VALUES (?, ?, ?)`, oid.Hex(), reaction, user)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	changed := n > 0
+	if changed {
+		if _, err := tx.ExecContext(detach(ctx), `UPDATE blogs SET `+column+` = `+column+` + ? WHERE id = ?`, delta, oid.Hex()); err != nil {
+			return nil, false, err
+		}
+	}
+
+	data, err := scanItem(tx.QueryRowContext(detach(ctx), `SELECT `+sqliteColumns+` FROM blogs WHERE id = ?`, oid.Hex()))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return data, changed, tx.Commit()
+}
+
+func (s *sqliteStore) AddViews(ctx context.Context, views map[string]int64) error {
+	tx, err := s.db.BeginTx(detach(ctx), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, n := range views {
+		oid, err := ParseID(id)
+		if err != nil {
+			continue
+		}
+		if _, err := tx.ExecContext(detach(ctx), `UPDATE blogs SET views = views + ? WHERE id = ?`, n, oid.Hex()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) CreateSeries(ctx context.Context, series *Series) (*Series, error) {
+	data := series.clone()
+	data.ID = primitive.NewObjectID()
+	data.CreatedAt = now()
+	data.UpdatedAt = data.CreatedAt
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(detach(ctx),
+			`INSERT INTO series (id, author_id, title, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			data.ID.Hex(), data.AuthorId, data.Title, data.Description, toMillis(data.CreatedAt), toMillis(data.UpdatedAt),
+		)
+		if err != nil {
+			return err
+		}
+		return insertSeriesBlogs(ctx, tx, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// insertSeriesBlogs relies on the blog_id primary key to keep blogs in a
+// single series
+func insertSeriesBlogs(ctx context.Context, tx *sql.Tx, series *Series) error {
+	for i, id := range series.BlogIDs {
+		_, err := tx.ExecContext(detach(ctx), `INSERT INTO series_blogs (blog_id, series_id, position) VALUES (?, ?, ?)`, id, series.ID.Hex(), i)
+		if err != nil {
+			if isConstraintError(err) {
+				return ErrInSeries
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) ReadSeries(ctx context.Context, id string) (*Series, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Series{ID: oid}
+	var createdAt, updatedAt int64
+	row := s.conn().QueryRowContext(detach(ctx), `SELECT author_id, title, description, created_at, updated_at FROM series WHERE id = ?`, oid.Hex())
+	if err := row.Scan(&data.AuthorId, &data.Title, &data.Description, &createdAt, &updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSeriesNotFound
+		}
+		return nil, err
+	}
+	data.CreatedAt = fromMillis(createdAt)
+	data.UpdatedAt = fromMillis(updatedAt)
+
+	rows, err := s.conn().QueryContext(detach(ctx), `SELECT blog_id FROM series_blogs WHERE series_id = ? ORDER BY position`, oid.Hex())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var blogID string
+		if err := rows.Scan(&blogID); err != nil {
+			return nil, err
+		}
+		data.BlogIDs = append(data.BlogIDs, blogID)
+	}
+
+	return data, rows.Err()
+}
+
+func (s *sqliteStore) UpdateSeries(ctx context.Context, id string, series *Series) (*Series, error) {
+	oid, err := ParseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(detach(ctx),
+			`UPDATE series SET title = ?, description = ?, updated_at = ? WHERE id = ?`,
+			series.Title, series.Description, toMillis(now()), oid.Hex(),
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrSeriesNotFound
+		}
+		if _, err := tx.ExecContext(detach(ctx), `DELETE FROM series_blogs WHERE series_id = ?`, oid.Hex()); err != nil {
+			return err
+		}
+		return insertSeriesBlogs(ctx, tx, &Series{ID: oid, BlogIDs: series.BlogIDs})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.ReadSeries(ctx, id)
+}
+
+func (s *sqliteStore) DeleteSeries(ctx context.Context, id string) error {
+	oid, err := ParseID(id)
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(detach(ctx), `DELETE FROM series WHERE id = ?`, oid.Hex())
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrSeriesNotFound
+		}
+		_, err = tx.ExecContext(detach(ctx), `DELETE FROM series_blogs WHERE series_id = ?`, oid.Hex())
+		return err
+	})
+}
+
+func (s *sqliteStore) FindSeries(ctx context.Context, blogID string) (*Series, error) {
+	var id string
+	err := s.conn().QueryRowContext(detach(ctx), `SELECT series_id FROM series_blogs WHERE blog_id = ?`, blogID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrSeriesNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.ReadSeries(ctx, id)
+}
+
+func (s *sqliteStore) ListSeries(ctx context.Context, fn func(*Series) error) error {
+	rows, err := s.conn().QueryContext(detach(ctx), `SELECT id FROM series ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// the series are read one by one, the connection is free while fn runs
+	for _, id := range ids {
+		data, err := s.ReadSeries(ctx, id)
+		if err == ErrSeriesNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) PutSeries(ctx context.Context, series *Series, overwrite bool) error {
+	insert := `INSERT INTO series`
+	if overwrite {
+		insert = `INSERT OR REPLACE INTO series`
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(detach(ctx),
+			insert+` (id, author_id, title, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			series.ID.Hex(), series.AuthorId, series.Title, series.Description, toMillis(series.CreatedAt), toMillis(series.UpdatedAt),
+		)
+		if isConstraintError(err) {
+			return ErrSeriesExists
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(detach(ctx), `DELETE FROM series_blogs WHERE series_id = ?`, series.ID.Hex()); err != nil {
+			return err
+		}
+		return insertSeriesBlogs(ctx, tx, series)
+	})
+}
+
+// RunInTx runs fn in a SQLite transaction. Access to the database is
+// serialized, so other calls wait until the transaction ends.
+func (s *sqliteStore) RunInTx(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return fn(ctx, &sqliteStore{db: s.db, tx: tx})
+	})
+}
+
+// Migrate is a no-op, the schema is upgraded when the store is opened
+func (s *sqliteStore) Migrate(ctx context.Context, opts MigrateOptions) ([]MigrationStep, error) {
+	return nil, nil
+}
+
+func (s *sqliteStore) Close(ctx context.Context) error {
+	return s.db.Close()
+}
+
+const sqliteColumns = `id, author_id, title, content, created_at, updated_at, likes, claps, views, tags, moderation_status, moderation_reason, visibility, acl, locale, translations, word_count, reading_time_minutes, excerpt`
+
+// sqlitePlaceholders has one placeholder per column of sqliteColumns
+var sqlitePlaceholders = strings.TrimSuffix(strings.Repeat("?, ", strings.Count(sqliteColumns, ",")+1), ", ")
+
+func itemValues(data *Item) []interface{} {
+	return []interface{}{
+		data.ID.Hex(), data.AuthorId, data.Title, data.Content,
+		toMillis(data.CreatedAt), toMillis(data.UpdatedAt),
+		data.Reactions[ReactionLike], data.Reactions[ReactionClap], data.Views,
+		joinTags(data.Tags), data.ModerationStatus, data.ModerationReason,
+		data.Visibility, joinACL(data.ACL),
+		data.Locale, marshalTranslations(data.Translations),
+		data.WordCount, data.ReadingTimeMinutes, data.Excerpt,
+	}
+}
+
+// joinTags stores the tags as ",a,b," so that a tag can be matched with
+// instr(tags, ",a,"), tags never contain commas
+func joinTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "," + strings.Join(tags, ",") + ","
+}
+
+func splitTags(tags string) []string {
+	tags = strings.Trim(tags, ",")
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
+
+// joinACL stores the ACL as ",user:a=reader,group:b=editor," so that a
+// principal can be matched with instr(acl, ",user:a="), principals never
+// contain commas nor equal signs
+func joinACL(acl []ACLEntry) string {
+	if len(acl) == 0 {
+		return ""
+	}
+	entries := make([]string, len(acl))
+	for i, entry := range acl {
+		entries[i] = entry.Principal + "=" + entry.Role
+	}
+	return "," + strings.Join(entries, ",") + ","
+}
+
+func splitACL(acl string) []ACLEntry {
+	var entries []ACLEntry
+	for _, entry := range splitTags(acl) {
+		if i := strings.LastIndex(entry, "="); i >= 0 {
+			entries = append(entries, ACLEntry{Principal: entry[:i], Role: entry[i+1:]})
+		}
+	}
+	return entries
+}
+
+// marshalTranslations stores the translations as a JSON object, empty
+// when there are none
+func marshalTranslations(translations map[string]Translation) string {
+	if len(translations) == 0 {
+		return ""
+	}
+	// a map of strings and numbers always marshals
+	b, _ := json.Marshal(translations)
+	return string(b)
+}
+
+func unmarshalTranslations(translations string) (map[string]Translation, error) {
+	if translations == "" {
+		return nil, nil
+	}
+	var result map[string]Translation
+	err := json.Unmarshal([]byte(translations), &result)
+	return result, err
+}
+
+// isConstraintError reports whether err is a violation of a primary key
+// or a unique index
+func isConstraintError(err error) bool {
+	e, ok := err.(*sqlite.Error)
+	if !ok {
+		return false
+	}
+	return e.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY || e.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanItem(row scanner) (*Item, error) {
+	var id string
+	var createdAt, updatedAt, likes, claps int64
+	var tags, acl, translations string
+	data := &Item{}
+
+	err := row.Scan(&id, &data.AuthorId, &data.Title, &data.Content, &createdAt, &updatedAt, &likes, &claps, &data.Views, &tags,
+		&data.ModerationStatus, &data.ModerationReason, &data.Visibility, &acl,
+		&data.Locale, &translations,
+		&data.WordCount, &data.ReadingTimeMinutes, &data.Excerpt)
+	if err != nil {
+		return nil, err
+	}
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	data.ID = oid
+	data.CreatedAt = fromMillis(createdAt)
+	data.UpdatedAt = fromMillis(updatedAt)
+	data.Tags = splitTags(tags)
+	data.ACL = splitACL(acl)
+	if data.Translations, err = unmarshalTranslations(translations); err != nil {
+		return nil, err
+	}
+	if likes != 0 || claps != 0 {
+		data.Reactions = map[string]int64{ReactionLike: likes, ReactionClap: claps}
+	}
+
+	return data, nil
+}
+
+// toMillis stores the zero time as 0 so that it reads back as the zero time
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func fromMillis(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
@@ -0,0 +1,245 @@
+// Package blogstore contains the storage backends used by the blog server.
+package blogstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when no blog matches the given ID
+	ErrNotFound = errors.New("blog not found")
+	// ErrInvalidID is returned when the given ID is not a valid ObjectID
+	ErrInvalidID = errors.New("invalid blog ID")
+	// ErrAlreadyExists is returned by Put when the ID is already taken
+	ErrAlreadyExists = errors.New("blog already exists")
+	// ErrInvalidReaction is returned by React for unknown reaction kinds
+	ErrInvalidReaction = errors.New("invalid reaction")
+)
+
+// Moderation states. Published blogs have an empty state, so that blogs
+// stored before moderation existed are published.
+const (
+	ModerationPending  = "pending"
+	ModerationRejected = "rejected"
+	// ModerationAny is only valid in ListOptions, to list every blog
+	ModerationAny = "*"
+)
+
+// Reactions a user can leave on a blog, at most once each
+const (
+	ReactionLike = "like"
+	ReactionClap = "clap"
+)
+
+func validReaction(reaction string) bool {
+	return reaction == ReactionLike || reaction == ReactionClap
+}
+
+// Item is the stored representation of a blog
+type Item struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	AuthorId  string             `bson:"author_id"`
+	Content   string             `bson:"content"`
+	Title     string             `bson:"title"`
+	Tags      []string           `bson:"tags,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+	// Reactions counts the users per reaction kind
+	Reactions map[string]int64 `bson:"reactions,omitempty"`
+	// Reactors lists the users per reaction kind. It is only filled by List
+	// with ListOptions.Reactors, for exports, and written by Put.
+	Reactors map[string][]string `bson:"reactors,omitempty"`
+	Views    int64               `bson:"views"`
+
+	ModerationStatus string `bson:"moderation_status,omitempty"`
+	ModerationReason string `bson:"moderation_reason,omitempty"`
+
+	Visibility string     `bson:"visibility,omitempty"`
+	ACL        []ACLEntry `bson:"acl,omitempty"`
+
+	// Locale is the BCP-47 locale of Title and Content
+	Locale string `bson:"locale,omitempty"`
+	// Translations holds the other variants, keyed by BCP-47 locale
+	Translations map[string]Translation `bson:"translations,omitempty"`
+
+	TextStats `bson:",inline"`
+}
+
+// TextStats are computed from the content when it is written
+type TextStats struct {
+	WordCount          int64  `bson:"word_count" json:"word_count,omitempty"`
+	ReadingTimeMinutes int64  `bson:"reading_time_minutes" json:"reading_time_minutes,omitempty"`
+	Excerpt            string `bson:"excerpt,omitempty" json:"excerpt,omitempty"`
+}
+
+// Translation is a localized variant of a blog
+type Translation struct {
+	Title   string `bson:"title" json:"title"`
+	Content string `bson:"content" json:"content"`
+
+	TextStats `bson:",inline"`
+}
+
+func (item *Item) clone() *Item {
+	data := *item
+	if item.Tags != nil {
+		data.Tags = append([]string(nil), item.Tags...)
+	}
+	if item.ACL != nil {
+		data.ACL = append([]ACLEntry(nil), item.ACL...)
+	}
+	if item.Translations != nil {
+		data.Translations = make(map[string]Translation, len(item.Translations))
+		for k, v := range item.Translations {
+			data.Translations[k] = v
+		}
+	}
+	if item.Reactions != nil {
+		data.Reactions = make(map[string]int64, len(item.Reactions))
+		for k, v := range item.Reactions {
+			data.Reactions[k] = v
+		}
+	}
+	if item.Reactors != nil {
+		data.Reactors = make(map[string][]string, len(item.Reactors))
+		for k, v := range item.Reactors {
+			data.Reactors[k] = append([]string(nil), v...)
+		}
+	}
+	return &data
+}
+
+// ListOptions filters and paginates List
+type ListOptions struct {
+	// AuthorId only lists the blogs of this author
+	AuthorId string
+	// Tag only lists the blogs having this tag
+	Tag string
+	// AfterID skips the blogs up to and including this ID
+	AfterID string
+	// Moderation only lists the blogs in this moderation state. The zero
+	// value lists the published blogs, ModerationAny lists all of them.
+	Moderation string
+	// Descending lists the newest blogs first, AfterID then skips the
+	// blogs from this ID up
+	Descending bool
+	// Limit is the maximum number of blogs listed, 0 means no limit
+	Limit int
+	// Viewer only lists the public blogs and the ones shared with the
+	// viewer. Unset lists every blog whatever its visibility.
+	Viewer *Viewer
+	// BatchSize bounds how many blogs the backend fetches ahead of fn, 0
+	// lets it choose
+	BatchSize int
+	// Reactors fills Item.Reactors
+	Reactors bool
+}
+
+// Store persists blogs. Implementations must be safe for concurrent use.
+type Store interface {
+	// Create inserts a new blog and returns it with its generated ID
+	Create(ctx context.Context, item *Item) (*Item, error)
+	Read(ctx context.Context, id string) (*Item, error)
+	// Update replaces the author, title, content, text statistics, locale,
+	// tags, visibility and moderation state of an existing blog
+	Update(ctx context.Context, id string, item *Item) (*Item, error)
+	Delete(ctx context.Context, id string) error
+	// Put stores the item as is, keeping its ID and timestamps and replacing
+	// the reactors with Item.Reactors. Unless overwrite is set it fails with
+	// ErrAlreadyExists if the ID is taken.
+	Put(ctx context.Context, item *Item, overwrite bool) error
+	// List calls fn for every blog matching opts ordered by ID, stopping at
+	// the first error
+	List(ctx context.Context, opts ListOptions, fn func(*Item) error) error
+
+	// SetModeration changes the moderation state of a blog without touching
+	// its update time
+	SetModeration(ctx context.Context, id, status, reason string) (*Item, error)
+	// SetACL replaces the ACL of a blog without touching its update time
+	SetACL(ctx context.Context, id string, acl []ACLEntry) (*Item, error)
+	// SetTranslations replaces the translations of a blog and sets its
+	// update time
+	SetTranslations(ctx context.Context, id string, translations map[string]Translation) (*Item, error)
+	// React adds, or removes, the reaction of a user and returns the blog
+	// with its updated counters. changed is false when the user had already
+	// reacted, or had not reacted when removing.
+	React(ctx context.Context, id, user, reaction string, remove bool) (item *Item, changed bool, err error)
+	// AddViews increments the view counter of each blog by the given amount,
+	// unknown IDs are ignored
+	AddViews(ctx context.Context, views map[string]int64) error
+
+	// RunInTx runs fn as a unit of work: the writes made through tx are
+	// applied together if fn returns nil and discarded otherwise. fn must
+	// only use the store through tx and the given ctx.
+	RunInTx(ctx context.Context, fn func(ctx context.Context, tx Tx) error) error
+
+	// CreateSeries inserts a new series and returns it with its generated ID
+	CreateSeries(ctx context.Context, series *Series) (*Series, error)
+	ReadSeries(ctx context.Context, id string) (*Series, error)
+	// UpdateSeries replaces the title, description and blogs of a series. It
+	// fails with ErrInSeries if a blog belongs to another series.
+	UpdateSeries(ctx context.Context, id string, series *Series) (*Series, error)
+	DeleteSeries(ctx context.Context, id string) error
+	// FindSeries returns the series of a blog, or ErrSeriesNotFound
+	FindSeries(ctx context.Context, blogID string) (*Series, error)
+	// ListSeries calls fn for every series ordered by ID, stopping at the
+	// first error
+	ListSeries(ctx context.Context, fn func(*Series) error) error
+	// PutSeries stores the series as is, keeping its ID and timestamps.
+	// Unless overwrite is set it fails with ErrSeriesExists if the ID is
+	// taken. It fails with ErrInSeries if a blog belongs to another series.
+	PutSeries(ctx context.Context, series *Series, overwrite bool) error
+
+	// Migrate brings the schema to the requested version
+	Migrate(ctx context.Context, opts MigrateOptions) ([]MigrationStep, error)
+	Close(ctx context.Context) error
+}
+
+// Tx holds the operations available in a transaction, see Store.RunInTx
+type Tx interface {
+	Read(ctx context.Context, id string) (*Item, error)
+	Update(ctx context.Context, id string, item *Item) (*Item, error)
+	Delete(ctx context.Context, id string) error
+	Put(ctx context.Context, item *Item, overwrite bool) error
+	SetModeration(ctx context.Context, id, status, reason string) (*Item, error)
+	SetACL(ctx context.Context, id string, acl []ACLEntry) (*Item, error)
+	SetTranslations(ctx context.Context, id string, translations map[string]Translation) (*Item, error)
+	ReadSeries(ctx context.Context, id string) (*Series, error)
+	UpdateSeries(ctx context.Context, id string, series *Series) (*Series, error)
+	FindSeries(ctx context.Context, blogID string) (*Series, error)
+}
+
+// ParseID converts a blog ID, it returns ErrInvalidID when it is malformed
+func ParseID(id string) (primitive.ObjectID, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return oid, ErrInvalidID
+	}
+	return oid, nil
+}
+
+func matchesModeration(item *Item, moderation string) bool {
+	return moderation == ModerationAny || item.ModerationStatus == moderation
+}
+
+func matchesViewer(item *Item, viewer *Viewer) bool {
+	return viewer == nil || viewer.CanList(item)
+}
+
+func hasTag(item *Item, tag string) bool {
+	for _, t := range item.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// now is truncated to milliseconds, the precision MongoDB stores dates with
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
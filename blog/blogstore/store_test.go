@@ -0,0 +1,125 @@
+package blogstore_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore/storetest"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) blogstore.Store {
+		return blogstore.NewMemoryStore()
+	})
+}
+
+func TestSQLiteStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blogstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	storetest.Run(t, func(t *testing.T) blogstore.Store {
+		s, err := blogstore.NewSQLiteStore(filepath.Join(dir, primitive.NewObjectID().Hex()+".db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+// TestSQLiteStoreContext checks that the statements do not start once the
+// context is done, the driver never sees its cancellation
+func TestSQLiteStoreContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blogstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := blogstore.NewSQLiteStore(filepath.Join(dir, "blog.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close(context.Background())
+	created, err := s.Create(context.Background(), &blogstore.Item{AuthorId: "victor", Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.ID.Hex()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	for _, ctx := range []context.Context{canceled, expired} {
+		calls := []struct {
+			name string
+			call func() error
+		}{
+			{"Create", func() error { _, err := s.Create(ctx, &blogstore.Item{Title: "second"}); return err }},
+			{"Read", func() error { _, err := s.Read(ctx, id); return err }},
+			{"Update", func() error { _, err := s.Update(ctx, id, &blogstore.Item{Title: "edited"}); return err }},
+			{"Delete", func() error { return s.Delete(ctx, id) }},
+			{"List", func() error {
+				return s.List(ctx, blogstore.ListOptions{}, func(*blogstore.Item) error { return nil })
+			}},
+			{"React", func() error { _, _, err := s.React(ctx, id, "ana", blogstore.ReactionLike, false); return err }},
+			{"AddViews", func() error { return s.AddViews(ctx, map[string]int64{id: 1}) }},
+			{"RunInTx", func() error {
+				return s.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error { return nil })
+			}},
+		}
+		for _, c := range calls {
+			if err := c.call(); err != ctx.Err() {
+				t.Errorf("%v with a done context (%v) = %v", c.name, ctx.Err(), err)
+			}
+		}
+	}
+
+	read, err := s.Read(context.Background(), id)
+	if err != nil || read.Title != "first" || read.Views != 0 {
+		t.Errorf("Read after the done calls = %+v, %v, want it unchanged", read, err)
+	}
+}
+
+// TestMongoStore runs against a real server, set BLOG_TEST_MONGO_URI to
+// enable it. Each subtest uses a fresh collection which is then dropped.
+func TestMongoStore(t *testing.T) {
+	uri := os.Getenv("BLOG_TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("BLOG_TEST_MONGO_URI is not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) blogstore.Store {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		if err != nil {
+			t.Fatal(err)
+		}
+		collection := client.Database("blogstore_test").Collection("blog_" + primitive.NewObjectID().Hex())
+		return &droppingStore{Store: blogstore.NewMongoStore(client, collection), collection: collection}
+	})
+}
+
+type droppingStore struct {
+	blogstore.Store
+	collection *mongo.Collection
+}
+
+func (s *droppingStore) Close(ctx context.Context) error {
+	s.collection.Drop(ctx)
+	return s.Store.Close(ctx)
+}
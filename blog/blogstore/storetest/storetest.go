@@ -0,0 +1,877 @@
+// Package storetest is a conformance suite for blogstore.Store
+// implementations, so that every backend behaves the same.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/blog/blogstore"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Run runs the whole suite. newStore must return an empty store, it is
+// called once per subtest and the store is closed at the end of it.
+func Run(t *testing.T, newStore func(t *testing.T) blogstore.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s blogstore.Store)
+	}{
+		{"CreateRead", testCreateRead},
+		{"ReadErrors", testReadErrors},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"Put", testPut},
+		{"PutReactors", testPutReactors},
+		{"ListOrder", testListOrder},
+		{"ListFilter", testListFilter},
+		{"ListPagination", testListPagination},
+		{"ListDescending", testListDescending},
+		{"Tags", testTags},
+		{"Moderation", testModeration},
+		{"ListStops", testListStops},
+		{"ListBatches", testListBatches},
+		{"React", testReact},
+		{"AddViews", testAddViews},
+		{"Transaction", testTransaction},
+		{"Access", testAccess},
+		{"Series", testSeries},
+		{"PutSeries", testPutSeries},
+		{"SeriesConcurrentAdd", testSeriesConcurrentAdd},
+		{"Translations", testTranslations},
+		{"TextStats", testTextStats},
+		{"TransactionRollback", testTransactionRollback},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStore(t)
+			defer s.Close(context.Background())
+			tt.fn(t, s)
+		})
+	}
+}
+
+func create(t *testing.T, s blogstore.Store, author, title string) *blogstore.Item {
+	t.Helper()
+	data, err := s.Create(context.Background(), &blogstore.Item{AuthorId: author, Title: title, Content: "content of " + title})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return data
+}
+
+func list(t *testing.T, s blogstore.Store, opts blogstore.ListOptions) []string {
+	t.Helper()
+	var titles []string
+	err := s.List(context.Background(), opts, func(data *blogstore.Item) error {
+		titles = append(titles, data.Title)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List(%+v): %v", opts, err)
+	}
+	return titles
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testCreateRead(t *testing.T, s blogstore.Store) {
+	created := create(t, s, "victor", "first")
+	if created.ID.IsZero() {
+		t.Fatal("Create did not set the ID")
+	}
+	if created.CreatedAt.IsZero() || !created.UpdatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Create timestamps = %v, %v", created.CreatedAt, created.UpdatedAt)
+	}
+
+	read, err := s.Read(context.Background(), created.ID.Hex())
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if read.ID != created.ID || read.AuthorId != "victor" || read.Title != "first" || read.Content != "content of first" {
+		t.Errorf("Read = %+v, want %+v", read, created)
+	}
+	if !read.CreatedAt.Equal(created.CreatedAt) || !read.UpdatedAt.Equal(created.UpdatedAt) {
+		t.Errorf("Read timestamps = %v, %v, want %v", read.CreatedAt, read.UpdatedAt, created.CreatedAt)
+	}
+}
+
+func testReadErrors(t *testing.T, s blogstore.Store) {
+	if _, err := s.Read(context.Background(), "213aad"); err != blogstore.ErrInvalidID {
+		t.Errorf("Read(invalid) error = %v, want ErrInvalidID", err)
+	}
+	if _, err := s.Read(context.Background(), primitive.NewObjectID().Hex()); err != blogstore.ErrNotFound {
+		t.Errorf("Read(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testUpdate(t *testing.T, s blogstore.Store) {
+	created := create(t, s, "victor", "first")
+
+	updated, err := s.Update(context.Background(), created.ID.Hex(), &blogstore.Item{AuthorId: "other", Title: "edited", Content: "new"})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.ID != created.ID || updated.AuthorId != "other" || updated.Title != "edited" || updated.Content != "new" {
+		t.Errorf("Update = %+v", updated)
+	}
+	if !updated.CreatedAt.Equal(created.CreatedAt) || updated.UpdatedAt.Before(created.UpdatedAt) {
+		t.Errorf("Update timestamps = %v, %v, created %v", updated.CreatedAt, updated.UpdatedAt, created.CreatedAt)
+	}
+
+	read, err := s.Read(context.Background(), created.ID.Hex())
+	if err != nil || read.Title != "edited" {
+		t.Errorf("Read after Update = %+v, %v", read, err)
+	}
+
+	if _, err := s.Update(context.Background(), primitive.NewObjectID().Hex(), &blogstore.Item{}); err != blogstore.ErrNotFound {
+		t.Errorf("Update(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Update(context.Background(), "nope", &blogstore.Item{}); err != blogstore.ErrInvalidID {
+		t.Errorf("Update(invalid) error = %v, want ErrInvalidID", err)
+	}
+}
+
+func testDelete(t *testing.T, s blogstore.Store) {
+	created := create(t, s, "victor", "first")
+
+	if err := s.Delete(context.Background(), created.ID.Hex()); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Read(context.Background(), created.ID.Hex()); err != blogstore.ErrNotFound {
+		t.Errorf("Read after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(context.Background(), created.ID.Hex()); err != blogstore.ErrNotFound {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(context.Background(), "nope"); err != blogstore.ErrInvalidID {
+		t.Errorf("Delete(invalid) error = %v, want ErrInvalidID", err)
+	}
+}
+
+func testPut(t *testing.T, s blogstore.Store) {
+	created := create(t, s, "victor", "first")
+
+	imported := *created
+	imported.Title = "imported"
+	if err := s.Put(context.Background(), &imported, false); err != blogstore.ErrAlreadyExists {
+		t.Errorf("Put(existing) error = %v, want ErrAlreadyExists", err)
+	}
+	if err := s.Put(context.Background(), &imported, true); err != nil {
+		t.Fatalf("Put(overwrite): %v", err)
+	}
+	read, err := s.Read(context.Background(), created.ID.Hex())
+	if err != nil || read.Title != "imported" || !read.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Read after Put = %+v, %v", read, err)
+	}
+
+	fresh := blogstore.Item{ID: primitive.NewObjectID(), AuthorId: "a", Title: "fresh"}
+	if err := s.Put(context.Background(), &fresh, false); err != nil {
+		t.Fatalf("Put(new): %v", err)
+	}
+	read, err = s.Read(context.Background(), fresh.ID.Hex())
+	if err != nil || read.Title != "fresh" || !read.CreatedAt.IsZero() {
+		t.Errorf("Read after Put(new) = %+v, %v", read, err)
+	}
+}
+
+func testPutReactors(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+	created := create(t, s, "victor", "first")
+	id := created.ID.Hex()
+	if _, _, err := s.React(ctx, id, "ana", blogstore.ReactionLike, false); err != nil {
+		t.Fatal(err)
+	}
+
+	reactors := func() map[string][]string {
+		t.Helper()
+		var got map[string][]string
+		err := s.List(ctx, blogstore.ListOptions{Reactors: true}, func(data *blogstore.Item) error {
+			got = data.Reactors
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("List(Reactors): %v", err)
+		}
+		return got
+	}
+	if got := reactors(); len(got) != 1 || !equal(got[blogstore.ReactionLike], []string{"ana"}) {
+		t.Errorf("Reactors = %v, want ana likes", got)
+	}
+	if got := list(t, s, blogstore.ListOptions{}); len(got) != 1 {
+		t.Fatalf("List = %v", got)
+	}
+	if read, err := s.Read(ctx, id); err != nil || read.Reactors != nil {
+		t.Errorf("Read = %+v, %v, want no reactors", read, err)
+	}
+
+	// overwriting replaces the reactors, which still deduplicate reactions
+	imported := *created
+	imported.Reactions = map[string]int64{blogstore.ReactionLike: 2}
+	imported.Reactors = map[string][]string{blogstore.ReactionLike: {"bob", "carl"}}
+	if err := s.Put(ctx, &imported, true); err != nil {
+		t.Fatalf("Put(overwrite): %v", err)
+	}
+	if got := reactors(); len(got) != 1 || !equal(got[blogstore.ReactionLike], []string{"bob", "carl"}) {
+		t.Errorf("Reactors after Put = %v, want bob and carl likes", got)
+	}
+	if data, changed, err := s.React(ctx, id, "bob", blogstore.ReactionLike, false); err != nil || changed || data.Reactions[blogstore.ReactionLike] != 2 {
+		t.Errorf("React(bob) = %+v, %v, %v, want no change", data, changed, err)
+	}
+	if data, changed, err := s.React(ctx, id, "ana", blogstore.ReactionLike, false); err != nil || !changed || data.Reactions[blogstore.ReactionLike] != 3 {
+		t.Errorf("React(ana) = %+v, %v, %v, want a new like", data, changed, err)
+	}
+
+	// so do transactions
+	imported.Reactors = nil
+	err := s.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		return tx.Put(ctx, &imported, true)
+	})
+	if err != nil {
+		t.Fatalf("Put in a transaction: %v", err)
+	}
+	if got := reactors(); len(got) != 0 {
+		t.Errorf("Reactors after Put in a transaction = %v, want none", got)
+	}
+}
+
+func testListOrder(t *testing.T, s blogstore.Store) {
+	if got := list(t, s, blogstore.ListOptions{}); len(got) != 0 {
+		t.Errorf("List(empty) = %v", got)
+	}
+
+	for _, title := range []string{"a", "b", "c"} {
+		create(t, s, "victor", title)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{}), []string{"a", "b", "c"}; !equal(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+}
+
+func testListFilter(t *testing.T, s blogstore.Store) {
+	create(t, s, "victor", "a")
+	create(t, s, "ana", "b")
+	create(t, s, "victor", "c")
+
+	if got, want := list(t, s, blogstore.ListOptions{AuthorId: "victor"}), []string{"a", "c"}; !equal(got, want) {
+		t.Errorf("List(victor) = %v, want %v", got, want)
+	}
+	if got := list(t, s, blogstore.ListOptions{AuthorId: "nobody"}); len(got) != 0 {
+		t.Errorf("List(nobody) = %v", got)
+	}
+}
+
+func testListPagination(t *testing.T, s blogstore.Store) {
+	var items []*blogstore.Item
+	for _, title := range []string{"a", "b", "c", "d", "e"} {
+		items = append(items, create(t, s, "victor", title))
+	}
+
+	if got, want := list(t, s, blogstore.ListOptions{Limit: 2}), []string{"a", "b"}; !equal(got, want) {
+		t.Errorf("first page = %v, want %v", got, want)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{AfterID: items[1].ID.Hex(), Limit: 2}), []string{"c", "d"}; !equal(got, want) {
+		t.Errorf("second page = %v, want %v", got, want)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{AfterID: items[3].ID.Hex(), Limit: 2}), []string{"e"}; !equal(got, want) {
+		t.Errorf("last page = %v, want %v", got, want)
+	}
+	if got := list(t, s, blogstore.ListOptions{AfterID: items[4].ID.Hex()}); len(got) != 0 {
+		t.Errorf("page after the end = %v", got)
+	}
+	// the ID used as cursor does not need to exist anymore
+	if err := s.Delete(context.Background(), items[2].ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{AfterID: items[2].ID.Hex()}), []string{"d", "e"}; !equal(got, want) {
+		t.Errorf("page after a deleted blog = %v, want %v", got, want)
+	}
+
+	err := s.List(context.Background(), blogstore.ListOptions{AfterID: "nope"}, func(*blogstore.Item) error { return nil })
+	if err != blogstore.ErrInvalidID {
+		t.Errorf("List(invalid after) error = %v, want ErrInvalidID", err)
+	}
+}
+
+func testListStops(t *testing.T, s blogstore.Store) {
+	for _, title := range []string{"a", "b", "c"} {
+		create(t, s, "victor", title)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := s.List(context.Background(), blogstore.ListOptions{}, func(*blogstore.Item) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("List returned %v after %v calls, want the callback error after 1 call", err, calls)
+	}
+}
+
+// testListBatches writes from the callback, which must not wait for the
+// end of the listing
+func testListBatches(t *testing.T, s blogstore.Store) {
+	for _, title := range []string{"a", "b", "c", "d", "e"} {
+		create(t, s, "victor", title)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var titles []string
+	err := s.List(ctx, blogstore.ListOptions{BatchSize: 2}, func(data *blogstore.Item) error {
+		titles = append(titles, data.Title)
+		data.Title += "!"
+		_, err := s.Update(ctx, data.ID.Hex(), data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("List with writes: %v", err)
+	}
+	if want := []string{"a", "b", "c", "d", "e"}; !equal(titles, want) {
+		t.Errorf("List in batches of 2 = %v, want %v", titles, want)
+	}
+
+	if got, want := list(t, s, blogstore.ListOptions{BatchSize: 2, Limit: 3}), []string{"a!", "b!", "c!"}; !equal(got, want) {
+		t.Errorf("List(limit 3) in batches of 2 = %v, want %v", got, want)
+	}
+}
+
+func testReact(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+	created := create(t, s, "victor", "first")
+	id := created.ID.Hex()
+
+	react := func(user, reaction string, remove bool, wantChanged bool, wantCount int64) {
+		t.Helper()
+		data, changed, err := s.React(ctx, id, user, reaction, remove)
+		if err != nil {
+			t.Fatalf("React(%v, %v, %v): %v", user, reaction, remove, err)
+		}
+		if changed != wantChanged || data.Reactions[reaction] != wantCount {
+			t.Errorf("React(%v, %v, %v) = %v, %v, want %v, %v", user, reaction, remove, data.Reactions[reaction], changed, wantCount, wantChanged)
+		}
+	}
+
+	react("ana", blogstore.ReactionLike, false, true, 1)
+	react("ana", blogstore.ReactionLike, false, false, 1)
+	react("bob", blogstore.ReactionLike, false, true, 2)
+	react("ana", blogstore.ReactionClap, false, true, 1)
+	react("ana", blogstore.ReactionLike, true, true, 1)
+	react("ana", blogstore.ReactionLike, true, false, 1)
+	react("carl", blogstore.ReactionClap, true, false, 1)
+
+	read, err := s.Read(ctx, id)
+	if err != nil || read.Reactions[blogstore.ReactionLike] != 1 || read.Reactions[blogstore.ReactionClap] != 1 {
+		t.Errorf("Read after React = %+v, %v", read, err)
+	}
+
+	if _, err := s.Update(ctx, id, &blogstore.Item{Title: "edited"}); err != nil {
+		t.Fatal(err)
+	}
+	react("bob", blogstore.ReactionLike, false, false, 1)
+
+	if _, _, err := s.React(ctx, id, "ana", "boo", false); err != blogstore.ErrInvalidReaction {
+		t.Errorf("React(unknown) error = %v, want ErrInvalidReaction", err)
+	}
+	if _, _, err := s.React(ctx, primitive.NewObjectID().Hex(), "ana", blogstore.ReactionLike, false); err != blogstore.ErrNotFound {
+		t.Errorf("React(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testAddViews(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+	a := create(t, s, "victor", "a")
+	b := create(t, s, "victor", "b")
+
+	views := map[string]int64{a.ID.Hex(): 3, b.ID.Hex(): 1, primitive.NewObjectID().Hex(): 5, "nope": 1}
+	if err := s.AddViews(ctx, views); err != nil {
+		t.Fatalf("AddViews: %v", err)
+	}
+	if err := s.AddViews(ctx, map[string]int64{a.ID.Hex(): 2}); err != nil {
+		t.Fatalf("AddViews: %v", err)
+	}
+
+	for id, want := range map[string]int64{a.ID.Hex(): 5, b.ID.Hex(): 1} {
+		read, err := s.Read(ctx, id)
+		if err != nil || read.Views != want {
+			t.Errorf("Read(%v).Views = %+v, %v, want %v", id, read, err, want)
+		}
+	}
+}
+
+func testListDescending(t *testing.T, s blogstore.Store) {
+	var items []*blogstore.Item
+	for _, title := range []string{"a", "b", "c", "d"} {
+		items = append(items, create(t, s, "victor", title))
+	}
+
+	if got, want := list(t, s, blogstore.ListOptions{Descending: true, Limit: 3}), []string{"d", "c", "b"}; !equal(got, want) {
+		t.Errorf("first page = %v, want %v", got, want)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{Descending: true, AfterID: items[2].ID.Hex()}), []string{"b", "a"}; !equal(got, want) {
+		t.Errorf("second page = %v, want %v", got, want)
+	}
+}
+
+func testTags(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+
+	a, err := s.Create(ctx, &blogstore.Item{Title: "a", Tags: []string{"go", "grpc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, &blogstore.Item{Title: "b", Tags: []string{"go"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create(ctx, &blogstore.Item{Title: "c", Tags: []string{"golang"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := s.Read(ctx, a.ID.Hex())
+	if err != nil || !equal(read.Tags, []string{"go", "grpc"}) {
+		t.Errorf("Read tags = %+v, %v", read, err)
+	}
+
+	if got, want := list(t, s, blogstore.ListOptions{Tag: "go"}), []string{"a", "b"}; !equal(got, want) {
+		t.Errorf("List(go) = %v, want %v", got, want)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{Tag: "grpc"}), []string{"a"}; !equal(got, want) {
+		t.Errorf("List(grpc) = %v, want %v", got, want)
+	}
+
+	if _, err := s.Update(ctx, a.ID.Hex(), &blogstore.Item{Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(t, s, blogstore.ListOptions{Tag: "grpc"}); len(got) != 0 {
+		t.Errorf("List(grpc) after removing the tag = %v", got)
+	}
+	read, err = s.Read(ctx, a.ID.Hex())
+	if err != nil || len(read.Tags) != 0 {
+		t.Errorf("Read tags after Update = %+v, %v", read, err)
+	}
+}
+
+func testModeration(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+
+	create(t, s, "victor", "published")
+	pending, err := s.Create(ctx, &blogstore.Item{Title: "pending", ModerationStatus: blogstore.ModerationPending, ModerationReason: "too many links"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := list(t, s, blogstore.ListOptions{}), []string{"published"}; !equal(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{Moderation: blogstore.ModerationPending}), []string{"pending"}; !equal(got, want) {
+		t.Errorf("List(pending) = %v, want %v", got, want)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{Moderation: blogstore.ModerationAny}), []string{"published", "pending"}; !equal(got, want) {
+		t.Errorf("List(any) = %v, want %v", got, want)
+	}
+
+	read, err := s.Read(ctx, pending.ID.Hex())
+	if err != nil || read.ModerationStatus != blogstore.ModerationPending || read.ModerationReason != "too many links" {
+		t.Errorf("Read(pending) = %+v, %v", read, err)
+	}
+
+	approved, err := s.SetModeration(ctx, pending.ID.Hex(), "", "")
+	if err != nil || approved.ModerationStatus != "" || !approved.UpdatedAt.Equal(pending.UpdatedAt) {
+		t.Errorf("SetModeration = %+v, %v", approved, err)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{}), []string{"published", "pending"}; !equal(got, want) {
+		t.Errorf("List after approval = %v, want %v", got, want)
+	}
+
+	if _, err := s.Update(ctx, pending.ID.Hex(), &blogstore.Item{Title: "pending", ModerationStatus: blogstore.ModerationRejected}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{Moderation: blogstore.ModerationRejected}), []string{"pending"}; !equal(got, want) {
+		t.Errorf("List(rejected) = %v, want %v", got, want)
+	}
+
+	if _, err := s.SetModeration(ctx, primitive.NewObjectID().Hex(), "", ""); err != blogstore.ErrNotFound {
+		t.Errorf("SetModeration(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testTransaction(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+	a := create(t, s, "walter", "a")
+	b := create(t, s, "walter", "b")
+
+	err := s.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		if _, err := tx.Update(ctx, a.ID.Hex(), &blogstore.Item{AuthorId: "walter", Title: "a2"}); err != nil {
+			return err
+		}
+		if read, err := tx.Read(ctx, a.ID.Hex()); err != nil || read.Title != "a2" {
+			t.Errorf("Read in the transaction = %+v, %v", read, err)
+		}
+		if err := tx.Delete(ctx, b.ID.Hex()); err != nil {
+			return err
+		}
+		if _, err := tx.Read(ctx, b.ID.Hex()); err != blogstore.ErrNotFound {
+			t.Errorf("Read deleted in the transaction error = %v, want ErrNotFound", err)
+		}
+		if err := tx.Delete(ctx, b.ID.Hex()); err != blogstore.ErrNotFound {
+			t.Errorf("Delete twice error = %v, want ErrNotFound", err)
+		}
+		return tx.Put(ctx, &blogstore.Item{ID: primitive.NewObjectID(), AuthorId: "walter", Title: "c"}, false)
+	})
+	if err != nil {
+		t.Fatalf("RunInTx: %v", err)
+	}
+
+	if got, want := list(t, s, blogstore.ListOptions{}), []string{"a2", "c"}; !equal(got, want) {
+		t.Errorf("List after commit = %v, want %v", got, want)
+	}
+}
+
+func testTransactionRollback(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+	a := create(t, s, "xavier", "a")
+	b := create(t, s, "xavier", "b")
+
+	failure := errors.New("failure")
+	err := s.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		if _, err := tx.Update(ctx, a.ID.Hex(), &blogstore.Item{AuthorId: "xavier", Title: "a2"}); err != nil {
+			return err
+		}
+		if _, err := tx.SetModeration(ctx, a.ID.Hex(), blogstore.ModerationPending, "held"); err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, b.ID.Hex()); err != nil {
+			return err
+		}
+		if err := tx.Put(ctx, &blogstore.Item{ID: primitive.NewObjectID(), AuthorId: "xavier", Title: "c"}, false); err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("RunInTx error = %v, want %v", err, failure)
+	}
+
+	if got, want := list(t, s, blogstore.ListOptions{Moderation: blogstore.ModerationAny}), []string{"a", "b"}; !equal(got, want) {
+		t.Errorf("List after rollback = %v, want %v", got, want)
+	}
+
+	err = s.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		return tx.Put(ctx, &blogstore.Item{ID: a.ID, Title: "conflict"}, false)
+	})
+	if err != blogstore.ErrAlreadyExists {
+		t.Errorf("Put in a transaction error = %v, want ErrAlreadyExists", err)
+	}
+}
+
+func testAccess(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+
+	create(t, s, "yara", "public")
+	var ids []string
+	for _, visibility := range []string{blogstore.VisibilityUnlisted, blogstore.VisibilityPrivate} {
+		data, err := s.Create(ctx, &blogstore.Item{AuthorId: "yara", Title: visibility, Visibility: visibility})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, data.ID.Hex())
+	}
+	unlisted, private := ids[0], ids[1]
+
+	acl := []blogstore.ACLEntry{
+		{Principal: "user:zoe", Role: blogstore.RoleReader},
+		{Principal: "group:staff", Role: blogstore.RoleEditor},
+	}
+	data, err := s.SetACL(ctx, private, acl)
+	if err != nil || len(data.ACL) != 2 || data.ACL[1] != acl[1] {
+		t.Fatalf("SetACL = %+v, %v", data, err)
+	}
+	if _, err := s.SetACL(ctx, primitive.NewObjectID().Hex(), acl); err != blogstore.ErrNotFound {
+		t.Errorf("SetACL(missing) error = %v, want ErrNotFound", err)
+	}
+
+	tests := []struct {
+		viewer *blogstore.Viewer
+		want   []string
+	}{
+		{nil, []string{"public", "unlisted", "private"}},
+		{&blogstore.Viewer{}, []string{"public"}},
+		{&blogstore.Viewer{UserId: "yara"}, []string{"public", "unlisted", "private"}},
+		{&blogstore.Viewer{UserId: "zoe"}, []string{"public", "private"}},
+		{&blogstore.Viewer{UserId: "zo"}, []string{"public"}},
+		{&blogstore.Viewer{UserId: "adam", Groups: []string{"dev", "staff"}}, []string{"public", "private"}},
+	}
+	for _, tt := range tests {
+		if got := list(t, s, blogstore.ListOptions{Viewer: tt.viewer}); !equal(got, tt.want) {
+			t.Errorf("List(%+v) = %v, want %v", tt.viewer, got, tt.want)
+		}
+	}
+
+	// Update changes the visibility and keeps the ACL
+	if _, err := s.Update(ctx, unlisted, &blogstore.Item{AuthorId: "yara", Title: "unlisted"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{Viewer: &blogstore.Viewer{}}), []string{"public", "unlisted"}; !equal(got, want) {
+		t.Errorf("List after publishing = %v, want %v", got, want)
+	}
+	if _, err := s.Update(ctx, private, &blogstore.Item{AuthorId: "yara", Title: "private", Visibility: blogstore.VisibilityPrivate}); err != nil {
+		t.Fatal(err)
+	}
+	read, err := s.Read(ctx, private)
+	if err != nil || len(read.ACL) != 2 || read.Visibility != blogstore.VisibilityPrivate {
+		t.Errorf("Read after Update = %+v, %v", read, err)
+	}
+
+	if data, err := s.SetACL(ctx, private, nil); err != nil || len(data.ACL) != 0 {
+		t.Errorf("SetACL(nil) = %+v, %v", data, err)
+	}
+	if got, want := list(t, s, blogstore.ListOptions{Viewer: &blogstore.Viewer{UserId: "zoe"}}), []string{"public", "unlisted"}; !equal(got, want) {
+		t.Errorf("List after unsharing = %v, want %v", got, want)
+	}
+}
+
+func testSeries(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+	a, b, c := create(t, s, "bruno", "a").ID.Hex(), create(t, s, "bruno", "b").ID.Hex(), create(t, s, "bruno", "c").ID.Hex()
+
+	series, err := s.CreateSeries(ctx, &blogstore.Series{AuthorId: "bruno", Title: "part", BlogIDs: []string{a, b}})
+	if err != nil {
+		t.Fatalf("CreateSeries: %v", err)
+	}
+	id := series.ID.Hex()
+	if series.ID.IsZero() || series.CreatedAt.IsZero() {
+		t.Errorf("CreateSeries = %+v, want an ID and a creation time", series)
+	}
+
+	read, err := s.ReadSeries(ctx, id)
+	if err != nil || read.Title != "part" || !equal(read.BlogIDs, []string{a, b}) {
+		t.Errorf("ReadSeries = %+v, %v", read, err)
+	}
+	if _, err := s.ReadSeries(ctx, primitive.NewObjectID().Hex()); err != blogstore.ErrSeriesNotFound {
+		t.Errorf("ReadSeries(missing) error = %v, want ErrSeriesNotFound", err)
+	}
+
+	updated, err := s.UpdateSeries(ctx, id, &blogstore.Series{Title: "parts", BlogIDs: []string{c, a}})
+	if err != nil || updated.Title != "parts" || !equal(updated.BlogIDs, []string{c, a}) || updated.AuthorId != "bruno" {
+		t.Errorf("UpdateSeries = %+v, %v", updated, err)
+	}
+
+	if found, err := s.FindSeries(ctx, c); err != nil || found.ID != series.ID {
+		t.Errorf("FindSeries(c) = %+v, %v", found, err)
+	}
+	if _, err := s.FindSeries(ctx, b); err != blogstore.ErrSeriesNotFound {
+		t.Errorf("FindSeries(b) error = %v, want ErrSeriesNotFound", err)
+	}
+
+	// a blog belongs to a single series
+	other, err := s.CreateSeries(ctx, &blogstore.Series{Title: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateSeries(ctx, &blogstore.Series{Title: "dup", BlogIDs: []string{a}}); err != blogstore.ErrInSeries {
+		t.Errorf("CreateSeries(a) error = %v, want ErrInSeries", err)
+	}
+	if _, err := s.UpdateSeries(ctx, other.ID.Hex(), &blogstore.Series{Title: "other", BlogIDs: []string{b, c}}); err != blogstore.ErrInSeries {
+		t.Errorf("UpdateSeries(c) error = %v, want ErrInSeries", err)
+	}
+
+	// series writes take part in transactions
+	failure := errors.New("failure")
+	err = s.RunInTx(ctx, func(ctx context.Context, tx blogstore.Tx) error {
+		if _, err := tx.UpdateSeries(ctx, id, &blogstore.Series{Title: "parts"}); err != nil {
+			return err
+		}
+		if found, err := tx.FindSeries(ctx, c); err != blogstore.ErrSeriesNotFound {
+			t.Errorf("FindSeries in the transaction = %+v, %v", found, err)
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("RunInTx error = %v, want %v", err, failure)
+	}
+	if read, err := s.ReadSeries(ctx, id); err != nil || !equal(read.BlogIDs, []string{c, a}) {
+		t.Errorf("ReadSeries after rollback = %+v, %v", read, err)
+	}
+
+	if err := s.DeleteSeries(ctx, id); err != nil {
+		t.Fatalf("DeleteSeries: %v", err)
+	}
+	if err := s.DeleteSeries(ctx, id); err != blogstore.ErrSeriesNotFound {
+		t.Errorf("DeleteSeries twice error = %v, want ErrSeriesNotFound", err)
+	}
+	if _, err := s.FindSeries(ctx, a); err != blogstore.ErrSeriesNotFound {
+		t.Errorf("FindSeries after delete error = %v, want ErrSeriesNotFound", err)
+	}
+}
+
+// testSeriesConcurrentAdd adds the same blog to two series at once, the
+// unique index must let a single series have it
+func testSeriesConcurrentAdd(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+	first, err := s.CreateSeries(ctx, &blogstore.Series{Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.CreateSeries(ctx, &blogstore.Series{Title: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := []*blogstore.Series{first, second}
+
+	for i := 0; i < 10; i++ {
+		id := create(t, s, "bruno", "blog").ID.Hex()
+
+		errs := make([]error, len(series))
+		var wg sync.WaitGroup
+		for j := range series {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				update := &blogstore.Series{Title: series[j].Title, BlogIDs: []string{id}}
+				_, errs[j] = s.UpdateSeries(ctx, series[j].ID.Hex(), update)
+			}(j)
+		}
+		wg.Wait()
+
+		winner := -1
+		for j, err := range errs {
+			switch err {
+			case nil:
+				if winner >= 0 {
+					t.Fatalf("blog %v was added to both series", i)
+				}
+				winner = j
+			case blogstore.ErrInSeries:
+			default:
+				t.Fatalf("UpdateSeries(%v) error = %v, want nil or ErrInSeries", series[j].Title, err)
+			}
+		}
+		if winner < 0 {
+			t.Fatalf("blog %v was added to no series", i)
+		}
+		if found, err := s.FindSeries(ctx, id); err != nil || found.ID != series[winner].ID {
+			t.Errorf("FindSeries(%v) = %+v, %v, want %v", i, found, err, series[winner].Title)
+		}
+	}
+}
+
+func testPutSeries(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+	a, b := create(t, s, "bruno", "a").ID.Hex(), create(t, s, "bruno", "b").ID.Hex()
+
+	first, err := s.CreateSeries(ctx, &blogstore.Series{AuthorId: "bruno", Title: "first", BlogIDs: []string{a}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := *first
+	imported.Title = "imported"
+	imported.BlogIDs = []string{b, a}
+	if err := s.PutSeries(ctx, &imported, false); err != blogstore.ErrSeriesExists {
+		t.Errorf("PutSeries(existing) error = %v, want ErrSeriesExists", err)
+	}
+	if err := s.PutSeries(ctx, &imported, true); err != nil {
+		t.Fatalf("PutSeries(overwrite): %v", err)
+	}
+	read, err := s.ReadSeries(ctx, first.ID.Hex())
+	if err != nil || read.Title != "imported" || !equal(read.BlogIDs, []string{b, a}) || !read.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("ReadSeries after PutSeries = %+v, %v", read, err)
+	}
+
+	fresh := blogstore.Series{ID: primitive.NewObjectID(), AuthorId: "bruno", Title: "fresh", BlogIDs: []string{a}}
+	if err := s.PutSeries(ctx, &fresh, false); err != blogstore.ErrInSeries {
+		t.Errorf("PutSeries(a) error = %v, want ErrInSeries", err)
+	}
+	fresh.BlogIDs = nil
+	if err := s.PutSeries(ctx, &fresh, false); err != nil {
+		t.Fatalf("PutSeries(new): %v", err)
+	}
+
+	var titles []string
+	err = s.ListSeries(ctx, func(series *blogstore.Series) error {
+		titles = append(titles, series.Title)
+		return nil
+	})
+	if err != nil || !equal(titles, []string{"imported", "fresh"}) {
+		t.Errorf("ListSeries = %v, %v, want imported and fresh", titles, err)
+	}
+}
+
+func testTranslations(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+
+	data, err := s.Create(ctx, &blogstore.Item{AuthorId: "carla", Title: "hello", Content: "world", Locale: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := data.ID.Hex()
+
+	translations := map[string]blogstore.Translation{
+		"pt-BR": {Title: "olá", Content: "mundo"},
+		"es":    {Title: "hola", Content: "mundo"},
+	}
+	updated, err := s.SetTranslations(ctx, id, translations)
+	if err != nil || len(updated.Translations) != 2 || updated.Translations["pt-BR"].Title != "olá" || updated.UpdatedAt.Before(data.UpdatedAt) {
+		t.Fatalf("SetTranslations = %+v, %v", updated, err)
+	}
+
+	// Update keeps the translations
+	if _, err := s.Update(ctx, id, &blogstore.Item{AuthorId: "carla", Title: "hi", Content: "world", Locale: "en-GB"}); err != nil {
+		t.Fatal(err)
+	}
+	read, err := s.Read(ctx, id)
+	if err != nil || read.Locale != "en-GB" || read.Translations["es"] != translations["es"] {
+		t.Errorf("Read after Update = %+v, %v", read, err)
+	}
+
+	if updated, err := s.SetTranslations(ctx, id, nil); err != nil || len(updated.Translations) != 0 {
+		t.Errorf("SetTranslations(nil) = %+v, %v", updated, err)
+	}
+	if _, err := s.SetTranslations(ctx, primitive.NewObjectID().Hex(), translations); err != blogstore.ErrNotFound {
+		t.Errorf("SetTranslations(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func testTextStats(t *testing.T, s blogstore.Store) {
+	ctx := context.Background()
+
+	stats := blogstore.TextStats{WordCount: 2, ReadingTimeMinutes: 1, Excerpt: "hello world"}
+	data, err := s.Create(ctx, &blogstore.Item{AuthorId: "dora", Title: "hi", Content: "hello *world*", TextStats: stats})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := data.ID.Hex()
+	if read, err := s.Read(ctx, id); err != nil || read.TextStats != stats {
+		t.Errorf("Read after Create = %+v, %v", read, err)
+	}
+
+	stats = blogstore.TextStats{WordCount: 1, ReadingTimeMinutes: 1, Excerpt: "bye"}
+	if updated, err := s.Update(ctx, id, &blogstore.Item{AuthorId: "dora", Title: "hi", Content: "bye", TextStats: stats}); err != nil || updated.TextStats != stats {
+		t.Errorf("Update = %+v, %v", updated, err)
+	}
+
+	translation := blogstore.Translation{Title: "oi", Content: "tchau", TextStats: blogstore.TextStats{WordCount: 1, ReadingTimeMinutes: 1, Excerpt: "tchau"}}
+	if _, err := s.SetTranslations(ctx, id, map[string]blogstore.Translation{"pt": translation}); err != nil {
+		t.Fatal(err)
+	}
+	read, err := s.Read(ctx, id)
+	if err != nil || read.TextStats != stats || read.Translations["pt"] != translation {
+		t.Errorf("Read after SetTranslations = %+v, %v", read, err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package moderation checks blogs before they are published. A Pipeline
+// runs a chain of rules, each of them may allow the blog, reject it or
+// queue it for a human review.
+package moderation
+
+import (
+	"context"
+	"fmt"
+)
+
+// Decision is the outcome of a rule, ordered by severity
+type Decision int
+
+const (
+	Allow Decision = iota
+	Review
+	Reject
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Allow:
+		return "allow"
+	case Review:
+		return "review"
+	case Reject:
+		return "reject"
+	default:
+		return fmt.Sprintf("Decision(%d)", int(d))
+	}
+}
+
+// Verdict is returned by rules and by the pipeline
+type Verdict struct {
+	Decision Decision
+	// Rule is the name of the rule that took the decision
+	Rule   string
+	Reason string
+}
+
+// Post is the content submitted for moderation
+type Post struct {
+	// ID is empty when the blog is being created
+	ID       string
+	AuthorId string
+	Title    string
+	Content  string
+	Tags     []string
+}
+
+// Rule is the extension point for custom checks. Check must be safe for
+// concurrent use, a non-nil error aborts the moderation.
+type Rule interface {
+	Name() string
+	Check(ctx context.Context, post *Post) (Verdict, error)
+}
+
+// RuleFunc adapts a function to the Rule interface
+func RuleFunc(name string, check func(ctx context.Context, post *Post) (Verdict, error)) Rule {
+	return &funcRule{name: name, check: check}
+}
+
+type funcRule struct {
+	name  string
+	check func(ctx context.Context, post *Post) (Verdict, error)
+}
+
+func (r *funcRule) Name() string { return r.name }
+
+func (r *funcRule) Check(ctx context.Context, post *Post) (Verdict, error) {
+	return r.check(ctx, post)
+}
+
+// Pipeline runs its rules in order. The first rejection stops the chain,
+// otherwise the first rule asking for a review decides the verdict.
+type Pipeline struct {
+	rules []Rule
+}
+
+// NewPipeline returns a pipeline running the given rules
+func NewPipeline(rules ...Rule) *Pipeline {
+	return &Pipeline{rules: rules}
+}
+
+// Use appends rules to the chain. It must not be called concurrently with
+// Moderate.
+func (p *Pipeline) Use(rules ...Rule) {
+	p.rules = append(p.rules, rules...)
+}
+
+// Moderate returns the verdict for the post, an empty pipeline allows
+// everything
+func (p *Pipeline) Moderate(ctx context.Context, post *Post) (Verdict, error) {
+	result := Verdict{Decision: Allow}
+
+	for _, rule := range p.rules {
+		v, err := rule.Check(ctx, post)
+		if err != nil {
+			return Verdict{}, fmt.Errorf("moderation rule %v: %v", rule.Name(), err)
+		}
+		if v.Rule == "" {
+			v.Rule = rule.Name()
+		}
+
+		switch {
+		case v.Decision == Reject:
+			return v, nil
+		case v.Decision == Review && result.Decision == Allow:
+			result = v
+		}
+	}
+
+	return result, nil
+}
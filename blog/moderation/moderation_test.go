@@ -0,0 +1,142 @@
+package moderation_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/blog/moderation"
+)
+
+func TestBannedWords(t *testing.T) {
+	rule := moderation.BannedWords([]string{"Spam", "buy now", "  ", "free-money"})
+
+	tests := []struct {
+		name string
+		post moderation.Post
+		want moderation.Decision
+	}{
+		{"clean", moderation.Post{Title: "Go", Content: "generics are coming"}, moderation.Allow},
+		{"in the content", moderation.Post{Content: "this is spam"}, moderation.Reject},
+		{"in the title", moderation.Post{Title: "SPAM!"}, moderation.Reject},
+		{"in a tag", moderation.Post{Tags: []string{"go", "spam"}}, moderation.Reject},
+		{"part of a word", moderation.Post{Content: "spammers and spamming"}, moderation.Allow},
+		{"phrase", moderation.Post{Content: "Buy now, while it lasts"}, moderation.Reject},
+		{"phrase across punctuation", moderation.Post{Content: "buy... NOW"}, moderation.Reject},
+		{"split phrase", moderation.Post{Content: "buy it now"}, moderation.Allow},
+		{"phrase at the end", moderation.Post{Content: "you should buy"}, moderation.Allow},
+		{"hyphenated phrase", moderation.Post{Content: "free money for all"}, moderation.Reject},
+		{"unicode", moderation.Post{Content: "ça c'est du spam"}, moderation.Reject},
+	}
+	for _, tt := range tests {
+		v, err := rule.Check(context.Background(), &tt.post)
+		if err != nil {
+			t.Fatalf("%v: Check: %v", tt.name, err)
+		}
+		if v.Decision != tt.want {
+			t.Errorf("%v: Check = %v (%v), want %v", tt.name, v.Decision, v.Reason, tt.want)
+		}
+	}
+}
+
+func TestMaxLinks(t *testing.T) {
+	rule := moderation.MaxLinks(2)
+
+	tests := []struct {
+		content string
+		want    moderation.Decision
+	}{
+		{"no links", moderation.Allow},
+		{"see https://golang.org and http://grpc.io", moderation.Allow},
+		{"https://a.com http://b.com ftp://c.com", moderation.Review},
+		{"HTTPS://A.COM www.b.com (www.c.com)", moderation.Review},
+		{"email me at someone@example.com, www dot com", moderation.Allow},
+	}
+	for _, tt := range tests {
+		v, err := rule.Check(context.Background(), &moderation.Post{Content: tt.content})
+		if err != nil {
+			t.Fatalf("Check(%q): %v", tt.content, err)
+		}
+		if v.Decision != tt.want {
+			t.Errorf("Check(%q) = %v (%v), want %v", tt.content, v.Decision, v.Reason, tt.want)
+		}
+	}
+}
+
+func TestMaxContentLength(t *testing.T) {
+	rule := moderation.MaxContentLength(5)
+
+	tests := []struct {
+		content string
+		want    moderation.Decision
+	}{
+		{"", moderation.Allow},
+		{"hello", moderation.Allow},
+		// the length is in characters, not bytes
+		{"héllö", moderation.Allow},
+		{"hello!", moderation.Reject},
+		{strings.Repeat("é", 6), moderation.Reject},
+	}
+	for _, tt := range tests {
+		v, err := rule.Check(context.Background(), &moderation.Post{Content: tt.content})
+		if err != nil {
+			t.Fatalf("Check(%q): %v", tt.content, err)
+		}
+		if v.Decision != tt.want {
+			t.Errorf("Check(%q) = %v (%v), want %v", tt.content, v.Decision, v.Reason, tt.want)
+		}
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	allow := decide("allow", moderation.Allow)
+	review := decide("review", moderation.Review)
+	review2 := decide("review2", moderation.Review)
+	reject := decide("reject", moderation.Reject)
+	failing := moderation.RuleFunc("failing", func(ctx context.Context, post *moderation.Post) (moderation.Verdict, error) {
+		return moderation.Verdict{}, errors.New("unavailable")
+	})
+
+	tests := []struct {
+		name  string
+		rules []moderation.Rule
+		want  moderation.Decision
+		rule  string
+		err   bool
+	}{
+		{"empty", nil, moderation.Allow, "", false},
+		{"allow", []moderation.Rule{allow, allow}, moderation.Allow, "", false},
+		{"first review wins", []moderation.Rule{allow, review, review2}, moderation.Review, "review", false},
+		{"reject wins over review", []moderation.Rule{review, reject}, moderation.Reject, "reject", false},
+		{"reject stops the chain", []moderation.Rule{reject, failing}, moderation.Reject, "reject", false},
+		{"error", []moderation.Rule{allow, failing, reject}, moderation.Allow, "", true},
+	}
+	for _, tt := range tests {
+		v, err := moderation.NewPipeline(tt.rules...).Moderate(context.Background(), &moderation.Post{})
+		if (err != nil) != tt.err {
+			t.Errorf("%v: Moderate error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if v.Decision != tt.want || v.Rule != tt.rule {
+			t.Errorf("%v: Moderate = %v by %q, want %v by %q", tt.name, v.Decision, v.Rule, tt.want, tt.rule)
+		}
+	}
+
+	// rules may name the verdict themselves
+	named := moderation.RuleFunc("named", func(ctx context.Context, post *moderation.Post) (moderation.Verdict, error) {
+		return moderation.Verdict{Decision: moderation.Review, Rule: "named/sub"}, nil
+	})
+	p := moderation.NewPipeline()
+	p.Use(allow, named)
+	if v, err := p.Moderate(context.Background(), &moderation.Post{}); err != nil || v.Rule != "named/sub" {
+		t.Errorf("Moderate = %+v, %v, want the rule named/sub", v, err)
+	}
+}
+
+// decide returns a rule that always takes the decision d
+func decide(name string, d moderation.Decision) moderation.Rule {
+	return moderation.RuleFunc(name, func(ctx context.Context, post *moderation.Post) (moderation.Verdict, error) {
+		return moderation.Verdict{Decision: d}, nil
+	})
+}
@@ -0,0 +1,84 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BannedWords rejects posts whose title, content or tags contain one of
+// the words or phrases. Matching is case-insensitive and on whole words
+// only, the punctuation and spacing between the words of a phrase do not
+// matter.
+func BannedWords(words []string) Rule {
+	// the phrases indexed by their first word
+	banned := map[string][][]string{}
+	for _, w := range words {
+		if phrase := splitWords(w); len(phrase) > 0 {
+			banned[phrase[0]] = append(banned[phrase[0]], phrase)
+		}
+	}
+
+	return RuleFunc("banned-words", func(ctx context.Context, post *Post) (Verdict, error) {
+		texts := append([]string{post.Title, post.Content}, post.Tags...)
+		for _, text := range texts {
+			words := splitWords(text)
+			for i, word := range words {
+				for _, phrase := range banned[word] {
+					if hasPrefix(words[i:], phrase) {
+						return Verdict{Decision: Reject, Reason: fmt.Sprintf("contains the banned word %q", strings.Join(phrase, " "))}, nil
+					}
+				}
+			}
+		}
+		return Verdict{Decision: Allow}, nil
+	})
+}
+
+// splitWords returns the lower-cased words of the text
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), notWordRune)
+}
+
+func hasPrefix(words, prefix []string) bool {
+	if len(words) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if words[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s<>()]+|\bwww\.[^\s<>()]+`)
+
+// MaxLinks queues posts with more than max links for review, they are a
+// common sign of spam
+func MaxLinks(max int) Rule {
+	return RuleFunc("max-links", func(ctx context.Context, post *Post) (Verdict, error) {
+		if n := len(linkPattern.FindAllStringIndex(post.Content, -1)); n > max {
+			return Verdict{Decision: Review, Reason: fmt.Sprintf("has %v links, more than %v", n, max)}, nil
+		}
+		return Verdict{Decision: Allow}, nil
+	})
+}
+
+// MaxContentLength rejects posts whose content is longer than max
+// characters
+func MaxContentLength(max int) Rule {
+	return RuleFunc("max-content-length", func(ctx context.Context, post *Post) (Verdict, error) {
+		if n := utf8.RuneCountInString(post.Content); n > max {
+			return Verdict{Decision: Reject, Reason: fmt.Sprintf("content has %v characters, more than %v", n, max)}, nil
+		}
+		return Verdict{Decision: Allow}, nil
+	})
+}
@@ -0,0 +1,278 @@
+// Package related finds blogs similar to a given one. Similarity combines
+// shared tags, the author and TF-IDF over the title and content.
+package related
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Weights of each similarity signal, they add up to 1 so scores are in [0, 1]
+const (
+	TagWeight    = 0.4
+	AuthorWeight = 0.1
+	TextWeight   = 0.5
+)
+
+// Doc is the indexed part of a blog
+type Doc struct {
+	ID       string
+	AuthorId string
+	Title    string
+	Content  string
+	Tags     []string
+}
+
+// Scored is a related blog with its similarity score
+type Scored struct {
+	ID    string
+	Score float64
+}
+
+type entry struct {
+	authorId string
+	tags     map[string]bool
+	// tf holds the term frequencies of the title and content
+	tf map[string]float64
+}
+
+// Index keeps the term statistics of every indexed blog. It is updated
+// incrementally and is safe for concurrent use.
+type Index struct {
+	mu      sync.RWMutex
+	entries map[string]*entry
+	// postings maps terms, tags and authors to the IDs containing them, to
+	// find the candidates without scanning every blog
+	terms   map[string]map[string]bool
+	tags    map[string]map[string]bool
+	authors map[string]map[string]bool
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{
+		entries: map[string]*entry{},
+		terms:   map[string]map[string]bool{},
+		tags:    map[string]map[string]bool{},
+		authors: map[string]map[string]bool{},
+	}
+}
+
+// Len returns the number of indexed blogs
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.entries)
+}
+
+// Upsert indexes the blog, replacing its previous version
+func (ix *Index) Upsert(doc Doc) {
+	e := &entry{
+		authorId: doc.AuthorId,
+		tags:     map[string]bool{},
+		tf:       termFrequencies(doc.Title + "\n" + doc.Content),
+	}
+	for _, tag := range doc.Tags {
+		e.tags[tag] = true
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(doc.ID)
+	ix.entries[doc.ID] = e
+	for term := range e.tf {
+		addPosting(ix.terms, term, doc.ID)
+	}
+	for tag := range e.tags {
+		addPosting(ix.tags, tag, doc.ID)
+	}
+	if e.authorId != "" {
+		addPosting(ix.authors, e.authorId, doc.ID)
+	}
+}
+
+// Remove drops the blog from the index, unknown IDs are ignored
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+// Replace swaps the whole content of the index, used to rebuild it
+func (ix *Index) Replace(other *Index) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.entries = other.entries
+	ix.terms = other.terms
+	ix.tags = other.tags
+	ix.authors = other.authors
+}
+
+func (ix *Index) remove(id string) {
+	e, ok := ix.entries[id]
+	if !ok {
+		return
+	}
+	delete(ix.entries, id)
+	for term := range e.tf {
+		removePosting(ix.terms, term, id)
+	}
+	for tag := range e.tags {
+		removePosting(ix.tags, tag, id)
+	}
+	if e.authorId != "" {
+		removePosting(ix.authors, e.authorId, id)
+	}
+}
+
+func addPosting(postings map[string]map[string]bool, key, id string) {
+	if postings[key] == nil {
+		postings[key] = map[string]bool{}
+	}
+	postings[key][id] = true
+}
+
+func removePosting(postings map[string]map[string]bool, key, id string) {
+	delete(postings[key], id)
+	if len(postings[key]) == 0 {
+		delete(postings, key)
+	}
+}
+
+// Related returns up to limit blogs most similar to id, best first. The
+// second result is false when id is not indexed.
+func (ix *Index) Related(id string, limit int) ([]Scored, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	source, ok := ix.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	candidates := map[string]bool{}
+	for term := range source.tf {
+		for other := range ix.terms[term] {
+			candidates[other] = true
+		}
+	}
+	for tag := range source.tags {
+		for other := range ix.tags[tag] {
+			candidates[other] = true
+		}
+	}
+	for other := range ix.authors[source.authorId] {
+		candidates[other] = true
+	}
+	delete(candidates, id)
+
+	sourceVec := ix.tfidf(source)
+	var result []Scored
+	for other := range candidates {
+		e := ix.entries[other]
+		score := TagWeight*jaccard(source.tags, e.tags) + TextWeight*cosine(sourceVec, ix.tfidf(e))
+		if source.authorId != "" && source.authorId == e.authorId {
+			score += AuthorWeight
+		}
+		if score > 0 {
+			result = append(result, Scored{ID: other, Score: score})
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].ID < result[j].ID
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, true
+}
+
+// tfidf weights the term frequencies with the current document
+// frequencies, computed at query time so they follow the corpus
+func (ix *Index) tfidf(e *entry) map[string]float64 {
+	n := float64(len(ix.entries))
+	vec := make(map[string]float64, len(e.tf))
+	for term, tf := range e.tf {
+		df := float64(len(ix.terms[term]))
+		vec[term] = tf * (math.Log((1+n)/(1+df)) + 1)
+	}
+	return vec
+}
+
+func cosine(a, b map[string]float64) float64 {
+	var dot, na, nb float64
+	for term, wa := range a {
+		na += wa * wa
+		if wb, ok := b[term]; ok {
+			dot += wa * wb
+		}
+	}
+	for _, wb := range b {
+		nb += wb * wb
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for tag := range a {
+		if b[tag] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// termFrequencies splits the text in lower-cased words and returns their
+// frequency, normalized by the number of words
+func termFrequencies(text string) map[string]float64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	counts := map[string]float64{}
+	total := 0.0
+	for _, w := range words {
+		if len([]rune(w)) < 2 || stopWords[w] {
+			continue
+		}
+		counts[w]++
+		total++
+	}
+	for w := range counts {
+		counts[w] /= total
+	}
+	return counts
+}
+
+// stopWords are frequent English and Portuguese words that carry no meaning
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		a an and are as at be but by for from has have in is it its of on or
+		that the this to was were will with you your we our not can do
+		ao aos as com da das de do dos e em na nas no nos o os para pela pelo
+		por que se sem um uma umas uns é à ou mais mas como foi são ser
+	`) {
+		stopWords[w] = true
+	}
+}
@@ -0,0 +1,139 @@
+package related_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/blog/related"
+)
+
+var docs = []related.Doc{
+	{ID: "grpc", AuthorId: "ana", Title: "Streaming with gRPC", Content: "gRPC streams send messages both ways", Tags: []string{"go", "grpc"}},
+	{ID: "grpc-copy", AuthorId: "ana", Title: "Streaming with gRPC", Content: "gRPC streams send messages both ways", Tags: []string{"go", "grpc"}},
+	{ID: "streams", AuthorId: "bob", Title: "Streams in Go", Content: "channels and streams of messages", Tags: []string{"go"}},
+	{ID: "ana-cooking", AuthorId: "ana", Title: "Bread", Content: "flour water salt yeast"},
+	{ID: "cooking", AuthorId: "carl", Title: "Cake", Content: "flour sugar eggs butter", Tags: []string{"food"}},
+	// only stop words and single letters, it has no terms
+	{ID: "empty", AuthorId: "dan", Title: "A", Content: "the and of x"},
+}
+
+func newIndex() *related.Index {
+	ix := related.NewIndex()
+	for _, doc := range docs {
+		ix.Upsert(doc)
+	}
+	return ix
+}
+
+func TestRelated(t *testing.T) {
+	ix := newIndex()
+
+	tests := []struct {
+		id    string
+		limit int
+		want  []string
+	}{
+		// the copy shares everything, streams the tag go and some terms,
+		// ana-cooking only the author
+		{"grpc", 0, []string{"grpc-copy", "streams", "ana-cooking"}},
+		{"grpc", 2, []string{"grpc-copy", "streams"}},
+		{"cooking", 0, []string{"ana-cooking"}},
+		{"empty", 0, nil},
+	}
+	for _, tt := range tests {
+		got, ok := ix.Related(tt.id, tt.limit)
+		if !ok {
+			t.Fatalf("Related(%q) did not find the blog", tt.id)
+		}
+		if ids := scoredIDs(got); !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Related(%q, %v) = %v, want %v", tt.id, tt.limit, ids, tt.want)
+		}
+		for _, s := range got {
+			if s.Score <= 0 || s.Score > 1+1e-9 {
+				t.Errorf("Related(%q) scored %v %v, want a score in (0, 1]", tt.id, s.ID, s.Score)
+			}
+		}
+	}
+
+	if _, ok := ix.Related("missing", 0); ok {
+		t.Errorf("Related found a blog that is not indexed")
+	}
+}
+
+func TestRelatedScores(t *testing.T) {
+	ix := newIndex()
+	got, _ := ix.Related("grpc", 0)
+	want := map[string]float64{
+		// same tags, same text and same author
+		"grpc-copy":   related.TagWeight + related.TextWeight + related.AuthorWeight,
+		"ana-cooking": related.AuthorWeight,
+	}
+	for _, s := range got {
+		if w, ok := want[s.ID]; ok && math.Abs(s.Score-w) > 1e-9 {
+			t.Errorf("score of %v = %v, want %v", s.ID, s.Score, w)
+		}
+	}
+
+	// the scores are symmetric
+	for _, s := range got {
+		back, _ := ix.Related(s.ID, 0)
+		for _, b := range back {
+			if b.ID == "grpc" && math.Abs(b.Score-s.Score) > 1e-9 {
+				t.Errorf("score of grpc from %v = %v, want %v", s.ID, b.Score, s.Score)
+			}
+		}
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	ix := newIndex()
+	if ix.Len() != len(docs) {
+		t.Fatalf("Len = %v, want %v", ix.Len(), len(docs))
+	}
+
+	// removing the copy
+	ix.Remove("grpc-copy")
+	ix.Remove("missing")
+	if ix.Len() != len(docs)-1 {
+		t.Errorf("Len after Remove = %v, want %v", ix.Len(), len(docs)-1)
+	}
+	if _, ok := ix.Related("grpc-copy", 0); ok {
+		t.Errorf("Related found a removed blog")
+	}
+	got, _ := ix.Related("grpc", 0)
+	if ids := scoredIDs(got); !reflect.DeepEqual(ids, []string{"streams", "ana-cooking"}) {
+		t.Errorf("Related after Remove = %v", ids)
+	}
+
+	// an update replaces the old terms, tags and author
+	ix.Upsert(related.Doc{ID: "cooking", AuthorId: "carl", Title: "gRPC streams", Content: "gRPC streams send messages", Tags: []string{"grpc"}})
+	if ix.Len() != len(docs)-1 {
+		t.Errorf("Len after an update = %v, want %v", ix.Len(), len(docs)-1)
+	}
+	got, _ = ix.Related("grpc", 0)
+	if ids := scoredIDs(got); len(ids) == 0 || ids[0] != "cooking" {
+		t.Errorf("Related after an update = %v, want cooking first", ids)
+	}
+	got, _ = ix.Related("ana-cooking", 0)
+	if ids := scoredIDs(got); !reflect.DeepEqual(ids, []string{"grpc"}) {
+		t.Errorf("Related(ana-cooking) after an update = %v, want [grpc]", ids)
+	}
+
+	// Replace swaps the whole content
+	ix.Replace(related.NewIndex())
+	if ix.Len() != 0 {
+		t.Errorf("Len after Replace = %v, want 0", ix.Len())
+	}
+	if _, ok := ix.Related("grpc", 0); ok {
+		t.Errorf("Related found a blog after Replace")
+	}
+}
+
+func scoredIDs(scored []related.Scored) []string {
+	var ids []string
+	for _, s := range scored {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
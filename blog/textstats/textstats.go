@@ -0,0 +1,188 @@
+// Package textstats computes the word count, reading time and excerpt of
+// the Markdown content of a blog.
+package textstats
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Options of the computed statistics
+type Options struct {
+	// ExcerptLength is the maximum length of the excerpt in characters,
+	// ellipsis included, 0 disables the excerpt
+	ExcerptLength int
+	// WordsPerMinute is the reading speed used for the reading time
+	WordsPerMinute int
+}
+
+// DefaultOptions are the options used by default, a zero WordsPerMinute
+// falls back to its value
+var DefaultOptions = Options{
+	ExcerptLength:  200,
+	WordsPerMinute: 200,
+}
+
+// Stats are the statistics of a text
+type Stats struct {
+	Words int
+	// ReadingTimeMinutes is rounded up, a text with words takes at least
+	// a minute
+	ReadingTimeMinutes int
+	Excerpt            string
+}
+
+// Analyze computes the statistics of the Markdown text
+func Analyze(markdown string, opts Options) Stats {
+	if opts.WordsPerMinute <= 0 {
+		opts.WordsPerMinute = DefaultOptions.WordsPerMinute
+	}
+
+	text := PlainText(markdown)
+	words := CountWords(text)
+	return Stats{
+		Words:              words,
+		ReadingTimeMinutes: (words + opts.WordsPerMinute - 1) / opts.WordsPerMinute,
+		Excerpt:            Excerpt(text, opts.ExcerptLength),
+	}
+}
+
+var (
+	fenceLine      = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+	linkDefinition = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s*\S+`)
+	ruleLine       = regexp.MustCompile(`^\s{0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,}|=+\s*)$`)
+	headingPrefix  = regexp.MustCompile(`^\s{0,3}#{1,6}(?:\s+|$)`)
+	headingSuffix  = regexp.MustCompile(`\s+#+\s*$`)
+	quotePrefix    = regexp.MustCompile(`^\s{0,3}(?:>\s?)+`)
+	listPrefix     = regexp.MustCompile(`^\s*(?:[-*+]|\d{1,9}[.)])\s+(?:\[[ xX]\]\s+)?`)
+
+	escaped      = regexp.MustCompile("\\\\([\\\\`*_{}\\[\\]()#+\\-.!>|~<])")
+	htmlComment  = regexp.MustCompile(`<!--[\s\S]*?-->`)
+	image        = regexp.MustCompile(`!\[([^\]]*)\](?:\([^)]*\)|\[[^\]]*\])`)
+	link         = regexp.MustCompile(`\[([^\]]*)\](?:\([^)]*\)|\[[^\]]*\])`)
+	autolink     = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	htmlTag      = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	inlineCode   = regexp.MustCompile("`+([^`]*)`+")
+	emphasisOpen = regexp.MustCompile(`(^|[^\w*~])(?:[*_]+|~~)(\S)`)
+	emphasisEnd  = regexp.MustCompile(`(\S)(?:[*_]+|~~)([^\w*~]|$)`)
+)
+
+// escapeBase maps the escaped punctuation to the private use area while
+// the markup is removed, so it is not mistaken for markup
+const escapeBase = 0xE000
+
+// PlainText removes the Markdown markup, keeping the text read by a human.
+// Fenced code blocks, HTML tags and link targets are left out, lines are
+// joined by single spaces.
+func PlainText(markdown string) string {
+	markdown = strings.Replace(markdown, "\r\n", "\n", -1)
+	markdown = htmlComment.ReplaceAllString(markdown, "")
+
+	var lines []string
+	inFence := false
+	for _, line := range strings.Split(markdown, "\n") {
+		if fenceLine.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence || linkDefinition.MatchString(line) || ruleLine.MatchString(line) || isTableDelimiter(line) {
+			continue
+		}
+
+		line = escaped.ReplaceAllStringFunc(line, func(s string) string {
+			return string(rune(escapeBase + int(s[1])))
+		})
+		if headingPrefix.MatchString(line) {
+			line = headingSuffix.ReplaceAllString(headingPrefix.ReplaceAllString(line, ""), "")
+		}
+		line = quotePrefix.ReplaceAllString(line, "")
+		line = listPrefix.ReplaceAllString(line, "")
+		if strings.HasPrefix(strings.TrimSpace(line), "|") {
+			line = strings.Replace(line, "|", " ", -1)
+		}
+
+		line = image.ReplaceAllString(line, "$1")
+		line = link.ReplaceAllString(line, "$1")
+		line = autolink.ReplaceAllString(line, "$1")
+		line = htmlTag.ReplaceAllString(line, " ")
+		line = inlineCode.ReplaceAllString(line, "$1")
+		// twice, as the matches of adjacent markers overlap
+		for i := 0; i < 2; i++ {
+			line = emphasisOpen.ReplaceAllString(line, "$1$2")
+			line = emphasisEnd.ReplaceAllString(line, "$1$2")
+		}
+
+		line = strings.Map(func(r rune) rune {
+			if r >= escapeBase && r < escapeBase+utf8.RuneSelf {
+				return r - escapeBase
+			}
+			return r
+		}, line)
+		lines = append(lines, html.UnescapeString(line))
+	}
+
+	return strings.Join(strings.Fields(strings.Join(lines, " ")), " ")
+}
+
+// CountWords counts the words separated by spaces. Scripts written without
+// spaces count a word per character.
+func CountWords(text string) int {
+	words := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			words++
+			inWord = false
+		case unicode.IsSpace(r):
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			if !inWord {
+				words++
+				inWord = true
+			}
+		}
+	}
+	return words
+}
+
+// Excerpt returns the beginning of the plain text, cut at a word boundary
+// and ended by an ellipsis when longer than length characters
+func Excerpt(text string, length int) string {
+	if length <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	runes := []rune(text)[:length]
+	// leave room for the ellipsis
+	cut := len(runes) - 1
+	if i := lastSpace(runes[:cut+1]); i > 0 {
+		cut = i
+	}
+	excerpt := strings.TrimRightFunc(string(runes[:cut]), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	return excerpt + "…"
+}
+
+// isTableDelimiter reports whether the line separates the header of a table
+// from its rows, like "| --- | :-: |"
+func isTableDelimiter(line string) bool {
+	return strings.Contains(line, "|") && strings.Contains(line, "-") &&
+		strings.Trim(line, "|:- \t") == ""
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package textstats_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/vmlellis/grpc-go-learning/blog/textstats"
+)
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name, markdown, want string
+	}{
+		{"plain", "Hello,   world", "Hello, world"},
+		{"headings", "# Title #\n## Sub\ntext", "Title Sub text"},
+		{"emphasis", "**bold**, *italic*, __strong__ and ~~gone~~", "bold, italic, strong and gone"},
+		{"snake case", "use snake_case_names and 2*3*4", "use snake_case_names and 2*3*4"},
+		{"links", "see [the docs](https://golang.org) or [ref][1]\n[1]: https://grpc.io", "see the docs or ref"},
+		{"images", "![a gopher](gopher.png) here", "a gopher here"},
+		{"autolinks", "mail <mailto:ana@example.com>", "mail mailto:ana@example.com"},
+		{"inline code", "call `fmt.Println` twice", "call fmt.Println twice"},
+		{"fenced code", "before\n```go\nfunc main() {}\n```\nafter", "before after"},
+		{"tilde fence", "before\n~~~\ncode\n~~~\nafter", "before after"},
+		{"lists", "- one\n* two\n1. three\n- [x] done", "one two three done"},
+		{"quotes", "> quoted\n> > nested", "quoted nested"},
+		{"rules", "above\n---\n***\nbelow", "above below"},
+		{"setext", "Title\n=====\ntext", "Title text"},
+		{"html", "a<br/>b <!-- hidden\ncomment --> <b>c</b>", "a b c"},
+		{"entities", "fish &amp; chips", "fish & chips"},
+		{"escapes", `\*not emphasis\* and \# not a heading`, "*not emphasis* and # not a heading"},
+		{"tables", "| a | b |\n| --- | :-: |\n| 1 | 2 |", "a b 1 2"},
+		{"crlf", "one\r\ntwo", "one two"},
+	}
+	for _, tt := range tests {
+		if got := textstats.PlainText(tt.markdown); got != tt.want {
+			t.Errorf("%v: PlainText(%q) = %q, want %q", tt.name, tt.markdown, got, tt.want)
+		}
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"   ", 0},
+		{"one", 1},
+		{"one two  three", 3},
+		{"it's a well-known fact", 4},
+		{"— , !", 0},
+		{"in 2020 there were 3 releases", 6},
+		{"café naïve", 2},
+		// a word per character in the scripts without spaces
+		{"日本語", 3},
+		{"Go言語", 3},
+	}
+	for _, tt := range tests {
+		if got := textstats.CountWords(tt.text); got != tt.want {
+			t.Errorf("CountWords(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		text   string
+		length int
+		want   string
+	}{
+		{"short", 10, "short"},
+		{"exactly ten", 11, "exactly ten"},
+		{"anything", 0, ""},
+		{"the quick brown fox", 12, "the quick…"},
+		// the punctuation before the cut is dropped
+		{"hello, world and more", 10, "hello…"},
+		// a single long word is cut inside
+		{"abcdefghijkl", 5, "abcd…"},
+		{"ééééé ééééé", 8, "ééééé…"},
+	}
+	for _, tt := range tests {
+		got := textstats.Excerpt(tt.text, tt.length)
+		if got != tt.want {
+			t.Errorf("Excerpt(%q, %v) = %q, want %q", tt.text, tt.length, got, tt.want)
+		}
+		if n := utf8.RuneCountInString(got); n > tt.length {
+			t.Errorf("Excerpt(%q, %v) has %v characters", tt.text, tt.length, n)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		opts     textstats.Options
+		want     textstats.Stats
+	}{
+		{"empty", "", textstats.DefaultOptions, textstats.Stats{}},
+		{"one word", "# Hi", textstats.DefaultOptions, textstats.Stats{Words: 1, ReadingTimeMinutes: 1, Excerpt: "Hi"}},
+		{"rounded up", strings.Repeat("word ", 201), textstats.Options{}, textstats.Stats{Words: 201, ReadingTimeMinutes: 2}},
+		{"custom speed", strings.Repeat("word ", 100), textstats.Options{WordsPerMinute: 50}, textstats.Stats{Words: 100, ReadingTimeMinutes: 2}},
+		{"excerpt", "**Go** is [fun](https://golang.org) to use", textstats.Options{ExcerptLength: 10},
+			textstats.Stats{Words: 5, ReadingTimeMinutes: 1, Excerpt: "Go is fun…"}},
+	}
+	for _, tt := range tests {
+		if got := textstats.Analyze(tt.markdown, tt.opts); got != tt.want {
+			t.Errorf("%v: Analyze = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
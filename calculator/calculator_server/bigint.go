@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/primes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxBigIntBits bounds the size of the big integers received and computed,
+// about 315000 decimal digits
+const maxBigIntBits = 1 << 20
+
+// maxFactorBits bounds the numbers BigPrimeNumberDecomposition accepts,
+// testing the primality of larger ones takes too long
+const maxFactorBits = 1024
+
+// maxFactorTime bounds the time BigPrimeNumberDecomposition spends on a
+// number, Pollard's rho takes too long on products of large primes
+const maxFactorTime = 30 * time.Second
+
+func (*server) BigArithmetic(ctx context.Context, req *calculatorpb.BigArithmeticRequest) (*calculatorpb.BigArithmeticResponse, error) {
+	log.Printf("Received BigArithmetic RPC: %v", req.GetOperation())
+
+	x, err := bigIntFromPb(req.GetFirstNumber(), "first_number")
+	if err != nil {
+		return nil, err
+	}
+	y, err := bigIntFromPb(req.GetSecondNumber(), "second_number")
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(big.Int)
+	switch req.GetOperation() {
+	case calculatorpb.BigOperation_OPERATION_ADD:
+		result.Add(x, y)
+	case calculatorpb.BigOperation_OPERATION_SUBTRACT:
+		result.Sub(x, y)
+	case calculatorpb.BigOperation_OPERATION_MULTIPLY:
+		result.Mul(x, y)
+	case calculatorpb.BigOperation_OPERATION_DIVIDE, calculatorpb.BigOperation_OPERATION_MOD:
+		if y.Sign() == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "Division by zero")
+		}
+		if req.GetOperation() == calculatorpb.BigOperation_OPERATION_DIVIDE {
+			result.Quo(x, y)
+		} else {
+			result.Rem(x, y)
+		}
+	case calculatorpb.BigOperation_OPERATION_POW:
+		if y.Sign() < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "Received a negative exponent: %v", y)
+		}
+		// |x|^y has at least (bits(x)-1)*y+1 bits
+		if x.CmpAbs(big.NewInt(1)) > 0 &&
+			(!y.IsInt64() || y.Int64() > maxBigIntBits || int64(x.BitLen()-1)*y.Int64() >= maxBigIntBits) {
+			return nil, status.Errorf(codes.OutOfRange, "Result exceeds %v bits", maxBigIntBits)
+		}
+		result.Exp(x, y, nil)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown operation %v", req.GetOperation())
+	}
+
+	if result.BitLen() > maxBigIntBits {
+		return nil, status.Errorf(codes.OutOfRange, "Result exceeds %v bits", maxBigIntBits)
+	}
+
+	return &calculatorpb.BigArithmeticResponse{
+		Result: bigIntToPb(result, req.GetFirstNumber()),
+	}, nil
+}
+
+func (*server) BigPrimeNumberDecomposition(req *calculatorpb.BigPrimeNumberDecompositionRequest, stream calculatorpb.CalculatorService_BigPrimeNumberDecompositionServer) error {
+	log.Printf("Received BigPrimeNumberDecomposition RPC")
+
+	number, err := bigIntFromPb(req.GetNumber(), "number")
+	if err != nil {
+		return err
+	}
+	if number.Sign() <= 0 {
+		return status.Errorf(codes.InvalidArgument, "Received a non-positive number: %v", number)
+	}
+	if number.BitLen() > maxFactorBits {
+		return status.Errorf(codes.OutOfRange, "Cannot factor numbers above %v bits", maxFactorBits)
+	}
+
+	ctx, cancel := context.WithTimeout(stream.Context(), maxFactorTime)
+	defer cancel()
+	err = primes.FactorBig(ctx, number, func(prime *big.Int) error {
+		return stream.Send(&calculatorpb.BigPrimeNumberDecompositionResponse{PrimeFactor: bigIntToPb(prime, req.GetNumber())})
+	})
+	if err == context.DeadlineExceeded && stream.Context().Err() == nil {
+		return status.Errorf(codes.DeadlineExceeded, "Factoring takes more than %v", maxFactorTime)
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return contextError(err)
+	}
+	return err
+}
+
+// contextError converts the error of a done context into a gRPC status
+func contextError(err error) error {
+	switch err {
+	case context.Canceled:
+		return status.Errorf(codes.Canceled, "Request canceled")
+	case context.DeadlineExceeded:
+		return status.Errorf(codes.DeadlineExceeded, "Request deadline exceeded")
+	default:
+		return status.Errorf(codes.Internal, "Internal error: %v", err)
+	}
+}
+
+// badRequest returns an INVALID_ARGUMENT status with a BadRequest detail
+// blaming field, the message is also the description of the violation
+func badRequest(field, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	st := status.New(codes.InvalidArgument, msg)
+	detailed, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: msg}},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// bigIntFromPb decodes n, unset is zero. field names n in the errors.
+func bigIntFromPb(n *calculatorpb.BigInteger, field string) (*big.Int, error) {
+	x := new(big.Int)
+	switch v := n.GetValue().(type) {
+	case *calculatorpb.BigInteger_Decimal:
+		if _, ok := x.SetString(strings.TrimSpace(v.Decimal), 10); !ok {
+			return nil, badRequest(field, "Cannot parse %v: %q is not a decimal integer", field, v.Decimal)
+		}
+	case *calculatorpb.BigInteger_TwosComplement:
+		b := v.TwosComplement
+		x.SetBytes(b)
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			x.Sub(x, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+		}
+	}
+
+	if x.BitLen() > maxBigIntBits {
+		return nil, badRequest(field, "%v exceeds %v bits", field, maxBigIntBits)
+	}
+	return x, nil
+}
+
+// bigIntToPb encodes x like the number received in like
+func bigIntToPb(x *big.Int, like *calculatorpb.BigInteger) *calculatorpb.BigInteger {
+	if _, ok := like.GetValue().(*calculatorpb.BigInteger_TwosComplement); ok {
+		return &calculatorpb.BigInteger{Value: &calculatorpb.BigInteger_TwosComplement{TwosComplement: twosComplement(x)}}
+	}
+	return &calculatorpb.BigInteger{Value: &calculatorpb.BigInteger_Decimal{Decimal: x.String()}}
+}
+
+// twosComplement returns the shortest big-endian two's complement of x
+func twosComplement(x *big.Int) []byte {
+	switch x.Sign() {
+	case 0:
+		return nil
+	case 1:
+		b := x.Bytes()
+		if b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b
+	}
+
+	// -2^(8n-1) <= x < 0 fits in n bytes, x + 2^(8n) is their value
+	n := new(big.Int).Not(x).BitLen()/8 + 1
+	y := new(big.Int).Lsh(big.NewInt(1), uint(8*n))
+	return y.Add(y, x).Bytes()
+}
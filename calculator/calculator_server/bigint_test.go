@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func decimal(s string) *calculatorpb.BigInteger {
+	return &calculatorpb.BigInteger{Value: &calculatorpb.BigInteger_Decimal{Decimal: s}}
+}
+
+func bytesInt(b ...byte) *calculatorpb.BigInteger {
+	return &calculatorpb.BigInteger{Value: &calculatorpb.BigInteger_TwosComplement{TwosComplement: b}}
+}
+
+func TestBigArithmetic(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	const (
+		add = calculatorpb.BigOperation_OPERATION_ADD
+		sub = calculatorpb.BigOperation_OPERATION_SUBTRACT
+		mul = calculatorpb.BigOperation_OPERATION_MULTIPLY
+		div = calculatorpb.BigOperation_OPERATION_DIVIDE
+		mod = calculatorpb.BigOperation_OPERATION_MOD
+		pow = calculatorpb.BigOperation_OPERATION_POW
+	)
+	tests := []struct {
+		name  string
+		op    calculatorpb.BigOperation
+		x, y  *calculatorpb.BigInteger
+		want  *calculatorpb.BigInteger
+		code  codes.Code
+		field string
+	}{
+		{"int32 overflow", add, decimal("2147483647"), decimal("1"), decimal("2147483648"), codes.OK, ""},
+		{"spaces", sub, decimal(" 10 "), decimal("-32"), decimal("42"), codes.OK, ""},
+		{"multiply", mul, decimal("-18446744073709551616"), decimal("18446744073709551616"), decimal("-340282366920938463463374607431768211456"), codes.OK, ""},
+		// division truncates toward zero, like Go
+		{"divide", div, decimal("-7"), decimal("2"), decimal("-3"), codes.OK, ""},
+		{"mod", mod, decimal("-7"), decimal("2"), decimal("-1"), codes.OK, ""},
+		{"pow", pow, decimal("2"), decimal("100"), decimal("1267650600228229401496703205376"), codes.OK, ""},
+		{"pow of one", pow, decimal("-1"), decimal("1000000000000"), decimal("1"), codes.OK, ""},
+		{"unset is zero", add, nil, decimal("5"), decimal("5"), codes.OK, ""},
+		// the result is encoded like the first number
+		{"bytes", add, bytesInt(0x7f), bytesInt(0x01), bytesInt(0x00, 0x80), codes.OK, ""},
+		{"negative bytes", sub, bytesInt(0x80), bytesInt(0x01), bytesInt(0xff, 0x7f), codes.OK, ""},
+		{"bytes zero", sub, bytesInt(0x05), decimal("5"), bytesInt(), codes.OK, ""},
+		{"divide by zero", div, decimal("1"), decimal("0"), nil, codes.InvalidArgument, ""},
+		{"mod by zero", mod, decimal("1"), nil, nil, codes.InvalidArgument, ""},
+		{"negative exponent", pow, decimal("2"), decimal("-1"), nil, codes.InvalidArgument, ""},
+		{"huge power", pow, decimal("2"), decimal("1048576"), nil, codes.OutOfRange, ""},
+		{"huge exponent", pow, decimal("3"), decimal("100000000000000000000"), nil, codes.OutOfRange, ""},
+		{"huge product", mul, decimal("1" + strings.Repeat("0", 200000)), decimal("1" + strings.Repeat("0", 200000)), nil, codes.OutOfRange, ""},
+		{"not a number", add, decimal("12a"), decimal("1"), nil, codes.InvalidArgument, "first_number"},
+		{"too large", add, decimal("1"), decimal("1" + strings.Repeat("0", 400000)), nil, codes.InvalidArgument, "second_number"},
+		{"unknown operation", calculatorpb.BigOperation(42), decimal("1"), decimal("1"), nil, codes.InvalidArgument, ""},
+	}
+	for _, tt := range tests {
+		res, err := c.BigArithmetic(context.Background(), &calculatorpb.BigArithmeticRequest{
+			Operation:    tt.op,
+			FirstNumber:  tt.x,
+			SecondNumber: tt.y,
+		})
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: BigArithmetic error = %v, want %v", tt.name, err, tt.code)
+			continue
+		}
+		if tt.field != "" {
+			if fields := violatedFields(err); !reflect.DeepEqual(fields, []string{tt.field}) {
+				t.Errorf("%v: violated fields = %v, want %v", tt.name, fields, tt.field)
+			}
+		}
+		if err == nil && !proto.Equal(res.GetResult(), tt.want) {
+			t.Errorf("%v: BigArithmetic = %v, want %v", tt.name, res.GetResult(), tt.want)
+		}
+	}
+}
+
+func TestBigPrimeNumberDecomposition(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		n    *calculatorpb.BigInteger
+		want []string
+		code codes.Code
+	}{
+		{decimal("1"), nil, codes.OK},
+		{decimal("360"), []string{"2", "2", "2", "3", "3", "5"}, codes.OK},
+		{decimal("18446744073709551617"), []string{"274177", "67280421310721"}, codes.OK},
+		{decimal("0"), nil, codes.InvalidArgument},
+		{decimal("-12"), nil, codes.InvalidArgument},
+		{decimal("1" + strings.Repeat("0", 400)), nil, codes.OutOfRange},
+	}
+	for _, tt := range tests {
+		stream, err := c.BigPrimeNumberDecomposition(context.Background(), &calculatorpb.BigPrimeNumberDecompositionRequest{Number: tt.n})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for {
+			var res *calculatorpb.BigPrimeNumberDecompositionResponse
+			if res, err = stream.Recv(); err != nil {
+				break
+			}
+			got = append(got, res.GetPrimeFactor().GetDecimal())
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		if status.Code(err) != tt.code {
+			t.Errorf("BigPrimeNumberDecomposition(%v) error = %v, want %v", tt.n.GetDecimal(), err, tt.code)
+			continue
+		}
+		sort.Slice(got, func(i, j int) bool { return bigLess(got[i], got[j]) })
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("BigPrimeNumberDecomposition(%v) = %v, want %v", tt.n.GetDecimal(), got, tt.want)
+		}
+	}
+}
+
+func bigLess(a, b string) bool {
+	x, _ := new(big.Int).SetString(a, 10)
+	y, _ := new(big.Int).SetString(b, 10)
+	return x.Cmp(y) < 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/expr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var numericModes = map[calculatorpb.NumericMode]expr.Mode{
+	calculatorpb.NumericMode_MODE_FLOAT64:      expr.Float64,
+	calculatorpb.NumericMode_MODE_INT64:        expr.Int64,
+	calculatorpb.NumericMode_MODE_BIG_RATIONAL: expr.BigRat,
+}
+
+func (s *server) Evaluate(ctx context.Context, req *calculatorpb.EvaluateRequest) (*calculatorpb.EvaluateResponse, error) {
+	log.Printf("Received Evaluate RPC: %v", req)
+
+	if req.GetSessionId() != "" {
+		return s.evaluateInSession(req)
+	}
+
+	mode, ok := numericModes[req.GetMode()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown mode %v", req.GetMode())
+	}
+
+	e, err := expr.Parse(req.GetExpression())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot parse expression: %v", err)
+	}
+	v, err := e.Eval(mode, nil)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot evaluate expression: %v", err)
+	}
+
+	return &calculatorpb.EvaluateResponse{Result: v.String(), Value: v.Float64()}, nil
+}
+
+// evaluateInSession evaluates with the mode and variables of the session,
+// and records the result in its history
+func (s *server) evaluateInSession(req *calculatorpb.EvaluateRequest) (*calculatorpb.EvaluateResponse, error) {
+	sess, err := s.sessions.get(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	e, err := expr.Parse(req.GetExpression())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot parse expression: %v", err)
+	}
+	v, err := sess.run(e, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &calculatorpb.EvaluateResponse{Result: v.String(), Value: v.Float64()}, nil
+}
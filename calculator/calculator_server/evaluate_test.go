@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEvaluate(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	const (
+		float64Mode = calculatorpb.NumericMode_MODE_FLOAT64
+		int64Mode   = calculatorpb.NumericMode_MODE_INT64
+		ratMode     = calculatorpb.NumericMode_MODE_BIG_RATIONAL
+	)
+	tests := []struct {
+		expression string
+		mode       calculatorpb.NumericMode
+		result     string
+		value      float64
+		code       codes.Code
+		// msg is part of the error message
+		msg string
+	}{
+		{"1 + 2 * 3", float64Mode, "7", 7, codes.OK, ""},
+		{"-(2 ^ 3) % 5", int64Mode, "-3", -3, codes.OK, ""},
+		{"7 / 2", int64Mode, "3", 3, codes.OK, ""},
+		{"7 / 2", float64Mode, "3.5", 3.5, codes.OK, ""},
+		{"1 / 3 + 1 / 6", ratMode, "1/2", 0.5, codes.OK, ""},
+		{"max(1, sqrt(16), abs(-3))", float64Mode, "4", 4, codes.OK, ""},
+		{"1 +", float64Mode, "", 0, codes.InvalidArgument, "position 4"},
+		{"2 * (3 + 4", int64Mode, "", 0, codes.InvalidArgument, "position 11"},
+		{"foo(1)", float64Mode, "", 0, codes.InvalidArgument, `unknown function "foo"`},
+		{"x + 1", float64Mode, "", 0, codes.InvalidArgument, ""},
+		{"1 / 0", int64Mode, "", 0, codes.InvalidArgument, ""},
+		{"1 / 0", ratMode, "", 0, codes.InvalidArgument, ""},
+		{"9223372036854775807 + 1", int64Mode, "", 0, codes.InvalidArgument, ""},
+		{strings.Repeat("1", 10001), float64Mode, "", 0, codes.InvalidArgument, "longer than"},
+		{"1", calculatorpb.NumericMode(42), "", 0, codes.InvalidArgument, "Unknown mode"},
+	}
+	for _, tt := range tests {
+		res, err := c.Evaluate(context.Background(), &calculatorpb.EvaluateRequest{Expression: tt.expression, Mode: tt.mode})
+		if status.Code(err) != tt.code {
+			t.Errorf("Evaluate(%.20q, %v) error = %v, want %v", tt.expression, tt.mode, err, tt.code)
+			continue
+		}
+		if err != nil {
+			if msg := status.Convert(err).Message(); !strings.Contains(msg, tt.msg) {
+				t.Errorf("Evaluate(%.20q, %v) error = %q, want it to contain %q", tt.expression, tt.mode, msg, tt.msg)
+			}
+			continue
+		}
+		if res.GetResult() != tt.result || res.GetValue() != tt.value {
+			t.Errorf("Evaluate(%q, %v) = %q (%v), want %q (%v)", tt.expression, tt.mode, res.GetResult(), res.GetValue(), tt.result, tt.value)
+		}
+	}
+}
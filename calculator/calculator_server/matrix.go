@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/matrix"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxMatrixValues bounds the operands and results, 2 MiB of doubles
+const maxMatrixValues = 1 << 18
+
+// maxMatrixWork bounds the multiply-adds of a product
+const maxMatrixWork = 1 << 30
+
+type matrixServer struct{}
+
+func (*matrixServer) Add(stream calculatorpb.MatrixService_AddServer) error {
+	log.Printf("Received Add RPC")
+
+	operands, err := receiveOperands(stream, 2)
+	if err != nil {
+		return err
+	}
+	sum, err := matrix.Add(operands[0], operands[1])
+	if err != nil {
+		return matrixError(err, operands...)
+	}
+	return stream.SendAndClose(matrixToPb(sum))
+}
+
+func (*matrixServer) Multiply(stream calculatorpb.MatrixService_MultiplyServer) error {
+	log.Printf("Received Multiply RPC")
+
+	operands, err := receiveOperands(stream, 2)
+	if err != nil {
+		return err
+	}
+	a, b := operands[0], operands[1]
+	if a.Cols() != b.Rows() {
+		return matrixError(matrix.ErrDimensions, operands...)
+	}
+	if a.Rows()*b.Cols() > maxMatrixValues {
+		return status.Errorf(codes.ResourceExhausted, "Product exceeds %v values", maxMatrixValues)
+	}
+	if int64(a.Rows())*int64(a.Cols())*int64(b.Cols()) > maxMatrixWork {
+		return status.Errorf(codes.ResourceExhausted, "Product exceeds %v multiplications", maxMatrixWork)
+	}
+
+	product, err := matrix.Mul(a, b)
+	if err != nil {
+		return matrixError(err)
+	}
+	return stream.SendAndClose(matrixToPb(product))
+}
+
+func (*matrixServer) Transpose(stream calculatorpb.MatrixService_TransposeServer) error {
+	log.Printf("Received Transpose RPC")
+
+	operands, err := receiveOperands(stream, 1)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(matrixToPb(operands[0].Transpose()))
+}
+
+func (*matrixServer) Determinant(stream calculatorpb.MatrixService_DeterminantServer) error {
+	log.Printf("Received Determinant RPC")
+
+	operands, err := receiveOperands(stream, 1)
+	if err != nil {
+		return err
+	}
+	det, err := matrix.Det(operands[0])
+	if err != nil {
+		return matrixError(err)
+	}
+	return stream.SendAndClose(&calculatorpb.DeterminantResponse{Determinant: det})
+}
+
+func (*matrixServer) Inverse(stream calculatorpb.MatrixService_InverseServer) error {
+	log.Printf("Received Inverse RPC")
+
+	operands, err := receiveOperands(stream, 1)
+	if err != nil {
+		return err
+	}
+	inverse, err := matrix.Inverse(operands[0])
+	if err != nil {
+		return matrixError(err)
+	}
+	return stream.SendAndClose(matrixToPb(inverse))
+}
+
+func (*matrixServer) Solve(stream calculatorpb.MatrixService_SolveServer) error {
+	log.Printf("Received Solve RPC")
+
+	operands, err := receiveOperands(stream, 2)
+	if err != nil {
+		return err
+	}
+	x, err := matrix.Solve(operands[0], operands[1])
+	if err != nil {
+		return matrixError(err, operands...)
+	}
+	return stream.SendAndClose(matrixToPb(x))
+}
+
+func (*matrixServer) Rank(stream calculatorpb.MatrixService_RankServer) error {
+	log.Printf("Received Rank RPC")
+
+	operands, err := receiveOperands(stream, 1)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&calculatorpb.RankResponse{Rank: int32(matrix.Rank(operands[0]))})
+}
+
+// rowReceiver is the receiving side of the MatrixService streams
+type rowReceiver interface {
+	Recv() (*calculatorpb.MatrixRow, error)
+}
+
+// receiveOperands reads the rows of the n operands until the end of the
+// stream
+func receiveOperands(stream rowReceiver, n int) ([]*matrix.Matrix, error) {
+	values := make([][]float64, n)
+	rows := make([]int, n)
+	cols := make([]int, n)
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		op := int(req.GetOperand())
+		if op < 0 || op >= n {
+			return nil, status.Errorf(codes.InvalidArgument, "Received operand %v, expected less than %v", op, n)
+		}
+		row := req.GetValues()
+		if len(row) == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "Received an empty row for operand %v", op)
+		}
+		if rows[op] > 0 && len(row) != cols[op] {
+			return nil, status.Errorf(codes.InvalidArgument, "Row %v of operand %v has %v values, expected %v", rows[op], op, len(row), cols[op])
+		}
+		if len(values[op])+len(row) > maxMatrixValues {
+			return nil, status.Errorf(codes.ResourceExhausted, "Operand %v exceeds %v values", op, maxMatrixValues)
+		}
+		for _, v := range row {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, status.Errorf(codes.InvalidArgument, "Received a non-finite value: %v", v)
+			}
+		}
+
+		values[op] = append(values[op], row...)
+		rows[op]++
+		cols[op] = len(row)
+	}
+
+	operands := make([]*matrix.Matrix, n)
+	for op := range operands {
+		if rows[op] == 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "Missing operand %v", op)
+		}
+		m, err := matrix.FromValues(rows[op], cols[op], values[op])
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Internal error: %v", err)
+		}
+		operands[op] = m
+	}
+	return operands, nil
+}
+
+// matrixError converts the errors of the matrix package into a gRPC
+// status, the dimensions of the operands explain the mismatches
+func matrixError(err error, operands ...*matrix.Matrix) error {
+	switch err {
+	case matrix.ErrDimensions:
+		dims := make([]string, len(operands))
+		for i, m := range operands {
+			dims[i] = fmt.Sprintf("%vx%v", m.Rows(), m.Cols())
+		}
+		return status.Errorf(codes.InvalidArgument, "The dimensions of the operands do not match: %v", strings.Join(dims, " and "))
+	case matrix.ErrNotSquare:
+		return status.Errorf(codes.InvalidArgument, "The matrix is not square")
+	case matrix.ErrSingular:
+		return status.Errorf(codes.InvalidArgument, "The matrix is singular")
+	default:
+		return status.Errorf(codes.Internal, "Internal error: %v", err)
+	}
+}
+
+func matrixToPb(m *matrix.Matrix) *calculatorpb.Matrix {
+	return &calculatorpb.Matrix{
+		Rows:    int32(m.Rows()),
+		Columns: int32(m.Cols()),
+		Values:  m.Values(),
+	}
+}
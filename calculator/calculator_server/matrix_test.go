@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// matrixStream is the client side of the MatrixService streams returning
+// a matrix
+type matrixStream interface {
+	Send(*calculatorpb.MatrixRow) error
+	CloseAndRecv() (*calculatorpb.Matrix, error)
+}
+
+func openMatrixStream(ctx context.Context, c calculatorpb.MatrixServiceClient, rpc string) (matrixStream, error) {
+	switch rpc {
+	case "Add":
+		return c.Add(ctx)
+	case "Multiply":
+		return c.Multiply(ctx)
+	case "Transpose":
+		return c.Transpose(ctx)
+	case "Inverse":
+		return c.Inverse(ctx)
+	case "Solve":
+		return c.Solve(ctx)
+	}
+	return nil, fmt.Errorf("unknown RPC %v", rpc)
+}
+
+// operand returns the rows of the operand op
+func operand(op int32, rows ...[]float64) []*calculatorpb.MatrixRow {
+	var reqs []*calculatorpb.MatrixRow
+	for _, row := range rows {
+		reqs = append(reqs, &calculatorpb.MatrixRow{Operand: op, Values: row})
+	}
+	return reqs
+}
+
+// operands returns the rows of a then b
+func operands(a, b []*calculatorpb.MatrixRow) []*calculatorpb.MatrixRow {
+	return append(append([]*calculatorpb.MatrixRow(nil), a...), b...)
+}
+
+// matricesNear compares the matrices up to rounding errors
+func matricesNear(a, b *calculatorpb.Matrix) bool {
+	if a.GetRows() != b.GetRows() || a.GetColumns() != b.GetColumns() || len(a.GetValues()) != len(b.GetValues()) {
+		return false
+	}
+	for i, x := range a.GetValues() {
+		if math.Abs(x-b.GetValues()[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMatrixService(t *testing.T) {
+	cc, stop := dialTestServer(t, &server{sessions: newSessionStore()})
+	defer stop()
+	c := calculatorpb.NewMatrixServiceClient(cc)
+
+	square := operand(0, []float64{1, 2}, []float64{3, 4})
+	wide := operand(0, []float64{1, 2, 3}, []float64{4, 5, 6})
+	long := operand(0, make([]float64, maxMatrixValues+1))
+	// a column times a row of 513 values has more than 2^18
+	var column []*calculatorpb.MatrixRow
+	for i := 0; i < 513; i++ {
+		column = append(column, &calculatorpb.MatrixRow{Values: []float64{1}})
+	}
+	tests := []struct {
+		name string
+		rpc  string
+		reqs []*calculatorpb.MatrixRow
+		want *calculatorpb.Matrix
+		code codes.Code
+	}{
+		{"add", "Add", operands(square, operand(1, []float64{1, 1}, []float64{-1, -1})),
+			&calculatorpb.Matrix{Rows: 2, Columns: 2, Values: []float64{2, 3, 2, 3}}, codes.OK},
+		// the rows of the operands may be interleaved
+		{"interleaved", "Add", []*calculatorpb.MatrixRow{
+			{Operand: 1, Values: []float64{5}}, {Operand: 0, Values: []float64{1}}, {Operand: 0, Values: []float64{2}}, {Operand: 1, Values: []float64{6}},
+		}, &calculatorpb.Matrix{Rows: 2, Columns: 1, Values: []float64{6, 8}}, codes.OK},
+		{"multiply", "Multiply", operands(wide, operand(1, []float64{1}, []float64{0}, []float64{-1})),
+			&calculatorpb.Matrix{Rows: 2, Columns: 1, Values: []float64{-2, -2}}, codes.OK},
+		{"transpose", "Transpose", wide,
+			&calculatorpb.Matrix{Rows: 3, Columns: 2, Values: []float64{1, 4, 2, 5, 3, 6}}, codes.OK},
+		{"inverse", "Inverse", operand(0, []float64{2, 0}, []float64{0, 4}),
+			&calculatorpb.Matrix{Rows: 2, Columns: 2, Values: []float64{0.5, 0, 0, 0.25}}, codes.OK},
+		{"solve", "Solve", operands(operand(0, []float64{2, 1}, []float64{1, 3}), operand(1, []float64{3, 4}, []float64{5, 7})),
+			&calculatorpb.Matrix{Rows: 2, Columns: 2, Values: []float64{0.8, 1, 1.4, 2}}, codes.OK},
+		{"add mismatch", "Add", operands(square, operand(1, []float64{1, 2, 3})), nil, codes.InvalidArgument},
+		{"multiply mismatch", "Multiply", operands(square, wide[:1]), nil, codes.InvalidArgument},
+		{"missing operand", "Add", square, nil, codes.InvalidArgument},
+		{"no rows", "Transpose", nil, nil, codes.InvalidArgument},
+		{"extra operand", "Transpose", operands(square, operand(1, []float64{1})), nil, codes.InvalidArgument},
+		{"negative operand", "Transpose", operand(-1, []float64{1}), nil, codes.InvalidArgument},
+		{"empty row", "Transpose", operand(0, []float64{}), nil, codes.InvalidArgument},
+		{"ragged rows", "Transpose", operand(0, []float64{1, 2}, []float64{3}), nil, codes.InvalidArgument},
+		{"NaN", "Transpose", operand(0, []float64{1, math.NaN()}), nil, codes.InvalidArgument},
+		{"infinity", "Add", operands(operand(0, []float64{1}), operand(1, []float64{math.Inf(-1)})), nil, codes.InvalidArgument},
+		{"inverse not square", "Inverse", wide, nil, codes.InvalidArgument},
+		{"inverse singular", "Inverse", operand(0, []float64{1, 2}, []float64{2, 4}), nil, codes.InvalidArgument},
+		{"solve singular", "Solve", operands(operand(0, []float64{1, 2}, []float64{2, 4}), operand(1, []float64{1}, []float64{1})), nil, codes.InvalidArgument},
+		{"solve mismatch", "Solve", operands(square, operand(1, []float64{1})), nil, codes.InvalidArgument},
+		{"operand too large", "Transpose", long, nil, codes.ResourceExhausted},
+		{"product too large", "Multiply", operands(column, operand(1, make([]float64, 513))), nil, codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		stream, err := openMatrixStream(context.Background(), c, tt.rpc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range tt.reqs {
+			// the server may have failed the stream already
+			if stream.Send(req) != nil {
+				break
+			}
+		}
+		res, err := stream.CloseAndRecv()
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: %v error = %v, want %v", tt.name, tt.rpc, err, tt.code)
+			continue
+		}
+		if err == nil && !matricesNear(res, tt.want) {
+			t.Errorf("%v: %v = %v, want %v", tt.name, tt.rpc, res, tt.want)
+		}
+	}
+}
+
+func TestDeterminantRank(t *testing.T) {
+	cc, stop := dialTestServer(t, &server{sessions: newSessionStore()})
+	defer stop()
+	c := calculatorpb.NewMatrixServiceClient(cc)
+
+	tests := []struct {
+		name string
+		reqs []*calculatorpb.MatrixRow
+		det  float64
+		rank int32
+		// code is the status of Determinant, Rank accepts any matrix
+		code codes.Code
+	}{
+		{"identity", operand(0, []float64{1, 0}, []float64{0, 1}), 1, 2, codes.OK},
+		{"pivoting", operand(0, []float64{0, 2}, []float64{3, 1}), -6, 2, codes.OK},
+		{"singular", operand(0, []float64{1, 2}, []float64{2, 4}), 0, 1, codes.OK},
+		{"zero", operand(0, []float64{0}), 0, 0, codes.OK},
+		{"not square", operand(0, []float64{1, 2, 3}, []float64{2, 4, 7}), 0, 2, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		det, err := c.Determinant(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range tt.reqs {
+			if err := det.Send(req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		res, err := det.CloseAndRecv()
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: Determinant error = %v, want %v", tt.name, err, tt.code)
+		} else if err == nil && math.Abs(res.GetDeterminant()-tt.det) > 1e-9 {
+			t.Errorf("%v: Determinant = %v, want %v", tt.name, res.GetDeterminant(), tt.det)
+		}
+
+		rank, err := c.Rank(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range tt.reqs {
+			if err := rank.Send(req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if res, err := rank.CloseAndRecv(); err != nil {
+			t.Errorf("%v: Rank error = %v", tt.name, err)
+		} else if res.GetRank() != tt.rank {
+			t.Errorf("%v: Rank = %v, want %v", tt.name, res.GetRank(), tt.rank)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/big"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxModulusBits bounds the moduli and exponents of ModPow, whose cost
+// grows with the product of their sizes
+const maxModulusBits = 8192
+
+func (*server) Gcd(ctx context.Context, req *calculatorpb.GcdRequest) (*calculatorpb.GcdResponse, error) {
+	log.Printf("Received Gcd RPC")
+
+	x, y, err := bigIntPairFromPb(req.GetFirstNumber(), req.GetSecondNumber())
+	if err != nil {
+		return nil, err
+	}
+
+	gcd, _, _ := extendedGcd(x, y)
+	return &calculatorpb.GcdResponse{Gcd: bigIntToPb(gcd, req.GetFirstNumber())}, nil
+}
+
+func (*server) Lcm(ctx context.Context, req *calculatorpb.LcmRequest) (*calculatorpb.LcmResponse, error) {
+	log.Printf("Received Lcm RPC")
+
+	x, y, err := bigIntPairFromPb(req.GetFirstNumber(), req.GetSecondNumber())
+	if err != nil {
+		return nil, err
+	}
+
+	lcm := new(big.Int)
+	if x.Sign() != 0 && y.Sign() != 0 {
+		gcd, _, _ := extendedGcd(x, y)
+		// |x| / gcd * |y|
+		lcm.Quo(x, gcd).Mul(lcm, y).Abs(lcm)
+	}
+	if lcm.BitLen() > maxBigIntBits {
+		return nil, status.Errorf(codes.OutOfRange, "Result exceeds %v bits", maxBigIntBits)
+	}
+	return &calculatorpb.LcmResponse{Lcm: bigIntToPb(lcm, req.GetFirstNumber())}, nil
+}
+
+func (*server) ExtendedGcd(ctx context.Context, req *calculatorpb.ExtendedGcdRequest) (*calculatorpb.ExtendedGcdResponse, error) {
+	log.Printf("Received ExtendedGcd RPC")
+
+	x, y, err := bigIntPairFromPb(req.GetFirstNumber(), req.GetSecondNumber())
+	if err != nil {
+		return nil, err
+	}
+
+	gcd, a, b := extendedGcd(x, y)
+	like := req.GetFirstNumber()
+	return &calculatorpb.ExtendedGcdResponse{
+		Gcd: bigIntToPb(gcd, like),
+		X:   bigIntToPb(a, like),
+		Y:   bigIntToPb(b, like),
+	}, nil
+}
+
+func (*server) ModPow(ctx context.Context, req *calculatorpb.ModPowRequest) (*calculatorpb.ModPowResponse, error) {
+	log.Printf("Received ModPow RPC")
+
+	base, err := bigIntFromPb(req.GetBase(), "base")
+	if err != nil {
+		return nil, err
+	}
+	exponent, err := bigIntFromPb(req.GetExponent(), "exponent")
+	if err != nil {
+		return nil, err
+	}
+	modulus, err := modulusFromPb(req.GetModulus())
+	if err != nil {
+		return nil, err
+	}
+	if exponent.BitLen() > maxModulusBits {
+		return nil, status.Errorf(codes.OutOfRange, "exponent exceeds %v bits", maxModulusBits)
+	}
+
+	if exponent.Sign() < 0 {
+		if base, err = modInverse(base, modulus, "base"); err != nil {
+			return nil, err
+		}
+		exponent = new(big.Int).Neg(exponent)
+	}
+	result := new(big.Int).Mod(base, modulus)
+	result.Exp(result, exponent, modulus)
+
+	return &calculatorpb.ModPowResponse{Result: bigIntToPb(result, req.GetBase())}, nil
+}
+
+func (*server) ModInverse(ctx context.Context, req *calculatorpb.ModInverseRequest) (*calculatorpb.ModInverseResponse, error) {
+	log.Printf("Received ModInverse RPC")
+
+	number, err := bigIntFromPb(req.GetNumber(), "number")
+	if err != nil {
+		return nil, err
+	}
+	modulus, err := modulusFromPb(req.GetModulus())
+	if err != nil {
+		return nil, err
+	}
+
+	inverse, err := modInverse(number, modulus, "number")
+	if err != nil {
+		return nil, err
+	}
+	return &calculatorpb.ModInverseResponse{Inverse: bigIntToPb(inverse, req.GetNumber())}, nil
+}
+
+func bigIntPairFromPb(first, second *calculatorpb.BigInteger) (*big.Int, *big.Int, error) {
+	x, err := bigIntFromPb(first, "first_number")
+	if err != nil {
+		return nil, nil, err
+	}
+	y, err := bigIntFromPb(second, "second_number")
+	if err != nil {
+		return nil, nil, err
+	}
+	return x, y, nil
+}
+
+func modulusFromPb(n *calculatorpb.BigInteger) (*big.Int, error) {
+	modulus, err := bigIntFromPb(n, "modulus")
+	if err != nil {
+		return nil, err
+	}
+	if modulus.Sign() <= 0 {
+		return nil, badRequest("modulus", "Received a non-positive modulus: %v", modulus)
+	}
+	if modulus.BitLen() > maxModulusBits {
+		return nil, status.Errorf(codes.OutOfRange, "modulus exceeds %v bits", maxModulusBits)
+	}
+	return modulus, nil
+}
+
+// extendedGcd returns the non-negative gcd of x and y, and a and b such
+// that gcd = a*x + b*y
+func extendedGcd(x, y *big.Int) (gcd, a, b *big.Int) {
+	a, b = new(big.Int), new(big.Int)
+	gcd = new(big.Int).GCD(a, b, new(big.Int).Abs(x), new(big.Int).Abs(y))
+	if x.Sign() < 0 {
+		a.Neg(a)
+	}
+	if y.Sign() < 0 {
+		b.Neg(b)
+	}
+	return gcd, a, b
+}
+
+// modInverse returns the inverse of x modulo the positive modulus in
+// [0, modulus), field names x in the error when there is none
+func modInverse(x, modulus *big.Int, field string) (*big.Int, error) {
+	gcd, a, _ := extendedGcd(new(big.Int).Mod(x, modulus), modulus)
+	if gcd.Cmp(big.NewInt(1)) != 0 {
+		return nil, badRequest(field, "%v is not invertible modulo %v, they share the factor %v", field, modulus, gcd)
+	}
+	return a.Mod(a, modulus), nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// checkViolation fails the test unless the BadRequest detail of err names
+// field alone, an empty field wants no detail
+func checkViolation(t *testing.T, name string, err error, field string) {
+	t.Helper()
+	var want []string
+	if field != "" {
+		want = []string{field}
+	}
+	if fields := violatedFields(err); !reflect.DeepEqual(fields, want) {
+		t.Errorf("%v: violated %v, want %v", name, fields, want)
+	}
+}
+
+func TestGcdLcm(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	// coprime numbers whose product exceeds maxBigIntBits
+	huge := strings.Repeat("0", 160000)
+	tests := []struct {
+		name     string
+		x, y     *calculatorpb.BigInteger
+		gcd, lcm *calculatorpb.BigInteger
+		code     codes.Code
+		field    string
+	}{
+		{"positive", decimal("12"), decimal("18"), decimal("6"), decimal("36"), codes.OK, ""},
+		{"negative", decimal("-12"), decimal("18"), decimal("6"), decimal("36"), codes.OK, ""},
+		{"coprime", decimal("35"), decimal("-64"), decimal("1"), decimal("2240"), codes.OK, ""},
+		{"zero", decimal("0"), decimal("7"), decimal("7"), decimal("0"), codes.OK, ""},
+		{"unset", nil, nil, decimal("0"), decimal("0"), codes.OK, ""},
+		{"bytes", bytesInt(0x0c), decimal("-18"), bytesInt(0x06), bytesInt(0x24), codes.OK, ""},
+		{"first not a number", decimal("1.5"), decimal("3"), nil, nil, codes.InvalidArgument, "first_number"},
+		{"second not a number", decimal("3"), decimal("three"), nil, nil, codes.InvalidArgument, "second_number"},
+	}
+	for _, tt := range tests {
+		gcd, err := c.Gcd(context.Background(), &calculatorpb.GcdRequest{FirstNumber: tt.x, SecondNumber: tt.y})
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: Gcd error = %v, want %v", tt.name, err, tt.code)
+		} else if err != nil {
+			checkViolation(t, tt.name, err, tt.field)
+		} else if !proto.Equal(gcd.GetGcd(), tt.gcd) {
+			t.Errorf("%v: Gcd = %v, want %v", tt.name, gcd.GetGcd(), tt.gcd)
+		}
+
+		lcm, err := c.Lcm(context.Background(), &calculatorpb.LcmRequest{FirstNumber: tt.x, SecondNumber: tt.y})
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: Lcm error = %v, want %v", tt.name, err, tt.code)
+		} else if err != nil {
+			checkViolation(t, tt.name, err, tt.field)
+		} else if !proto.Equal(lcm.GetLcm(), tt.lcm) {
+			t.Errorf("%v: Lcm = %v, want %v", tt.name, lcm.GetLcm(), tt.lcm)
+		}
+	}
+
+	_, err := c.Lcm(context.Background(), &calculatorpb.LcmRequest{FirstNumber: decimal("1" + huge), SecondNumber: decimal("1" + huge[1:] + "1")})
+	if status.Code(err) != codes.OutOfRange {
+		t.Errorf("Lcm of huge numbers error = %v, want %v", err, codes.OutOfRange)
+	}
+}
+
+func TestExtendedGcd(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		x, y string
+		gcd  string
+	}{
+		{"240", "46", "2"},
+		{"-240", "46", "2"},
+		{"240", "-46", "2"},
+		{"17", "0", "17"},
+		{"0", "0", "0"},
+		{"123456789012345678901234567890", "987654321098765432109876543210", "9000000000900000000090"},
+	}
+	for _, tt := range tests {
+		res, err := c.ExtendedGcd(context.Background(), &calculatorpb.ExtendedGcdRequest{FirstNumber: decimal(tt.x), SecondNumber: decimal(tt.y)})
+		if err != nil {
+			t.Errorf("ExtendedGcd(%v, %v) error = %v", tt.x, tt.y, err)
+			continue
+		}
+		if res.GetGcd().GetDecimal() != tt.gcd {
+			t.Errorf("ExtendedGcd(%v, %v) gcd = %v, want %v", tt.x, tt.y, res.GetGcd().GetDecimal(), tt.gcd)
+		}
+		// Bézout's identity
+		x, _ := new(big.Int).SetString(tt.x, 10)
+		y, _ := new(big.Int).SetString(tt.y, 10)
+		a, _ := new(big.Int).SetString(res.GetX().GetDecimal(), 10)
+		b, _ := new(big.Int).SetString(res.GetY().GetDecimal(), 10)
+		if a == nil || b == nil {
+			t.Errorf("ExtendedGcd(%v, %v) = %v, want decimal coefficients", tt.x, tt.y, res)
+			continue
+		}
+		if sum := new(big.Int).Add(a.Mul(a, x), b.Mul(b, y)); sum.String() != tt.gcd {
+			t.Errorf("ExtendedGcd(%v, %v): %v*x + %v*y = %v, want %v", tt.x, tt.y, res.GetX().GetDecimal(), res.GetY().GetDecimal(), sum, tt.gcd)
+		}
+	}
+
+	_, err := c.ExtendedGcd(context.Background(), &calculatorpb.ExtendedGcdRequest{FirstNumber: decimal("1"), SecondNumber: decimal("0x10")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ExtendedGcd of a hexadecimal error = %v, want %v", err, codes.InvalidArgument)
+	}
+	checkViolation(t, "ExtendedGcd", err, "second_number")
+}
+
+func TestModPow(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tooLarge := new(big.Int).Lsh(big.NewInt(1), maxModulusBits).String()
+	tests := []struct {
+		name                    string
+		base, exponent, modulus *calculatorpb.BigInteger
+		want                    *calculatorpb.BigInteger
+		code                    codes.Code
+		field                   string
+	}{
+		{"power", decimal("4"), decimal("13"), decimal("497"), decimal("445"), codes.OK, ""},
+		{"negative base", decimal("-2"), decimal("3"), decimal("5"), decimal("2"), codes.OK, ""},
+		{"zero exponent", decimal("7"), nil, decimal("13"), decimal("1"), codes.OK, ""},
+		{"modulus one", decimal("7"), decimal("3"), decimal("1"), decimal("0"), codes.OK, ""},
+		// a negative exponent raises the inverse
+		{"negative exponent", decimal("3"), decimal("-2"), decimal("7"), decimal("4"), codes.OK, ""},
+		{"bytes", bytesInt(0x02), decimal("10"), decimal("1000"), bytesInt(0x18), codes.OK, ""},
+		{"no inverse", decimal("2"), decimal("-1"), decimal("4"), nil, codes.InvalidArgument, "base"},
+		{"zero modulus", decimal("2"), decimal("3"), nil, nil, codes.InvalidArgument, "modulus"},
+		{"negative modulus", decimal("2"), decimal("3"), decimal("-5"), nil, codes.InvalidArgument, "modulus"},
+		{"base not a number", decimal("two"), decimal("3"), decimal("5"), nil, codes.InvalidArgument, "base"},
+		{"exponent not a number", decimal("2"), decimal("3e2"), decimal("5"), nil, codes.InvalidArgument, "exponent"},
+		{"modulus too large", decimal("2"), decimal("3"), decimal(tooLarge), nil, codes.OutOfRange, ""},
+		{"exponent too large", decimal("2"), decimal(tooLarge), decimal("5"), nil, codes.OutOfRange, ""},
+	}
+	for _, tt := range tests {
+		res, err := c.ModPow(context.Background(), &calculatorpb.ModPowRequest{Base: tt.base, Exponent: tt.exponent, Modulus: tt.modulus})
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: ModPow error = %v, want %v", tt.name, err, tt.code)
+			continue
+		}
+		checkViolation(t, tt.name, err, tt.field)
+		if err == nil && !proto.Equal(res.GetResult(), tt.want) {
+			t.Errorf("%v: ModPow = %v, want %v", tt.name, res.GetResult(), tt.want)
+		}
+	}
+}
+
+func TestModInverse(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		name            string
+		number, modulus *calculatorpb.BigInteger
+		want            *calculatorpb.BigInteger
+		code            codes.Code
+		field           string
+	}{
+		{"inverse", decimal("3"), decimal("11"), decimal("4"), codes.OK, ""},
+		{"negative", decimal("-3"), decimal("11"), decimal("7"), codes.OK, ""},
+		{"above the modulus", decimal("14"), decimal("11"), decimal("4"), codes.OK, ""},
+		{"modulus one", decimal("5"), decimal("1"), decimal("0"), codes.OK, ""},
+		{"bytes", bytesInt(0xfd), decimal("11"), bytesInt(0x07), codes.OK, ""},
+		{"common factor", decimal("6"), decimal("9"), nil, codes.InvalidArgument, "number"},
+		{"zero", nil, decimal("9"), nil, codes.InvalidArgument, "number"},
+		{"negative modulus", decimal("3"), decimal("-11"), nil, codes.InvalidArgument, "modulus"},
+		{"number not a number", decimal("-"), decimal("11"), nil, codes.InvalidArgument, "number"},
+	}
+	for _, tt := range tests {
+		res, err := c.ModInverse(context.Background(), &calculatorpb.ModInverseRequest{Number: tt.number, Modulus: tt.modulus})
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: ModInverse error = %v, want %v", tt.name, err, tt.code)
+			continue
+		}
+		checkViolation(t, tt.name, err, tt.field)
+		if err == nil && !proto.Equal(res.GetInverse(), tt.want) {
+			t.Errorf("%v: ModInverse = %v, want %v", tt.name, res.GetInverse(), tt.want)
+		}
+	}
+}
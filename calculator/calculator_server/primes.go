@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/big"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/primes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxPrimalityBits bounds the numbers IsPrime tests, each Miller-Rabin
+// round is cubic in their size
+const maxPrimalityBits = 8192
+
+// maxPrimesRange bounds the width of the ranges ListPrimes sieves
+const maxPrimesRange = 1000000000
+
+func (*server) IsPrime(ctx context.Context, req *calculatorpb.IsPrimeRequest) (*calculatorpb.IsPrimeResponse, error) {
+	log.Printf("Received IsPrime RPC: %v", req)
+
+	var number *big.Int
+	switch v := req.GetNumber().(type) {
+	case *calculatorpb.IsPrimeRequest_BigValue:
+		var err error
+		if number, err = bigIntFromPb(v.BigValue, "big_value"); err != nil {
+			return nil, err
+		}
+	default:
+		number = big.NewInt(req.GetValue())
+	}
+	if number.BitLen() > maxPrimalityBits {
+		return nil, status.Errorf(codes.OutOfRange, "Cannot test numbers above %v bits", maxPrimalityBits)
+	}
+
+	prime, err := primes.IsProbablePrime(ctx, number)
+	if err != nil {
+		return nil, contextError(err)
+	}
+
+	return &calculatorpb.IsPrimeResponse{
+		Prime:    prime,
+		Probable: prime && !number.IsUint64(),
+	}, nil
+}
+
+func (*server) ListPrimes(req *calculatorpb.ListPrimesRequest, stream calculatorpb.CalculatorService_ListPrimesServer) error {
+	log.Printf("Received ListPrimes RPC: %v", req)
+
+	from, to := req.GetFrom(), req.GetTo()
+	if from < 0 || to < 0 {
+		return status.Errorf(codes.InvalidArgument, "Received a negative bound: from %v, to %v", from, to)
+	}
+	if from > to {
+		return status.Errorf(codes.InvalidArgument, "Received from %v greater than to %v", from, to)
+	}
+	if to > primes.MaxSieve {
+		return status.Errorf(codes.OutOfRange, "Cannot list primes above %v", int64(primes.MaxSieve))
+	}
+	if to-from > maxPrimesRange {
+		return status.Errorf(codes.OutOfRange, "Cannot list primes over more than %v numbers", maxPrimesRange)
+	}
+
+	err := primes.Primes(stream.Context(), uint64(from), uint64(to), func(p uint64) error {
+		return stream.Send(&calculatorpb.ListPrimesResponse{Prime: int64(p)})
+	})
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return contextError(err)
+	}
+	return err
+}
+
+func (*server) NthPrime(ctx context.Context, req *calculatorpb.NthPrimeRequest) (*calculatorpb.NthPrimeResponse, error) {
+	log.Printf("Received NthPrime RPC: %v", req)
+
+	n := req.GetN()
+	if n <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Received a non-positive n: %v", n)
+	}
+	if n > primes.MaxNth {
+		return nil, status.Errorf(codes.OutOfRange, "Cannot find primes beyond the %vth", primes.MaxNth)
+	}
+
+	p, err := primes.NthPrime(ctx, uint64(n))
+	if err != nil {
+		return nil, contextError(err)
+	}
+	return &calculatorpb.NthPrimeResponse{Prime: int64(p)}, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/primes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsPrime(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	value := func(x int64) *calculatorpb.IsPrimeRequest {
+		return &calculatorpb.IsPrimeRequest{Number: &calculatorpb.IsPrimeRequest_Value{Value: x}}
+	}
+	bigValue := func(x *calculatorpb.BigInteger) *calculatorpb.IsPrimeRequest {
+		return &calculatorpb.IsPrimeRequest{Number: &calculatorpb.IsPrimeRequest_BigValue{BigValue: x}}
+	}
+	tooLarge := new(big.Int).Lsh(big.NewInt(1), maxPrimalityBits).String()
+
+	tests := []struct {
+		name     string
+		req      *calculatorpb.IsPrimeRequest
+		prime    bool
+		probable bool
+		code     codes.Code
+	}{
+		{"unset", &calculatorpb.IsPrimeRequest{}, false, false, codes.OK},
+		{"one", value(1), false, false, codes.OK},
+		{"prime", value(97), true, false, codes.OK},
+		{"composite", value(91), false, false, codes.OK},
+		{"large prime", value(3037000493), true, false, codes.OK},
+		{"Mersenne prime", bigValue(decimal("170141183460469231731687303715884105727")), true, true, codes.OK},
+		{"Mersenne composite", bigValue(decimal("340282366920938463463374607431768211455")), false, false, codes.OK},
+		{"bytes", bigValue(bytesInt(0x61)), true, false, codes.OK},
+		{"invalid decimal", bigValue(decimal("97a")), false, false, codes.InvalidArgument},
+		{"too large", bigValue(decimal(tooLarge)), false, false, codes.OutOfRange},
+	}
+	for _, tt := range tests {
+		res, err := c.IsPrime(context.Background(), tt.req)
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: IsPrime error = %v, want %v", tt.name, err, tt.code)
+			continue
+		}
+		if tt.code == codes.InvalidArgument {
+			if fields := violatedFields(err); !reflect.DeepEqual(fields, []string{"big_value"}) {
+				t.Errorf("%v: IsPrime violated %v, want [big_value]", tt.name, fields)
+			}
+		}
+		if err == nil && (res.GetPrime() != tt.prime || res.GetProbable() != tt.probable) {
+			t.Errorf("%v: IsPrime = %v, %v, want %v, %v", tt.name, res.GetPrime(), res.GetProbable(), tt.prime, tt.probable)
+		}
+	}
+}
+
+func TestListPrimes(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		from, to int64
+		want     []int64
+		code     codes.Code
+	}{
+		{0, 30, []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}, codes.OK},
+		{7, 7, []int64{7}, codes.OK},
+		{24, 28, nil, codes.OK},
+		{1000000000000, 1000000000100, []int64{1000000000039, 1000000000061, 1000000000063, 1000000000091}, codes.OK},
+		{-1, 10, nil, codes.InvalidArgument},
+		{10, 5, nil, codes.InvalidArgument},
+		{0, primes.MaxSieve + 1, nil, codes.OutOfRange},
+		{0, maxPrimesRange + 1, nil, codes.OutOfRange},
+	}
+	for _, tt := range tests {
+		stream, err := c.ListPrimes(context.Background(), &calculatorpb.ListPrimesRequest{From: tt.from, To: tt.to})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int64
+		for {
+			var res *calculatorpb.ListPrimesResponse
+			if res, err = stream.Recv(); err != nil {
+				break
+			}
+			got = append(got, res.GetPrime())
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		if status.Code(err) != tt.code {
+			t.Errorf("ListPrimes(%v, %v) error = %v, want %v", tt.from, tt.to, err, tt.code)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListPrimes(%v, %v) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestNthPrime(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		n    int64
+		want int64
+		code codes.Code
+	}{
+		{1, 2, codes.OK},
+		{10, 29, codes.OK},
+		{1000, 7919, codes.OK},
+		{0, 0, codes.InvalidArgument},
+		{-3, 0, codes.InvalidArgument},
+		{primes.MaxNth + 1, 0, codes.OutOfRange},
+	}
+	for _, tt := range tests {
+		res, err := c.NthPrime(context.Background(), &calculatorpb.NthPrimeRequest{N: tt.n})
+		if status.Code(err) != tt.code {
+			t.Errorf("NthPrime(%v) error = %v, want %v", tt.n, err, tt.code)
+			continue
+		}
+		if err == nil && res.GetPrime() != tt.want {
+			t.Errorf("NthPrime(%v) = %v, want %v", tt.n, res.GetPrime(), tt.want)
+		}
+	}
+}
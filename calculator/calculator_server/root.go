@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/roots"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRootDegree = 2
+	maxRootDegree     = 10000
+
+	defaultSignificantDigits = 15
+	maxSignificantDigits     = 1000
+
+	maxDecimalLength = 10000
+)
+
+// bitsPerDigit is log2(10)
+const bitsPerDigit = 3.3219280948873626
+
+func (*server) Root(ctx context.Context, req *calculatorpb.RootRequest) (*calculatorpb.RootResponse, error) {
+	log.Printf("Received Root RPC: %v", req)
+
+	degree := int(req.GetDegree())
+	if degree == 0 {
+		degree = defaultRootDegree
+	}
+	if degree < 0 || degree > maxRootDegree {
+		return nil, status.Errorf(codes.InvalidArgument, "Degree must be in [1, %v]", maxRootDegree)
+	}
+	digits := int(req.GetSignificantDigits())
+	if digits == 0 {
+		digits = defaultSignificantDigits
+	}
+	if digits < 0 || digits > maxSignificantDigits {
+		return nil, status.Errorf(codes.InvalidArgument, "Significant digits must be in [1, %v]", maxSignificantDigits)
+	}
+	prec := uint(math.Ceil(float64(digits)*bitsPerDigit)) + 8
+
+	number, err := rootNumber(req, prec)
+	if err != nil {
+		return nil, err
+	}
+	if number.Sign() < 0 && degree%2 == 0 && !req.GetAllowComplex() {
+		return nil, status.Errorf(codes.InvalidArgument, "Received a negative number for an even root, set allow_complex for a complex result")
+	}
+
+	root := roots.Nth(number, degree, prec)
+	res := &calculatorpb.RootResponse{Real: root.Real.Text('g', digits)}
+	res.RealValue, _ = root.Real.Float64()
+	if root.Imag.Sign() != 0 {
+		res.Imaginary = root.Imag.Text('g', digits)
+		res.ImaginaryValue, _ = root.Imag.Float64()
+	}
+
+	return res, nil
+}
+
+// rootNumber returns the number of the request, decimals are parsed with
+// at least prec bits
+func rootNumber(req *calculatorpb.RootRequest, prec uint) (*big.Float, error) {
+	switch n := req.GetNumber().(type) {
+	case *calculatorpb.RootRequest_Decimal:
+		decimal := strings.TrimSpace(n.Decimal)
+		if len(decimal) > maxDecimalLength {
+			return nil, status.Errorf(codes.InvalidArgument, "Decimal longer than %v characters", maxDecimalLength)
+		}
+		// keep every digit received
+		if p := uint(float64(len(decimal))*bitsPerDigit) + 8; p > prec {
+			prec = p
+		}
+		x, ok := new(big.Float).SetPrec(prec).SetString(decimal)
+		if !ok || x.IsInf() {
+			return nil, status.Errorf(codes.InvalidArgument, "Cannot parse decimal %q", n.Decimal)
+		}
+		return x, nil
+	default:
+		value := req.GetValue()
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, status.Errorf(codes.InvalidArgument, "Received a non-finite number: %v", value)
+		}
+		return new(big.Float).SetPrec(prec).SetFloat64(value), nil
+	}
+}
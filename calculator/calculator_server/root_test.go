@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRoot(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	value := func(x float64) *calculatorpb.RootRequest_Value {
+		return &calculatorpb.RootRequest_Value{Value: x}
+	}
+	decimal := func(s string) *calculatorpb.RootRequest_Decimal {
+		return &calculatorpb.RootRequest_Decimal{Decimal: s}
+	}
+
+	tests := []struct {
+		name string
+		req  *calculatorpb.RootRequest
+		real string
+		imag string
+		code codes.Code
+	}{
+		{"square root", &calculatorpb.RootRequest{Number: value(16)}, "4", "", codes.OK},
+		{"unset is zero", &calculatorpb.RootRequest{}, "0", "", codes.OK},
+		{"digits", &calculatorpb.RootRequest{Number: value(2), SignificantDigits: 30}, "1.41421356237309504880168872421", "", codes.OK},
+		{"cube root", &calculatorpb.RootRequest{Number: value(-27), Degree: 3}, "-3", "", codes.OK},
+		{"beyond doubles", &calculatorpb.RootRequest{Number: decimal("1e1000"), Degree: 10}, "1e+100", "", codes.OK},
+		{"complex", &calculatorpb.RootRequest{Number: value(-4), AllowComplex: true}, "0", "2", codes.OK},
+		{"negative even root", &calculatorpb.RootRequest{Number: value(-4)}, "", "", codes.InvalidArgument},
+		{"negative degree", &calculatorpb.RootRequest{Number: value(4), Degree: -2}, "", "", codes.InvalidArgument},
+		{"degree too large", &calculatorpb.RootRequest{Number: value(4), Degree: maxRootDegree + 1}, "", "", codes.InvalidArgument},
+		{"too many digits", &calculatorpb.RootRequest{Number: value(4), SignificantDigits: maxSignificantDigits + 1}, "", "", codes.InvalidArgument},
+		{"NaN", &calculatorpb.RootRequest{Number: value(math.NaN())}, "", "", codes.InvalidArgument},
+		{"infinity", &calculatorpb.RootRequest{Number: value(math.Inf(1))}, "", "", codes.InvalidArgument},
+		{"invalid decimal", &calculatorpb.RootRequest{Number: decimal("12,5")}, "", "", codes.InvalidArgument},
+		{"decimal too long", &calculatorpb.RootRequest{Number: decimal(strings.Repeat("1", maxDecimalLength+1))}, "", "", codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		res, err := c.Root(context.Background(), tt.req)
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: Root error = %v, want %v", tt.name, err, tt.code)
+			continue
+		}
+		if err == nil && (res.GetReal() != tt.real || res.GetImaginary() != tt.imag) {
+			t.Errorf("%v: Root = %q + %qi, want %q + %qi", tt.name, res.GetReal(), res.GetImaginary(), tt.real, tt.imag)
+		}
+	}
+}
+
+func TestSquareRoot(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		number int32
+		want   float64
+		code   codes.Code
+	}{
+		{0, 0, codes.OK},
+		{16, 4, codes.OK},
+		{2, math.Sqrt2, codes.OK},
+		{-1, 0, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		res, err := c.SquareRoot(context.Background(), &calculatorpb.SquareRootRequest{Number: tt.number})
+		if status.Code(err) != tt.code {
+			t.Errorf("SquareRoot(%v) error = %v, want %v", tt.number, err, tt.code)
+			continue
+		}
+		if err == nil && res.GetNumberRoot() != tt.want {
+			t.Errorf("SquareRoot(%v) = %v, want %v", tt.number, res.GetNumberRoot(), tt.want)
+		}
+	}
+}
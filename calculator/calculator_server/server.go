@@ -7,20 +7,27 @@ import (
 	"log"
 	"math"
 	"net"
+	"sort"
 
 	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/primes"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
 )
 
-type server struct{}
+type server struct {
+	sessions *sessionStore
+}
 
 func (*server) Sum(ctx context.Context, req *calculatorpb.SumRequest) (*calculatorpb.SumResponse, error) {
 	log.Printf("Received Sum RPC: %v", req)
-	result := req.GetFirstNumber() + req.GetSecondNumber()
-	res := &calculatorpb.SumResponse{SumResult: result}
+	result := int64(req.GetFirstNumber()) + int64(req.GetSecondNumber())
+	if result > math.MaxInt32 || result < math.MinInt32 {
+		return nil, status.Errorf(codes.OutOfRange, "Sum %v does not fit in an int32, use BigArithmetic", result)
+	}
+	res := &calculatorpb.SumResponse{SumResult: int32(result)}
 	return res, nil
 }
 
@@ -28,31 +35,54 @@ func (*server) PrimeNumberDecomposition(req *calculatorpb.PrimeNumberDecompositi
 	log.Printf("Received PrimeNumberDecomposition RPC: %v", req)
 
 	number := req.GetNumber()
-	divisor := int64(2)
-
-	for number > 1 {
-		if number%divisor == 0 {
-			res := &calculatorpb.PrimeNumberDecompositionResponse{PrimeFactor: divisor}
-			stream.Send(res)
-			number = number / divisor
-		} else {
-			divisor++
-			//fmt.Printf("Divisor has increased to %v\n", divisor)
+	if number <= 0 {
+		return status.Errorf(codes.InvalidArgument, "Received a non-positive number: %v", number)
+	}
+
+	// grouped factors are sent as soon as they are found, the repeated ones
+	// in ascending order like before grouping existed
+	var factors []int64
+	err := primes.Factor(stream.Context(), uint64(number), func(prime uint64, multiplicity int) error {
+		if req.GetGroupFactors() {
+			return stream.Send(&calculatorpb.PrimeNumberDecompositionResponse{
+				PrimeFactor:  int64(prime),
+				Multiplicity: int32(multiplicity),
+			})
+		}
+		for i := 0; i < multiplicity; i++ {
+			factors = append(factors, int64(prime))
 		}
+		return nil
+	})
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return contextError(err)
+	}
+	if err != nil {
+		return err
 	}
 
+	sort.Slice(factors, func(i, j int) bool { return factors[i] < factors[j] })
+	for _, prime := range factors {
+		if err := stream.Send(&calculatorpb.PrimeNumberDecompositionResponse{PrimeFactor: prime}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (*server) ComputeAverage(stream calculatorpb.CalculatorService_ComputeAverageServer) error {
 	log.Printf("Received ComputeAverage RPC")
 
-	sum := int32(0)
+	// the sum of int32 values cannot overflow an int64 in practice
+	sum := int64(0)
 	count := 0
 
 	for {
 		req, err := stream.Recv()
 		if err == io.EOF {
+			if count == 0 {
+				return status.Errorf(codes.InvalidArgument, "Received no numbers")
+			}
 			average := float64(sum) / float64(count)
 			return stream.SendAndClose(&calculatorpb.ComputeAverageResponse{
 				Average: average,
@@ -61,7 +91,7 @@ func (*server) ComputeAverage(stream calculatorpb.CalculatorService_ComputeAvera
 		if err != nil {
 			log.Fatalf("Error while reading client stream: %v", err)
 		}
-		sum += req.GetNumber()
+		sum += int64(req.GetNumber())
 		count++
 	}
 }
@@ -122,8 +152,12 @@ func main() {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
+	sessions := newSessionStore()
+	go sessions.sweep(sessionSweepInterval)
+
 	s := grpc.NewServer()
-	calculatorpb.RegisterCalculatorServiceServer(s, &server{})
+	calculatorpb.RegisterCalculatorServiceServer(s, &server{sessions: sessions})
+	calculatorpb.RegisterMatrixServiceServer(s, &matrixServer{})
 
 	// Register reflection service on gRPC server.
 	reflection.Register(s)
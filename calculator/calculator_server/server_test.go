@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialTestServer serves srv and the matrix service over an in-memory
+// connection, stop closes both ends
+func dialTestServer(t *testing.T, srv *server) (cc *grpc.ClientConn, stop func()) {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	calculatorpb.RegisterCalculatorServiceServer(s, srv)
+	calculatorpb.RegisterMatrixServiceServer(s, &matrixServer{})
+	go s.Serve(lis)
+
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) { return lis.Dial() }
+	cc, err := grpc.Dial("bufconn", grpc.WithContextDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		s.Stop()
+		t.Fatalf("cannot dial the test server: %v", err)
+	}
+	return cc, func() {
+		cc.Close()
+		s.Stop()
+	}
+}
+
+// newTestClient returns a client of a calculator server with a fresh
+// session store
+func newTestClient(t *testing.T) (calculatorpb.CalculatorServiceClient, func()) {
+	t.Helper()
+	cc, stop := dialTestServer(t, &server{sessions: newSessionStore()})
+	return calculatorpb.NewCalculatorServiceClient(cc), stop
+}
+
+// violatedFields returns the fields of the BadRequest detail of err
+func violatedFields(err error) []string {
+	var fields []string
+	for _, detail := range status.Convert(err).Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				fields = append(fields, v.GetField())
+			}
+		}
+	}
+	return fields
+}
+
+func TestSum(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		a, b int32
+		want int32
+		code codes.Code
+	}{
+		{3, 10, 13, codes.OK},
+		{-5, 2, -3, codes.OK},
+		{math.MaxInt32, math.MinInt32, -1, codes.OK},
+		{math.MaxInt32, 0, math.MaxInt32, codes.OK},
+		{math.MaxInt32, 1, 0, codes.OutOfRange},
+		{math.MinInt32, -1, 0, codes.OutOfRange},
+		{math.MaxInt32, math.MaxInt32, 0, codes.OutOfRange},
+	}
+	for _, tt := range tests {
+		res, err := c.Sum(context.Background(), &calculatorpb.SumRequest{FirstNumber: tt.a, SecondNumber: tt.b})
+		if status.Code(err) != tt.code {
+			t.Errorf("Sum(%v, %v) error = %v, want %v", tt.a, tt.b, err, tt.code)
+			continue
+		}
+		if err == nil && res.GetSumResult() != tt.want {
+			t.Errorf("Sum(%v, %v) = %v, want %v", tt.a, tt.b, res.GetSumResult(), tt.want)
+		}
+	}
+}
+
+func TestPrimeNumberDecomposition(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	type factor struct {
+		prime        int64
+		multiplicity int32
+	}
+	tests := []struct {
+		number int64
+		group  bool
+		want   []factor
+		code   codes.Code
+	}{
+		{1, false, nil, codes.OK},
+		{120, false, []factor{{2, 0}, {2, 0}, {2, 0}, {3, 0}, {5, 0}}, codes.OK},
+		{120, true, []factor{{2, 3}, {3, 1}, {5, 1}}, codes.OK},
+		// the factors found by Pollard's rho are sorted too
+		{2 * 2 * 3037000493 * 1000003, false, []factor{{2, 0}, {2, 0}, {1000003, 0}, {3037000493, 0}}, codes.OK},
+		{math.MaxInt64, false, []factor{{7, 0}, {7, 0}, {73, 0}, {127, 0}, {337, 0}, {92737, 0}, {649657, 0}}, codes.OK},
+		{0, false, nil, codes.InvalidArgument},
+		{-12, true, nil, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		stream, err := c.PrimeNumberDecomposition(context.Background(), &calculatorpb.PrimeNumberDecompositionRequest{
+			Number:       tt.number,
+			GroupFactors: tt.group,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []factor
+		for {
+			var res *calculatorpb.PrimeNumberDecompositionResponse
+			if res, err = stream.Recv(); err != nil {
+				break
+			}
+			got = append(got, factor{res.GetPrimeFactor(), res.GetMultiplicity()})
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		if status.Code(err) != tt.code {
+			t.Errorf("PrimeNumberDecomposition(%v, %v) error = %v, want %v", tt.number, tt.group, err, tt.code)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PrimeNumberDecomposition(%v, %v) = %v, want %v", tt.number, tt.group, got, tt.want)
+		}
+	}
+
+	// an expired deadline stops the decomposition
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	stream, err := c.PrimeNumberDecomposition(ctx, &calculatorpb.PrimeNumberDecompositionRequest{Number: 3037000493 * 3037000453})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("PrimeNumberDecomposition after the deadline error = %v, want %v", err, codes.DeadlineExceeded)
+	}
+}
+
+func TestComputeAverage(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		numbers []int32
+		want    float64
+		code    codes.Code
+	}{
+		{[]int32{1, 2, 3, 4}, 2.5, codes.OK},
+		{[]int32{-7}, -7, codes.OK},
+		// the sum does not overflow an int32
+		{[]int32{math.MaxInt32, math.MaxInt32, math.MaxInt32}, math.MaxInt32, codes.OK},
+		{nil, 0, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		stream, err := c.ComputeAverage(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range tt.numbers {
+			if err := stream.Send(&calculatorpb.ComputeAverageRequest{Number: n}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		res, err := stream.CloseAndRecv()
+		if status.Code(err) != tt.code {
+			t.Errorf("ComputeAverage(%v) error = %v, want %v", tt.numbers, err, tt.code)
+			continue
+		}
+		if err == nil && res.GetAverage() != tt.want {
+			t.Errorf("ComputeAverage(%v) = %v, want %v", tt.numbers, res.GetAverage(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/expr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// sessionIdleTimeout closes the sessions left unused
+	sessionIdleTimeout = 30 * time.Minute
+	// sessionSweepInterval is how often the idle sessions are freed
+	sessionSweepInterval = time.Minute
+	maxSessions          = 10000
+	maxSessionVariables  = 100
+	// maxSessionHistory entries are kept, the oldest are dropped
+	maxSessionHistory = 100
+)
+
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+type historyEntry struct {
+	expression string
+	variable   string
+	value      expr.Value
+	time       time.Time
+}
+
+// session holds the variables and history of a client, its mutex
+// serializes the calculations
+type session struct {
+	mu      sync.Mutex
+	mode    expr.Mode
+	vars    map[string]expr.Value
+	history []historyEntry
+}
+
+// sessionStore is safe for concurrent use
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	lastUsed map[string]time.Time
+}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{
+		sessions: make(map[string]*session),
+		lastUsed: make(map[string]time.Time),
+	}
+}
+
+func (st *sessionStore) create(mode expr.Mode) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", status.Errorf(codes.Internal, "Cannot generate a session id: %v", err)
+	}
+	id := hex.EncodeToString(b)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if len(st.sessions) >= maxSessions {
+		st.expireLocked(time.Now())
+		if len(st.sessions) >= maxSessions {
+			return "", status.Errorf(codes.ResourceExhausted, "Too many sessions, at most %d can be open", maxSessions)
+		}
+	}
+	st.sessions[id] = &session{mode: mode, vars: make(map[string]expr.Value)}
+	st.lastUsed[id] = time.Now()
+	return id, nil
+}
+
+// get returns the session and marks it as used
+func (st *sessionStore) get(id string) (*session, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sess, ok := st.sessions[id]
+	now := time.Now()
+	if !ok || now.Sub(st.lastUsed[id]) >= sessionIdleTimeout {
+		return nil, status.Errorf(codes.NotFound, "Session %q does not exist or expired", id)
+	}
+	st.lastUsed[id] = now
+	return sess, nil
+}
+
+// close frees the session, an expired one is freed too but reported as
+// not found, as get does
+func (st *sessionStore) close(id string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	_, ok := st.sessions[id]
+	expired := time.Since(st.lastUsed[id]) >= sessionIdleTimeout
+	delete(st.sessions, id)
+	delete(st.lastUsed, id)
+	if !ok || expired {
+		return status.Errorf(codes.NotFound, "Session %q does not exist or expired", id)
+	}
+	return nil
+}
+
+func (st *sessionStore) expireLocked(now time.Time) {
+	for id, t := range st.lastUsed {
+		if now.Sub(t) >= sessionIdleTimeout {
+			delete(st.sessions, id)
+			delete(st.lastUsed, id)
+		}
+	}
+}
+
+// sweep frees the idle sessions every interval, forever
+func (st *sessionStore) sweep(interval time.Duration) {
+	for now := range time.Tick(interval) {
+		st.mu.Lock()
+		st.expireLocked(now)
+		st.mu.Unlock()
+	}
+}
+
+// run evaluates the expression with the variables of the session, assigns
+// the result to variable unless it is empty, and records it in the history
+func (sess *session) run(e *expr.Expr, variable string) (expr.Value, error) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if _, ok := sess.vars[variable]; variable != "" && !ok && len(sess.vars) >= maxSessionVariables {
+		return expr.Value{}, status.Errorf(codes.ResourceExhausted, "Too many variables, a session holds at most %d", maxSessionVariables)
+	}
+	v, err := e.Eval(sess.mode, sess.vars)
+	if err != nil {
+		return expr.Value{}, status.Errorf(codes.InvalidArgument, "Cannot evaluate expression: %v", err)
+	}
+	if variable != "" {
+		sess.vars[variable] = v
+	}
+
+	if len(sess.history) == maxSessionHistory {
+		copy(sess.history, sess.history[1:])
+		sess.history = sess.history[:maxSessionHistory-1]
+	}
+	sess.history = append(sess.history, historyEntry{
+		expression: e.String(),
+		variable:   variable,
+		value:      v,
+		time:       time.Now(),
+	})
+	return v, nil
+}
+
+func (s *server) CreateSession(ctx context.Context, req *calculatorpb.CreateSessionRequest) (*calculatorpb.CreateSessionResponse, error) {
+	log.Printf("Received CreateSession RPC: %v", req)
+
+	mode, ok := numericModes[req.GetMode()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown mode %v", req.GetMode())
+	}
+	id, err := s.sessions.create(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &calculatorpb.CreateSessionResponse{
+		SessionId:   id,
+		IdleTimeout: ptypes.DurationProto(sessionIdleTimeout),
+	}, nil
+}
+
+func (s *server) Assign(ctx context.Context, req *calculatorpb.AssignRequest) (*calculatorpb.AssignResponse, error) {
+	log.Printf("Received Assign RPC: %v", req)
+
+	name := req.GetName()
+	if !variableName.MatchString(name) {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid variable name %q, use up to 64 letters, digits and underscores not starting with a digit", name)
+	}
+	if expr.IsFunction(name) {
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid variable name %q, it is a function", name)
+	}
+
+	sess, err := s.sessions.get(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	e, err := expr.Parse(req.GetExpression())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot parse expression: %v", err)
+	}
+	v, err := sess.run(e, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &calculatorpb.AssignResponse{Result: v.String(), Value: v.Float64()}, nil
+}
+
+func (s *server) GetHistory(ctx context.Context, req *calculatorpb.GetHistoryRequest) (*calculatorpb.GetHistoryResponse, error) {
+	log.Printf("Received GetHistory RPC: %v", req)
+
+	sess, err := s.sessions.get(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	res := &calculatorpb.GetHistoryResponse{}
+	for _, h := range sess.history {
+		ts, err := ptypes.TimestampProto(h.time)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "Invalid history time: %v", err)
+		}
+		res.Entries = append(res.Entries, &calculatorpb.HistoryEntry{
+			Expression: h.expression,
+			Variable:   h.variable,
+			Result:     h.value.String(),
+			Value:      h.value.Float64(),
+			Time:       ts,
+		})
+	}
+	return res, nil
+}
+
+func (s *server) CloseSession(ctx context.Context, req *calculatorpb.CloseSessionRequest) (*calculatorpb.CloseSessionResponse, error) {
+	log.Printf("Received CloseSession RPC: %v", req)
+
+	if err := s.sessions.close(req.GetSessionId()); err != nil {
+		return nil, err
+	}
+	return &calculatorpb.CloseSessionResponse{}, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/expr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newSessionTestClient is newTestClient returning the session store too
+func newSessionTestClient(t *testing.T) (calculatorpb.CalculatorServiceClient, *sessionStore, func()) {
+	t.Helper()
+	st := newSessionStore()
+	cc, stop := dialTestServer(t, &server{sessions: st})
+	return calculatorpb.NewCalculatorServiceClient(cc), st, stop
+}
+
+// expire makes the session idle for longer than the timeout
+func expire(st *sessionStore, id string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.lastUsed[id] = time.Now().Add(-sessionIdleTimeout)
+}
+
+func createSession(t *testing.T, c calculatorpb.CalculatorServiceClient, mode calculatorpb.NumericMode) string {
+	t.Helper()
+	res, err := c.CreateSession(context.Background(), &calculatorpb.CreateSessionRequest{Mode: mode})
+	if err != nil {
+		t.Fatalf("CreateSession(%v) error = %v", mode, err)
+	}
+	return res.GetSessionId()
+}
+
+func TestCreateSession(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	tests := []struct {
+		mode calculatorpb.NumericMode
+		code codes.Code
+	}{
+		{calculatorpb.NumericMode_MODE_FLOAT64, codes.OK},
+		{calculatorpb.NumericMode_MODE_INT64, codes.OK},
+		{calculatorpb.NumericMode_MODE_BIG_RATIONAL, codes.OK},
+		{calculatorpb.NumericMode(42), codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		res, err := c.CreateSession(context.Background(), &calculatorpb.CreateSessionRequest{Mode: tt.mode})
+		if status.Code(err) != tt.code {
+			t.Errorf("CreateSession(%v) error = %v, want %v", tt.mode, err, tt.code)
+			continue
+		}
+		if err == nil && (res.GetSessionId() == "" || res.GetIdleTimeout().GetSeconds() != int64(sessionIdleTimeout/time.Second)) {
+			t.Errorf("CreateSession(%v) = %v, want an id and the idle timeout", tt.mode, res)
+		}
+	}
+}
+
+func TestAssign(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+	id := createSession(t, c, calculatorpb.NumericMode_MODE_INT64)
+
+	tests := []struct {
+		session    string
+		name       string
+		expression string
+		result     string
+		code       codes.Code
+	}{
+		{id, "x", "7 / 2", "3", codes.OK},
+		{id, "y_2", "x * 2 + 1", "7", codes.OK},
+		{id, "x", "x + y_2", "10", codes.OK},
+		{id, strings.Repeat("a", 64), "1", "1", codes.OK},
+		{id, strings.Repeat("a", 65), "1", "", codes.InvalidArgument},
+		{id, "", "1", "", codes.InvalidArgument},
+		{id, "2x", "1", "", codes.InvalidArgument},
+		{id, "sqrt", "1", "", codes.InvalidArgument},
+		{id, "z", "1 +", "", codes.InvalidArgument},
+		{id, "z", "unknown + 1", "", codes.InvalidArgument},
+		{id, "z", "1 / 0", "", codes.InvalidArgument},
+		{"missing", "z", "1", "", codes.NotFound},
+	}
+	for _, tt := range tests {
+		res, err := c.Assign(context.Background(), &calculatorpb.AssignRequest{SessionId: tt.session, Name: tt.name, Expression: tt.expression})
+		if status.Code(err) != tt.code {
+			t.Errorf("Assign(%.20q = %q) error = %v, want %v", tt.name, tt.expression, err, tt.code)
+			continue
+		}
+		if err == nil && res.GetResult() != tt.result {
+			t.Errorf("Assign(%.20q = %q) = %q, want %q", tt.name, tt.expression, res.GetResult(), tt.result)
+		}
+	}
+}
+
+func TestSessionHistory(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+	ctx := context.Background()
+	id := createSession(t, c, calculatorpb.NumericMode_MODE_BIG_RATIONAL)
+
+	if _, err := c.Assign(ctx, &calculatorpb.AssignRequest{SessionId: id, Name: "third", Expression: "1 / 3"}); err != nil {
+		t.Fatal(err)
+	}
+	// the session mode overrides the mode of the request
+	res, err := c.Evaluate(ctx, &calculatorpb.EvaluateRequest{SessionId: id, Expression: "third * 2", Mode: calculatorpb.NumericMode_MODE_INT64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetResult() != "2/3" {
+		t.Errorf("Evaluate in session = %q, want %q", res.GetResult(), "2/3")
+	}
+	// failed evaluations are not recorded
+	if _, err := c.Evaluate(ctx, &calculatorpb.EvaluateRequest{SessionId: id, Expression: "third / 0"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Evaluate in session error = %v, want %v", err, codes.InvalidArgument)
+	}
+
+	hist, err := c.GetHistory(ctx, &calculatorpb.GetHistoryRequest{SessionId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ variable, result string }{{"third", "1/3"}, {"", "2/3"}}
+	if len(hist.GetEntries()) != len(want) {
+		t.Fatalf("GetHistory = %v, want %d entries", hist.GetEntries(), len(want))
+	}
+	for i, e := range hist.GetEntries() {
+		if e.GetVariable() != want[i].variable || e.GetResult() != want[i].result || e.GetTime() == nil {
+			t.Errorf("history entry %d = %v, want %v = %v", i, e, want[i].variable, want[i].result)
+		}
+	}
+}
+
+func TestSessionLimits(t *testing.T) {
+	c, st, stop := newSessionTestClient(t)
+	defer stop()
+	ctx := context.Background()
+	id := createSession(t, c, calculatorpb.NumericMode_MODE_INT64)
+
+	// only the latest history entries are kept
+	for i := 0; i < maxSessionHistory+5; i++ {
+		if _, err := c.Evaluate(ctx, &calculatorpb.EvaluateRequest{SessionId: id, Expression: fmt.Sprint(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hist, err := c.GetHistory(ctx, &calculatorpb.GetHistoryRequest{SessionId: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := hist.GetEntries(); len(entries) != maxSessionHistory {
+		t.Errorf("GetHistory = %d entries, want %d", len(entries), maxSessionHistory)
+	} else if entries[0].GetResult() != "5" {
+		t.Errorf("oldest history entry = %q, want %q", entries[0].GetResult(), "5")
+	}
+
+	for i := 0; i < maxSessionVariables; i++ {
+		if _, err := c.Assign(ctx, &calculatorpb.AssignRequest{SessionId: id, Name: fmt.Sprintf("v%d", i), Expression: "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		code codes.Code
+	}{
+		{"v0", codes.OK},
+		{"extra", codes.ResourceExhausted},
+	}
+	for _, tt := range tests {
+		_, err := c.Assign(ctx, &calculatorpb.AssignRequest{SessionId: id, Name: tt.name, Expression: "2"})
+		if status.Code(err) != tt.code {
+			t.Errorf("Assign(%v) with %d variables error = %v, want %v", tt.name, maxSessionVariables, err, tt.code)
+		}
+	}
+
+	// fill the store, the idle sessions make room for new ones
+	for i := 1; i < maxSessions; i++ {
+		if _, err := st.create(expr.Float64); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.CreateSession(ctx, &calculatorpb.CreateSessionRequest{}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("CreateSession with %d sessions error = %v, want %v", maxSessions, err, codes.ResourceExhausted)
+	}
+	expire(st, id)
+	if _, err := c.CreateSession(ctx, &calculatorpb.CreateSessionRequest{}); err != nil {
+		t.Errorf("CreateSession after an expiry error = %v", err)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	c, st, stop := newSessionTestClient(t)
+	defer stop()
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		call func(id string) error
+	}{
+		{"Assign", func(id string) error {
+			_, err := c.Assign(ctx, &calculatorpb.AssignRequest{SessionId: id, Name: "x", Expression: "1"})
+			return err
+		}},
+		{"Evaluate", func(id string) error {
+			_, err := c.Evaluate(ctx, &calculatorpb.EvaluateRequest{SessionId: id, Expression: "1"})
+			return err
+		}},
+		{"GetHistory", func(id string) error {
+			_, err := c.GetHistory(ctx, &calculatorpb.GetHistoryRequest{SessionId: id})
+			return err
+		}},
+		{"CloseSession", func(id string) error {
+			_, err := c.CloseSession(ctx, &calculatorpb.CloseSessionRequest{SessionId: id})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		id := createSession(t, c, calculatorpb.NumericMode_MODE_FLOAT64)
+		expire(st, id)
+		if err := tt.call(id); status.Code(err) != codes.NotFound {
+			t.Errorf("%v on an expired session error = %v, want %v", tt.name, err, codes.NotFound)
+		}
+	}
+
+	// a closed session is gone
+	id := createSession(t, c, calculatorpb.NumericMode_MODE_FLOAT64)
+	if _, err := c.CloseSession(ctx, &calculatorpb.CloseSessionRequest{SessionId: id}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if err := tt.call(id); status.Code(err) != codes.NotFound {
+			t.Errorf("%v on a closed session error = %v, want %v", tt.name, err, codes.NotFound)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io"
+	"log"
+	"math"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/stats"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var defaultPercentiles = []float64{25, 50, 75, 90, 95, 99}
+
+// maxCompression bounds the memory of a ComputeStatistics stream
+const maxCompression = 1000
+
+const maxPercentiles = 100
+
+func (*server) ComputeStatistics(stream calculatorpb.CalculatorService_ComputeStatisticsServer) error {
+	log.Printf("Received ComputeStatistics RPC")
+
+	opts := &calculatorpb.StatisticsOptions{}
+	var summary stats.Summary
+	var digest *stats.Digest
+	first := true
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if o := req.GetOptions(); o != nil {
+			if !first {
+				return status.Errorf(codes.InvalidArgument, "Options must be sent first")
+			}
+			if err := validateStatisticsOptions(o); err != nil {
+				return err
+			}
+			opts = o
+			first = false
+			continue
+		}
+		first = false
+
+		number := req.GetNumber()
+		if math.IsNaN(number) || math.IsInf(number, 0) {
+			return status.Errorf(codes.InvalidArgument, "Received a non-finite number: %v", number)
+		}
+		if digest == nil {
+			digest = stats.NewDigest(int(opts.GetCompression()))
+		}
+		summary.Add(number)
+		digest.Add(number)
+	}
+
+	res := &calculatorpb.ComputeStatisticsResponse{Count: summary.Count()}
+	if summary.Count() == 0 {
+		return stream.SendAndClose(res)
+	}
+
+	res.Sum = summary.Sum()
+	res.Mean = summary.Mean()
+	res.Variance = summary.Variance()
+	res.Stddev = math.Sqrt(summary.Variance())
+	res.SampleVariance = summary.SampleVariance()
+	// the numbers are finite but near the largest doubles their sum, or
+	// the squares of their deviations, are not
+	for _, v := range []float64{res.Sum, res.Mean, res.Variance, res.SampleVariance} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return status.Errorf(codes.OutOfRange, "The statistics of the numbers overflow a double")
+		}
+	}
+	res.Min = summary.Min()
+	res.Max = summary.Max()
+	res.Median = digest.Quantile(0.5)
+
+	percentiles := opts.GetPercentiles()
+	if len(percentiles) == 0 {
+		percentiles = defaultPercentiles
+	}
+	for _, p := range percentiles {
+		res.Percentiles = append(res.Percentiles, &calculatorpb.Percentile{
+			Percentile: p,
+			Value:      digest.Quantile(p / 100),
+		})
+	}
+
+	return stream.SendAndClose(res)
+}
+
+func validateStatisticsOptions(opts *calculatorpb.StatisticsOptions) error {
+	if len(opts.GetPercentiles()) > maxPercentiles {
+		return status.Errorf(codes.InvalidArgument, "At most %v percentiles can be requested", maxPercentiles)
+	}
+	for _, p := range opts.GetPercentiles() {
+		if !(p >= 0 && p <= 100) {
+			return status.Errorf(codes.InvalidArgument, "Percentile %v is not in [0, 100]", p)
+		}
+	}
+	if c := opts.GetCompression(); c < 0 || c > maxCompression {
+		return status.Errorf(codes.InvalidArgument, "Compression must be in [0, %v]", maxCompression)
+	}
+	return nil
+}
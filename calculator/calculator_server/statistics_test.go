@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestComputeStatistics(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	options := func(compression int32, percentiles ...float64) *calculatorpb.ComputeStatisticsRequest {
+		return &calculatorpb.ComputeStatisticsRequest{Value: &calculatorpb.ComputeStatisticsRequest_Options{
+			Options: &calculatorpb.StatisticsOptions{Percentiles: percentiles, Compression: compression},
+		}}
+	}
+	number := func(x float64) *calculatorpb.ComputeStatisticsRequest {
+		return &calculatorpb.ComputeStatisticsRequest{Value: &calculatorpb.ComputeStatisticsRequest_Number{Number: x}}
+	}
+	numbers := func(xs ...float64) []*calculatorpb.ComputeStatisticsRequest {
+		var reqs []*calculatorpb.ComputeStatisticsRequest
+		for _, x := range xs {
+			reqs = append(reqs, number(x))
+		}
+		return reqs
+	}
+	prepend := func(req *calculatorpb.ComputeStatisticsRequest, reqs []*calculatorpb.ComputeStatisticsRequest) []*calculatorpb.ComputeStatisticsRequest {
+		return append([]*calculatorpb.ComputeStatisticsRequest{req}, reqs...)
+	}
+
+	tests := []struct {
+		name string
+		reqs []*calculatorpb.ComputeStatisticsRequest
+		want *calculatorpb.ComputeStatisticsResponse
+		code codes.Code
+	}{
+		{"empty", nil, &calculatorpb.ComputeStatisticsResponse{}, codes.OK},
+		{"numbers", numbers(2, 4, 4, 4, 5, 5, 7, 9), &calculatorpb.ComputeStatisticsResponse{
+			Count: 8, Sum: 40, Mean: 5, Variance: 4, Stddev: 2, SampleVariance: 32.0 / 7, Min: 2, Max: 9, Median: 4.5,
+		}, codes.OK},
+		{"options", prepend(options(200, 0, 100), numbers(1, 2, 3)), &calculatorpb.ComputeStatisticsResponse{
+			Count: 3, Sum: 6, Mean: 2, Variance: 2.0 / 3, Stddev: math.Sqrt(2.0 / 3), SampleVariance: 1, Min: 1, Max: 3, Median: 2,
+			Percentiles: []*calculatorpb.Percentile{{Percentile: 0, Value: 1}, {Percentile: 100, Value: 3}},
+		}, codes.OK},
+		{"options after numbers", append(numbers(1), options(0)), nil, codes.InvalidArgument},
+		{"options twice", []*calculatorpb.ComputeStatisticsRequest{options(0), options(0)}, nil, codes.InvalidArgument},
+		{"percentile above 100", []*calculatorpb.ComputeStatisticsRequest{options(0, 101)}, nil, codes.InvalidArgument},
+		{"NaN percentile", []*calculatorpb.ComputeStatisticsRequest{options(0, math.NaN())}, nil, codes.InvalidArgument},
+		{"compression too high", []*calculatorpb.ComputeStatisticsRequest{options(maxCompression + 1)}, nil, codes.InvalidArgument},
+		{"NaN", numbers(1, math.NaN()), nil, codes.InvalidArgument},
+		{"infinity", numbers(math.Inf(-1)), nil, codes.InvalidArgument},
+		{"sum overflow", numbers(math.MaxFloat64, math.MaxFloat64), nil, codes.OutOfRange},
+		{"variance overflow", numbers(-math.MaxFloat64, math.MaxFloat64), nil, codes.OutOfRange},
+	}
+	for _, tt := range tests {
+		stream, err := c.ComputeStatistics(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range tt.reqs {
+			// the server may have failed the stream already
+			if stream.Send(req) != nil {
+				break
+			}
+		}
+		res, err := stream.CloseAndRecv()
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: ComputeStatistics error = %v, want %v", tt.name, err, tt.code)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if tt.want.GetPercentiles() == nil {
+			// the default percentiles
+			if len(res.GetPercentiles()) != len(defaultPercentiles) && tt.want.GetCount() > 0 {
+				t.Errorf("%v: %v percentiles, want %v", tt.name, len(res.GetPercentiles()), len(defaultPercentiles))
+			}
+			res.Percentiles = nil
+		}
+		if !statisticsEqual(res, tt.want) {
+			t.Errorf("%v: ComputeStatistics = %v, want %v", tt.name, res, tt.want)
+		}
+	}
+}
+
+// statisticsEqual compares the responses up to rounding errors
+func statisticsEqual(a, b *calculatorpb.ComputeStatisticsResponse) bool {
+	near := func(x, y float64) bool { return math.Abs(x-y) <= 1e-9*math.Max(1, math.Abs(y)) }
+	if a.GetCount() != b.GetCount() || len(a.GetPercentiles()) != len(b.GetPercentiles()) {
+		return false
+	}
+	for i, p := range a.GetPercentiles() {
+		q := b.GetPercentiles()[i]
+		if p.GetPercentile() != q.GetPercentile() || !near(p.GetValue(), q.GetValue()) {
+			return false
+		}
+	}
+	return near(a.GetSum(), b.GetSum()) && near(a.GetMean(), b.GetMean()) &&
+		near(a.GetVariance(), b.GetVariance()) && near(a.GetStddev(), b.GetStddev()) &&
+		near(a.GetSampleVariance(), b.GetSampleVariance()) &&
+		near(a.GetMin(), b.GetMin()) && near(a.GetMax(), b.GetMax()) && near(a.GetMedian(), b.GetMedian())
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io"
+	"log"
+	"math"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/window"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var windowAggregates = map[calculatorpb.WindowAggregate]window.Aggregate{
+	calculatorpb.WindowAggregate_AGGREGATE_MAX:  window.Max,
+	calculatorpb.WindowAggregate_AGGREGATE_MIN:  window.Min,
+	calculatorpb.WindowAggregate_AGGREGATE_SUM:  window.Sum,
+	calculatorpb.WindowAggregate_AGGREGATE_MEAN: window.Mean,
+}
+
+func (*server) WindowedAggregate(stream calculatorpb.CalculatorService_WindowedAggregateServer) error {
+	log.Printf("Received WindowedAggregate RPC")
+
+	var w *window.Window
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if opts := req.GetOptions(); opts != nil {
+			if w != nil {
+				return status.Errorf(codes.InvalidArgument, "Options must be sent first")
+			}
+			if w, err = newWindow(opts); err != nil {
+				return err
+			}
+			continue
+		}
+		if w == nil {
+			return status.Errorf(codes.InvalidArgument, "Options must be sent first")
+		}
+
+		number := req.GetNumber()
+		if math.IsNaN(number) || math.IsInf(number, 0) {
+			return status.Errorf(codes.InvalidArgument, "Received a non-finite number: %v", number)
+		}
+		t := time.Now()
+		if req.GetTime() != nil {
+			if t, err = ptypes.Timestamp(req.GetTime()); err != nil {
+				return status.Errorf(codes.InvalidArgument, "Invalid time: %v", err)
+			}
+		}
+
+		value, err := w.Add(number, t)
+		switch err {
+		case nil:
+		case window.ErrTimeDecreased:
+			return status.Errorf(codes.InvalidArgument, "Time %v is before the previous one", t)
+		case window.ErrTooManyValues:
+			return status.Errorf(codes.ResourceExhausted, "The window holds more than %v numbers", window.MaxValues)
+		default:
+			return status.Errorf(codes.Internal, "Internal error: %v", err)
+		}
+
+		if err := stream.Send(&calculatorpb.WindowedAggregateResponse{Value: value}); err != nil {
+			return err
+		}
+	}
+}
+
+func newWindow(opts *calculatorpb.WindowOptions) (*window.Window, error) {
+	aggregate, ok := windowAggregates[opts.GetAggregate()]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown aggregate %v", opts.GetAggregate())
+	}
+
+	switch w := opts.GetWindow().(type) {
+	case *calculatorpb.WindowOptions_Size:
+		if w.Size <= 0 || w.Size > window.MaxValues {
+			return nil, status.Errorf(codes.InvalidArgument, "Window size must be in [1, %v]", window.MaxValues)
+		}
+		return window.NewCount(aggregate, int(w.Size)), nil
+	case *calculatorpb.WindowOptions_Period:
+		period, err := ptypes.Duration(w.Period)
+		if err != nil || period <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "Window period must be positive")
+		}
+		return window.NewTime(aggregate, period), nil
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "Window size or period is required")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/vmlellis/grpc-go-learning/calculator/calculatorpb"
+	"github.com/vmlellis/grpc-go-learning/calculator/window"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWindowedAggregate(t *testing.T) {
+	c, stop := newTestClient(t)
+	defer stop()
+
+	start := time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC)
+	sized := func(aggregate calculatorpb.WindowAggregate, size int32) *calculatorpb.WindowedAggregateRequest {
+		return &calculatorpb.WindowedAggregateRequest{Value: &calculatorpb.WindowedAggregateRequest_Options{
+			Options: &calculatorpb.WindowOptions{Aggregate: aggregate, Window: &calculatorpb.WindowOptions_Size{Size: size}},
+		}}
+	}
+	timed := func(aggregate calculatorpb.WindowAggregate, period time.Duration) *calculatorpb.WindowedAggregateRequest {
+		return &calculatorpb.WindowedAggregateRequest{Value: &calculatorpb.WindowedAggregateRequest_Options{
+			Options: &calculatorpb.WindowOptions{Aggregate: aggregate, Window: &calculatorpb.WindowOptions_Period{Period: ptypes.DurationProto(period)}},
+		}}
+	}
+	// at returns the number x at the second s after start
+	at := func(x float64, s int) *calculatorpb.WindowedAggregateRequest {
+		ts, _ := ptypes.TimestampProto(start.Add(time.Duration(s) * time.Second))
+		return &calculatorpb.WindowedAggregateRequest{Value: &calculatorpb.WindowedAggregateRequest_Number{Number: x}, Time: ts}
+	}
+	number := func(x float64) *calculatorpb.WindowedAggregateRequest {
+		return &calculatorpb.WindowedAggregateRequest{Value: &calculatorpb.WindowedAggregateRequest_Number{Number: x}}
+	}
+
+	tests := []struct {
+		name string
+		reqs []*calculatorpb.WindowedAggregateRequest
+		want []float64
+		code codes.Code
+	}{
+		{"max of 3", []*calculatorpb.WindowedAggregateRequest{
+			sized(calculatorpb.WindowAggregate_AGGREGATE_MAX, 3), number(1), number(5), number(2), number(3), number(1), number(0),
+		}, []float64{1, 5, 5, 5, 3, 3}, codes.OK},
+		{"min of 2", []*calculatorpb.WindowedAggregateRequest{
+			sized(calculatorpb.WindowAggregate_AGGREGATE_MIN, 2), number(4), number(2), number(6), number(7),
+		}, []float64{4, 2, 2, 6}, codes.OK},
+		{"sum of 2", []*calculatorpb.WindowedAggregateRequest{
+			sized(calculatorpb.WindowAggregate_AGGREGATE_SUM, 2), number(1), number(2), number(3),
+		}, []float64{1, 3, 5}, codes.OK},
+		{"mean over 10s", []*calculatorpb.WindowedAggregateRequest{
+			timed(calculatorpb.WindowAggregate_AGGREGATE_MEAN, 10*time.Second), at(2, 0), at(4, 5), at(9, 12), at(1, 30),
+		}, []float64{2, 3, 6.5, 1}, codes.OK},
+		{"no options", []*calculatorpb.WindowedAggregateRequest{number(1)}, nil, codes.InvalidArgument},
+		{"options twice", []*calculatorpb.WindowedAggregateRequest{
+			sized(calculatorpb.WindowAggregate_AGGREGATE_MAX, 3), number(1), sized(calculatorpb.WindowAggregate_AGGREGATE_MAX, 3),
+		}, []float64{1}, codes.InvalidArgument},
+		{"no window", []*calculatorpb.WindowedAggregateRequest{{Value: &calculatorpb.WindowedAggregateRequest_Options{
+			Options: &calculatorpb.WindowOptions{},
+		}}}, nil, codes.InvalidArgument},
+		{"zero size", []*calculatorpb.WindowedAggregateRequest{sized(calculatorpb.WindowAggregate_AGGREGATE_MAX, 0)}, nil, codes.InvalidArgument},
+		{"size too large", []*calculatorpb.WindowedAggregateRequest{sized(calculatorpb.WindowAggregate_AGGREGATE_MAX, window.MaxValues+1)}, nil, codes.InvalidArgument},
+		{"negative period", []*calculatorpb.WindowedAggregateRequest{timed(calculatorpb.WindowAggregate_AGGREGATE_SUM, -time.Second)}, nil, codes.InvalidArgument},
+		{"unknown aggregate", []*calculatorpb.WindowedAggregateRequest{sized(calculatorpb.WindowAggregate(42), 3)}, nil, codes.InvalidArgument},
+		{"NaN", []*calculatorpb.WindowedAggregateRequest{
+			sized(calculatorpb.WindowAggregate_AGGREGATE_SUM, 3), number(1), number(math.NaN()),
+		}, []float64{1}, codes.InvalidArgument},
+		{"time going back", []*calculatorpb.WindowedAggregateRequest{
+			timed(calculatorpb.WindowAggregate_AGGREGATE_SUM, time.Minute), at(1, 10), at(2, 5),
+		}, []float64{1}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		stream, err := c.WindowedAggregate(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range tt.reqs {
+			// the server may have failed the stream already
+			if stream.Send(req) != nil {
+				break
+			}
+		}
+		stream.CloseSend()
+
+		var got []float64
+		for {
+			var res *calculatorpb.WindowedAggregateResponse
+			if res, err = stream.Recv(); err != nil {
+				break
+			}
+			got = append(got, res.GetValue())
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: WindowedAggregate error = %v, want %v", tt.name, err, tt.code)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: WindowedAggregate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -9,6 +9,8 @@ package calculatorpb
 import (
 	context "context"
 	proto "github.com/golang/protobuf/proto"
+	duration "github.com/golang/protobuf/ptypes/duration"
+	timestamp "github.com/golang/protobuf/ptypes/timestamp"
 	grpc "google.golang.org/grpc"
 	codes "google.golang.org/grpc/codes"
 	status "google.golang.org/grpc/status"
@@ -29,6 +31,172 @@ const (
 // of the legacy proto package is being used.
 const _ = proto.ProtoPackageIsVersion4
 
+type BigOperation int32
+
+const (
+	BigOperation_OPERATION_ADD      BigOperation = 0
+	BigOperation_OPERATION_SUBTRACT BigOperation = 1
+	BigOperation_OPERATION_MULTIPLY BigOperation = 2
+	// truncated towards zero
+	BigOperation_OPERATION_DIVIDE BigOperation = 3
+	// remainder of OPERATION_DIVIDE, it has the sign of the first number
+	BigOperation_OPERATION_MOD BigOperation = 4
+	// raises the first number to the second, which must not be negative
+	BigOperation_OPERATION_POW BigOperation = 5
+)
+
+// Enum value maps for BigOperation.
+var (
+	BigOperation_name = map[int32]string{
+		0: "OPERATION_ADD",
+		1: "OPERATION_SUBTRACT",
+		2: "OPERATION_MULTIPLY",
+		3: "OPERATION_DIVIDE",
+		4: "OPERATION_MOD",
+		5: "OPERATION_POW",
+	}
+	BigOperation_value = map[string]int32{
+		"OPERATION_ADD":      0,
+		"OPERATION_SUBTRACT": 1,
+		"OPERATION_MULTIPLY": 2,
+		"OPERATION_DIVIDE":   3,
+		"OPERATION_MOD":      4,
+		"OPERATION_POW":      5,
+	}
+)
+
+func (x BigOperation) Enum() *BigOperation {
+	p := new(BigOperation)
+	*p = x
+	return p
+}
+
+func (x BigOperation) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BigOperation) Descriptor() protoreflect.EnumDescriptor {
+	return file_calculator_calculatorpb_calculator_proto_enumTypes[0].Descriptor()
+}
+
+func (BigOperation) Type() protoreflect.EnumType {
+	return &file_calculator_calculatorpb_calculator_proto_enumTypes[0]
+}
+
+func (x BigOperation) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BigOperation.Descriptor instead.
+func (BigOperation) EnumDescriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{0}
+}
+
+type NumericMode int32
+
+const (
+	// IEEE 754 doubles, the results must stay finite
+	NumericMode_MODE_FLOAT64 NumericMode = 0
+	// fails on overflow, division truncates towards zero
+	NumericMode_MODE_INT64 NumericMode = 1
+	// exact fractions, log, sin and cos are not available and sqrt only
+	// for the squares of rationals
+	NumericMode_MODE_BIG_RATIONAL NumericMode = 2
+)
+
+// Enum value maps for NumericMode.
+var (
+	NumericMode_name = map[int32]string{
+		0: "MODE_FLOAT64",
+		1: "MODE_INT64",
+		2: "MODE_BIG_RATIONAL",
+	}
+	NumericMode_value = map[string]int32{
+		"MODE_FLOAT64":      0,
+		"MODE_INT64":        1,
+		"MODE_BIG_RATIONAL": 2,
+	}
+)
+
+func (x NumericMode) Enum() *NumericMode {
+	p := new(NumericMode)
+	*p = x
+	return p
+}
+
+func (x NumericMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (NumericMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_calculator_calculatorpb_calculator_proto_enumTypes[1].Descriptor()
+}
+
+func (NumericMode) Type() protoreflect.EnumType {
+	return &file_calculator_calculatorpb_calculator_proto_enumTypes[1]
+}
+
+func (x NumericMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use NumericMode.Descriptor instead.
+func (NumericMode) EnumDescriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{1}
+}
+
+type WindowAggregate int32
+
+const (
+	WindowAggregate_AGGREGATE_MAX  WindowAggregate = 0
+	WindowAggregate_AGGREGATE_MIN  WindowAggregate = 1
+	WindowAggregate_AGGREGATE_SUM  WindowAggregate = 2
+	WindowAggregate_AGGREGATE_MEAN WindowAggregate = 3
+)
+
+// Enum value maps for WindowAggregate.
+var (
+	WindowAggregate_name = map[int32]string{
+		0: "AGGREGATE_MAX",
+		1: "AGGREGATE_MIN",
+		2: "AGGREGATE_SUM",
+		3: "AGGREGATE_MEAN",
+	}
+	WindowAggregate_value = map[string]int32{
+		"AGGREGATE_MAX":  0,
+		"AGGREGATE_MIN":  1,
+		"AGGREGATE_SUM":  2,
+		"AGGREGATE_MEAN": 3,
+	}
+)
+
+func (x WindowAggregate) Enum() *WindowAggregate {
+	p := new(WindowAggregate)
+	*p = x
+	return p
+}
+
+func (x WindowAggregate) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (WindowAggregate) Descriptor() protoreflect.EnumDescriptor {
+	return file_calculator_calculatorpb_calculator_proto_enumTypes[2].Descriptor()
+}
+
+func (WindowAggregate) Type() protoreflect.EnumType {
+	return &file_calculator_calculatorpb_calculator_proto_enumTypes[2]
+}
+
+func (x WindowAggregate) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use WindowAggregate.Descriptor instead.
+func (WindowAggregate) EnumDescriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{2}
+}
+
 type SumRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -137,6 +305,9 @@ type PrimeNumberDecompositionRequest struct {
 	unknownFields protoimpl.UnknownFields
 
 	Number int64 `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	// send each prime factor once with its multiplicity, instead of
+	// repeating it as many times as it divides the number
+	GroupFactors bool `protobuf:"varint,2,opt,name=group_factors,json=groupFactors,proto3" json:"group_factors,omitempty"`
 }
 
 func (x *PrimeNumberDecompositionRequest) Reset() {
@@ -178,12 +349,26 @@ func (x *PrimeNumberDecompositionRequest) GetNumber() int64 {
 	return 0
 }
 
+func (x *PrimeNumberDecompositionRequest) GetGroupFactors() bool {
+	if x != nil {
+		return x.GroupFactors
+	}
+	return false
+}
+
 type PrimeNumberDecompositionResponse struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
+	// the repeated prime factors are sent in ascending order once the number
+	// is factored. With group_factors, they are sent as soon as they are
+	// found: the ones below 1000 in ascending order, then the others in no
+	// particular order.
 	PrimeFactor int64 `protobuf:"varint,1,opt,name=prime_factor,json=primeFactor,proto3" json:"prime_factor,omitempty"`
+	// number of times prime_factor divides the number, only set with
+	// group_factors
+	Multiplicity int32 `protobuf:"varint,2,opt,name=multiplicity,proto3" json:"multiplicity,omitempty"`
 }
 
 func (x *PrimeNumberDecompositionResponse) Reset() {
@@ -225,6 +410,13 @@ func (x *PrimeNumberDecompositionResponse) GetPrimeFactor() int64 {
 	return 0
 }
 
+func (x *PrimeNumberDecompositionResponse) GetMultiplicity() int32 {
+	if x != nil {
+		return x.Multiplicity
+	}
+	return 0
+}
+
 type ComputeAverageRequest struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -507,589 +699,5815 @@ func (x *SquareRootResponse) GetNumberRoot() float64 {
 	return 0
 }
 
-var File_calculator_calculatorpb_calculator_proto protoreflect.FileDescriptor
+// BigInteger is an integer of arbitrary size
+type BigInteger struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
 
-var file_calculator_calculatorpb_calculator_proto_rawDesc = []byte{
-	0x0a, 0x28, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2f, 0x63, 0x61, 0x6c,
-	0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x70, 0x62, 0x2f, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c,
-	0x61, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0a, 0x63, 0x61, 0x6c, 0x63,
-	0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x22, 0x54, 0x0a, 0x0a, 0x53, 0x75, 0x6d, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x66, 0x69, 0x72, 0x73, 0x74, 0x5f, 0x6e, 0x75,
-	0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x66, 0x69, 0x72, 0x73,
-	0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65, 0x63, 0x6f, 0x6e,
-	0x64, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c,
-	0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x2c, 0x0a, 0x0b,
-	0x53, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x73,
-	0x75, 0x6d, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52,
-	0x09, 0x73, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x39, 0x0a, 0x1f, 0x50, 0x72,
-	0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f,
-	0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a,
-	0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x6e,
-	0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x45, 0x0a, 0x20, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75,
-	0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f,
-	0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x70, 0x72, 0x69,
-	0x6d, 0x65, 0x5f, 0x66, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52,
-	0x0b, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x46, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x22, 0x2f, 0x0a, 0x15,
-	0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x32, 0x0a,
-	0x16, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x52,
-	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x76, 0x65, 0x72, 0x61,
-	0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x07, 0x61, 0x76, 0x65, 0x72, 0x61, 0x67,
-	0x65, 0x22, 0x2c, 0x0a, 0x12, 0x46, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d,
-	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65,
-	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22,
-	0x2f, 0x0a, 0x13, 0x46, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x52, 0x65,
-	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x61, 0x78, 0x69, 0x6d, 0x75,
-	0x6d, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x6d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d,
-	0x22, 0x2b, 0x0a, 0x11, 0x53, 0x71, 0x75, 0x61, 0x72, 0x65, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65,
-	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x35, 0x0a,
-	0x12, 0x53, 0x71, 0x75, 0x61, 0x72, 0x65, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f,
-	0x6e, 0x73, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x5f, 0x72, 0x6f,
-	0x6f, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0a, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72,
-	0x52, 0x6f, 0x6f, 0x74, 0x32, 0xca, 0x03, 0x0a, 0x11, 0x43, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61,
-	0x74, 0x6f, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x38, 0x0a, 0x03, 0x53, 0x75,
-	0x6d, 0x12, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x53,
-	0x75, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x63, 0x61, 0x6c, 0x63,
-	0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x53, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
-	0x73, 0x65, 0x22, 0x00, 0x12, 0x79, 0x0a, 0x18, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d,
-	0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e,
-	0x12, 0x2b, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x50, 0x72,
-	0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f,
-	0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2c, 0x2e,
-	0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x50, 0x72, 0x69, 0x6d, 0x65,
-	0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69, 0x74,
-	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x30, 0x01, 0x12,
-	0x5b, 0x0a, 0x0e, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67,
-	0x65, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x43,
-	0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x52, 0x65, 0x71,
-	0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f,
-	0x72, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65,
-	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x12, 0x54, 0x0a, 0x0b,
-	0x46, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x12, 0x1e, 0x2e, 0x63, 0x61,
-	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x78,
-	0x69, 0x6d, 0x75, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x61,
-	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x78,
-	0x69, 0x6d, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01,
-	0x30, 0x01, 0x12, 0x4d, 0x0a, 0x0a, 0x53, 0x71, 0x75, 0x61, 0x72, 0x65, 0x52, 0x6f, 0x6f, 0x74,
-	0x12, 0x1d, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x53, 0x71,
-	0x75, 0x61, 0x72, 0x65, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
-	0x1e, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x53, 0x71, 0x75,
-	0x61, 0x72, 0x65, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
-	0x00, 0x42, 0x19, 0x5a, 0x17, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2f,
-	0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x33,
+	// Types that are assignable to Value:
+	//	*BigInteger_Decimal
+	//	*BigInteger_TwosComplement
+	Value isBigInteger_Value `protobuf_oneof:"value"`
 }
 
-var (
-	file_calculator_calculatorpb_calculator_proto_rawDescOnce sync.Once
-	file_calculator_calculatorpb_calculator_proto_rawDescData = file_calculator_calculatorpb_calculator_proto_rawDesc
-)
+func (x *BigInteger) Reset() {
+	*x = BigInteger{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
 
-func file_calculator_calculatorpb_calculator_proto_rawDescGZIP() []byte {
-	file_calculator_calculatorpb_calculator_proto_rawDescOnce.Do(func() {
-		file_calculator_calculatorpb_calculator_proto_rawDescData = protoimpl.X.CompressGZIP(file_calculator_calculatorpb_calculator_proto_rawDescData)
-	})
-	return file_calculator_calculatorpb_calculator_proto_rawDescData
+func (x *BigInteger) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var file_calculator_calculatorpb_calculator_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
-var file_calculator_calculatorpb_calculator_proto_goTypes = []interface{}{
-	(*SumRequest)(nil),                       // 0: calculator.SumRequest
-	(*SumResponse)(nil),                      // 1: calculator.SumResponse
-	(*PrimeNumberDecompositionRequest)(nil),  // 2: calculator.PrimeNumberDecompositionRequest
-	(*PrimeNumberDecompositionResponse)(nil), // 3: calculator.PrimeNumberDecompositionResponse
-	(*ComputeAverageRequest)(nil),            // 4: calculator.ComputeAverageRequest
-	(*ComputeAverageResponse)(nil),           // 5: calculator.ComputeAverageResponse
-	(*FindMaximumRequest)(nil),               // 6: calculator.FindMaximumRequest
-	(*FindMaximumResponse)(nil),              // 7: calculator.FindMaximumResponse
-	(*SquareRootRequest)(nil),                // 8: calculator.SquareRootRequest
-	(*SquareRootResponse)(nil),               // 9: calculator.SquareRootResponse
+func (*BigInteger) ProtoMessage() {}
+
+func (x *BigInteger) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
-var file_calculator_calculatorpb_calculator_proto_depIdxs = []int32{
-	0, // 0: calculator.CalculatorService.Sum:input_type -> calculator.SumRequest
-	2, // 1: calculator.CalculatorService.PrimeNumberDecomposition:input_type -> calculator.PrimeNumberDecompositionRequest
-	4, // 2: calculator.CalculatorService.ComputeAverage:input_type -> calculator.ComputeAverageRequest
-	6, // 3: calculator.CalculatorService.FindMaximum:input_type -> calculator.FindMaximumRequest
-	8, // 4: calculator.CalculatorService.SquareRoot:input_type -> calculator.SquareRootRequest
-	1, // 5: calculator.CalculatorService.Sum:output_type -> calculator.SumResponse
-	3, // 6: calculator.CalculatorService.PrimeNumberDecomposition:output_type -> calculator.PrimeNumberDecompositionResponse
-	5, // 7: calculator.CalculatorService.ComputeAverage:output_type -> calculator.ComputeAverageResponse
-	7, // 8: calculator.CalculatorService.FindMaximum:output_type -> calculator.FindMaximumResponse
-	9, // 9: calculator.CalculatorService.SquareRoot:output_type -> calculator.SquareRootResponse
-	5, // [5:10] is the sub-list for method output_type
-	0, // [0:5] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+
+// Deprecated: Use BigInteger.ProtoReflect.Descriptor instead.
+func (*BigInteger) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{10}
 }
 
-func init() { file_calculator_calculatorpb_calculator_proto_init() }
-func file_calculator_calculatorpb_calculator_proto_init() {
-	if File_calculator_calculatorpb_calculator_proto != nil {
-		return
+func (m *BigInteger) GetValue() isBigInteger_Value {
+	if m != nil {
+		return m.Value
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_calculator_calculatorpb_calculator_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SumRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_calculator_calculatorpb_calculator_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SumResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_calculator_calculatorpb_calculator_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PrimeNumberDecompositionRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_calculator_calculatorpb_calculator_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*PrimeNumberDecompositionResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_calculator_calculatorpb_calculator_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ComputeAverageRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_calculator_calculatorpb_calculator_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ComputeAverageResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_calculator_calculatorpb_calculator_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FindMaximumRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_calculator_calculatorpb_calculator_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*FindMaximumResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_calculator_calculatorpb_calculator_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SquareRootRequest); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_calculator_calculatorpb_calculator_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*SquareRootResponse); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
+	return nil
+}
+
+func (x *BigInteger) GetDecimal() string {
+	if x, ok := x.GetValue().(*BigInteger_Decimal); ok {
+		return x.Decimal
 	}
-	type x struct{}
-	out := protoimpl.TypeBuilder{
-		File: protoimpl.DescBuilder{
-			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
-			RawDescriptor: file_calculator_calculatorpb_calculator_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   10,
-			NumExtensions: 0,
-			NumServices:   1,
-		},
-		GoTypes:           file_calculator_calculatorpb_calculator_proto_goTypes,
-		DependencyIndexes: file_calculator_calculatorpb_calculator_proto_depIdxs,
-		MessageInfos:      file_calculator_calculatorpb_calculator_proto_msgTypes,
-	}.Build()
-	File_calculator_calculatorpb_calculator_proto = out.File
-	file_calculator_calculatorpb_calculator_proto_rawDesc = nil
-	file_calculator_calculatorpb_calculator_proto_goTypes = nil
-	file_calculator_calculatorpb_calculator_proto_depIdxs = nil
+	return ""
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
+func (x *BigInteger) GetTwosComplement() []byte {
+	if x, ok := x.GetValue().(*BigInteger_TwosComplement); ok {
+		return x.TwosComplement
+	}
+	return nil
+}
+
+type isBigInteger_Value interface {
+	isBigInteger_Value()
+}
+
+type BigInteger_Decimal struct {
+	// base 10 with an optional sign, e.g. "-12345678901234567890"
+	Decimal string `protobuf:"bytes,1,opt,name=decimal,proto3,oneof"`
+}
+
+type BigInteger_TwosComplement struct {
+	// big-endian two's complement, as produced by Java's
+	// BigInteger.toByteArray, empty is zero
+	TwosComplement []byte `protobuf:"bytes,2,opt,name=twos_complement,json=twosComplement,proto3,oneof"`
+}
+
+func (*BigInteger_Decimal) isBigInteger_Value() {}
+
+func (*BigInteger_TwosComplement) isBigInteger_Value() {}
+
+type BigArithmeticRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Operation    BigOperation `protobuf:"varint,1,opt,name=operation,proto3,enum=calculator.BigOperation" json:"operation,omitempty"`
+	FirstNumber  *BigInteger  `protobuf:"bytes,2,opt,name=first_number,json=firstNumber,proto3" json:"first_number,omitempty"`
+	SecondNumber *BigInteger  `protobuf:"bytes,3,opt,name=second_number,json=secondNumber,proto3" json:"second_number,omitempty"`
+}
+
+func (x *BigArithmeticRequest) Reset() {
+	*x = BigArithmeticRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BigArithmeticRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BigArithmeticRequest) ProtoMessage() {}
+
+func (x *BigArithmeticRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BigArithmeticRequest.ProtoReflect.Descriptor instead.
+func (*BigArithmeticRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *BigArithmeticRequest) GetOperation() BigOperation {
+	if x != nil {
+		return x.Operation
+	}
+	return BigOperation_OPERATION_ADD
+}
+
+func (x *BigArithmeticRequest) GetFirstNumber() *BigInteger {
+	if x != nil {
+		return x.FirstNumber
+	}
+	return nil
+}
+
+func (x *BigArithmeticRequest) GetSecondNumber() *BigInteger {
+	if x != nil {
+		return x.SecondNumber
+	}
+	return nil
+}
+
+type BigArithmeticResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// encoded like first_number
+	Result *BigInteger `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+}
+
+func (x *BigArithmeticResponse) Reset() {
+	*x = BigArithmeticResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BigArithmeticResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BigArithmeticResponse) ProtoMessage() {}
+
+func (x *BigArithmeticResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BigArithmeticResponse.ProtoReflect.Descriptor instead.
+func (*BigArithmeticResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *BigArithmeticResponse) GetResult() *BigInteger {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+type BigPrimeNumberDecompositionRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Number *BigInteger `protobuf:"bytes,1,opt,name=number,proto3" json:"number,omitempty"`
+}
+
+func (x *BigPrimeNumberDecompositionRequest) Reset() {
+	*x = BigPrimeNumberDecompositionRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BigPrimeNumberDecompositionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BigPrimeNumberDecompositionRequest) ProtoMessage() {}
+
+func (x *BigPrimeNumberDecompositionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BigPrimeNumberDecompositionRequest.ProtoReflect.Descriptor instead.
+func (*BigPrimeNumberDecompositionRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *BigPrimeNumberDecompositionRequest) GetNumber() *BigInteger {
+	if x != nil {
+		return x.Number
+	}
+	return nil
+}
+
+type BigPrimeNumberDecompositionResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// encoded like number, sent as soon as it is found and repeated as many
+	// times as it divides the number: the factors below 1000 in ascending
+	// order, then the others in no particular order
+	PrimeFactor *BigInteger `protobuf:"bytes,1,opt,name=prime_factor,json=primeFactor,proto3" json:"prime_factor,omitempty"`
+}
+
+func (x *BigPrimeNumberDecompositionResponse) Reset() {
+	*x = BigPrimeNumberDecompositionResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BigPrimeNumberDecompositionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BigPrimeNumberDecompositionResponse) ProtoMessage() {}
+
+func (x *BigPrimeNumberDecompositionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BigPrimeNumberDecompositionResponse.ProtoReflect.Descriptor instead.
+func (*BigPrimeNumberDecompositionResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *BigPrimeNumberDecompositionResponse) GetPrimeFactor() *BigInteger {
+	if x != nil {
+		return x.PrimeFactor
+	}
+	return nil
+}
+
+type EvaluateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// infix expression with + - * / % ^, parentheses, unary minus and the
+	// functions sqrt, abs, log (natural), sin, cos, min and max
+	Expression string `protobuf:"bytes,1,opt,name=expression,proto3" json:"expression,omitempty"`
+	// ignored with a session, which has its own mode
+	Mode NumericMode `protobuf:"varint,2,opt,name=mode,proto3,enum=calculator.NumericMode" json:"mode,omitempty"`
+	// evaluate in this session, the expression may refer to its variables
+	SessionId string `protobuf:"bytes,3,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+}
+
+func (x *EvaluateRequest) Reset() {
+	*x = EvaluateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EvaluateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvaluateRequest) ProtoMessage() {}
+
+func (x *EvaluateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvaluateRequest.ProtoReflect.Descriptor instead.
+func (*EvaluateRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *EvaluateRequest) GetExpression() string {
+	if x != nil {
+		return x.Expression
+	}
+	return ""
+}
+
+func (x *EvaluateRequest) GetMode() NumericMode {
+	if x != nil {
+		return x.Mode
+	}
+	return NumericMode_MODE_FLOAT64
+}
+
+func (x *EvaluateRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type EvaluateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// exact result in the requested mode: an integer, the shortest
+	// representation of a double or a fraction "p/q"
+	Result string `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	// closest double to the result
+	Value float64 `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *EvaluateResponse) Reset() {
+	*x = EvaluateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EvaluateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EvaluateResponse) ProtoMessage() {}
+
+func (x *EvaluateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EvaluateResponse.ProtoReflect.Descriptor instead.
+func (*EvaluateResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *EvaluateResponse) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+func (x *EvaluateResponse) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type StatisticsOptions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// percentiles to estimate, in [0, 100], 25, 50, 75, 90, 95 and 99 when
+	// empty
+	Percentiles []float64 `protobuf:"fixed64,1,rep,packed,name=percentiles,proto3" json:"percentiles,omitempty"`
+	// accuracy of the percentile estimates, the memory used grows with it,
+	// 100 when 0, at most 1000
+	Compression int32 `protobuf:"varint,2,opt,name=compression,proto3" json:"compression,omitempty"`
+}
+
+func (x *StatisticsOptions) Reset() {
+	*x = StatisticsOptions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StatisticsOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatisticsOptions) ProtoMessage() {}
+
+func (x *StatisticsOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatisticsOptions.ProtoReflect.Descriptor instead.
+func (*StatisticsOptions) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *StatisticsOptions) GetPercentiles() []float64 {
+	if x != nil {
+		return x.Percentiles
+	}
+	return nil
+}
+
+func (x *StatisticsOptions) GetCompression() int32 {
+	if x != nil {
+		return x.Compression
+	}
+	return 0
+}
+
+type ComputeStatisticsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Value:
+	//	*ComputeStatisticsRequest_Options
+	//	*ComputeStatisticsRequest_Number
+	Value isComputeStatisticsRequest_Value `protobuf_oneof:"value"`
+}
+
+func (x *ComputeStatisticsRequest) Reset() {
+	*x = ComputeStatisticsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ComputeStatisticsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ComputeStatisticsRequest) ProtoMessage() {}
+
+func (x *ComputeStatisticsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ComputeStatisticsRequest.ProtoReflect.Descriptor instead.
+func (*ComputeStatisticsRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{18}
+}
+
+func (m *ComputeStatisticsRequest) GetValue() isComputeStatisticsRequest_Value {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (x *ComputeStatisticsRequest) GetOptions() *StatisticsOptions {
+	if x, ok := x.GetValue().(*ComputeStatisticsRequest_Options); ok {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *ComputeStatisticsRequest) GetNumber() float64 {
+	if x, ok := x.GetValue().(*ComputeStatisticsRequest_Number); ok {
+		return x.Number
+	}
+	return 0
+}
+
+type isComputeStatisticsRequest_Value interface {
+	isComputeStatisticsRequest_Value()
+}
+
+type ComputeStatisticsRequest_Options struct {
+	// only in the first message
+	Options *StatisticsOptions `protobuf:"bytes,1,opt,name=options,proto3,oneof"`
+}
+
+type ComputeStatisticsRequest_Number struct {
+	Number float64 `protobuf:"fixed64,2,opt,name=number,proto3,oneof"`
+}
+
+func (*ComputeStatisticsRequest_Options) isComputeStatisticsRequest_Value() {}
+
+func (*ComputeStatisticsRequest_Number) isComputeStatisticsRequest_Value() {}
+
+type Percentile struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Percentile float64 `protobuf:"fixed64,1,opt,name=percentile,proto3" json:"percentile,omitempty"`
+	Value      float64 `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *Percentile) Reset() {
+	*x = Percentile{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Percentile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Percentile) ProtoMessage() {}
+
+func (x *Percentile) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Percentile.ProtoReflect.Descriptor instead.
+func (*Percentile) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Percentile) GetPercentile() float64 {
+	if x != nil {
+		return x.Percentile
+	}
+	return 0
+}
+
+func (x *Percentile) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type ComputeStatisticsResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the other fields are only set when count is positive
+	Count int64   `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Sum   float64 `protobuf:"fixed64,2,opt,name=sum,proto3" json:"sum,omitempty"`
+	Mean  float64 `protobuf:"fixed64,3,opt,name=mean,proto3" json:"mean,omitempty"`
+	// of the population, sample_variance divides by count-1
+	Variance       float64 `protobuf:"fixed64,4,opt,name=variance,proto3" json:"variance,omitempty"`
+	Stddev         float64 `protobuf:"fixed64,5,opt,name=stddev,proto3" json:"stddev,omitempty"`
+	SampleVariance float64 `protobuf:"fixed64,6,opt,name=sample_variance,json=sampleVariance,proto3" json:"sample_variance,omitempty"`
+	Min            float64 `protobuf:"fixed64,7,opt,name=min,proto3" json:"min,omitempty"`
+	Max            float64 `protobuf:"fixed64,8,opt,name=max,proto3" json:"max,omitempty"`
+	// the median and percentiles are estimates
+	Median      float64       `protobuf:"fixed64,9,opt,name=median,proto3" json:"median,omitempty"`
+	Percentiles []*Percentile `protobuf:"bytes,10,rep,name=percentiles,proto3" json:"percentiles,omitempty"`
+}
+
+func (x *ComputeStatisticsResponse) Reset() {
+	*x = ComputeStatisticsResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ComputeStatisticsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ComputeStatisticsResponse) ProtoMessage() {}
+
+func (x *ComputeStatisticsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ComputeStatisticsResponse.ProtoReflect.Descriptor instead.
+func (*ComputeStatisticsResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ComputeStatisticsResponse) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *ComputeStatisticsResponse) GetSum() float64 {
+	if x != nil {
+		return x.Sum
+	}
+	return 0
+}
+
+func (x *ComputeStatisticsResponse) GetMean() float64 {
+	if x != nil {
+		return x.Mean
+	}
+	return 0
+}
+
+func (x *ComputeStatisticsResponse) GetVariance() float64 {
+	if x != nil {
+		return x.Variance
+	}
+	return 0
+}
+
+func (x *ComputeStatisticsResponse) GetStddev() float64 {
+	if x != nil {
+		return x.Stddev
+	}
+	return 0
+}
+
+func (x *ComputeStatisticsResponse) GetSampleVariance() float64 {
+	if x != nil {
+		return x.SampleVariance
+	}
+	return 0
+}
+
+func (x *ComputeStatisticsResponse) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *ComputeStatisticsResponse) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *ComputeStatisticsResponse) GetMedian() float64 {
+	if x != nil {
+		return x.Median
+	}
+	return 0
+}
+
+func (x *ComputeStatisticsResponse) GetPercentiles() []*Percentile {
+	if x != nil {
+		return x.Percentiles
+	}
+	return nil
+}
+
+type WindowOptions struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Aggregate WindowAggregate `protobuf:"varint,1,opt,name=aggregate,proto3,enum=calculator.WindowAggregate" json:"aggregate,omitempty"`
+	// Types that are assignable to Window:
+	//	*WindowOptions_Size
+	//	*WindowOptions_Period
+	Window isWindowOptions_Window `protobuf_oneof:"window"`
+}
+
+func (x *WindowOptions) Reset() {
+	*x = WindowOptions{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WindowOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WindowOptions) ProtoMessage() {}
+
+func (x *WindowOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WindowOptions.ProtoReflect.Descriptor instead.
+func (*WindowOptions) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *WindowOptions) GetAggregate() WindowAggregate {
+	if x != nil {
+		return x.Aggregate
+	}
+	return WindowAggregate_AGGREGATE_MAX
+}
+
+func (m *WindowOptions) GetWindow() isWindowOptions_Window {
+	if m != nil {
+		return m.Window
+	}
+	return nil
+}
+
+func (x *WindowOptions) GetSize() int32 {
+	if x, ok := x.GetWindow().(*WindowOptions_Size); ok {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *WindowOptions) GetPeriod() *duration.Duration {
+	if x, ok := x.GetWindow().(*WindowOptions_Period); ok {
+		return x.Period
+	}
+	return nil
+}
+
+type isWindowOptions_Window interface {
+	isWindowOptions_Window()
+}
+
+type WindowOptions_Size struct {
+	// the last size values, at most 1048576
+	Size int32 `protobuf:"varint,2,opt,name=size,proto3,oneof"`
+}
+
+type WindowOptions_Period struct {
+	// the values whose time is within period of the last one
+	Period *duration.Duration `protobuf:"bytes,3,opt,name=period,proto3,oneof"`
+}
+
+func (*WindowOptions_Size) isWindowOptions_Window() {}
+
+func (*WindowOptions_Period) isWindowOptions_Window() {}
+
+type WindowedAggregateRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Value:
+	//	*WindowedAggregateRequest_Options
+	//	*WindowedAggregateRequest_Number
+	Value isWindowedAggregateRequest_Value `protobuf_oneof:"value"`
+	// time of number for the period windows, the time the server received it
+	// when unset; it must not go backwards
+	Time *timestamp.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (x *WindowedAggregateRequest) Reset() {
+	*x = WindowedAggregateRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[22]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WindowedAggregateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WindowedAggregateRequest) ProtoMessage() {}
+
+func (x *WindowedAggregateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[22]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WindowedAggregateRequest.ProtoReflect.Descriptor instead.
+func (*WindowedAggregateRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{22}
+}
+
+func (m *WindowedAggregateRequest) GetValue() isWindowedAggregateRequest_Value {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (x *WindowedAggregateRequest) GetOptions() *WindowOptions {
+	if x, ok := x.GetValue().(*WindowedAggregateRequest_Options); ok {
+		return x.Options
+	}
+	return nil
+}
+
+func (x *WindowedAggregateRequest) GetNumber() float64 {
+	if x, ok := x.GetValue().(*WindowedAggregateRequest_Number); ok {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *WindowedAggregateRequest) GetTime() *timestamp.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+type isWindowedAggregateRequest_Value interface {
+	isWindowedAggregateRequest_Value()
+}
+
+type WindowedAggregateRequest_Options struct {
+	// required, only in the first message
+	Options *WindowOptions `protobuf:"bytes,1,opt,name=options,proto3,oneof"`
+}
+
+type WindowedAggregateRequest_Number struct {
+	Number float64 `protobuf:"fixed64,2,opt,name=number,proto3,oneof"`
+}
+
+func (*WindowedAggregateRequest_Options) isWindowedAggregateRequest_Value() {}
+
+func (*WindowedAggregateRequest_Number) isWindowedAggregateRequest_Value() {}
+
+type WindowedAggregateResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// aggregate of the window ending with the last number received
+	Value float64 `protobuf:"fixed64,1,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *WindowedAggregateResponse) Reset() {
+	*x = WindowedAggregateResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[23]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WindowedAggregateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WindowedAggregateResponse) ProtoMessage() {}
+
+func (x *WindowedAggregateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[23]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WindowedAggregateResponse.ProtoReflect.Descriptor instead.
+func (*WindowedAggregateResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *WindowedAggregateResponse) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type RootRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Number:
+	//	*RootRequest_Value
+	//	*RootRequest_Decimal
+	Number isRootRequest_Number `protobuf_oneof:"number"`
+	// 2 when 0, at most 10000
+	Degree int32 `protobuf:"varint,3,opt,name=degree,proto3" json:"degree,omitempty"`
+	// significant digits of the result, 15 when 0, at most 1000
+	SignificantDigits int32 `protobuf:"varint,4,opt,name=significant_digits,json=significantDigits,proto3" json:"significant_digits,omitempty"`
+	// return the principal complex root of the negative numbers with an even
+	// degree instead of INVALID_ARGUMENT
+	AllowComplex bool `protobuf:"varint,5,opt,name=allow_complex,json=allowComplex,proto3" json:"allow_complex,omitempty"`
+}
+
+func (x *RootRequest) Reset() {
+	*x = RootRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[24]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RootRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RootRequest) ProtoMessage() {}
+
+func (x *RootRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[24]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RootRequest.ProtoReflect.Descriptor instead.
+func (*RootRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{24}
+}
+
+func (m *RootRequest) GetNumber() isRootRequest_Number {
+	if m != nil {
+		return m.Number
+	}
+	return nil
+}
+
+func (x *RootRequest) GetValue() float64 {
+	if x, ok := x.GetNumber().(*RootRequest_Value); ok {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *RootRequest) GetDecimal() string {
+	if x, ok := x.GetNumber().(*RootRequest_Decimal); ok {
+		return x.Decimal
+	}
+	return ""
+}
+
+func (x *RootRequest) GetDegree() int32 {
+	if x != nil {
+		return x.Degree
+	}
+	return 0
+}
+
+func (x *RootRequest) GetSignificantDigits() int32 {
+	if x != nil {
+		return x.SignificantDigits
+	}
+	return 0
+}
+
+func (x *RootRequest) GetAllowComplex() bool {
+	if x != nil {
+		return x.AllowComplex
+	}
+	return false
+}
+
+type isRootRequest_Number interface {
+	isRootRequest_Number()
+}
+
+type RootRequest_Value struct {
+	Value float64 `protobuf:"fixed64,1,opt,name=value,proto3,oneof"`
+}
+
+type RootRequest_Decimal struct {
+	// base 10, e.g. "-2.5e-400", for the numbers beyond the range or the
+	// precision of a double
+	Decimal string `protobuf:"bytes,2,opt,name=decimal,proto3,oneof"`
+}
+
+func (*RootRequest_Value) isRootRequest_Number() {}
+
+func (*RootRequest_Decimal) isRootRequest_Number() {}
+
+type RootResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// rounded to the significant digits requested
+	Real string `protobuf:"bytes,1,opt,name=real,proto3" json:"real,omitempty"`
+	// only set for complex roots
+	Imaginary string `protobuf:"bytes,2,opt,name=imaginary,proto3" json:"imaginary,omitempty"`
+	// closest doubles to real and imaginary
+	RealValue      float64 `protobuf:"fixed64,3,opt,name=real_value,json=realValue,proto3" json:"real_value,omitempty"`
+	ImaginaryValue float64 `protobuf:"fixed64,4,opt,name=imaginary_value,json=imaginaryValue,proto3" json:"imaginary_value,omitempty"`
+}
+
+func (x *RootResponse) Reset() {
+	*x = RootResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[25]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RootResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RootResponse) ProtoMessage() {}
+
+func (x *RootResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[25]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RootResponse.ProtoReflect.Descriptor instead.
+func (*RootResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *RootResponse) GetReal() string {
+	if x != nil {
+		return x.Real
+	}
+	return ""
+}
+
+func (x *RootResponse) GetImaginary() string {
+	if x != nil {
+		return x.Imaginary
+	}
+	return ""
+}
+
+func (x *RootResponse) GetRealValue() float64 {
+	if x != nil {
+		return x.RealValue
+	}
+	return 0
+}
+
+func (x *RootResponse) GetImaginaryValue() float64 {
+	if x != nil {
+		return x.ImaginaryValue
+	}
+	return 0
+}
+
+type CreateSessionRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// mode of the expressions evaluated in the session
+	Mode NumericMode `protobuf:"varint,1,opt,name=mode,proto3,enum=calculator.NumericMode" json:"mode,omitempty"`
+}
+
+func (x *CreateSessionRequest) Reset() {
+	*x = CreateSessionRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[26]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSessionRequest) ProtoMessage() {}
+
+func (x *CreateSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[26]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSessionRequest.ProtoReflect.Descriptor instead.
+func (*CreateSessionRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *CreateSessionRequest) GetMode() NumericMode {
+	if x != nil {
+		return x.Mode
+	}
+	return NumericMode_MODE_FLOAT64
+}
+
+type CreateSessionResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// the session is closed after being unused for this long
+	IdleTimeout *duration.Duration `protobuf:"bytes,2,opt,name=idle_timeout,json=idleTimeout,proto3" json:"idle_timeout,omitempty"`
+}
+
+func (x *CreateSessionResponse) Reset() {
+	*x = CreateSessionResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[27]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSessionResponse) ProtoMessage() {}
+
+func (x *CreateSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[27]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSessionResponse.ProtoReflect.Descriptor instead.
+func (*CreateSessionResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *CreateSessionResponse) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *CreateSessionResponse) GetIdleTimeout() *duration.Duration {
+	if x != nil {
+		return x.IdleTimeout
+	}
+	return nil
+}
+
+type AssignRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// letters, digits and underscores, not starting with a digit, at most 64
+	// characters; function names are reserved
+	Name       string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Expression string `protobuf:"bytes,3,opt,name=expression,proto3" json:"expression,omitempty"`
+}
+
+func (x *AssignRequest) Reset() {
+	*x = AssignRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[28]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AssignRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AssignRequest) ProtoMessage() {}
+
+func (x *AssignRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[28]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AssignRequest.ProtoReflect.Descriptor instead.
+func (*AssignRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *AssignRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+func (x *AssignRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *AssignRequest) GetExpression() string {
+	if x != nil {
+		return x.Expression
+	}
+	return ""
+}
+
+type AssignResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the value assigned, formatted like EvaluateResponse
+	Result string  `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	Value  float64 `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *AssignResponse) Reset() {
+	*x = AssignResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[29]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AssignResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AssignResponse) ProtoMessage() {}
+
+func (x *AssignResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[29]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AssignResponse.ProtoReflect.Descriptor instead.
+func (*AssignResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *AssignResponse) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+func (x *AssignResponse) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type HistoryEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Expression string `protobuf:"bytes,1,opt,name=expression,proto3" json:"expression,omitempty"`
+	// the variable assigned, empty for evaluations
+	Variable string               `protobuf:"bytes,2,opt,name=variable,proto3" json:"variable,omitempty"`
+	Result   string               `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`
+	Value    float64              `protobuf:"fixed64,4,opt,name=value,proto3" json:"value,omitempty"`
+	Time     *timestamp.Timestamp `protobuf:"bytes,5,opt,name=time,proto3" json:"time,omitempty"`
+}
+
+func (x *HistoryEntry) Reset() {
+	*x = HistoryEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[30]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *HistoryEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HistoryEntry) ProtoMessage() {}
+
+func (x *HistoryEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[30]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HistoryEntry.ProtoReflect.Descriptor instead.
+func (*HistoryEntry) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *HistoryEntry) GetExpression() string {
+	if x != nil {
+		return x.Expression
+	}
+	return ""
+}
+
+func (x *HistoryEntry) GetVariable() string {
+	if x != nil {
+		return x.Variable
+	}
+	return ""
+}
+
+func (x *HistoryEntry) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+func (x *HistoryEntry) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *HistoryEntry) GetTime() *timestamp.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+type GetHistoryRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+}
+
+func (x *GetHistoryRequest) Reset() {
+	*x = GetHistoryRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[31]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetHistoryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHistoryRequest) ProtoMessage() {}
+
+func (x *GetHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[31]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *GetHistoryRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type GetHistoryResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// oldest first, only the latest entries are kept
+	Entries []*HistoryEntry `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+}
+
+func (x *GetHistoryResponse) Reset() {
+	*x = GetHistoryResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[32]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHistoryResponse) ProtoMessage() {}
+
+func (x *GetHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[32]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *GetHistoryResponse) GetEntries() []*HistoryEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type CloseSessionRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SessionId string `protobuf:"bytes,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+}
+
+func (x *CloseSessionRequest) Reset() {
+	*x = CloseSessionRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[33]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CloseSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloseSessionRequest) ProtoMessage() {}
+
+func (x *CloseSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[33]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloseSessionRequest.ProtoReflect.Descriptor instead.
+func (*CloseSessionRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *CloseSessionRequest) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
+}
+
+type CloseSessionResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *CloseSessionResponse) Reset() {
+	*x = CloseSessionResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[34]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CloseSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloseSessionResponse) ProtoMessage() {}
+
+func (x *CloseSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[34]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloseSessionResponse.ProtoReflect.Descriptor instead.
+func (*CloseSessionResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{34}
+}
+
+type IsPrimeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Number:
+	//	*IsPrimeRequest_Value
+	//	*IsPrimeRequest_BigValue
+	Number isIsPrimeRequest_Number `protobuf_oneof:"number"`
+}
+
+func (x *IsPrimeRequest) Reset() {
+	*x = IsPrimeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[35]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IsPrimeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsPrimeRequest) ProtoMessage() {}
+
+func (x *IsPrimeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[35]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsPrimeRequest.ProtoReflect.Descriptor instead.
+func (*IsPrimeRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{35}
+}
+
+func (m *IsPrimeRequest) GetNumber() isIsPrimeRequest_Number {
+	if m != nil {
+		return m.Number
+	}
+	return nil
+}
+
+func (x *IsPrimeRequest) GetValue() int64 {
+	if x, ok := x.GetNumber().(*IsPrimeRequest_Value); ok {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *IsPrimeRequest) GetBigValue() *BigInteger {
+	if x, ok := x.GetNumber().(*IsPrimeRequest_BigValue); ok {
+		return x.BigValue
+	}
+	return nil
+}
+
+type isIsPrimeRequest_Number interface {
+	isIsPrimeRequest_Number()
+}
+
+type IsPrimeRequest_Value struct {
+	Value int64 `protobuf:"varint,1,opt,name=value,proto3,oneof"`
+}
+
+type IsPrimeRequest_BigValue struct {
+	// at most 8192 bits
+	BigValue *BigInteger `protobuf:"bytes,2,opt,name=big_value,json=bigValue,proto3,oneof"`
+}
+
+func (*IsPrimeRequest_Value) isIsPrimeRequest_Number() {}
+
+func (*IsPrimeRequest_BigValue) isIsPrimeRequest_Number() {}
+
+type IsPrimeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Prime bool `protobuf:"varint,1,opt,name=prime,proto3" json:"prime,omitempty"`
+	// the test is probabilistic above 2^64, a composite is reported as prime
+	// with a probability below 4^-20
+	Probable bool `protobuf:"varint,2,opt,name=probable,proto3" json:"probable,omitempty"`
+}
+
+func (x *IsPrimeResponse) Reset() {
+	*x = IsPrimeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[36]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IsPrimeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsPrimeResponse) ProtoMessage() {}
+
+func (x *IsPrimeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[36]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsPrimeResponse.ProtoReflect.Descriptor instead.
+func (*IsPrimeResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *IsPrimeResponse) GetPrime() bool {
+	if x != nil {
+		return x.Prime
+	}
+	return false
+}
+
+func (x *IsPrimeResponse) GetProbable() bool {
+	if x != nil {
+		return x.Probable
+	}
+	return false
+}
+
+type ListPrimesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the bounds are included, to is at most 10^14 and to - from at most 10^9
+	From int64 `protobuf:"varint,1,opt,name=from,proto3" json:"from,omitempty"`
+	To   int64 `protobuf:"varint,2,opt,name=to,proto3" json:"to,omitempty"`
+}
+
+func (x *ListPrimesRequest) Reset() {
+	*x = ListPrimesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[37]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListPrimesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPrimesRequest) ProtoMessage() {}
+
+func (x *ListPrimesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[37]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPrimesRequest.ProtoReflect.Descriptor instead.
+func (*ListPrimesRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *ListPrimesRequest) GetFrom() int64 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *ListPrimesRequest) GetTo() int64 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+type ListPrimesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Prime int64 `protobuf:"varint,1,opt,name=prime,proto3" json:"prime,omitempty"`
+}
+
+func (x *ListPrimesResponse) Reset() {
+	*x = ListPrimesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[38]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListPrimesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPrimesResponse) ProtoMessage() {}
+
+func (x *ListPrimesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[38]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPrimesResponse.ProtoReflect.Descriptor instead.
+func (*ListPrimesResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *ListPrimesResponse) GetPrime() int64 {
+	if x != nil {
+		return x.Prime
+	}
+	return 0
+}
+
+type NthPrimeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 1 for 2, at most 10^7
+	N int64 `protobuf:"varint,1,opt,name=n,proto3" json:"n,omitempty"`
+}
+
+func (x *NthPrimeRequest) Reset() {
+	*x = NthPrimeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[39]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NthPrimeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NthPrimeRequest) ProtoMessage() {}
+
+func (x *NthPrimeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[39]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NthPrimeRequest.ProtoReflect.Descriptor instead.
+func (*NthPrimeRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *NthPrimeRequest) GetN() int64 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
+
+type NthPrimeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Prime int64 `protobuf:"varint,1,opt,name=prime,proto3" json:"prime,omitempty"`
+}
+
+func (x *NthPrimeResponse) Reset() {
+	*x = NthPrimeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[40]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *NthPrimeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NthPrimeResponse) ProtoMessage() {}
+
+func (x *NthPrimeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[40]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NthPrimeResponse.ProtoReflect.Descriptor instead.
+func (*NthPrimeResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *NthPrimeResponse) GetPrime() int64 {
+	if x != nil {
+		return x.Prime
+	}
+	return 0
+}
+
+type GcdRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FirstNumber  *BigInteger `protobuf:"bytes,1,opt,name=first_number,json=firstNumber,proto3" json:"first_number,omitempty"`
+	SecondNumber *BigInteger `protobuf:"bytes,2,opt,name=second_number,json=secondNumber,proto3" json:"second_number,omitempty"`
+}
+
+func (x *GcdRequest) Reset() {
+	*x = GcdRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[41]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GcdRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GcdRequest) ProtoMessage() {}
+
+func (x *GcdRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[41]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GcdRequest.ProtoReflect.Descriptor instead.
+func (*GcdRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *GcdRequest) GetFirstNumber() *BigInteger {
+	if x != nil {
+		return x.FirstNumber
+	}
+	return nil
+}
+
+func (x *GcdRequest) GetSecondNumber() *BigInteger {
+	if x != nil {
+		return x.SecondNumber
+	}
+	return nil
+}
+
+type GcdResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// non-negative, 0 only when both numbers are
+	Gcd *BigInteger `protobuf:"bytes,1,opt,name=gcd,proto3" json:"gcd,omitempty"`
+}
+
+func (x *GcdResponse) Reset() {
+	*x = GcdResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[42]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GcdResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GcdResponse) ProtoMessage() {}
+
+func (x *GcdResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[42]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GcdResponse.ProtoReflect.Descriptor instead.
+func (*GcdResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{42}
+}
+
+func (x *GcdResponse) GetGcd() *BigInteger {
+	if x != nil {
+		return x.Gcd
+	}
+	return nil
+}
+
+type LcmRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FirstNumber  *BigInteger `protobuf:"bytes,1,opt,name=first_number,json=firstNumber,proto3" json:"first_number,omitempty"`
+	SecondNumber *BigInteger `protobuf:"bytes,2,opt,name=second_number,json=secondNumber,proto3" json:"second_number,omitempty"`
+}
+
+func (x *LcmRequest) Reset() {
+	*x = LcmRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[43]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LcmRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LcmRequest) ProtoMessage() {}
+
+func (x *LcmRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[43]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LcmRequest.ProtoReflect.Descriptor instead.
+func (*LcmRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *LcmRequest) GetFirstNumber() *BigInteger {
+	if x != nil {
+		return x.FirstNumber
+	}
+	return nil
+}
+
+func (x *LcmRequest) GetSecondNumber() *BigInteger {
+	if x != nil {
+		return x.SecondNumber
+	}
+	return nil
+}
+
+type LcmResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// non-negative, 0 when a number is
+	Lcm *BigInteger `protobuf:"bytes,1,opt,name=lcm,proto3" json:"lcm,omitempty"`
+}
+
+func (x *LcmResponse) Reset() {
+	*x = LcmResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[44]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LcmResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LcmResponse) ProtoMessage() {}
+
+func (x *LcmResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[44]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LcmResponse.ProtoReflect.Descriptor instead.
+func (*LcmResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *LcmResponse) GetLcm() *BigInteger {
+	if x != nil {
+		return x.Lcm
+	}
+	return nil
+}
+
+type ExtendedGcdRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	FirstNumber  *BigInteger `protobuf:"bytes,1,opt,name=first_number,json=firstNumber,proto3" json:"first_number,omitempty"`
+	SecondNumber *BigInteger `protobuf:"bytes,2,opt,name=second_number,json=secondNumber,proto3" json:"second_number,omitempty"`
+}
+
+func (x *ExtendedGcdRequest) Reset() {
+	*x = ExtendedGcdRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[45]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExtendedGcdRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtendedGcdRequest) ProtoMessage() {}
+
+func (x *ExtendedGcdRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[45]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtendedGcdRequest.ProtoReflect.Descriptor instead.
+func (*ExtendedGcdRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{45}
+}
+
+func (x *ExtendedGcdRequest) GetFirstNumber() *BigInteger {
+	if x != nil {
+		return x.FirstNumber
+	}
+	return nil
+}
+
+func (x *ExtendedGcdRequest) GetSecondNumber() *BigInteger {
+	if x != nil {
+		return x.SecondNumber
+	}
+	return nil
+}
+
+type ExtendedGcdResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Gcd *BigInteger `protobuf:"bytes,1,opt,name=gcd,proto3" json:"gcd,omitempty"`
+	// Bézout coefficients, gcd = x * first_number + y * second_number
+	X *BigInteger `protobuf:"bytes,2,opt,name=x,proto3" json:"x,omitempty"`
+	Y *BigInteger `protobuf:"bytes,3,opt,name=y,proto3" json:"y,omitempty"`
+}
+
+func (x *ExtendedGcdResponse) Reset() {
+	*x = ExtendedGcdResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[46]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ExtendedGcdResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExtendedGcdResponse) ProtoMessage() {}
+
+func (x *ExtendedGcdResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[46]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExtendedGcdResponse.ProtoReflect.Descriptor instead.
+func (*ExtendedGcdResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *ExtendedGcdResponse) GetGcd() *BigInteger {
+	if x != nil {
+		return x.Gcd
+	}
+	return nil
+}
+
+func (x *ExtendedGcdResponse) GetX() *BigInteger {
+	if x != nil {
+		return x.X
+	}
+	return nil
+}
+
+func (x *ExtendedGcdResponse) GetY() *BigInteger {
+	if x != nil {
+		return x.Y
+	}
+	return nil
+}
+
+type ModPowRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Base *BigInteger `protobuf:"bytes,1,opt,name=base,proto3" json:"base,omitempty"`
+	// a negative exponent raises the inverse of the base
+	Exponent *BigInteger `protobuf:"bytes,2,opt,name=exponent,proto3" json:"exponent,omitempty"`
+	// positive
+	Modulus *BigInteger `protobuf:"bytes,3,opt,name=modulus,proto3" json:"modulus,omitempty"`
+}
+
+func (x *ModPowRequest) Reset() {
+	*x = ModPowRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[47]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ModPowRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModPowRequest) ProtoMessage() {}
+
+func (x *ModPowRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[47]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModPowRequest.ProtoReflect.Descriptor instead.
+func (*ModPowRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *ModPowRequest) GetBase() *BigInteger {
+	if x != nil {
+		return x.Base
+	}
+	return nil
+}
+
+func (x *ModPowRequest) GetExponent() *BigInteger {
+	if x != nil {
+		return x.Exponent
+	}
+	return nil
+}
+
+func (x *ModPowRequest) GetModulus() *BigInteger {
+	if x != nil {
+		return x.Modulus
+	}
+	return nil
+}
+
+type ModPowResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// in [0, modulus)
+	Result *BigInteger `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+}
+
+func (x *ModPowResponse) Reset() {
+	*x = ModPowResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[48]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ModPowResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModPowResponse) ProtoMessage() {}
+
+func (x *ModPowResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[48]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModPowResponse.ProtoReflect.Descriptor instead.
+func (*ModPowResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{48}
+}
+
+func (x *ModPowResponse) GetResult() *BigInteger {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
+type ModInverseRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Number *BigInteger `protobuf:"bytes,1,opt,name=number,proto3" json:"number,omitempty"`
+	// positive
+	Modulus *BigInteger `protobuf:"bytes,2,opt,name=modulus,proto3" json:"modulus,omitempty"`
+}
+
+func (x *ModInverseRequest) Reset() {
+	*x = ModInverseRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[49]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ModInverseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModInverseRequest) ProtoMessage() {}
+
+func (x *ModInverseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[49]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModInverseRequest.ProtoReflect.Descriptor instead.
+func (*ModInverseRequest) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *ModInverseRequest) GetNumber() *BigInteger {
+	if x != nil {
+		return x.Number
+	}
+	return nil
+}
+
+func (x *ModInverseRequest) GetModulus() *BigInteger {
+	if x != nil {
+		return x.Modulus
+	}
+	return nil
+}
+
+type ModInverseResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// in [0, modulus)
+	Inverse *BigInteger `protobuf:"bytes,1,opt,name=inverse,proto3" json:"inverse,omitempty"`
+}
+
+func (x *ModInverseResponse) Reset() {
+	*x = ModInverseResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[50]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ModInverseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModInverseResponse) ProtoMessage() {}
+
+func (x *ModInverseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[50]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModInverseResponse.ProtoReflect.Descriptor instead.
+func (*ModInverseResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *ModInverseResponse) GetInverse() *BigInteger {
+	if x != nil {
+		return x.Inverse
+	}
+	return nil
+}
+
+// MatrixRow is a row of an operand of the MatrixService RPCs, the rows of
+// each operand are streamed in order
+type MatrixRow struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 0 for the first operand, 1 for the second
+	Operand int32     `protobuf:"varint,1,opt,name=operand,proto3" json:"operand,omitempty"`
+	Values  []float64 `protobuf:"fixed64,2,rep,packed,name=values,proto3" json:"values,omitempty"`
+}
+
+func (x *MatrixRow) Reset() {
+	*x = MatrixRow{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[51]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MatrixRow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MatrixRow) ProtoMessage() {}
+
+func (x *MatrixRow) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[51]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MatrixRow.ProtoReflect.Descriptor instead.
+func (*MatrixRow) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{51}
+}
+
+func (x *MatrixRow) GetOperand() int32 {
+	if x != nil {
+		return x.Operand
+	}
+	return 0
+}
+
+func (x *MatrixRow) GetValues() []float64 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+// Matrix is a dense matrix
+type Matrix struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rows    int32 `protobuf:"varint,1,opt,name=rows,proto3" json:"rows,omitempty"`
+	Columns int32 `protobuf:"varint,2,opt,name=columns,proto3" json:"columns,omitempty"`
+	// row-major, rows * columns values
+	Values []float64 `protobuf:"fixed64,3,rep,packed,name=values,proto3" json:"values,omitempty"`
+}
+
+func (x *Matrix) Reset() {
+	*x = Matrix{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[52]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Matrix) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Matrix) ProtoMessage() {}
+
+func (x *Matrix) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[52]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Matrix.ProtoReflect.Descriptor instead.
+func (*Matrix) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{52}
+}
+
+func (x *Matrix) GetRows() int32 {
+	if x != nil {
+		return x.Rows
+	}
+	return 0
+}
+
+func (x *Matrix) GetColumns() int32 {
+	if x != nil {
+		return x.Columns
+	}
+	return 0
+}
+
+func (x *Matrix) GetValues() []float64 {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type DeterminantResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Determinant float64 `protobuf:"fixed64,1,opt,name=determinant,proto3" json:"determinant,omitempty"`
+}
+
+func (x *DeterminantResponse) Reset() {
+	*x = DeterminantResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[53]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeterminantResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeterminantResponse) ProtoMessage() {}
+
+func (x *DeterminantResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[53]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeterminantResponse.ProtoReflect.Descriptor instead.
+func (*DeterminantResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{53}
+}
+
+func (x *DeterminantResponse) GetDeterminant() float64 {
+	if x != nil {
+		return x.Determinant
+	}
+	return 0
+}
+
+type RankResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rank int32 `protobuf:"varint,1,opt,name=rank,proto3" json:"rank,omitempty"`
+}
+
+func (x *RankResponse) Reset() {
+	*x = RankResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[54]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RankResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RankResponse) ProtoMessage() {}
+
+func (x *RankResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_calculator_calculatorpb_calculator_proto_msgTypes[54]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RankResponse.ProtoReflect.Descriptor instead.
+func (*RankResponse) Descriptor() ([]byte, []int) {
+	return file_calculator_calculatorpb_calculator_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *RankResponse) GetRank() int32 {
+	if x != nil {
+		return x.Rank
+	}
+	return 0
+}
+
+var File_calculator_calculatorpb_calculator_proto protoreflect.FileDescriptor
+
+var file_calculator_calculatorpb_calculator_proto_rawDesc = []byte{
+	0x0a, 0x28, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2f, 0x63, 0x61, 0x6c,
+	0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x70, 0x62, 0x2f, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c,
+	0x61, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0a, 0x63, 0x61, 0x6c, 0x63,
+	0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x1a, 0x1e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x54, 0x0a, 0x0a, 0x53, 0x75, 0x6d, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x66, 0x69, 0x72, 0x73, 0x74, 0x5f, 0x6e,
+	0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0b, 0x66, 0x69, 0x72,
+	0x73, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65, 0x63, 0x6f,
+	0x6e, 0x64, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x0c, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x2c, 0x0a,
+	0x0b, 0x53, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1d, 0x0a, 0x0a,
+	0x73, 0x75, 0x6d, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05,
+	0x52, 0x09, 0x73, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x5e, 0x0a, 0x1f, 0x50,
+	0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70,
+	0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16,
+	0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06,
+	0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x23, 0x0a, 0x0d, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x5f,
+	0x66, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x67,
+	0x72, 0x6f, 0x75, 0x70, 0x46, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x73, 0x22, 0x69, 0x0a, 0x20, 0x50,
+	0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70,
+	0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x21, 0x0a, 0x0c, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x5f, 0x66, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x46, 0x61, 0x63, 0x74,
+	0x6f, 0x72, 0x12, 0x22, 0x0a, 0x0c, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c, 0x69, 0x63, 0x69,
+	0x74, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0c, 0x6d, 0x75, 0x6c, 0x74, 0x69, 0x70,
+	0x6c, 0x69, 0x63, 0x69, 0x74, 0x79, 0x22, 0x2f, 0x0a, 0x15, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74,
+	0x65, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x32, 0x0a, 0x16, 0x43, 0x6f, 0x6d, 0x70, 0x75,
+	0x74, 0x65, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x18, 0x0a, 0x07, 0x61, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x07, 0x61, 0x76, 0x65, 0x72, 0x61, 0x67, 0x65, 0x22, 0x2c, 0x0a, 0x12, 0x46,
+	0x69, 0x6e, 0x64, 0x4d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x2f, 0x0a, 0x13, 0x46, 0x69, 0x6e,
+	0x64, 0x4d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x18, 0x0a, 0x07, 0x6d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x07, 0x6d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x22, 0x2b, 0x0a, 0x11, 0x53, 0x71,
+	0x75, 0x61, 0x72, 0x65, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12,
+	0x16, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x35, 0x0a, 0x12, 0x53, 0x71, 0x75, 0x61, 0x72,
+	0x65, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1f, 0x0a,
+	0x0b, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x5f, 0x72, 0x6f, 0x6f, 0x74, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x0a, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x52, 0x6f, 0x6f, 0x74, 0x22, 0x5c,
+	0x0a, 0x0a, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x12, 0x1a, 0x0a, 0x07,
+	0x64, 0x65, 0x63, 0x69, 0x6d, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52,
+	0x07, 0x64, 0x65, 0x63, 0x69, 0x6d, 0x61, 0x6c, 0x12, 0x29, 0x0a, 0x0f, 0x74, 0x77, 0x6f, 0x73,
+	0x5f, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x48, 0x00, 0x52, 0x0e, 0x74, 0x77, 0x6f, 0x73, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x6d,
+	0x65, 0x6e, 0x74, 0x42, 0x07, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0xc6, 0x01, 0x0a,
+	0x14, 0x42, 0x69, 0x67, 0x41, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x65, 0x74, 0x69, 0x63, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x36, 0x0a, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75,
+	0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x09, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x39, 0x0a,
+	0x0c, 0x66, 0x69, 0x72, 0x73, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72,
+	0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x0b, 0x66, 0x69, 0x72,
+	0x73, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x3b, 0x0a, 0x0d, 0x73, 0x65, 0x63, 0x6f,
+	0x6e, 0x64, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67,
+	0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x0c, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x4e,
+	0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x47, 0x0a, 0x15, 0x42, 0x69, 0x67, 0x41, 0x72, 0x69, 0x74,
+	0x68, 0x6d, 0x65, 0x74, 0x69, 0x63, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2e,
+	0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16,
+	0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49,
+	0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x54,
+	0x0a, 0x22, 0x42, 0x69, 0x67, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72,
+	0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x2e, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f,
+	0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x06, 0x6e, 0x75,
+	0x6d, 0x62, 0x65, 0x72, 0x22, 0x60, 0x0a, 0x23, 0x42, 0x69, 0x67, 0x50, 0x72, 0x69, 0x6d, 0x65,
+	0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69, 0x74,
+	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x39, 0x0a, 0x0c, 0x70,
+	0x72, 0x69, 0x6d, 0x65, 0x5f, 0x66, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42,
+	0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x0b, 0x70, 0x72, 0x69, 0x6d, 0x65,
+	0x46, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x22, 0x7d, 0x0a, 0x0f, 0x45, 0x76, 0x61, 0x6c, 0x75, 0x61,
+	0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x65, 0x78, 0x70,
+	0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x65,
+	0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x2b, 0x0a, 0x04, 0x6d, 0x6f, 0x64,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c,
+	0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4e, 0x75, 0x6d, 0x65, 0x72, 0x69, 0x63, 0x4d, 0x6f, 0x64, 0x65,
+	0x52, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x49, 0x64, 0x22, 0x40, 0x0a, 0x10, 0x45, 0x76, 0x61, 0x6c, 0x75, 0x61, 0x74,
+	0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x57, 0x0a, 0x11, 0x53, 0x74, 0x61, 0x74, 0x69,
+	0x73, 0x74, 0x69, 0x63, 0x73, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x20, 0x0a, 0x0b,
+	0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x01, 0x52, 0x0b, 0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x20,
+	0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x22, 0x78, 0x0a, 0x18, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x53, 0x74, 0x61, 0x74, 0x69,
+	0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x39, 0x0a, 0x07,
+	0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e,
+	0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x69,
+	0x73, 0x74, 0x69, 0x63, 0x73, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x48, 0x00, 0x52, 0x07,
+	0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x18, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x42, 0x07, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x42, 0x0a, 0x0a, 0x50, 0x65,
+	0x72, 0x63, 0x65, 0x6e, 0x74, 0x69, 0x6c, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x70, 0x65, 0x72, 0x63,
+	0x65, 0x6e, 0x74, 0x69, 0x6c, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0a, 0x70, 0x65,
+	0x72, 0x63, 0x65, 0x6e, 0x74, 0x69, 0x6c, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0xaa,
+	0x02, 0x0a, 0x19, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x53, 0x74, 0x61, 0x74, 0x69, 0x73,
+	0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05,
+	0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63, 0x6f, 0x75,
+	0x6e, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x03, 0x73, 0x75, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x65, 0x61, 0x6e, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x04, 0x6d, 0x65, 0x61, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x76, 0x61, 0x72, 0x69,
+	0x61, 0x6e, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x08, 0x76, 0x61, 0x72, 0x69,
+	0x61, 0x6e, 0x63, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x64, 0x64, 0x65, 0x76, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x73, 0x74, 0x64, 0x64, 0x65, 0x76, 0x12, 0x27, 0x0a, 0x0f,
+	0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x76, 0x61, 0x72, 0x69, 0x61, 0x6e, 0x63, 0x65, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x56, 0x61, 0x72,
+	0x69, 0x61, 0x6e, 0x63, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x69, 0x6e, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x03, 0x6d, 0x69, 0x6e, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x61, 0x78, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x6d, 0x61, 0x78, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x64,
+	0x69, 0x61, 0x6e, 0x18, 0x09, 0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x6d, 0x65, 0x64, 0x69, 0x61,
+	0x6e, 0x12, 0x38, 0x0a, 0x0b, 0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x69, 0x6c, 0x65, 0x73,
+	0x18, 0x0a, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61,
+	0x74, 0x6f, 0x72, 0x2e, 0x50, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x69, 0x6c, 0x65, 0x52, 0x0b,
+	0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x69, 0x6c, 0x65, 0x73, 0x22, 0x9f, 0x01, 0x0a, 0x0d,
+	0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x39, 0x0a,
+	0x09, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x1b, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x57, 0x69,
+	0x6e, 0x64, 0x6f, 0x77, 0x41, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x52, 0x09, 0x61,
+	0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x48, 0x00, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x33,
+	0x0a, 0x06, 0x70, 0x65, 0x72, 0x69, 0x6f, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x00, 0x52, 0x06, 0x70, 0x65, 0x72,
+	0x69, 0x6f, 0x64, 0x42, 0x08, 0x0a, 0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x22, 0xa4, 0x01,
+	0x0a, 0x18, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x65, 0x64, 0x41, 0x67, 0x67, 0x72, 0x65, 0x67,
+	0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x35, 0x0a, 0x07, 0x6f, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x63, 0x61,
+	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x4f,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x48, 0x00, 0x52, 0x07, 0x6f, 0x70, 0x74, 0x69, 0x6f, 0x6e,
+	0x73, 0x12, 0x18, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x01, 0x48, 0x00, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x2e, 0x0a, 0x04, 0x74,
+	0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x42, 0x07, 0x0a, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x22, 0x31, 0x0a, 0x19, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x65, 0x64,
+	0x41, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0xb7, 0x01, 0x0a, 0x0b, 0x52, 0x6f, 0x6f, 0x74,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12,
+	0x1a, 0x0a, 0x07, 0x64, 0x65, 0x63, 0x69, 0x6d, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x48, 0x00, 0x52, 0x07, 0x64, 0x65, 0x63, 0x69, 0x6d, 0x61, 0x6c, 0x12, 0x16, 0x0a, 0x06, 0x64,
+	0x65, 0x67, 0x72, 0x65, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x64, 0x65, 0x67,
+	0x72, 0x65, 0x65, 0x12, 0x2d, 0x0a, 0x12, 0x73, 0x69, 0x67, 0x6e, 0x69, 0x66, 0x69, 0x63, 0x61,
+	0x6e, 0x74, 0x5f, 0x64, 0x69, 0x67, 0x69, 0x74, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x11, 0x73, 0x69, 0x67, 0x6e, 0x69, 0x66, 0x69, 0x63, 0x61, 0x6e, 0x74, 0x44, 0x69, 0x67, 0x69,
+	0x74, 0x73, 0x12, 0x23, 0x0a, 0x0d, 0x61, 0x6c, 0x6c, 0x6f, 0x77, 0x5f, 0x63, 0x6f, 0x6d, 0x70,
+	0x6c, 0x65, 0x78, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x61, 0x6c, 0x6c, 0x6f, 0x77,
+	0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x78, 0x42, 0x08, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x22, 0x88, 0x01, 0x0a, 0x0c, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x65, 0x61, 0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x72, 0x65, 0x61, 0x6c, 0x12, 0x1c, 0x0a, 0x09, 0x69, 0x6d, 0x61, 0x67, 0x69, 0x6e,
+	0x61, 0x72, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x69, 0x6d, 0x61, 0x67, 0x69,
+	0x6e, 0x61, 0x72, 0x79, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x61, 0x6c, 0x5f, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x09, 0x72, 0x65, 0x61, 0x6c, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x69, 0x6d, 0x61, 0x67, 0x69, 0x6e, 0x61, 0x72, 0x79,
+	0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x69, 0x6d,
+	0x61, 0x67, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x43, 0x0a, 0x14,
+	0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x2b, 0x0a, 0x04, 0x6d, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x17, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e,
+	0x4e, 0x75, 0x6d, 0x65, 0x72, 0x69, 0x63, 0x4d, 0x6f, 0x64, 0x65, 0x52, 0x04, 0x6d, 0x6f, 0x64,
+	0x65, 0x22, 0x74, 0x0a, 0x15, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09,
+	0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x3c, 0x0a, 0x0c, 0x69, 0x64, 0x6c,
+	0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x69, 0x64, 0x6c, 0x65,
+	0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x22, 0x62, 0x0a, 0x0d, 0x41, 0x73, 0x73, 0x69, 0x67,
+	0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x73, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x65,
+	0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0a, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x3e, 0x0a, 0x0e, 0x41,
+	0x73, 0x73, 0x69, 0x67, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0xa8, 0x01, 0x0a, 0x0c,
+	0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x1e, 0x0a, 0x0a,
+	0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0a, 0x65, 0x78, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08,
+	0x76, 0x61, 0x72, 0x69, 0x61, 0x62, 0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x76, 0x61, 0x72, 0x69, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75,
+	0x6c, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x2e, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x22, 0x32, 0x0a, 0x11, 0x47, 0x65, 0x74, 0x48, 0x69, 0x73,
+	0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x73,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x22, 0x48, 0x0a, 0x12, 0x47, 0x65,
+	0x74, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x32, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x18, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x48,
+	0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x65, 0x6e, 0x74,
+	0x72, 0x69, 0x65, 0x73, 0x22, 0x34, 0x0a, 0x13, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x53, 0x65, 0x73,
+	0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x73,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x22, 0x16, 0x0a, 0x14, 0x43, 0x6c,
+	0x6f, 0x73, 0x65, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x69, 0x0a, 0x0e, 0x49, 0x73, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x03, 0x48, 0x00, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x35, 0x0a, 0x09,
+	0x62, 0x69, 0x67, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67,
+	0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x48, 0x00, 0x52, 0x08, 0x62, 0x69, 0x67, 0x56, 0x61,
+	0x6c, 0x75, 0x65, 0x42, 0x08, 0x0a, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x43, 0x0a,
+	0x0f, 0x49, 0x73, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x05, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x62, 0x61, 0x62,
+	0x6c, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x62, 0x61, 0x62,
+	0x6c, 0x65, 0x22, 0x37, 0x0a, 0x11, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12, 0x0e, 0x0a, 0x02, 0x74,
+	0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x02, 0x74, 0x6f, 0x22, 0x2a, 0x0a, 0x12, 0x4c,
+	0x69, 0x73, 0x74, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x05, 0x70, 0x72, 0x69, 0x6d, 0x65, 0x22, 0x1f, 0x0a, 0x0f, 0x4e, 0x74, 0x68, 0x50, 0x72,
+	0x69, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0c, 0x0a, 0x01, 0x6e, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x01, 0x6e, 0x22, 0x28, 0x0a, 0x10, 0x4e, 0x74, 0x68, 0x50,
+	0x72, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x14, 0x0a, 0x05,
+	0x70, 0x72, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x70, 0x72, 0x69,
+	0x6d, 0x65, 0x22, 0x84, 0x01, 0x0a, 0x0a, 0x47, 0x63, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x39, 0x0a, 0x0c, 0x66, 0x69, 0x72, 0x73, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c,
+	0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52,
+	0x0b, 0x66, 0x69, 0x72, 0x73, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x3b, 0x0a, 0x0d,
+	0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72,
+	0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x0c, 0x73, 0x65, 0x63,
+	0x6f, 0x6e, 0x64, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x37, 0x0a, 0x0b, 0x47, 0x63, 0x64,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x03, 0x67, 0x63, 0x64, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74,
+	0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x03, 0x67,
+	0x63, 0x64, 0x22, 0x84, 0x01, 0x0a, 0x0a, 0x4c, 0x63, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x39, 0x0a, 0x0c, 0x66, 0x69, 0x72, 0x73, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c,
+	0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52,
+	0x0b, 0x66, 0x69, 0x72, 0x73, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x3b, 0x0a, 0x0d,
+	0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72,
+	0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x0c, 0x73, 0x65, 0x63,
+	0x6f, 0x6e, 0x64, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x22, 0x37, 0x0a, 0x0b, 0x4c, 0x63, 0x6d,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x03, 0x6c, 0x63, 0x6d, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74,
+	0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x03, 0x6c,
+	0x63, 0x6d, 0x22, 0x8c, 0x01, 0x0a, 0x12, 0x45, 0x78, 0x74, 0x65, 0x6e, 0x64, 0x65, 0x64, 0x47,
+	0x63, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x39, 0x0a, 0x0c, 0x66, 0x69, 0x72,
+	0x73, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67,
+	0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x0b, 0x66, 0x69, 0x72, 0x73, 0x74, 0x4e, 0x75,
+	0x6d, 0x62, 0x65, 0x72, 0x12, 0x3b, 0x0a, 0x0d, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x5f, 0x6e,
+	0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61,
+	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65,
+	0x67, 0x65, 0x72, 0x52, 0x0c, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x4e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x22, 0x8b, 0x01, 0x0a, 0x13, 0x45, 0x78, 0x74, 0x65, 0x6e, 0x64, 0x65, 0x64, 0x47, 0x63,
+	0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x28, 0x0a, 0x03, 0x67, 0x63, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61,
+	0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x03,
+	0x67, 0x63, 0x64, 0x12, 0x24, 0x0a, 0x01, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16,
+	0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49,
+	0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x01, 0x78, 0x12, 0x24, 0x0a, 0x01, 0x79, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f,
+	0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x01, 0x79, 0x22,
+	0xa1, 0x01, 0x0a, 0x0d, 0x4d, 0x6f, 0x64, 0x50, 0x6f, 0x77, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x2a, 0x0a, 0x04, 0x62, 0x61, 0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67,
+	0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x04, 0x62, 0x61, 0x73, 0x65, 0x12, 0x32, 0x0a,
+	0x08, 0x65, 0x78, 0x70, 0x6f, 0x6e, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67,
+	0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x08, 0x65, 0x78, 0x70, 0x6f, 0x6e, 0x65, 0x6e,
+	0x74, 0x12, 0x30, 0x0a, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x75, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e,
+	0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x07, 0x6d, 0x6f, 0x64, 0x75,
+	0x6c, 0x75, 0x73, 0x22, 0x40, 0x0a, 0x0e, 0x4d, 0x6f, 0x64, 0x50, 0x6f, 0x77, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2e, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74,
+	0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x06, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x75, 0x0a, 0x11, 0x4d, 0x6f, 0x64, 0x49, 0x6e, 0x76, 0x65,
+	0x72, 0x73, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x2e, 0x0a, 0x06, 0x6e, 0x75,
+	0x6d, 0x62, 0x65, 0x72, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c,
+	0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67,
+	0x65, 0x72, 0x52, 0x06, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x12, 0x30, 0x0a, 0x07, 0x6d, 0x6f,
+	0x64, 0x75, 0x6c, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61,
+	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65,
+	0x67, 0x65, 0x72, 0x52, 0x07, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x75, 0x73, 0x22, 0x46, 0x0a, 0x12,
+	0x4d, 0x6f, 0x64, 0x49, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x30, 0x0a, 0x07, 0x69, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72,
+	0x2e, 0x42, 0x69, 0x67, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x65, 0x72, 0x52, 0x07, 0x69, 0x6e, 0x76,
+	0x65, 0x72, 0x73, 0x65, 0x22, 0x3d, 0x0a, 0x09, 0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x52, 0x6f,
+	0x77, 0x12, 0x18, 0x0a, 0x07, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x07, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x6e, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x01, 0x52, 0x06, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x73, 0x22, 0x4e, 0x0a, 0x06, 0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x12, 0x12, 0x0a,
+	0x04, 0x72, 0x6f, 0x77, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x72, 0x6f, 0x77,
+	0x73, 0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x07, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x01, 0x52, 0x06, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x73, 0x22, 0x37, 0x0a, 0x13, 0x44, 0x65, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61,
+	0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65,
+	0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x0b, 0x64, 0x65, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6e, 0x74, 0x22, 0x22, 0x0a, 0x0c,
+	0x52, 0x61, 0x6e, 0x6b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x12, 0x0a, 0x04,
+	0x72, 0x61, 0x6e, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x72, 0x61, 0x6e, 0x6b,
+	0x2a, 0x8d, 0x01, 0x0a, 0x0c, 0x42, 0x69, 0x67, 0x4f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x11, 0x0a, 0x0d, 0x4f, 0x50, 0x45, 0x52, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x41,
+	0x44, 0x44, 0x10, 0x00, 0x12, 0x16, 0x0a, 0x12, 0x4f, 0x50, 0x45, 0x52, 0x41, 0x54, 0x49, 0x4f,
+	0x4e, 0x5f, 0x53, 0x55, 0x42, 0x54, 0x52, 0x41, 0x43, 0x54, 0x10, 0x01, 0x12, 0x16, 0x0a, 0x12,
+	0x4f, 0x50, 0x45, 0x52, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x4d, 0x55, 0x4c, 0x54, 0x49, 0x50,
+	0x4c, 0x59, 0x10, 0x02, 0x12, 0x14, 0x0a, 0x10, 0x4f, 0x50, 0x45, 0x52, 0x41, 0x54, 0x49, 0x4f,
+	0x4e, 0x5f, 0x44, 0x49, 0x56, 0x49, 0x44, 0x45, 0x10, 0x03, 0x12, 0x11, 0x0a, 0x0d, 0x4f, 0x50,
+	0x45, 0x52, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x4d, 0x4f, 0x44, 0x10, 0x04, 0x12, 0x11, 0x0a,
+	0x0d, 0x4f, 0x50, 0x45, 0x52, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x50, 0x4f, 0x57, 0x10, 0x05,
+	0x2a, 0x46, 0x0a, 0x0b, 0x4e, 0x75, 0x6d, 0x65, 0x72, 0x69, 0x63, 0x4d, 0x6f, 0x64, 0x65, 0x12,
+	0x10, 0x0a, 0x0c, 0x4d, 0x4f, 0x44, 0x45, 0x5f, 0x46, 0x4c, 0x4f, 0x41, 0x54, 0x36, 0x34, 0x10,
+	0x00, 0x12, 0x0e, 0x0a, 0x0a, 0x4d, 0x4f, 0x44, 0x45, 0x5f, 0x49, 0x4e, 0x54, 0x36, 0x34, 0x10,
+	0x01, 0x12, 0x15, 0x0a, 0x11, 0x4d, 0x4f, 0x44, 0x45, 0x5f, 0x42, 0x49, 0x47, 0x5f, 0x52, 0x41,
+	0x54, 0x49, 0x4f, 0x4e, 0x41, 0x4c, 0x10, 0x02, 0x2a, 0x5e, 0x0a, 0x0f, 0x57, 0x69, 0x6e, 0x64,
+	0x6f, 0x77, 0x41, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x12, 0x11, 0x0a, 0x0d, 0x41,
+	0x47, 0x47, 0x52, 0x45, 0x47, 0x41, 0x54, 0x45, 0x5f, 0x4d, 0x41, 0x58, 0x10, 0x00, 0x12, 0x11,
+	0x0a, 0x0d, 0x41, 0x47, 0x47, 0x52, 0x45, 0x47, 0x41, 0x54, 0x45, 0x5f, 0x4d, 0x49, 0x4e, 0x10,
+	0x01, 0x12, 0x11, 0x0a, 0x0d, 0x41, 0x47, 0x47, 0x52, 0x45, 0x47, 0x41, 0x54, 0x45, 0x5f, 0x53,
+	0x55, 0x4d, 0x10, 0x02, 0x12, 0x12, 0x0a, 0x0e, 0x41, 0x47, 0x47, 0x52, 0x45, 0x47, 0x41, 0x54,
+	0x45, 0x5f, 0x4d, 0x45, 0x41, 0x4e, 0x10, 0x03, 0x32, 0xf2, 0x0e, 0x0a, 0x11, 0x43, 0x61, 0x6c,
+	0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x38,
+	0x0a, 0x03, 0x53, 0x75, 0x6d, 0x12, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74,
+	0x6f, 0x72, 0x2e, 0x53, 0x75, 0x6d, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e,
+	0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x53, 0x75, 0x6d, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x79, 0x0a, 0x18, 0x50, 0x72, 0x69, 0x6d,
+	0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2b, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f,
+	0x72, 0x2e, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63,
+	0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x2c, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x50,
+	0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70,
+	0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
+	0x00, 0x30, 0x01, 0x12, 0x5b, 0x0a, 0x0e, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x41, 0x76,
+	0x65, 0x72, 0x61, 0x67, 0x65, 0x12, 0x21, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74,
+	0x6f, 0x72, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x41, 0x76, 0x65, 0x72, 0x61, 0x67,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x22, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75,
+	0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x41, 0x76, 0x65,
+	0x72, 0x61, 0x67, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01,
+	0x12, 0x64, 0x0a, 0x11, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x53, 0x74, 0x61, 0x74, 0x69,
+	0x73, 0x74, 0x69, 0x63, 0x73, 0x12, 0x24, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74,
+	0x6f, 0x72, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65, 0x53, 0x74, 0x61, 0x74, 0x69, 0x73,
+	0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x25, 0x2e, 0x63, 0x61,
+	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x75, 0x74, 0x65,
+	0x53, 0x74, 0x61, 0x74, 0x69, 0x73, 0x74, 0x69, 0x63, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x12, 0x54, 0x0a, 0x0b, 0x46, 0x69, 0x6e, 0x64, 0x4d, 0x61,
+	0x78, 0x69, 0x6d, 0x75, 0x6d, 0x12, 0x1e, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74,
+	0x6f, 0x72, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74,
+	0x6f, 0x72, 0x2e, 0x46, 0x69, 0x6e, 0x64, 0x4d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x30, 0x01, 0x12, 0x66, 0x0a, 0x11,
+	0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x65, 0x64, 0x41, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74,
+	0x65, 0x12, 0x24, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x57,
+	0x69, 0x6e, 0x64, 0x6f, 0x77, 0x65, 0x64, 0x41, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x25, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c,
+	0x61, 0x74, 0x6f, 0x72, 0x2e, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x65, 0x64, 0x41, 0x67, 0x67,
+	0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
+	0x28, 0x01, 0x30, 0x01, 0x12, 0x4d, 0x0a, 0x0a, 0x53, 0x71, 0x75, 0x61, 0x72, 0x65, 0x52, 0x6f,
+	0x6f, 0x74, 0x12, 0x1d, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e,
+	0x53, 0x71, 0x75, 0x61, 0x72, 0x65, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1e, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x53,
+	0x71, 0x75, 0x61, 0x72, 0x65, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x22, 0x00, 0x12, 0x3b, 0x0a, 0x04, 0x52, 0x6f, 0x6f, 0x74, 0x12, 0x17, 0x2e, 0x63, 0x61,
+	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f,
+	0x72, 0x2e, 0x52, 0x6f, 0x6f, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00,
+	0x12, 0x56, 0x0a, 0x0d, 0x42, 0x69, 0x67, 0x41, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x65, 0x74, 0x69,
+	0x63, 0x12, 0x20, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42,
+	0x69, 0x67, 0x41, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x65, 0x74, 0x69, 0x63, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72,
+	0x2e, 0x42, 0x69, 0x67, 0x41, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x65, 0x74, 0x69, 0x63, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x82, 0x01, 0x0a, 0x1b, 0x42, 0x69, 0x67,
+	0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d,
+	0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x2e, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75,
+	0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75,
+	0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2f, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75,
+	0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x42, 0x69, 0x67, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x4e, 0x75,
+	0x6d, 0x62, 0x65, 0x72, 0x44, 0x65, 0x63, 0x6f, 0x6d, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x30, 0x01, 0x12, 0x44, 0x0a,
+	0x07, 0x49, 0x73, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x12, 0x1a, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75,
+	0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x49, 0x73, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f,
+	0x72, 0x2e, 0x49, 0x73, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x22, 0x00, 0x12, 0x4f, 0x0a, 0x0a, 0x4c, 0x69, 0x73, 0x74, 0x50, 0x72, 0x69, 0x6d, 0x65,
+	0x73, 0x12, 0x1d, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4c,
+	0x69, 0x73, 0x74, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x1e, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4c, 0x69,
+	0x73, 0x74, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65,
+	0x22, 0x00, 0x30, 0x01, 0x12, 0x47, 0x0a, 0x08, 0x4e, 0x74, 0x68, 0x50, 0x72, 0x69, 0x6d, 0x65,
+	0x12, 0x1b, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4e, 0x74,
+	0x68, 0x50, 0x72, 0x69, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c, 0x2e,
+	0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4e, 0x74, 0x68, 0x50, 0x72,
+	0x69, 0x6d, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x38, 0x0a,
+	0x03, 0x47, 0x63, 0x64, 0x12, 0x16, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f,
+	0x72, 0x2e, 0x47, 0x63, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x63,
+	0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x47, 0x63, 0x64, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x38, 0x0a, 0x03, 0x4c, 0x63, 0x6d, 0x12, 0x16,
+	0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4c, 0x63, 0x6d, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x17, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61,
+	0x74, 0x6f, 0x72, 0x2e, 0x4c, 0x63, 0x6d, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22,
+	0x00, 0x12, 0x50, 0x0a, 0x0b, 0x45, 0x78, 0x74, 0x65, 0x6e, 0x64, 0x65, 0x64, 0x47, 0x63, 0x64,
+	0x12, 0x1e, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x45, 0x78,
+	0x74, 0x65, 0x6e, 0x64, 0x65, 0x64, 0x47, 0x63, 0x64, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x45, 0x78,
+	0x74, 0x65, 0x6e, 0x64, 0x65, 0x64, 0x47, 0x63, 0x64, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x22, 0x00, 0x12, 0x41, 0x0a, 0x06, 0x4d, 0x6f, 0x64, 0x50, 0x6f, 0x77, 0x12, 0x19, 0x2e,
+	0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x6f, 0x64, 0x50, 0x6f,
+	0x77, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75,
+	0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x6f, 0x64, 0x50, 0x6f, 0x77, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4d, 0x0a, 0x0a, 0x4d, 0x6f, 0x64, 0x49, 0x6e, 0x76,
+	0x65, 0x72, 0x73, 0x65, 0x12, 0x1d, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f,
+	0x72, 0x2e, 0x4d, 0x6f, 0x64, 0x49, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72,
+	0x2e, 0x4d, 0x6f, 0x64, 0x49, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x47, 0x0a, 0x08, 0x45, 0x76, 0x61, 0x6c, 0x75, 0x61, 0x74,
+	0x65, 0x12, 0x1b, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x45,
+	0x76, 0x61, 0x6c, 0x75, 0x61, 0x74, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1c,
+	0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x45, 0x76, 0x61, 0x6c,
+	0x75, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x56,
+	0x0a, 0x0d, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x20, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x43, 0x72, 0x65,
+	0x61, 0x74, 0x65, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x21, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x43,
+	0x72, 0x65, 0x61, 0x74, 0x65, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x41, 0x0a, 0x06, 0x41, 0x73, 0x73, 0x69, 0x67, 0x6e,
+	0x12, 0x19, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x41, 0x73,
+	0x73, 0x69, 0x67, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x63, 0x61,
+	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x41, 0x73, 0x73, 0x69, 0x67, 0x6e, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4d, 0x0a, 0x0a, 0x47, 0x65, 0x74,
+	0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x12, 0x1d, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c,
+	0x61, 0x74, 0x6f, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61,
+	0x74, 0x6f, 0x72, 0x2e, 0x47, 0x65, 0x74, 0x48, 0x69, 0x73, 0x74, 0x6f, 0x72, 0x79, 0x52, 0x65,
+	0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x53, 0x0a, 0x0c, 0x43, 0x6c, 0x6f, 0x73,
+	0x65, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1f, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75,
+	0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x53, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x20, 0x2e, 0x63, 0x61, 0x6c, 0x63,
+	0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x43, 0x6c, 0x6f, 0x73, 0x65, 0x53, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x32, 0xb6, 0x03,
+	0x0a, 0x0d, 0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12,
+	0x34, 0x0a, 0x03, 0x41, 0x64, 0x64, 0x12, 0x15, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61,
+	0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x52, 0x6f, 0x77, 0x1a, 0x12, 0x2e,
+	0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x61, 0x74, 0x72, 0x69,
+	0x78, 0x22, 0x00, 0x28, 0x01, 0x12, 0x39, 0x0a, 0x08, 0x4d, 0x75, 0x6c, 0x74, 0x69, 0x70, 0x6c,
+	0x79, 0x12, 0x15, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d,
+	0x61, 0x74, 0x72, 0x69, 0x78, 0x52, 0x6f, 0x77, 0x1a, 0x12, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75,
+	0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x22, 0x00, 0x28, 0x01,
+	0x12, 0x3a, 0x0a, 0x09, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x70, 0x6f, 0x73, 0x65, 0x12, 0x15, 0x2e,
+	0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x61, 0x74, 0x72, 0x69,
+	0x78, 0x52, 0x6f, 0x77, 0x1a, 0x12, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f,
+	0x72, 0x2e, 0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x22, 0x00, 0x28, 0x01, 0x12, 0x49, 0x0a, 0x0b,
+	0x44, 0x65, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6e, 0x74, 0x12, 0x15, 0x2e, 0x63, 0x61,
+	0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x52,
+	0x6f, 0x77, 0x1a, 0x1f, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e,
+	0x44, 0x65, 0x74, 0x65, 0x72, 0x6d, 0x69, 0x6e, 0x61, 0x6e, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f,
+	0x6e, 0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x12, 0x38, 0x0a, 0x07, 0x49, 0x6e, 0x76, 0x65, 0x72,
+	0x73, 0x65, 0x12, 0x15, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e,
+	0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x52, 0x6f, 0x77, 0x1a, 0x12, 0x2e, 0x63, 0x61, 0x6c, 0x63,
+	0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x22, 0x00, 0x28,
+	0x01, 0x12, 0x36, 0x0a, 0x05, 0x53, 0x6f, 0x6c, 0x76, 0x65, 0x12, 0x15, 0x2e, 0x63, 0x61, 0x6c,
+	0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d, 0x61, 0x74, 0x72, 0x69, 0x78, 0x52, 0x6f,
+	0x77, 0x1a, 0x12, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d,
+	0x61, 0x74, 0x72, 0x69, 0x78, 0x22, 0x00, 0x28, 0x01, 0x12, 0x3b, 0x0a, 0x04, 0x52, 0x61, 0x6e,
+	0x6b, 0x12, 0x15, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x4d,
+	0x61, 0x74, 0x72, 0x69, 0x78, 0x52, 0x6f, 0x77, 0x1a, 0x18, 0x2e, 0x63, 0x61, 0x6c, 0x63, 0x75,
+	0x6c, 0x61, 0x74, 0x6f, 0x72, 0x2e, 0x52, 0x61, 0x6e, 0x6b, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x22, 0x00, 0x28, 0x01, 0x42, 0x19, 0x5a, 0x17, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c,
+	0x61, 0x74, 0x6f, 0x72, 0x2f, 0x63, 0x61, 0x6c, 0x63, 0x75, 0x6c, 0x61, 0x74, 0x6f, 0x72, 0x70,
+	0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_calculator_calculatorpb_calculator_proto_rawDescOnce sync.Once
+	file_calculator_calculatorpb_calculator_proto_rawDescData = file_calculator_calculatorpb_calculator_proto_rawDesc
+)
+
+func file_calculator_calculatorpb_calculator_proto_rawDescGZIP() []byte {
+	file_calculator_calculatorpb_calculator_proto_rawDescOnce.Do(func() {
+		file_calculator_calculatorpb_calculator_proto_rawDescData = protoimpl.X.CompressGZIP(file_calculator_calculatorpb_calculator_proto_rawDescData)
+	})
+	return file_calculator_calculatorpb_calculator_proto_rawDescData
+}
+
+var file_calculator_calculatorpb_calculator_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_calculator_calculatorpb_calculator_proto_msgTypes = make([]protoimpl.MessageInfo, 55)
+var file_calculator_calculatorpb_calculator_proto_goTypes = []interface{}{
+	(BigOperation)(0),                           // 0: calculator.BigOperation
+	(NumericMode)(0),                            // 1: calculator.NumericMode
+	(WindowAggregate)(0),                        // 2: calculator.WindowAggregate
+	(*SumRequest)(nil),                          // 3: calculator.SumRequest
+	(*SumResponse)(nil),                         // 4: calculator.SumResponse
+	(*PrimeNumberDecompositionRequest)(nil),     // 5: calculator.PrimeNumberDecompositionRequest
+	(*PrimeNumberDecompositionResponse)(nil),    // 6: calculator.PrimeNumberDecompositionResponse
+	(*ComputeAverageRequest)(nil),               // 7: calculator.ComputeAverageRequest
+	(*ComputeAverageResponse)(nil),              // 8: calculator.ComputeAverageResponse
+	(*FindMaximumRequest)(nil),                  // 9: calculator.FindMaximumRequest
+	(*FindMaximumResponse)(nil),                 // 10: calculator.FindMaximumResponse
+	(*SquareRootRequest)(nil),                   // 11: calculator.SquareRootRequest
+	(*SquareRootResponse)(nil),                  // 12: calculator.SquareRootResponse
+	(*BigInteger)(nil),                          // 13: calculator.BigInteger
+	(*BigArithmeticRequest)(nil),                // 14: calculator.BigArithmeticRequest
+	(*BigArithmeticResponse)(nil),               // 15: calculator.BigArithmeticResponse
+	(*BigPrimeNumberDecompositionRequest)(nil),  // 16: calculator.BigPrimeNumberDecompositionRequest
+	(*BigPrimeNumberDecompositionResponse)(nil), // 17: calculator.BigPrimeNumberDecompositionResponse
+	(*EvaluateRequest)(nil),                     // 18: calculator.EvaluateRequest
+	(*EvaluateResponse)(nil),                    // 19: calculator.EvaluateResponse
+	(*StatisticsOptions)(nil),                   // 20: calculator.StatisticsOptions
+	(*ComputeStatisticsRequest)(nil),            // 21: calculator.ComputeStatisticsRequest
+	(*Percentile)(nil),                          // 22: calculator.Percentile
+	(*ComputeStatisticsResponse)(nil),           // 23: calculator.ComputeStatisticsResponse
+	(*WindowOptions)(nil),                       // 24: calculator.WindowOptions
+	(*WindowedAggregateRequest)(nil),            // 25: calculator.WindowedAggregateRequest
+	(*WindowedAggregateResponse)(nil),           // 26: calculator.WindowedAggregateResponse
+	(*RootRequest)(nil),                         // 27: calculator.RootRequest
+	(*RootResponse)(nil),                        // 28: calculator.RootResponse
+	(*CreateSessionRequest)(nil),                // 29: calculator.CreateSessionRequest
+	(*CreateSessionResponse)(nil),               // 30: calculator.CreateSessionResponse
+	(*AssignRequest)(nil),                       // 31: calculator.AssignRequest
+	(*AssignResponse)(nil),                      // 32: calculator.AssignResponse
+	(*HistoryEntry)(nil),                        // 33: calculator.HistoryEntry
+	(*GetHistoryRequest)(nil),                   // 34: calculator.GetHistoryRequest
+	(*GetHistoryResponse)(nil),                  // 35: calculator.GetHistoryResponse
+	(*CloseSessionRequest)(nil),                 // 36: calculator.CloseSessionRequest
+	(*CloseSessionResponse)(nil),                // 37: calculator.CloseSessionResponse
+	(*IsPrimeRequest)(nil),                      // 38: calculator.IsPrimeRequest
+	(*IsPrimeResponse)(nil),                     // 39: calculator.IsPrimeResponse
+	(*ListPrimesRequest)(nil),                   // 40: calculator.ListPrimesRequest
+	(*ListPrimesResponse)(nil),                  // 41: calculator.ListPrimesResponse
+	(*NthPrimeRequest)(nil),                     // 42: calculator.NthPrimeRequest
+	(*NthPrimeResponse)(nil),                    // 43: calculator.NthPrimeResponse
+	(*GcdRequest)(nil),                          // 44: calculator.GcdRequest
+	(*GcdResponse)(nil),                         // 45: calculator.GcdResponse
+	(*LcmRequest)(nil),                          // 46: calculator.LcmRequest
+	(*LcmResponse)(nil),                         // 47: calculator.LcmResponse
+	(*ExtendedGcdRequest)(nil),                  // 48: calculator.ExtendedGcdRequest
+	(*ExtendedGcdResponse)(nil),                 // 49: calculator.ExtendedGcdResponse
+	(*ModPowRequest)(nil),                       // 50: calculator.ModPowRequest
+	(*ModPowResponse)(nil),                      // 51: calculator.ModPowResponse
+	(*ModInverseRequest)(nil),                   // 52: calculator.ModInverseRequest
+	(*ModInverseResponse)(nil),                  // 53: calculator.ModInverseResponse
+	(*MatrixRow)(nil),                           // 54: calculator.MatrixRow
+	(*Matrix)(nil),                              // 55: calculator.Matrix
+	(*DeterminantResponse)(nil),                 // 56: calculator.DeterminantResponse
+	(*RankResponse)(nil),                        // 57: calculator.RankResponse
+	(*duration.Duration)(nil),                   // 58: google.protobuf.Duration
+	(*timestamp.Timestamp)(nil),                 // 59: google.protobuf.Timestamp
+}
+var file_calculator_calculatorpb_calculator_proto_depIdxs = []int32{
+	0,  // 0: calculator.BigArithmeticRequest.operation:type_name -> calculator.BigOperation
+	13, // 1: calculator.BigArithmeticRequest.first_number:type_name -> calculator.BigInteger
+	13, // 2: calculator.BigArithmeticRequest.second_number:type_name -> calculator.BigInteger
+	13, // 3: calculator.BigArithmeticResponse.result:type_name -> calculator.BigInteger
+	13, // 4: calculator.BigPrimeNumberDecompositionRequest.number:type_name -> calculator.BigInteger
+	13, // 5: calculator.BigPrimeNumberDecompositionResponse.prime_factor:type_name -> calculator.BigInteger
+	1,  // 6: calculator.EvaluateRequest.mode:type_name -> calculator.NumericMode
+	20, // 7: calculator.ComputeStatisticsRequest.options:type_name -> calculator.StatisticsOptions
+	22, // 8: calculator.ComputeStatisticsResponse.percentiles:type_name -> calculator.Percentile
+	2,  // 9: calculator.WindowOptions.aggregate:type_name -> calculator.WindowAggregate
+	58, // 10: calculator.WindowOptions.period:type_name -> google.protobuf.Duration
+	24, // 11: calculator.WindowedAggregateRequest.options:type_name -> calculator.WindowOptions
+	59, // 12: calculator.WindowedAggregateRequest.time:type_name -> google.protobuf.Timestamp
+	1,  // 13: calculator.CreateSessionRequest.mode:type_name -> calculator.NumericMode
+	58, // 14: calculator.CreateSessionResponse.idle_timeout:type_name -> google.protobuf.Duration
+	59, // 15: calculator.HistoryEntry.time:type_name -> google.protobuf.Timestamp
+	33, // 16: calculator.GetHistoryResponse.entries:type_name -> calculator.HistoryEntry
+	13, // 17: calculator.IsPrimeRequest.big_value:type_name -> calculator.BigInteger
+	13, // 18: calculator.GcdRequest.first_number:type_name -> calculator.BigInteger
+	13, // 19: calculator.GcdRequest.second_number:type_name -> calculator.BigInteger
+	13, // 20: calculator.GcdResponse.gcd:type_name -> calculator.BigInteger
+	13, // 21: calculator.LcmRequest.first_number:type_name -> calculator.BigInteger
+	13, // 22: calculator.LcmRequest.second_number:type_name -> calculator.BigInteger
+	13, // 23: calculator.LcmResponse.lcm:type_name -> calculator.BigInteger
+	13, // 24: calculator.ExtendedGcdRequest.first_number:type_name -> calculator.BigInteger
+	13, // 25: calculator.ExtendedGcdRequest.second_number:type_name -> calculator.BigInteger
+	13, // 26: calculator.ExtendedGcdResponse.gcd:type_name -> calculator.BigInteger
+	13, // 27: calculator.ExtendedGcdResponse.x:type_name -> calculator.BigInteger
+	13, // 28: calculator.ExtendedGcdResponse.y:type_name -> calculator.BigInteger
+	13, // 29: calculator.ModPowRequest.base:type_name -> calculator.BigInteger
+	13, // 30: calculator.ModPowRequest.exponent:type_name -> calculator.BigInteger
+	13, // 31: calculator.ModPowRequest.modulus:type_name -> calculator.BigInteger
+	13, // 32: calculator.ModPowResponse.result:type_name -> calculator.BigInteger
+	13, // 33: calculator.ModInverseRequest.number:type_name -> calculator.BigInteger
+	13, // 34: calculator.ModInverseRequest.modulus:type_name -> calculator.BigInteger
+	13, // 35: calculator.ModInverseResponse.inverse:type_name -> calculator.BigInteger
+	3,  // 36: calculator.CalculatorService.Sum:input_type -> calculator.SumRequest
+	5,  // 37: calculator.CalculatorService.PrimeNumberDecomposition:input_type -> calculator.PrimeNumberDecompositionRequest
+	7,  // 38: calculator.CalculatorService.ComputeAverage:input_type -> calculator.ComputeAverageRequest
+	21, // 39: calculator.CalculatorService.ComputeStatistics:input_type -> calculator.ComputeStatisticsRequest
+	9,  // 40: calculator.CalculatorService.FindMaximum:input_type -> calculator.FindMaximumRequest
+	25, // 41: calculator.CalculatorService.WindowedAggregate:input_type -> calculator.WindowedAggregateRequest
+	11, // 42: calculator.CalculatorService.SquareRoot:input_type -> calculator.SquareRootRequest
+	27, // 43: calculator.CalculatorService.Root:input_type -> calculator.RootRequest
+	14, // 44: calculator.CalculatorService.BigArithmetic:input_type -> calculator.BigArithmeticRequest
+	16, // 45: calculator.CalculatorService.BigPrimeNumberDecomposition:input_type -> calculator.BigPrimeNumberDecompositionRequest
+	38, // 46: calculator.CalculatorService.IsPrime:input_type -> calculator.IsPrimeRequest
+	40, // 47: calculator.CalculatorService.ListPrimes:input_type -> calculator.ListPrimesRequest
+	42, // 48: calculator.CalculatorService.NthPrime:input_type -> calculator.NthPrimeRequest
+	44, // 49: calculator.CalculatorService.Gcd:input_type -> calculator.GcdRequest
+	46, // 50: calculator.CalculatorService.Lcm:input_type -> calculator.LcmRequest
+	48, // 51: calculator.CalculatorService.ExtendedGcd:input_type -> calculator.ExtendedGcdRequest
+	50, // 52: calculator.CalculatorService.ModPow:input_type -> calculator.ModPowRequest
+	52, // 53: calculator.CalculatorService.ModInverse:input_type -> calculator.ModInverseRequest
+	18, // 54: calculator.CalculatorService.Evaluate:input_type -> calculator.EvaluateRequest
+	29, // 55: calculator.CalculatorService.CreateSession:input_type -> calculator.CreateSessionRequest
+	31, // 56: calculator.CalculatorService.Assign:input_type -> calculator.AssignRequest
+	34, // 57: calculator.CalculatorService.GetHistory:input_type -> calculator.GetHistoryRequest
+	36, // 58: calculator.CalculatorService.CloseSession:input_type -> calculator.CloseSessionRequest
+	54, // 59: calculator.MatrixService.Add:input_type -> calculator.MatrixRow
+	54, // 60: calculator.MatrixService.Multiply:input_type -> calculator.MatrixRow
+	54, // 61: calculator.MatrixService.Transpose:input_type -> calculator.MatrixRow
+	54, // 62: calculator.MatrixService.Determinant:input_type -> calculator.MatrixRow
+	54, // 63: calculator.MatrixService.Inverse:input_type -> calculator.MatrixRow
+	54, // 64: calculator.MatrixService.Solve:input_type -> calculator.MatrixRow
+	54, // 65: calculator.MatrixService.Rank:input_type -> calculator.MatrixRow
+	4,  // 66: calculator.CalculatorService.Sum:output_type -> calculator.SumResponse
+	6,  // 67: calculator.CalculatorService.PrimeNumberDecomposition:output_type -> calculator.PrimeNumberDecompositionResponse
+	8,  // 68: calculator.CalculatorService.ComputeAverage:output_type -> calculator.ComputeAverageResponse
+	23, // 69: calculator.CalculatorService.ComputeStatistics:output_type -> calculator.ComputeStatisticsResponse
+	10, // 70: calculator.CalculatorService.FindMaximum:output_type -> calculator.FindMaximumResponse
+	26, // 71: calculator.CalculatorService.WindowedAggregate:output_type -> calculator.WindowedAggregateResponse
+	12, // 72: calculator.CalculatorService.SquareRoot:output_type -> calculator.SquareRootResponse
+	28, // 73: calculator.CalculatorService.Root:output_type -> calculator.RootResponse
+	15, // 74: calculator.CalculatorService.BigArithmetic:output_type -> calculator.BigArithmeticResponse
+	17, // 75: calculator.CalculatorService.BigPrimeNumberDecomposition:output_type -> calculator.BigPrimeNumberDecompositionResponse
+	39, // 76: calculator.CalculatorService.IsPrime:output_type -> calculator.IsPrimeResponse
+	41, // 77: calculator.CalculatorService.ListPrimes:output_type -> calculator.ListPrimesResponse
+	43, // 78: calculator.CalculatorService.NthPrime:output_type -> calculator.NthPrimeResponse
+	45, // 79: calculator.CalculatorService.Gcd:output_type -> calculator.GcdResponse
+	47, // 80: calculator.CalculatorService.Lcm:output_type -> calculator.LcmResponse
+	49, // 81: calculator.CalculatorService.ExtendedGcd:output_type -> calculator.ExtendedGcdResponse
+	51, // 82: calculator.CalculatorService.ModPow:output_type -> calculator.ModPowResponse
+	53, // 83: calculator.CalculatorService.ModInverse:output_type -> calculator.ModInverseResponse
+	19, // 84: calculator.CalculatorService.Evaluate:output_type -> calculator.EvaluateResponse
+	30, // 85: calculator.CalculatorService.CreateSession:output_type -> calculator.CreateSessionResponse
+	32, // 86: calculator.CalculatorService.Assign:output_type -> calculator.AssignResponse
+	35, // 87: calculator.CalculatorService.GetHistory:output_type -> calculator.GetHistoryResponse
+	37, // 88: calculator.CalculatorService.CloseSession:output_type -> calculator.CloseSessionResponse
+	55, // 89: calculator.MatrixService.Add:output_type -> calculator.Matrix
+	55, // 90: calculator.MatrixService.Multiply:output_type -> calculator.Matrix
+	55, // 91: calculator.MatrixService.Transpose:output_type -> calculator.Matrix
+	56, // 92: calculator.MatrixService.Determinant:output_type -> calculator.DeterminantResponse
+	55, // 93: calculator.MatrixService.Inverse:output_type -> calculator.Matrix
+	55, // 94: calculator.MatrixService.Solve:output_type -> calculator.Matrix
+	57, // 95: calculator.MatrixService.Rank:output_type -> calculator.RankResponse
+	66, // [66:96] is the sub-list for method output_type
+	36, // [36:66] is the sub-list for method input_type
+	36, // [36:36] is the sub-list for extension type_name
+	36, // [36:36] is the sub-list for extension extendee
+	0,  // [0:36] is the sub-list for field type_name
+}
+
+func init() { file_calculator_calculatorpb_calculator_proto_init() }
+func file_calculator_calculatorpb_calculator_proto_init() {
+	if File_calculator_calculatorpb_calculator_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_calculator_calculatorpb_calculator_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SumRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SumResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrimeNumberDecompositionRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*PrimeNumberDecompositionResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ComputeAverageRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ComputeAverageResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FindMaximumRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FindMaximumResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SquareRootRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SquareRootResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BigInteger); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BigArithmeticRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BigArithmeticResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BigPrimeNumberDecompositionRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BigPrimeNumberDecompositionResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EvaluateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EvaluateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StatisticsOptions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ComputeStatisticsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Percentile); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ComputeStatisticsResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WindowOptions); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WindowedAggregateRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*WindowedAggregateResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RootRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RootResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateSessionRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CreateSessionResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AssignRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[29].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AssignResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[30].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*HistoryEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[31].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetHistoryRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[32].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetHistoryResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[33].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CloseSessionRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[34].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CloseSessionResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[35].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IsPrimeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[36].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IsPrimeResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[37].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListPrimesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[38].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListPrimesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[39].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NthPrimeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[40].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NthPrimeResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[41].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GcdRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[42].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GcdResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[43].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LcmRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[44].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LcmResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[45].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExtendedGcdRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[46].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ExtendedGcdResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[47].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModPowRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[48].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModPowResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[49].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModInverseRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[50].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ModInverseResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[51].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MatrixRow); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[52].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Matrix); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[53].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeterminantResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_calculator_calculatorpb_calculator_proto_msgTypes[54].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RankResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_calculator_calculatorpb_calculator_proto_msgTypes[10].OneofWrappers = []interface{}{
+		(*BigInteger_Decimal)(nil),
+		(*BigInteger_TwosComplement)(nil),
+	}
+	file_calculator_calculatorpb_calculator_proto_msgTypes[18].OneofWrappers = []interface{}{
+		(*ComputeStatisticsRequest_Options)(nil),
+		(*ComputeStatisticsRequest_Number)(nil),
+	}
+	file_calculator_calculatorpb_calculator_proto_msgTypes[21].OneofWrappers = []interface{}{
+		(*WindowOptions_Size)(nil),
+		(*WindowOptions_Period)(nil),
+	}
+	file_calculator_calculatorpb_calculator_proto_msgTypes[22].OneofWrappers = []interface{}{
+		(*WindowedAggregateRequest_Options)(nil),
+		(*WindowedAggregateRequest_Number)(nil),
+	}
+	file_calculator_calculatorpb_calculator_proto_msgTypes[24].OneofWrappers = []interface{}{
+		(*RootRequest_Value)(nil),
+		(*RootRequest_Decimal)(nil),
+	}
+	file_calculator_calculatorpb_calculator_proto_msgTypes[35].OneofWrappers = []interface{}{
+		(*IsPrimeRequest_Value)(nil),
+		(*IsPrimeRequest_BigValue)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_calculator_calculatorpb_calculator_proto_rawDesc,
+			NumEnums:      3,
+			NumMessages:   55,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_calculator_calculatorpb_calculator_proto_goTypes,
+		DependencyIndexes: file_calculator_calculatorpb_calculator_proto_depIdxs,
+		EnumInfos:         file_calculator_calculatorpb_calculator_proto_enumTypes,
+		MessageInfos:      file_calculator_calculatorpb_calculator_proto_msgTypes,
+	}.Build()
+	File_calculator_calculatorpb_calculator_proto = out.File
+	file_calculator_calculatorpb_calculator_proto_rawDesc = nil
+	file_calculator_calculatorpb_calculator_proto_goTypes = nil
+	file_calculator_calculatorpb_calculator_proto_depIdxs = nil
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
 var _ grpc.ClientConnInterface
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion6
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// CalculatorServiceClient is the client API for CalculatorService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type CalculatorServiceClient interface {
+	// Return OUT_OF_RANGE if the sum does not fit in an int32
+	Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumResponse, error)
+	// Return INVALID_ARGUMENT if the number is not positive
+	PrimeNumberDecomposition(ctx context.Context, in *PrimeNumberDecompositionRequest, opts ...grpc.CallOption) (CalculatorService_PrimeNumberDecompositionClient, error)
+	// Return INVALID_ARGUMENT if no number is sent
+	ComputeAverage(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_ComputeAverageClient, error)
+	// Return INVALID_ARGUMENT on NaN or infinite numbers, invalid options or
+	// options sent after the first message, and OUT_OF_RANGE if the sum, mean
+	// or variance overflows a double
+	ComputeStatistics(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_ComputeStatisticsClient, error)
+	FindMaximum(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_FindMaximumClient, error)
+	// Answers each number with the aggregate of the window ending with it.
+	// Return INVALID_ARGUMENT on missing or invalid options, NaN or
+	// infinite numbers and times going backwards, and RESOURCE_EXHAUSTED
+	// when a period window holds too many numbers
+	WindowedAggregate(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_WindowedAggregateClient, error)
+	// error handling
+	// this RPC will throw an exception if the sent number is negative
+	// the error being sent is of the type INVALID_ARGUMENT
+	SquareRoot(ctx context.Context, in *SquareRootRequest, opts ...grpc.CallOption) (*SquareRootResponse, error)
+	// nth root with the requested precision. Odd roots of negative numbers
+	// are real. Return INVALID_ARGUMENT on even roots of negative numbers
+	// unless allow_complex is set, and on invalid numbers or options
+	Root(ctx context.Context, in *RootRequest, opts ...grpc.CallOption) (*RootResponse, error)
+	// Return INVALID_ARGUMENT on division by zero or a negative exponent,
+	// and OUT_OF_RANGE if the result is too large
+	BigArithmetic(ctx context.Context, in *BigArithmeticRequest, opts ...grpc.CallOption) (*BigArithmeticResponse, error)
+	// Return INVALID_ARGUMENT if the number is not positive, OUT_OF_RANGE if
+	// it has too many bits and DEADLINE_EXCEEDED if factoring it takes longer
+	// than the server allows
+	BigPrimeNumberDecomposition(ctx context.Context, in *BigPrimeNumberDecompositionRequest, opts ...grpc.CallOption) (CalculatorService_BigPrimeNumberDecompositionClient, error)
+	// Return OUT_OF_RANGE if the number has too many bits
+	IsPrime(ctx context.Context, in *IsPrimeRequest, opts ...grpc.CallOption) (*IsPrimeResponse, error)
+	// streams the primes between from and to in ascending order. Return
+	// INVALID_ARGUMENT if from > to or a bound is negative, and OUT_OF_RANGE
+	// if the range is too large
+	ListPrimes(ctx context.Context, in *ListPrimesRequest, opts ...grpc.CallOption) (CalculatorService_ListPrimesClient, error)
+	// Return INVALID_ARGUMENT if n is not positive and OUT_OF_RANGE if it is
+	// too large
+	NthPrime(ctx context.Context, in *NthPrimeRequest, opts ...grpc.CallOption) (*NthPrimeResponse, error)
+	// number theory on big integers, the results are encoded like the first
+	// number of the request. Return INVALID_ARGUMENT with a BadRequest
+	// detail naming the field at fault on invalid numbers, non-positive
+	// moduli and numbers not invertible modulo the modulus, and
+	// OUT_OF_RANGE if the numbers or the result are too large
+	Gcd(ctx context.Context, in *GcdRequest, opts ...grpc.CallOption) (*GcdResponse, error)
+	Lcm(ctx context.Context, in *LcmRequest, opts ...grpc.CallOption) (*LcmResponse, error)
+	ExtendedGcd(ctx context.Context, in *ExtendedGcdRequest, opts ...grpc.CallOption) (*ExtendedGcdResponse, error)
+	ModPow(ctx context.Context, in *ModPowRequest, opts ...grpc.CallOption) (*ModPowResponse, error)
+	ModInverse(ctx context.Context, in *ModInverseRequest, opts ...grpc.CallOption) (*ModInverseResponse, error)
+	// Return INVALID_ARGUMENT with the 1-based position of the failure if the
+	// expression cannot be parsed or evaluated
+	Evaluate(ctx context.Context, in *EvaluateRequest, opts ...grpc.CallOption) (*EvaluateResponse, error)
+	// sessions keep variables and a history of the calculations. Return
+	// NOT_FOUND if the session does not exist, was closed or expired, and
+	// RESOURCE_EXHAUSTED when too many sessions or variables exist
+	CreateSession(ctx context.Context, in *CreateSessionRequest, opts ...grpc.CallOption) (*CreateSessionResponse, error)
+	Assign(ctx context.Context, in *AssignRequest, opts ...grpc.CallOption) (*AssignResponse, error)
+	GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (*GetHistoryResponse, error)
+	CloseSession(ctx context.Context, in *CloseSessionRequest, opts ...grpc.CallOption) (*CloseSessionResponse, error)
+}
+
+type calculatorServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCalculatorServiceClient(cc grpc.ClientConnInterface) CalculatorServiceClient {
+	return &calculatorServiceClient{cc}
+}
+
+func (c *calculatorServiceClient) Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumResponse, error) {
+	out := new(SumResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/Sum", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) PrimeNumberDecomposition(ctx context.Context, in *PrimeNumberDecompositionRequest, opts ...grpc.CallOption) (CalculatorService_PrimeNumberDecompositionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[0], "/calculator.CalculatorService/PrimeNumberDecomposition", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calculatorServicePrimeNumberDecompositionClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CalculatorService_PrimeNumberDecompositionClient interface {
+	Recv() (*PrimeNumberDecompositionResponse, error)
+	grpc.ClientStream
+}
+
+type calculatorServicePrimeNumberDecompositionClient struct {
+	grpc.ClientStream
+}
+
+func (x *calculatorServicePrimeNumberDecompositionClient) Recv() (*PrimeNumberDecompositionResponse, error) {
+	m := new(PrimeNumberDecompositionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calculatorServiceClient) ComputeAverage(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_ComputeAverageClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[1], "/calculator.CalculatorService/ComputeAverage", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calculatorServiceComputeAverageClient{stream}
+	return x, nil
+}
+
+type CalculatorService_ComputeAverageClient interface {
+	Send(*ComputeAverageRequest) error
+	CloseAndRecv() (*ComputeAverageResponse, error)
+	grpc.ClientStream
+}
+
+type calculatorServiceComputeAverageClient struct {
+	grpc.ClientStream
+}
+
+func (x *calculatorServiceComputeAverageClient) Send(m *ComputeAverageRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *calculatorServiceComputeAverageClient) CloseAndRecv() (*ComputeAverageResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ComputeAverageResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calculatorServiceClient) ComputeStatistics(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_ComputeStatisticsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[2], "/calculator.CalculatorService/ComputeStatistics", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calculatorServiceComputeStatisticsClient{stream}
+	return x, nil
+}
+
+type CalculatorService_ComputeStatisticsClient interface {
+	Send(*ComputeStatisticsRequest) error
+	CloseAndRecv() (*ComputeStatisticsResponse, error)
+	grpc.ClientStream
+}
+
+type calculatorServiceComputeStatisticsClient struct {
+	grpc.ClientStream
+}
+
+func (x *calculatorServiceComputeStatisticsClient) Send(m *ComputeStatisticsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *calculatorServiceComputeStatisticsClient) CloseAndRecv() (*ComputeStatisticsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ComputeStatisticsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calculatorServiceClient) FindMaximum(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_FindMaximumClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[3], "/calculator.CalculatorService/FindMaximum", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calculatorServiceFindMaximumClient{stream}
+	return x, nil
+}
+
+type CalculatorService_FindMaximumClient interface {
+	Send(*FindMaximumRequest) error
+	Recv() (*FindMaximumResponse, error)
+	grpc.ClientStream
+}
+
+type calculatorServiceFindMaximumClient struct {
+	grpc.ClientStream
+}
+
+func (x *calculatorServiceFindMaximumClient) Send(m *FindMaximumRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *calculatorServiceFindMaximumClient) Recv() (*FindMaximumResponse, error) {
+	m := new(FindMaximumResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calculatorServiceClient) WindowedAggregate(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_WindowedAggregateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[4], "/calculator.CalculatorService/WindowedAggregate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calculatorServiceWindowedAggregateClient{stream}
+	return x, nil
+}
+
+type CalculatorService_WindowedAggregateClient interface {
+	Send(*WindowedAggregateRequest) error
+	Recv() (*WindowedAggregateResponse, error)
+	grpc.ClientStream
+}
+
+type calculatorServiceWindowedAggregateClient struct {
+	grpc.ClientStream
+}
+
+func (x *calculatorServiceWindowedAggregateClient) Send(m *WindowedAggregateRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *calculatorServiceWindowedAggregateClient) Recv() (*WindowedAggregateResponse, error) {
+	m := new(WindowedAggregateResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calculatorServiceClient) SquareRoot(ctx context.Context, in *SquareRootRequest, opts ...grpc.CallOption) (*SquareRootResponse, error) {
+	out := new(SquareRootResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/SquareRoot", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) Root(ctx context.Context, in *RootRequest, opts ...grpc.CallOption) (*RootResponse, error) {
+	out := new(RootResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/Root", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) BigArithmetic(ctx context.Context, in *BigArithmeticRequest, opts ...grpc.CallOption) (*BigArithmeticResponse, error) {
+	out := new(BigArithmeticResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/BigArithmetic", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) BigPrimeNumberDecomposition(ctx context.Context, in *BigPrimeNumberDecompositionRequest, opts ...grpc.CallOption) (CalculatorService_BigPrimeNumberDecompositionClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[5], "/calculator.CalculatorService/BigPrimeNumberDecomposition", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calculatorServiceBigPrimeNumberDecompositionClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CalculatorService_BigPrimeNumberDecompositionClient interface {
+	Recv() (*BigPrimeNumberDecompositionResponse, error)
+	grpc.ClientStream
+}
+
+type calculatorServiceBigPrimeNumberDecompositionClient struct {
+	grpc.ClientStream
+}
+
+func (x *calculatorServiceBigPrimeNumberDecompositionClient) Recv() (*BigPrimeNumberDecompositionResponse, error) {
+	m := new(BigPrimeNumberDecompositionResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calculatorServiceClient) IsPrime(ctx context.Context, in *IsPrimeRequest, opts ...grpc.CallOption) (*IsPrimeResponse, error) {
+	out := new(IsPrimeResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/IsPrime", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) ListPrimes(ctx context.Context, in *ListPrimesRequest, opts ...grpc.CallOption) (CalculatorService_ListPrimesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[6], "/calculator.CalculatorService/ListPrimes", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &calculatorServiceListPrimesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CalculatorService_ListPrimesClient interface {
+	Recv() (*ListPrimesResponse, error)
+	grpc.ClientStream
+}
+
+type calculatorServiceListPrimesClient struct {
+	grpc.ClientStream
+}
+
+func (x *calculatorServiceListPrimesClient) Recv() (*ListPrimesResponse, error) {
+	m := new(ListPrimesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *calculatorServiceClient) NthPrime(ctx context.Context, in *NthPrimeRequest, opts ...grpc.CallOption) (*NthPrimeResponse, error) {
+	out := new(NthPrimeResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/NthPrime", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) Gcd(ctx context.Context, in *GcdRequest, opts ...grpc.CallOption) (*GcdResponse, error) {
+	out := new(GcdResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/Gcd", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) Lcm(ctx context.Context, in *LcmRequest, opts ...grpc.CallOption) (*LcmResponse, error) {
+	out := new(LcmResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/Lcm", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) ExtendedGcd(ctx context.Context, in *ExtendedGcdRequest, opts ...grpc.CallOption) (*ExtendedGcdResponse, error) {
+	out := new(ExtendedGcdResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/ExtendedGcd", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) ModPow(ctx context.Context, in *ModPowRequest, opts ...grpc.CallOption) (*ModPowResponse, error) {
+	out := new(ModPowResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/ModPow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) ModInverse(ctx context.Context, in *ModInverseRequest, opts ...grpc.CallOption) (*ModInverseResponse, error) {
+	out := new(ModInverseResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/ModInverse", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) Evaluate(ctx context.Context, in *EvaluateRequest, opts ...grpc.CallOption) (*EvaluateResponse, error) {
+	out := new(EvaluateResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/Evaluate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) CreateSession(ctx context.Context, in *CreateSessionRequest, opts ...grpc.CallOption) (*CreateSessionResponse, error) {
+	out := new(CreateSessionResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/CreateSession", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) Assign(ctx context.Context, in *AssignRequest, opts ...grpc.CallOption) (*AssignResponse, error) {
+	out := new(AssignResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/Assign", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) GetHistory(ctx context.Context, in *GetHistoryRequest, opts ...grpc.CallOption) (*GetHistoryResponse, error) {
+	out := new(GetHistoryResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/GetHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *calculatorServiceClient) CloseSession(ctx context.Context, in *CloseSessionRequest, opts ...grpc.CallOption) (*CloseSessionResponse, error) {
+	out := new(CloseSessionResponse)
+	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/CloseSession", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CalculatorServiceServer is the server API for CalculatorService service.
+type CalculatorServiceServer interface {
+	// Return OUT_OF_RANGE if the sum does not fit in an int32
+	Sum(context.Context, *SumRequest) (*SumResponse, error)
+	// Return INVALID_ARGUMENT if the number is not positive
+	PrimeNumberDecomposition(*PrimeNumberDecompositionRequest, CalculatorService_PrimeNumberDecompositionServer) error
+	// Return INVALID_ARGUMENT if no number is sent
+	ComputeAverage(CalculatorService_ComputeAverageServer) error
+	// Return INVALID_ARGUMENT on NaN or infinite numbers, invalid options or
+	// options sent after the first message, and OUT_OF_RANGE if the sum, mean
+	// or variance overflows a double
+	ComputeStatistics(CalculatorService_ComputeStatisticsServer) error
+	FindMaximum(CalculatorService_FindMaximumServer) error
+	// Answers each number with the aggregate of the window ending with it.
+	// Return INVALID_ARGUMENT on missing or invalid options, NaN or
+	// infinite numbers and times going backwards, and RESOURCE_EXHAUSTED
+	// when a period window holds too many numbers
+	WindowedAggregate(CalculatorService_WindowedAggregateServer) error
+	// error handling
+	// this RPC will throw an exception if the sent number is negative
+	// the error being sent is of the type INVALID_ARGUMENT
+	SquareRoot(context.Context, *SquareRootRequest) (*SquareRootResponse, error)
+	// nth root with the requested precision. Odd roots of negative numbers
+	// are real. Return INVALID_ARGUMENT on even roots of negative numbers
+	// unless allow_complex is set, and on invalid numbers or options
+	Root(context.Context, *RootRequest) (*RootResponse, error)
+	// Return INVALID_ARGUMENT on division by zero or a negative exponent,
+	// and OUT_OF_RANGE if the result is too large
+	BigArithmetic(context.Context, *BigArithmeticRequest) (*BigArithmeticResponse, error)
+	// Return INVALID_ARGUMENT if the number is not positive, OUT_OF_RANGE if
+	// it has too many bits and DEADLINE_EXCEEDED if factoring it takes longer
+	// than the server allows
+	BigPrimeNumberDecomposition(*BigPrimeNumberDecompositionRequest, CalculatorService_BigPrimeNumberDecompositionServer) error
+	// Return OUT_OF_RANGE if the number has too many bits
+	IsPrime(context.Context, *IsPrimeRequest) (*IsPrimeResponse, error)
+	// streams the primes between from and to in ascending order. Return
+	// INVALID_ARGUMENT if from > to or a bound is negative, and OUT_OF_RANGE
+	// if the range is too large
+	ListPrimes(*ListPrimesRequest, CalculatorService_ListPrimesServer) error
+	// Return INVALID_ARGUMENT if n is not positive and OUT_OF_RANGE if it is
+	// too large
+	NthPrime(context.Context, *NthPrimeRequest) (*NthPrimeResponse, error)
+	// number theory on big integers, the results are encoded like the first
+	// number of the request. Return INVALID_ARGUMENT with a BadRequest
+	// detail naming the field at fault on invalid numbers, non-positive
+	// moduli and numbers not invertible modulo the modulus, and
+	// OUT_OF_RANGE if the numbers or the result are too large
+	Gcd(context.Context, *GcdRequest) (*GcdResponse, error)
+	Lcm(context.Context, *LcmRequest) (*LcmResponse, error)
+	ExtendedGcd(context.Context, *ExtendedGcdRequest) (*ExtendedGcdResponse, error)
+	ModPow(context.Context, *ModPowRequest) (*ModPowResponse, error)
+	ModInverse(context.Context, *ModInverseRequest) (*ModInverseResponse, error)
+	// Return INVALID_ARGUMENT with the 1-based position of the failure if the
+	// expression cannot be parsed or evaluated
+	Evaluate(context.Context, *EvaluateRequest) (*EvaluateResponse, error)
+	// sessions keep variables and a history of the calculations. Return
+	// NOT_FOUND if the session does not exist, was closed or expired, and
+	// RESOURCE_EXHAUSTED when too many sessions or variables exist
+	CreateSession(context.Context, *CreateSessionRequest) (*CreateSessionResponse, error)
+	Assign(context.Context, *AssignRequest) (*AssignResponse, error)
+	GetHistory(context.Context, *GetHistoryRequest) (*GetHistoryResponse, error)
+	CloseSession(context.Context, *CloseSessionRequest) (*CloseSessionResponse, error)
+}
+
+// UnimplementedCalculatorServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedCalculatorServiceServer struct {
+}
+
+func (*UnimplementedCalculatorServiceServer) Sum(context.Context, *SumRequest) (*SumResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sum not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) PrimeNumberDecomposition(*PrimeNumberDecompositionRequest, CalculatorService_PrimeNumberDecompositionServer) error {
+	return status.Errorf(codes.Unimplemented, "method PrimeNumberDecomposition not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) ComputeAverage(CalculatorService_ComputeAverageServer) error {
+	return status.Errorf(codes.Unimplemented, "method ComputeAverage not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) ComputeStatistics(CalculatorService_ComputeStatisticsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ComputeStatistics not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) FindMaximum(CalculatorService_FindMaximumServer) error {
+	return status.Errorf(codes.Unimplemented, "method FindMaximum not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) WindowedAggregate(CalculatorService_WindowedAggregateServer) error {
+	return status.Errorf(codes.Unimplemented, "method WindowedAggregate not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) SquareRoot(context.Context, *SquareRootRequest) (*SquareRootResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SquareRoot not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) Root(context.Context, *RootRequest) (*RootResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Root not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) BigArithmetic(context.Context, *BigArithmeticRequest) (*BigArithmeticResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BigArithmetic not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) BigPrimeNumberDecomposition(*BigPrimeNumberDecompositionRequest, CalculatorService_BigPrimeNumberDecompositionServer) error {
+	return status.Errorf(codes.Unimplemented, "method BigPrimeNumberDecomposition not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) IsPrime(context.Context, *IsPrimeRequest) (*IsPrimeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IsPrime not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) ListPrimes(*ListPrimesRequest, CalculatorService_ListPrimesServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListPrimes not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) NthPrime(context.Context, *NthPrimeRequest) (*NthPrimeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NthPrime not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) Gcd(context.Context, *GcdRequest) (*GcdResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Gcd not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) Lcm(context.Context, *LcmRequest) (*LcmResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lcm not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) ExtendedGcd(context.Context, *ExtendedGcdRequest) (*ExtendedGcdResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExtendedGcd not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) ModPow(context.Context, *ModPowRequest) (*ModPowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ModPow not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) ModInverse(context.Context, *ModInverseRequest) (*ModInverseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ModInverse not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) Evaluate(context.Context, *EvaluateRequest) (*EvaluateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Evaluate not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) CreateSession(context.Context, *CreateSessionRequest) (*CreateSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSession not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) Assign(context.Context, *AssignRequest) (*AssignResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Assign not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) GetHistory(context.Context, *GetHistoryRequest) (*GetHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHistory not implemented")
+}
+func (*UnimplementedCalculatorServiceServer) CloseSession(context.Context, *CloseSessionRequest) (*CloseSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CloseSession not implemented")
+}
+
+func RegisterCalculatorServiceServer(s *grpc.Server, srv CalculatorServiceServer) {
+	s.RegisterService(&_CalculatorService_serviceDesc, srv)
+}
+
+func _CalculatorService_Sum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SumRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).Sum(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/Sum",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).Sum(ctx, req.(*SumRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_PrimeNumberDecomposition_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PrimeNumberDecompositionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CalculatorServiceServer).PrimeNumberDecomposition(m, &calculatorServicePrimeNumberDecompositionServer{stream})
+}
+
+type CalculatorService_PrimeNumberDecompositionServer interface {
+	Send(*PrimeNumberDecompositionResponse) error
+	grpc.ServerStream
+}
+
+type calculatorServicePrimeNumberDecompositionServer struct {
+	grpc.ServerStream
+}
+
+func (x *calculatorServicePrimeNumberDecompositionServer) Send(m *PrimeNumberDecompositionResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CalculatorService_ComputeAverage_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CalculatorServiceServer).ComputeAverage(&calculatorServiceComputeAverageServer{stream})
+}
+
+type CalculatorService_ComputeAverageServer interface {
+	SendAndClose(*ComputeAverageResponse) error
+	Recv() (*ComputeAverageRequest, error)
+	grpc.ServerStream
+}
+
+type calculatorServiceComputeAverageServer struct {
+	grpc.ServerStream
+}
+
+func (x *calculatorServiceComputeAverageServer) SendAndClose(m *ComputeAverageResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *calculatorServiceComputeAverageServer) Recv() (*ComputeAverageRequest, error) {
+	m := new(ComputeAverageRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CalculatorService_ComputeStatistics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CalculatorServiceServer).ComputeStatistics(&calculatorServiceComputeStatisticsServer{stream})
+}
+
+type CalculatorService_ComputeStatisticsServer interface {
+	SendAndClose(*ComputeStatisticsResponse) error
+	Recv() (*ComputeStatisticsRequest, error)
+	grpc.ServerStream
+}
+
+type calculatorServiceComputeStatisticsServer struct {
+	grpc.ServerStream
+}
+
+func (x *calculatorServiceComputeStatisticsServer) SendAndClose(m *ComputeStatisticsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *calculatorServiceComputeStatisticsServer) Recv() (*ComputeStatisticsRequest, error) {
+	m := new(ComputeStatisticsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CalculatorService_FindMaximum_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CalculatorServiceServer).FindMaximum(&calculatorServiceFindMaximumServer{stream})
+}
+
+type CalculatorService_FindMaximumServer interface {
+	Send(*FindMaximumResponse) error
+	Recv() (*FindMaximumRequest, error)
+	grpc.ServerStream
+}
+
+type calculatorServiceFindMaximumServer struct {
+	grpc.ServerStream
+}
+
+func (x *calculatorServiceFindMaximumServer) Send(m *FindMaximumResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *calculatorServiceFindMaximumServer) Recv() (*FindMaximumRequest, error) {
+	m := new(FindMaximumRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CalculatorService_WindowedAggregate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CalculatorServiceServer).WindowedAggregate(&calculatorServiceWindowedAggregateServer{stream})
+}
+
+type CalculatorService_WindowedAggregateServer interface {
+	Send(*WindowedAggregateResponse) error
+	Recv() (*WindowedAggregateRequest, error)
+	grpc.ServerStream
+}
+
+type calculatorServiceWindowedAggregateServer struct {
+	grpc.ServerStream
+}
+
+func (x *calculatorServiceWindowedAggregateServer) Send(m *WindowedAggregateResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *calculatorServiceWindowedAggregateServer) Recv() (*WindowedAggregateRequest, error) {
+	m := new(WindowedAggregateRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CalculatorService_SquareRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SquareRootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).SquareRoot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/SquareRoot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).SquareRoot(ctx, req.(*SquareRootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_Root_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RootRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).Root(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/Root",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).Root(ctx, req.(*RootRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_BigArithmetic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BigArithmeticRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).BigArithmetic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/BigArithmetic",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).BigArithmetic(ctx, req.(*BigArithmeticRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_BigPrimeNumberDecomposition_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BigPrimeNumberDecompositionRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CalculatorServiceServer).BigPrimeNumberDecomposition(m, &calculatorServiceBigPrimeNumberDecompositionServer{stream})
+}
+
+type CalculatorService_BigPrimeNumberDecompositionServer interface {
+	Send(*BigPrimeNumberDecompositionResponse) error
+	grpc.ServerStream
+}
+
+type calculatorServiceBigPrimeNumberDecompositionServer struct {
+	grpc.ServerStream
+}
+
+func (x *calculatorServiceBigPrimeNumberDecompositionServer) Send(m *BigPrimeNumberDecompositionResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CalculatorService_IsPrime_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IsPrimeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).IsPrime(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/IsPrime",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).IsPrime(ctx, req.(*IsPrimeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_ListPrimes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListPrimesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CalculatorServiceServer).ListPrimes(m, &calculatorServiceListPrimesServer{stream})
+}
+
+type CalculatorService_ListPrimesServer interface {
+	Send(*ListPrimesResponse) error
+	grpc.ServerStream
+}
+
+type calculatorServiceListPrimesServer struct {
+	grpc.ServerStream
+}
+
+func (x *calculatorServiceListPrimesServer) Send(m *ListPrimesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CalculatorService_NthPrime_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NthPrimeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).NthPrime(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/NthPrime",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).NthPrime(ctx, req.(*NthPrimeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_Gcd_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GcdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).Gcd(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/Gcd",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).Gcd(ctx, req.(*GcdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_Lcm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LcmRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).Lcm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/Lcm",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).Lcm(ctx, req.(*LcmRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_ExtendedGcd_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExtendedGcdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).ExtendedGcd(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/ExtendedGcd",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).ExtendedGcd(ctx, req.(*ExtendedGcdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
 
-// CalculatorServiceClient is the client API for CalculatorService service.
+func _CalculatorService_ModPow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModPowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).ModPow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/ModPow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).ModPow(ctx, req.(*ModPowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_ModInverse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModInverseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).ModInverse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/ModInverse",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).ModInverse(ctx, req.(*ModInverseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_Evaluate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EvaluateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).Evaluate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/Evaluate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).Evaluate(ctx, req.(*EvaluateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_CreateSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).CreateSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/CreateSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).CreateSession(ctx, req.(*CreateSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_Assign_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssignRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).Assign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/Assign",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).Assign(ctx, req.(*AssignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_GetHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).GetHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/GetHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).GetHistory(ctx, req.(*GetHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CalculatorService_CloseSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CloseSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CalculatorServiceServer).CloseSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/calculator.CalculatorService/CloseSession",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CalculatorServiceServer).CloseSession(ctx, req.(*CloseSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _CalculatorService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "calculator.CalculatorService",
+	HandlerType: (*CalculatorServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Sum",
+			Handler:    _CalculatorService_Sum_Handler,
+		},
+		{
+			MethodName: "SquareRoot",
+			Handler:    _CalculatorService_SquareRoot_Handler,
+		},
+		{
+			MethodName: "Root",
+			Handler:    _CalculatorService_Root_Handler,
+		},
+		{
+			MethodName: "BigArithmetic",
+			Handler:    _CalculatorService_BigArithmetic_Handler,
+		},
+		{
+			MethodName: "IsPrime",
+			Handler:    _CalculatorService_IsPrime_Handler,
+		},
+		{
+			MethodName: "NthPrime",
+			Handler:    _CalculatorService_NthPrime_Handler,
+		},
+		{
+			MethodName: "Gcd",
+			Handler:    _CalculatorService_Gcd_Handler,
+		},
+		{
+			MethodName: "Lcm",
+			Handler:    _CalculatorService_Lcm_Handler,
+		},
+		{
+			MethodName: "ExtendedGcd",
+			Handler:    _CalculatorService_ExtendedGcd_Handler,
+		},
+		{
+			MethodName: "ModPow",
+			Handler:    _CalculatorService_ModPow_Handler,
+		},
+		{
+			MethodName: "ModInverse",
+			Handler:    _CalculatorService_ModInverse_Handler,
+		},
+		{
+			MethodName: "Evaluate",
+			Handler:    _CalculatorService_Evaluate_Handler,
+		},
+		{
+			MethodName: "CreateSession",
+			Handler:    _CalculatorService_CreateSession_Handler,
+		},
+		{
+			MethodName: "Assign",
+			Handler:    _CalculatorService_Assign_Handler,
+		},
+		{
+			MethodName: "GetHistory",
+			Handler:    _CalculatorService_GetHistory_Handler,
+		},
+		{
+			MethodName: "CloseSession",
+			Handler:    _CalculatorService_CloseSession_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PrimeNumberDecomposition",
+			Handler:       _CalculatorService_PrimeNumberDecomposition_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ComputeAverage",
+			Handler:       _CalculatorService_ComputeAverage_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ComputeStatistics",
+			Handler:       _CalculatorService_ComputeStatistics_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "FindMaximum",
+			Handler:       _CalculatorService_FindMaximum_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WindowedAggregate",
+			Handler:       _CalculatorService_WindowedAggregate_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "BigPrimeNumberDecomposition",
+			Handler:       _CalculatorService_BigPrimeNumberDecomposition_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListPrimes",
+			Handler:       _CalculatorService_ListPrimes_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "calculator/calculatorpb/calculator.proto",
+}
+
+// MatrixServiceClient is the client API for MatrixService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
-type CalculatorServiceClient interface {
-	Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumResponse, error)
-	PrimeNumberDecomposition(ctx context.Context, in *PrimeNumberDecompositionRequest, opts ...grpc.CallOption) (CalculatorService_PrimeNumberDecompositionClient, error)
-	ComputeAverage(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_ComputeAverageClient, error)
-	FindMaximum(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_FindMaximumClient, error)
-	// error handling
-	// this RPC will throw an exception if the sent number is negative
-	// the error being sent is of the type INVALID_ARGUMENT
-	SquareRoot(ctx context.Context, in *SquareRootRequest, opts ...grpc.CallOption) (*SquareRootResponse, error)
+type MatrixServiceClient interface {
+	Add(ctx context.Context, opts ...grpc.CallOption) (MatrixService_AddClient, error)
+	Multiply(ctx context.Context, opts ...grpc.CallOption) (MatrixService_MultiplyClient, error)
+	Transpose(ctx context.Context, opts ...grpc.CallOption) (MatrixService_TransposeClient, error)
+	// Return INVALID_ARGUMENT if the matrix is not square
+	Determinant(ctx context.Context, opts ...grpc.CallOption) (MatrixService_DeterminantClient, error)
+	// Return INVALID_ARGUMENT if the matrix is not square or is singular
+	Inverse(ctx context.Context, opts ...grpc.CallOption) (MatrixService_InverseClient, error)
+	// solves A x = b with the LU decomposition of A, the first operand. b is
+	// the second one and may have several columns, x has as many. Return
+	// INVALID_ARGUMENT if A is not square or is singular
+	Solve(ctx context.Context, opts ...grpc.CallOption) (MatrixService_SolveClient, error)
+	// rank up to the rounding errors
+	Rank(ctx context.Context, opts ...grpc.CallOption) (MatrixService_RankClient, error)
+}
+
+type matrixServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMatrixServiceClient(cc grpc.ClientConnInterface) MatrixServiceClient {
+	return &matrixServiceClient{cc}
+}
+
+func (c *matrixServiceClient) Add(ctx context.Context, opts ...grpc.CallOption) (MatrixService_AddClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_MatrixService_serviceDesc.Streams[0], "/calculator.MatrixService/Add", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &matrixServiceAddClient{stream}
+	return x, nil
+}
+
+type MatrixService_AddClient interface {
+	Send(*MatrixRow) error
+	CloseAndRecv() (*Matrix, error)
+	grpc.ClientStream
+}
+
+type matrixServiceAddClient struct {
+	grpc.ClientStream
+}
+
+func (x *matrixServiceAddClient) Send(m *MatrixRow) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *matrixServiceAddClient) CloseAndRecv() (*Matrix, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Matrix)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *matrixServiceClient) Multiply(ctx context.Context, opts ...grpc.CallOption) (MatrixService_MultiplyClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_MatrixService_serviceDesc.Streams[1], "/calculator.MatrixService/Multiply", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &matrixServiceMultiplyClient{stream}
+	return x, nil
+}
+
+type MatrixService_MultiplyClient interface {
+	Send(*MatrixRow) error
+	CloseAndRecv() (*Matrix, error)
+	grpc.ClientStream
+}
+
+type matrixServiceMultiplyClient struct {
+	grpc.ClientStream
+}
+
+func (x *matrixServiceMultiplyClient) Send(m *MatrixRow) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *matrixServiceMultiplyClient) CloseAndRecv() (*Matrix, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Matrix)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *matrixServiceClient) Transpose(ctx context.Context, opts ...grpc.CallOption) (MatrixService_TransposeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_MatrixService_serviceDesc.Streams[2], "/calculator.MatrixService/Transpose", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &matrixServiceTransposeClient{stream}
+	return x, nil
 }
 
-type calculatorServiceClient struct {
-	cc grpc.ClientConnInterface
+type MatrixService_TransposeClient interface {
+	Send(*MatrixRow) error
+	CloseAndRecv() (*Matrix, error)
+	grpc.ClientStream
 }
 
-func NewCalculatorServiceClient(cc grpc.ClientConnInterface) CalculatorServiceClient {
-	return &calculatorServiceClient{cc}
+type matrixServiceTransposeClient struct {
+	grpc.ClientStream
 }
 
-func (c *calculatorServiceClient) Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumResponse, error) {
-	out := new(SumResponse)
-	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/Sum", in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+func (x *matrixServiceTransposeClient) Send(m *MatrixRow) error {
+	return x.ClientStream.SendMsg(m)
 }
 
-func (c *calculatorServiceClient) PrimeNumberDecomposition(ctx context.Context, in *PrimeNumberDecompositionRequest, opts ...grpc.CallOption) (CalculatorService_PrimeNumberDecompositionClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[0], "/calculator.CalculatorService/PrimeNumberDecomposition", opts...)
-	if err != nil {
+func (x *matrixServiceTransposeClient) CloseAndRecv() (*Matrix, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
 		return nil, err
 	}
-	x := &calculatorServicePrimeNumberDecompositionClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
+	m := new(Matrix)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
+	return m, nil
+}
+
+func (c *matrixServiceClient) Determinant(ctx context.Context, opts ...grpc.CallOption) (MatrixService_DeterminantClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_MatrixService_serviceDesc.Streams[3], "/calculator.MatrixService/Determinant", opts...)
+	if err != nil {
 		return nil, err
 	}
+	x := &matrixServiceDeterminantClient{stream}
 	return x, nil
 }
 
-type CalculatorService_PrimeNumberDecompositionClient interface {
-	Recv() (*PrimeNumberDecompositionResponse, error)
+type MatrixService_DeterminantClient interface {
+	Send(*MatrixRow) error
+	CloseAndRecv() (*DeterminantResponse, error)
 	grpc.ClientStream
 }
 
-type calculatorServicePrimeNumberDecompositionClient struct {
+type matrixServiceDeterminantClient struct {
 	grpc.ClientStream
 }
 
-func (x *calculatorServicePrimeNumberDecompositionClient) Recv() (*PrimeNumberDecompositionResponse, error) {
-	m := new(PrimeNumberDecompositionResponse)
+func (x *matrixServiceDeterminantClient) Send(m *MatrixRow) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *matrixServiceDeterminantClient) CloseAndRecv() (*DeterminantResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(DeterminantResponse)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func (c *calculatorServiceClient) ComputeAverage(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_ComputeAverageClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[1], "/calculator.CalculatorService/ComputeAverage", opts...)
+func (c *matrixServiceClient) Inverse(ctx context.Context, opts ...grpc.CallOption) (MatrixService_InverseClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_MatrixService_serviceDesc.Streams[4], "/calculator.MatrixService/Inverse", opts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &calculatorServiceComputeAverageClient{stream}
+	x := &matrixServiceInverseClient{stream}
 	return x, nil
 }
 
-type CalculatorService_ComputeAverageClient interface {
-	Send(*ComputeAverageRequest) error
-	CloseAndRecv() (*ComputeAverageResponse, error)
+type MatrixService_InverseClient interface {
+	Send(*MatrixRow) error
+	CloseAndRecv() (*Matrix, error)
 	grpc.ClientStream
 }
 
-type calculatorServiceComputeAverageClient struct {
+type matrixServiceInverseClient struct {
 	grpc.ClientStream
 }
 
-func (x *calculatorServiceComputeAverageClient) Send(m *ComputeAverageRequest) error {
+func (x *matrixServiceInverseClient) Send(m *MatrixRow) error {
 	return x.ClientStream.SendMsg(m)
 }
 
-func (x *calculatorServiceComputeAverageClient) CloseAndRecv() (*ComputeAverageResponse, error) {
+func (x *matrixServiceInverseClient) CloseAndRecv() (*Matrix, error) {
 	if err := x.ClientStream.CloseSend(); err != nil {
 		return nil, err
 	}
-	m := new(ComputeAverageResponse)
+	m := new(Matrix)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func (c *calculatorServiceClient) FindMaximum(ctx context.Context, opts ...grpc.CallOption) (CalculatorService_FindMaximumClient, error) {
-	stream, err := c.cc.NewStream(ctx, &_CalculatorService_serviceDesc.Streams[2], "/calculator.CalculatorService/FindMaximum", opts...)
+func (c *matrixServiceClient) Solve(ctx context.Context, opts ...grpc.CallOption) (MatrixService_SolveClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_MatrixService_serviceDesc.Streams[5], "/calculator.MatrixService/Solve", opts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &calculatorServiceFindMaximumClient{stream}
+	x := &matrixServiceSolveClient{stream}
 	return x, nil
 }
 
-type CalculatorService_FindMaximumClient interface {
-	Send(*FindMaximumRequest) error
-	Recv() (*FindMaximumResponse, error)
+type MatrixService_SolveClient interface {
+	Send(*MatrixRow) error
+	CloseAndRecv() (*Matrix, error)
 	grpc.ClientStream
 }
 
-type calculatorServiceFindMaximumClient struct {
+type matrixServiceSolveClient struct {
 	grpc.ClientStream
 }
 
-func (x *calculatorServiceFindMaximumClient) Send(m *FindMaximumRequest) error {
+func (x *matrixServiceSolveClient) Send(m *MatrixRow) error {
 	return x.ClientStream.SendMsg(m)
 }
 
-func (x *calculatorServiceFindMaximumClient) Recv() (*FindMaximumResponse, error) {
-	m := new(FindMaximumResponse)
+func (x *matrixServiceSolveClient) CloseAndRecv() (*Matrix, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Matrix)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func (c *calculatorServiceClient) SquareRoot(ctx context.Context, in *SquareRootRequest, opts ...grpc.CallOption) (*SquareRootResponse, error) {
-	out := new(SquareRootResponse)
-	err := c.cc.Invoke(ctx, "/calculator.CalculatorService/SquareRoot", in, out, opts...)
+func (c *matrixServiceClient) Rank(ctx context.Context, opts ...grpc.CallOption) (MatrixService_RankClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_MatrixService_serviceDesc.Streams[6], "/calculator.MatrixService/Rank", opts...)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	x := &matrixServiceRankClient{stream}
+	return x, nil
 }
 
-// CalculatorServiceServer is the server API for CalculatorService service.
-type CalculatorServiceServer interface {
-	Sum(context.Context, *SumRequest) (*SumResponse, error)
-	PrimeNumberDecomposition(*PrimeNumberDecompositionRequest, CalculatorService_PrimeNumberDecompositionServer) error
-	ComputeAverage(CalculatorService_ComputeAverageServer) error
-	FindMaximum(CalculatorService_FindMaximumServer) error
-	// error handling
-	// this RPC will throw an exception if the sent number is negative
-	// the error being sent is of the type INVALID_ARGUMENT
-	SquareRoot(context.Context, *SquareRootRequest) (*SquareRootResponse, error)
+type MatrixService_RankClient interface {
+	Send(*MatrixRow) error
+	CloseAndRecv() (*RankResponse, error)
+	grpc.ClientStream
 }
 
-// UnimplementedCalculatorServiceServer can be embedded to have forward compatible implementations.
-type UnimplementedCalculatorServiceServer struct {
+type matrixServiceRankClient struct {
+	grpc.ClientStream
 }
 
-func (*UnimplementedCalculatorServiceServer) Sum(context.Context, *SumRequest) (*SumResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Sum not implemented")
+func (x *matrixServiceRankClient) Send(m *MatrixRow) error {
+	return x.ClientStream.SendMsg(m)
 }
-func (*UnimplementedCalculatorServiceServer) PrimeNumberDecomposition(*PrimeNumberDecompositionRequest, CalculatorService_PrimeNumberDecompositionServer) error {
-	return status.Errorf(codes.Unimplemented, "method PrimeNumberDecomposition not implemented")
+
+func (x *matrixServiceRankClient) CloseAndRecv() (*RankResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(RankResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
-func (*UnimplementedCalculatorServiceServer) ComputeAverage(CalculatorService_ComputeAverageServer) error {
-	return status.Errorf(codes.Unimplemented, "method ComputeAverage not implemented")
+
+// MatrixServiceServer is the server API for MatrixService service.
+type MatrixServiceServer interface {
+	Add(MatrixService_AddServer) error
+	Multiply(MatrixService_MultiplyServer) error
+	Transpose(MatrixService_TransposeServer) error
+	// Return INVALID_ARGUMENT if the matrix is not square
+	Determinant(MatrixService_DeterminantServer) error
+	// Return INVALID_ARGUMENT if the matrix is not square or is singular
+	Inverse(MatrixService_InverseServer) error
+	// solves A x = b with the LU decomposition of A, the first operand. b is
+	// the second one and may have several columns, x has as many. Return
+	// INVALID_ARGUMENT if A is not square or is singular
+	Solve(MatrixService_SolveServer) error
+	// rank up to the rounding errors
+	Rank(MatrixService_RankServer) error
 }
-func (*UnimplementedCalculatorServiceServer) FindMaximum(CalculatorService_FindMaximumServer) error {
-	return status.Errorf(codes.Unimplemented, "method FindMaximum not implemented")
+
+// UnimplementedMatrixServiceServer can be embedded to have forward compatible implementations.
+type UnimplementedMatrixServiceServer struct {
 }
-func (*UnimplementedCalculatorServiceServer) SquareRoot(context.Context, *SquareRootRequest) (*SquareRootResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method SquareRoot not implemented")
+
+func (*UnimplementedMatrixServiceServer) Add(MatrixService_AddServer) error {
+	return status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (*UnimplementedMatrixServiceServer) Multiply(MatrixService_MultiplyServer) error {
+	return status.Errorf(codes.Unimplemented, "method Multiply not implemented")
+}
+func (*UnimplementedMatrixServiceServer) Transpose(MatrixService_TransposeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Transpose not implemented")
+}
+func (*UnimplementedMatrixServiceServer) Determinant(MatrixService_DeterminantServer) error {
+	return status.Errorf(codes.Unimplemented, "method Determinant not implemented")
+}
+func (*UnimplementedMatrixServiceServer) Inverse(MatrixService_InverseServer) error {
+	return status.Errorf(codes.Unimplemented, "method Inverse not implemented")
+}
+func (*UnimplementedMatrixServiceServer) Solve(MatrixService_SolveServer) error {
+	return status.Errorf(codes.Unimplemented, "method Solve not implemented")
+}
+func (*UnimplementedMatrixServiceServer) Rank(MatrixService_RankServer) error {
+	return status.Errorf(codes.Unimplemented, "method Rank not implemented")
 }
 
-func RegisterCalculatorServiceServer(s *grpc.Server, srv CalculatorServiceServer) {
-	s.RegisterService(&_CalculatorService_serviceDesc, srv)
+func RegisterMatrixServiceServer(s *grpc.Server, srv MatrixServiceServer) {
+	s.RegisterService(&_MatrixService_serviceDesc, srv)
 }
 
-func _CalculatorService_Sum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SumRequest)
-	if err := dec(in); err != nil {
+func _MatrixService_Add_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MatrixServiceServer).Add(&matrixServiceAddServer{stream})
+}
+
+type MatrixService_AddServer interface {
+	SendAndClose(*Matrix) error
+	Recv() (*MatrixRow, error)
+	grpc.ServerStream
+}
+
+type matrixServiceAddServer struct {
+	grpc.ServerStream
+}
+
+func (x *matrixServiceAddServer) SendAndClose(m *Matrix) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *matrixServiceAddServer) Recv() (*MatrixRow, error) {
+	m := new(MatrixRow)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(CalculatorServiceServer).Sum(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/calculator.CalculatorService/Sum",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CalculatorServiceServer).Sum(ctx, req.(*SumRequest))
-	}
-	return interceptor(ctx, in, info, handler)
+	return m, nil
 }
 
-func _CalculatorService_PrimeNumberDecomposition_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(PrimeNumberDecompositionRequest)
-	if err := stream.RecvMsg(m); err != nil {
-		return err
-	}
-	return srv.(CalculatorServiceServer).PrimeNumberDecomposition(m, &calculatorServicePrimeNumberDecompositionServer{stream})
+func _MatrixService_Multiply_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MatrixServiceServer).Multiply(&matrixServiceMultiplyServer{stream})
 }
 
-type CalculatorService_PrimeNumberDecompositionServer interface {
-	Send(*PrimeNumberDecompositionResponse) error
+type MatrixService_MultiplyServer interface {
+	SendAndClose(*Matrix) error
+	Recv() (*MatrixRow, error)
 	grpc.ServerStream
 }
 
-type calculatorServicePrimeNumberDecompositionServer struct {
+type matrixServiceMultiplyServer struct {
 	grpc.ServerStream
 }
 
-func (x *calculatorServicePrimeNumberDecompositionServer) Send(m *PrimeNumberDecompositionResponse) error {
+func (x *matrixServiceMultiplyServer) SendAndClose(m *Matrix) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _CalculatorService_ComputeAverage_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(CalculatorServiceServer).ComputeAverage(&calculatorServiceComputeAverageServer{stream})
+func (x *matrixServiceMultiplyServer) Recv() (*MatrixRow, error) {
+	m := new(MatrixRow)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-type CalculatorService_ComputeAverageServer interface {
-	SendAndClose(*ComputeAverageResponse) error
-	Recv() (*ComputeAverageRequest, error)
+func _MatrixService_Transpose_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MatrixServiceServer).Transpose(&matrixServiceTransposeServer{stream})
+}
+
+type MatrixService_TransposeServer interface {
+	SendAndClose(*Matrix) error
+	Recv() (*MatrixRow, error)
 	grpc.ServerStream
 }
 
-type calculatorServiceComputeAverageServer struct {
+type matrixServiceTransposeServer struct {
 	grpc.ServerStream
 }
 
-func (x *calculatorServiceComputeAverageServer) SendAndClose(m *ComputeAverageResponse) error {
+func (x *matrixServiceTransposeServer) SendAndClose(m *Matrix) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func (x *calculatorServiceComputeAverageServer) Recv() (*ComputeAverageRequest, error) {
-	m := new(ComputeAverageRequest)
+func (x *matrixServiceTransposeServer) Recv() (*MatrixRow, error) {
+	m := new(MatrixRow)
 	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func _CalculatorService_FindMaximum_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(CalculatorServiceServer).FindMaximum(&calculatorServiceFindMaximumServer{stream})
+func _MatrixService_Determinant_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MatrixServiceServer).Determinant(&matrixServiceDeterminantServer{stream})
 }
 
-type CalculatorService_FindMaximumServer interface {
-	Send(*FindMaximumResponse) error
-	Recv() (*FindMaximumRequest, error)
+type MatrixService_DeterminantServer interface {
+	SendAndClose(*DeterminantResponse) error
+	Recv() (*MatrixRow, error)
 	grpc.ServerStream
 }
 
-type calculatorServiceFindMaximumServer struct {
+type matrixServiceDeterminantServer struct {
 	grpc.ServerStream
 }
 
-func (x *calculatorServiceFindMaximumServer) Send(m *FindMaximumResponse) error {
+func (x *matrixServiceDeterminantServer) SendAndClose(m *DeterminantResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func (x *calculatorServiceFindMaximumServer) Recv() (*FindMaximumRequest, error) {
-	m := new(FindMaximumRequest)
+func (x *matrixServiceDeterminantServer) Recv() (*MatrixRow, error) {
+	m := new(MatrixRow)
 	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func _CalculatorService_SquareRoot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SquareRootRequest)
-	if err := dec(in); err != nil {
+func _MatrixService_Inverse_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MatrixServiceServer).Inverse(&matrixServiceInverseServer{stream})
+}
+
+type MatrixService_InverseServer interface {
+	SendAndClose(*Matrix) error
+	Recv() (*MatrixRow, error)
+	grpc.ServerStream
+}
+
+type matrixServiceInverseServer struct {
+	grpc.ServerStream
+}
+
+func (x *matrixServiceInverseServer) SendAndClose(m *Matrix) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *matrixServiceInverseServer) Recv() (*MatrixRow, error) {
+	m := new(MatrixRow)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
-	if interceptor == nil {
-		return srv.(CalculatorServiceServer).SquareRoot(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/calculator.CalculatorService/SquareRoot",
+	return m, nil
+}
+
+func _MatrixService_Solve_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MatrixServiceServer).Solve(&matrixServiceSolveServer{stream})
+}
+
+type MatrixService_SolveServer interface {
+	SendAndClose(*Matrix) error
+	Recv() (*MatrixRow, error)
+	grpc.ServerStream
+}
+
+type matrixServiceSolveServer struct {
+	grpc.ServerStream
+}
+
+func (x *matrixServiceSolveServer) SendAndClose(m *Matrix) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *matrixServiceSolveServer) Recv() (*MatrixRow, error) {
+	m := new(MatrixRow)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
 	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CalculatorServiceServer).SquareRoot(ctx, req.(*SquareRootRequest))
+	return m, nil
+}
+
+func _MatrixService_Rank_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MatrixServiceServer).Rank(&matrixServiceRankServer{stream})
+}
+
+type MatrixService_RankServer interface {
+	SendAndClose(*RankResponse) error
+	Recv() (*MatrixRow, error)
+	grpc.ServerStream
+}
+
+type matrixServiceRankServer struct {
+	grpc.ServerStream
+}
+
+func (x *matrixServiceRankServer) SendAndClose(m *RankResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *matrixServiceRankServer) Recv() (*MatrixRow, error) {
+	m := new(MatrixRow)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
 	}
-	return interceptor(ctx, in, info, handler)
+	return m, nil
 }
 
-var _CalculatorService_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "calculator.CalculatorService",
-	HandlerType: (*CalculatorServiceServer)(nil),
-	Methods: []grpc.MethodDesc{
+var _MatrixService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "calculator.MatrixService",
+	HandlerType: (*MatrixServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
 		{
-			MethodName: "Sum",
-			Handler:    _CalculatorService_Sum_Handler,
+			StreamName:    "Add",
+			Handler:       _MatrixService_Add_Handler,
+			ClientStreams: true,
 		},
 		{
-			MethodName: "SquareRoot",
-			Handler:    _CalculatorService_SquareRoot_Handler,
+			StreamName:    "Multiply",
+			Handler:       _MatrixService_Multiply_Handler,
+			ClientStreams: true,
 		},
-	},
-	Streams: []grpc.StreamDesc{
 		{
-			StreamName:    "PrimeNumberDecomposition",
-			Handler:       _CalculatorService_PrimeNumberDecomposition_Handler,
-			ServerStreams: true,
+			StreamName:    "Transpose",
+			Handler:       _MatrixService_Transpose_Handler,
+			ClientStreams: true,
 		},
 		{
-			StreamName:    "ComputeAverage",
-			Handler:       _CalculatorService_ComputeAverage_Handler,
+			StreamName:    "Determinant",
+			Handler:       _MatrixService_Determinant_Handler,
 			ClientStreams: true,
 		},
 		{
-			StreamName:    "FindMaximum",
-			Handler:       _CalculatorService_FindMaximum_Handler,
-			ServerStreams: true,
+			StreamName:    "Inverse",
+			Handler:       _MatrixService_Inverse_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Solve",
+			Handler:       _MatrixService_Solve_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Rank",
+			Handler:       _MatrixService_Rank_Handler,
 			ClientStreams: true,
 		},
 	},
@@ -0,0 +1,360 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errOverflow       = errors.New("overflow")
+)
+
+// arith implements the operations of a mode
+type arith interface {
+	zero() Value
+	number(text string) (Value, error)
+	neg(x Value) (Value, error)
+	// binary applies one of + - * / % ^
+	binary(op rune, x, y Value) (Value, error)
+	cmp(x, y Value) int
+	// function applies sqrt, log, sin or cos
+	function(name string, x Value) (Value, error)
+}
+
+type floatArith struct{}
+
+func floatValue(f float64) (Value, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return Value{}, errOverflow
+	}
+	return Value{mode: Float64, f: f}, nil
+}
+
+func (floatArith) zero() Value {
+	return Value{mode: Float64}
+}
+
+func (floatArith) number(text string) (Value, error) {
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return Value{}, fmt.Errorf("%v is out of the float64 range", text)
+	}
+	return floatValue(f)
+}
+
+func (floatArith) neg(x Value) (Value, error) {
+	return floatValue(-x.f)
+}
+
+func (floatArith) binary(op rune, x, y Value) (Value, error) {
+	switch op {
+	case '+':
+		return floatValue(x.f + y.f)
+	case '-':
+		return floatValue(x.f - y.f)
+	case '*':
+		return floatValue(x.f * y.f)
+	case '/':
+		if y.f == 0 {
+			return Value{}, errDivisionByZero
+		}
+		return floatValue(x.f / y.f)
+	case '%':
+		if y.f == 0 {
+			return Value{}, errDivisionByZero
+		}
+		return floatValue(math.Mod(x.f, y.f))
+	default:
+		if x.f < 0 && y.f != math.Trunc(y.f) {
+			return Value{}, errors.New("fractional power of a negative number")
+		}
+		if x.f == 0 && y.f < 0 {
+			return Value{}, errDivisionByZero
+		}
+		return floatValue(math.Pow(x.f, y.f))
+	}
+}
+
+func (floatArith) cmp(x, y Value) int {
+	switch {
+	case x.f < y.f:
+		return -1
+	case x.f > y.f:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (floatArith) function(name string, x Value) (Value, error) {
+	switch name {
+	case "sqrt":
+		if x.f < 0 {
+			return Value{}, errors.New("square root of a negative number")
+		}
+		return floatValue(math.Sqrt(x.f))
+	case "log":
+		if x.f <= 0 {
+			return Value{}, errors.New("logarithm of a non-positive number")
+		}
+		return floatValue(math.Log(x.f))
+	case "sin":
+		return floatValue(math.Sin(x.f))
+	default:
+		return floatValue(math.Cos(x.f))
+	}
+}
+
+type intArith struct{}
+
+// maxInt64Sqrt is the largest integer whose square fits in an int64
+const maxInt64Sqrt = 3037000499
+
+func intValue(i int64) Value {
+	return Value{mode: Int64, i: i}
+}
+
+func (intArith) zero() Value {
+	return intValue(0)
+}
+
+func (intArith) number(text string) (Value, error) {
+	i, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+			return Value{}, fmt.Errorf("%v is out of the int64 range", text)
+		}
+		return Value{}, fmt.Errorf("%v is not an integer", text)
+	}
+	return intValue(i), nil
+}
+
+func (intArith) neg(x Value) (Value, error) {
+	if x.i == math.MinInt64 {
+		return Value{}, errOverflow
+	}
+	return intValue(-x.i), nil
+}
+
+func (a intArith) binary(op rune, x, y Value) (Value, error) {
+	switch op {
+	case '+':
+		if (y.i > 0 && x.i > math.MaxInt64-y.i) || (y.i < 0 && x.i < math.MinInt64-y.i) {
+			return Value{}, errOverflow
+		}
+		return intValue(x.i + y.i), nil
+	case '-':
+		if (y.i < 0 && x.i > math.MaxInt64+y.i) || (y.i > 0 && x.i < math.MinInt64+y.i) {
+			return Value{}, errOverflow
+		}
+		return intValue(x.i - y.i), nil
+	case '*':
+		return a.mul(x.i, y.i)
+	case '/':
+		if y.i == 0 {
+			return Value{}, errDivisionByZero
+		}
+		if x.i == math.MinInt64 && y.i == -1 {
+			return Value{}, errOverflow
+		}
+		return intValue(x.i / y.i), nil
+	case '%':
+		if y.i == 0 {
+			return Value{}, errDivisionByZero
+		}
+		return intValue(x.i % y.i), nil
+	default:
+		if y.i < 0 {
+			return Value{}, errors.New("negative exponent in int64 mode")
+		}
+		result := intValue(1)
+		base := x
+		var err error
+		for e := y.i; e > 0; e >>= 1 {
+			if e&1 == 1 {
+				if result, err = a.mul(result.i, base.i); err != nil {
+					return Value{}, err
+				}
+			}
+			if e > 1 {
+				if base, err = a.mul(base.i, base.i); err != nil {
+					return Value{}, err
+				}
+			}
+		}
+		return result, nil
+	}
+}
+
+func (intArith) mul(x, y int64) (Value, error) {
+	if x != 0 && y != 0 {
+		p := x * y
+		if p/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+			return Value{}, errOverflow
+		}
+	}
+	return intValue(x * y), nil
+}
+
+func (intArith) cmp(x, y Value) int {
+	switch {
+	case x.i < y.i:
+		return -1
+	case x.i > y.i:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (intArith) function(name string, x Value) (Value, error) {
+	if name != "sqrt" {
+		return Value{}, fmt.Errorf("%v is not available in int64 mode", name)
+	}
+	if x.i < 0 {
+		return Value{}, errors.New("square root of a negative number")
+	}
+	r := int64(math.Sqrt(float64(x.i)))
+	// the float64 square root can be off by one for large numbers
+	if r > maxInt64Sqrt {
+		r = maxInt64Sqrt
+	}
+	for r*r > x.i {
+		r--
+	}
+	for r < maxInt64Sqrt && (r+1)*(r+1) <= x.i {
+		r++
+	}
+	if r*r != x.i {
+		return Value{}, fmt.Errorf("sqrt(%v) is not an integer", x.i)
+	}
+	return intValue(r), nil
+}
+
+type ratArith struct{}
+
+// maxRatExponent bounds the exponent of the literals in BigRat mode
+const maxRatExponent = 1000
+
+func ratValue(r *big.Rat) (Value, error) {
+	if r.Num().BitLen() > MaxRatBits || r.Denom().BitLen() > MaxRatBits {
+		return Value{}, errOverflow
+	}
+	return Value{mode: BigRat, r: r}, nil
+}
+
+func (ratArith) zero() Value {
+	return Value{mode: BigRat, r: new(big.Rat)}
+}
+
+func (ratArith) number(text string) (Value, error) {
+	// big.Rat expands the exponent of the literals like 1e999999999
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		if exp, err := strconv.Atoi(text[i+1:]); err != nil || exp > maxRatExponent || exp < -maxRatExponent {
+			return Value{}, fmt.Errorf("%v is out of range", text)
+		}
+	}
+	r, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return Value{}, fmt.Errorf("%v is not a number", text)
+	}
+	return ratValue(r)
+}
+
+func (ratArith) neg(x Value) (Value, error) {
+	return ratValue(new(big.Rat).Neg(x.r))
+}
+
+func (ratArith) binary(op rune, x, y Value) (Value, error) {
+	switch op {
+	case '+':
+		return ratValue(new(big.Rat).Add(x.r, y.r))
+	case '-':
+		return ratValue(new(big.Rat).Sub(x.r, y.r))
+	case '*':
+		return ratValue(new(big.Rat).Mul(x.r, y.r))
+	case '/':
+		if y.r.Sign() == 0 {
+			return Value{}, errDivisionByZero
+		}
+		return ratValue(new(big.Rat).Quo(x.r, y.r))
+	case '%':
+		if y.r.Sign() == 0 {
+			return Value{}, errDivisionByZero
+		}
+		// x - y*trunc(x/y), which has the sign of x like in int64 mode
+		q := new(big.Rat).Quo(x.r, y.r)
+		trunc := new(big.Int).Quo(q.Num(), q.Denom())
+		q.SetInt(trunc)
+		return ratValue(q.Sub(x.r, q.Mul(q, y.r)))
+	default:
+		return ratPow(x.r, y.r)
+	}
+}
+
+func ratPow(x, y *big.Rat) (Value, error) {
+	if !y.IsInt() {
+		return Value{}, errors.New("fractional exponent in big rational mode")
+	}
+	e := y.Num()
+	if x.Sign() == 0 {
+		if e.Sign() < 0 {
+			return Value{}, errDivisionByZero
+		}
+		if e.Sign() == 0 {
+			return ratValue(big.NewRat(1, 1))
+		}
+		return ratValue(new(big.Rat))
+	}
+
+	num, den := new(big.Int).Abs(x.Num()), x.Denom()
+	if num.Cmp(den) == 0 {
+		// ±1
+		if x.Sign() < 0 && e.Bit(0) == 1 {
+			return ratValue(big.NewRat(-1, 1))
+		}
+		return ratValue(big.NewRat(1, 1))
+	}
+	// the numerator or the denominator has at least |e| bits
+	abs := new(big.Int).Abs(e)
+	if !abs.IsInt64() || abs.Int64() > MaxRatBits {
+		return Value{}, errOverflow
+	}
+	bits := num.BitLen()
+	if den.BitLen() > bits {
+		bits = den.BitLen()
+	}
+	if int64(bits-1)*abs.Int64() > MaxRatBits {
+		return Value{}, errOverflow
+	}
+
+	result := new(big.Rat).SetFrac(new(big.Int).Exp(x.Num(), abs, nil), new(big.Int).Exp(den, abs, nil))
+	if e.Sign() < 0 {
+		result.Inv(result)
+	}
+	return ratValue(result)
+}
+
+func (ratArith) cmp(x, y Value) int {
+	return x.r.Cmp(y.r)
+}
+
+func (ratArith) function(name string, x Value) (Value, error) {
+	if name != "sqrt" {
+		return Value{}, fmt.Errorf("%v is not available in big rational mode", name)
+	}
+	if x.r.Sign() < 0 {
+		return Value{}, errors.New("square root of a negative number")
+	}
+	num, den := new(big.Int).Sqrt(x.r.Num()), new(big.Int).Sqrt(x.r.Denom())
+	r := new(big.Rat).SetFrac(num, den)
+	if new(big.Rat).Mul(r, r).Cmp(x.r) != 0 {
+		return Value{}, fmt.Errorf("sqrt(%v) is irrational, use the float64 mode", x.r.RatString())
+	}
+	return ratValue(r)
+}
@@ -0,0 +1,170 @@
+package expr
+
+import (
+	"math/big"
+	"strconv"
+)
+
+// Mode selects the numbers an expression is evaluated with
+type Mode int
+
+const (
+	// Float64 follows IEEE 754, the results must stay finite
+	Float64 Mode = iota
+	// Int64 fails on overflow, division truncates towards zero
+	Int64
+	// BigRat is exact, the functions with irrational results are only
+	// available when the result is rational
+	BigRat
+)
+
+// MaxRatBits bounds the size of the numerators and denominators in
+// BigRat mode
+const MaxRatBits = 1 << 16
+
+func (m Mode) String() string {
+	switch m {
+	case Float64:
+		return "float64"
+	case Int64:
+		return "int64"
+	case BigRat:
+		return "big rational"
+	default:
+		return "unknown"
+	}
+}
+
+// Value is the result of an evaluation in a given mode
+type Value struct {
+	mode Mode
+	f    float64
+	i    int64
+	r    *big.Rat
+}
+
+// Mode returns the mode the value was computed in
+func (v Value) Mode() Mode {
+	return v.mode
+}
+
+// String formats the value exactly: an integer, the shortest
+// representation of a float64 or a fraction "p/q"
+func (v Value) String() string {
+	switch v.mode {
+	case Int64:
+		return strconv.FormatInt(v.i, 10)
+	case BigRat:
+		return v.r.RatString()
+	default:
+		return strconv.FormatFloat(v.f, 'g', -1, 64)
+	}
+}
+
+// Float64 returns the closest float64 to the value
+func (v Value) Float64() float64 {
+	switch v.mode {
+	case Int64:
+		return float64(v.i)
+	case BigRat:
+		f, _ := v.r.Float64()
+		return f
+	default:
+		return v.f
+	}
+}
+
+// Eval evaluates the expression in the mode, the variables must have been
+// computed in the same mode
+func (e *Expr) Eval(mode Mode, vars map[string]Value) (Value, error) {
+	var a arith
+	switch mode {
+	case Float64:
+		a = floatArith{}
+	case Int64:
+		a = intArith{}
+	case BigRat:
+		a = ratArith{}
+	default:
+		return Value{}, errorf(1, "unknown mode %v", mode)
+	}
+
+	ev := &evaluator{arith: a, mode: mode, vars: vars}
+	return ev.eval(e.root)
+}
+
+type evaluator struct {
+	arith arith
+	mode  Mode
+	vars  map[string]Value
+}
+
+func (ev *evaluator) eval(n node) (Value, error) {
+	var v Value
+	var err error
+
+	switch n := n.(type) {
+	case *numberNode:
+		v, err = ev.arith.number(n.text)
+	case *varNode:
+		var ok bool
+		if v, ok = ev.vars[n.name]; !ok {
+			return Value{}, errorf(n.pos, "unknown variable %q", n.name)
+		}
+		if v.mode != ev.mode {
+			return Value{}, errorf(n.pos, "variable %q holds a %v, not a %v", n.name, v.mode, ev.mode)
+		}
+	case *unaryNode:
+		var x Value
+		if x, err = ev.eval(n.x); err != nil {
+			return Value{}, err
+		}
+		v, err = ev.arith.neg(x)
+	case *binaryNode:
+		var x, y Value
+		if x, err = ev.eval(n.x); err != nil {
+			return Value{}, err
+		}
+		if y, err = ev.eval(n.y); err != nil {
+			return Value{}, err
+		}
+		v, err = ev.arith.binary(n.op, x, y)
+	case *callNode:
+		args := make([]Value, len(n.args))
+		for i, arg := range n.args {
+			if args[i], err = ev.eval(arg); err != nil {
+				return Value{}, err
+			}
+		}
+		v, err = ev.call(n.name, args)
+	}
+
+	if err != nil {
+		if _, ok := err.(*Error); ok {
+			return Value{}, err
+		}
+		return Value{}, &Error{Pos: n.position(), Msg: err.Error()}
+	}
+	return v, nil
+}
+
+func (ev *evaluator) call(name string, args []Value) (Value, error) {
+	switch name {
+	case "min", "max":
+		result := args[0]
+		for _, arg := range args[1:] {
+			c := ev.arith.cmp(arg, result)
+			if (name == "min" && c < 0) || (name == "max" && c > 0) {
+				result = arg
+			}
+		}
+		return result, nil
+	case "abs":
+		if ev.arith.cmp(args[0], ev.arith.zero()) < 0 {
+			return ev.arith.neg(args[0])
+		}
+		return args[0], nil
+	default:
+		return ev.arith.function(name, args[0])
+	}
+}
@@ -0,0 +1,92 @@
+package expr_test
+
+import (
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/expr"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		s    string
+		mode expr.Mode
+		want string
+	}{
+		{"1 + 2 * 3", expr.Float64, "7"},
+		{"max(2, -3) ^ 2 + sqrt(16) % 3", expr.Float64, "5"},
+		// ^ binds tighter than unary minus and is right-associative
+		{"-2 ^ 2", expr.Float64, "-4"},
+		{"2 ^ 3 ^ 2", expr.Float64, "512"},
+		{"7 / 2", expr.Float64, "3.5"},
+		{"7 / 2", expr.Int64, "3"},
+		{"-7 / 2", expr.Int64, "-3"},
+		{"-7 % 3", expr.Int64, "-1"},
+		{"7 / 2", expr.BigRat, "7/2"},
+		{"1 / 3 + 1 / 6", expr.BigRat, "1/2"},
+		{"sqrt(9 / 4)", expr.BigRat, "3/2"},
+		{"0.1 + 0.2", expr.BigRat, "3/10"},
+		{"abs(-3) + min(4, 1, 2)", expr.Int64, "4"},
+		{"x * 2 + y", expr.Int64, "13"},
+	}
+	for _, tt := range tests {
+		vars := map[string]expr.Value{
+			"x": eval(t, "5", tt.mode),
+			"y": eval(t, "3", tt.mode),
+		}
+		e, err := expr.Parse(tt.s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.s, err)
+			continue
+		}
+		v, err := e.Eval(tt.mode, vars)
+		if err != nil || v.String() != tt.want || v.Mode() != tt.mode {
+			t.Errorf("Eval(%q, %v) = %v in %v, %v, want %v", tt.s, tt.mode, v, v.Mode(), err, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		s    string
+		mode expr.Mode
+		pos  int
+	}{
+		{"1 + x", expr.Float64, 5},
+		{"1 + 1 / 0", expr.Int64, 7},
+		{"1 + 1 / 0", expr.BigRat, 7},
+		{"1 + 2 ^ 70", expr.Int64, 7},
+		{"sqrt(-1)", expr.Float64, 1},
+		{"sqrt(2)", expr.BigRat, 1},
+		{"9223372036854775807 + 1", expr.Int64, 21},
+	}
+	for _, tt := range tests {
+		e, err := expr.Parse(tt.s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.s, err)
+			continue
+		}
+		_, err = e.Eval(tt.mode, nil)
+		if e, ok := err.(*expr.Error); !ok || e.Pos != tt.pos {
+			t.Errorf("Eval(%q, %v) error = %v, want one at position %d", tt.s, tt.mode, err, tt.pos)
+		}
+	}
+
+	three := eval(t, "3", expr.Int64)
+	e, _ := expr.Parse("2 * y")
+	if _, err := e.Eval(expr.Float64, map[string]expr.Value{"y": three}); err == nil {
+		t.Errorf("Eval with a variable of another mode succeeded")
+	}
+}
+
+func eval(t *testing.T, s string, mode expr.Mode) expr.Value {
+	t.Helper()
+	e, err := expr.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := e.Eval(mode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
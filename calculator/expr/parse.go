@@ -0,0 +1,367 @@
+// Package expr parses and evaluates infix arithmetic expressions such as
+// "max(2, -3) ^ 2 + sqrt(16) % 3".
+package expr
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// MaxLength bounds the length of the expressions Parse accepts, in bytes
+const MaxLength = 10000
+
+// maxDepth bounds the nesting of the expressions, to keep the recursion of
+// the parser and the evaluator shallow
+const maxDepth = 200
+
+// Error is a parse or evaluation error. Pos is the 1-based position of
+// the character it refers to.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+func errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// arities maps the functions to their number of arguments, -1 for any
+// number but at least one
+var arities = map[string]int{
+	"sqrt": 1,
+	"abs":  1,
+	"log":  1,
+	"sin":  1,
+	"cos":  1,
+	"min":  -1,
+	"max":  -1,
+}
+
+// IsFunction reports whether name is one of the functions of the
+// expressions, which cannot be used as a variable
+func IsFunction(name string) bool {
+	_, ok := arities[name]
+	return ok
+}
+
+// Expr is a parsed expression, it can be evaluated many times
+type Expr struct {
+	root node
+	text string
+}
+
+// String returns the text the expression was parsed from
+func (e *Expr) String() string {
+	return e.text
+}
+
+type node interface {
+	position() int
+}
+
+type numberNode struct {
+	pos  int
+	text string
+}
+
+type varNode struct {
+	pos  int
+	name string
+}
+
+type unaryNode struct {
+	pos int
+	op  rune
+	x   node
+}
+
+type binaryNode struct {
+	pos  int
+	op   rune
+	x, y node
+}
+
+type callNode struct {
+	pos  int
+	name string
+	args []node
+}
+
+func (n *numberNode) position() int { return n.pos }
+func (n *varNode) position() int    { return n.pos }
+func (n *unaryNode) position() int  { return n.pos }
+func (n *binaryNode) position() int { return n.pos }
+func (n *callNode) position() int   { return n.pos }
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	pos  int
+	text string
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lex splits the expression into tokens, the last one is tokenEOF
+func lex(s string) ([]token, error) {
+	runes := []rune(s)
+	var tokens []token
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case isDigit(r) || r == '.':
+			for i < len(runes) && isDigit(runes[i]) {
+				i++
+			}
+			if i < len(runes) && runes[i] == '.' {
+				i++
+				for i < len(runes) && isDigit(runes[i]) {
+					i++
+				}
+			}
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && isDigit(runes[j]) {
+					for i = j; i < len(runes) && isDigit(runes[i]); i++ {
+					}
+				}
+			}
+			text := string(runes[start:i])
+			if text == "." {
+				return nil, errorf(start+1, "unexpected %q", text)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, pos: start + 1, text: text})
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || isDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, pos: start + 1, text: string(runes[start:i])})
+		case r == '+' || r == '-' || r == '*' || r == '/' || r == '^' || r == '%' || r == '(' || r == ')' || r == ',':
+			i++
+			tokens = append(tokens, token{kind: tokenOp, pos: start + 1, text: string(r)})
+		default:
+			return nil, errorf(start+1, "unexpected character %q", r)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes) + 1}), nil
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// Parse parses an expression made of numbers, variables, the operators
+// + - * / % ^, parentheses and the functions sqrt, abs, log, sin, cos, min
+// and max. ^ binds tighter than unary minus and is right-associative.
+func Parse(s string) (*Expr, error) {
+	if len(s) > MaxLength {
+		return nil, errorf(MaxLength+1, "expression longer than %d characters", MaxLength)
+	}
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorf(t.pos, "unexpected %v", t)
+	}
+	return &Expr{root: root, text: s}, nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// isOp reports whether the next token is one of the operators
+func (p *parser) isOp(ops string) bool {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == string(op) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		t := p.peek()
+		return errorf(t.pos, "expected %q, found %v", op, t)
+	}
+	p.advance()
+	return nil
+}
+
+// sum := product (("+" | "-") product)*
+func (p *parser) sum() (node, error) {
+	x, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+-") {
+		t := p.advance()
+		y, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: t.pos, op: rune(t.text[0]), x: x, y: y}
+	}
+	return x, nil
+}
+
+// product := unary (("*" | "/" | "%") unary)*
+func (p *parser) product() (node, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*/%") {
+		t := p.advance()
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{pos: t.pos, op: rune(t.text[0]), x: x, y: y}
+	}
+	return x, nil
+}
+
+// unary := ("-" | "+") unary | power
+func (p *parser) unary() (node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDepth {
+		return nil, errorf(p.peek().pos, "expression nested deeper than %d levels", maxDepth)
+	}
+
+	if p.isOp("+-") {
+		t := p.advance()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if t.text == "+" {
+			return x, nil
+		}
+		return &unaryNode{pos: t.pos, op: '-', x: x}, nil
+	}
+	return p.power()
+}
+
+// power := primary ("^" unary)?
+func (p *parser) power() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("^") {
+		return x, nil
+	}
+	t := p.advance()
+	y, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return &binaryNode{pos: t.pos, op: '^', x: x, y: y}, nil
+}
+
+// primary := number | variable | function "(" sum ("," sum)* ")" | "(" sum ")"
+func (p *parser) primary() (node, error) {
+	t := p.advance()
+	switch {
+	case t.kind == tokenNumber:
+		return &numberNode{pos: t.pos, text: t.text}, nil
+	case t.kind == tokenIdent && p.isOp("("):
+		return p.call(t)
+	case t.kind == tokenIdent:
+		return &varNode{pos: t.pos, name: t.text}, nil
+	case t.kind == tokenOp && t.text == "(":
+		x, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	default:
+		return nil, errorf(t.pos, "unexpected %v", t)
+	}
+}
+
+func (p *parser) call(name token) (node, error) {
+	arity, ok := arities[name.text]
+	if !ok {
+		return nil, errorf(name.pos, "unknown function %q", name.text)
+	}
+	p.advance()
+
+	n := &callNode{pos: name.pos, name: name.text}
+	for {
+		arg, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		n.args = append(n.args, arg)
+		if !p.isOp(",") {
+			break
+		}
+		p.advance()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if arity >= 0 && len(n.args) != arity {
+		return nil, errorf(name.pos, "%v takes %d argument(s), got %d", name.text, arity, len(n.args))
+	}
+	return n, nil
+}
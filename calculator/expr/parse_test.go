@@ -0,0 +1,62 @@
+package expr_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/expr"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		s   string
+		pos int
+		msg string
+	}{
+		{"", 1, "unexpected end of expression"},
+		{"1 +", 4, "unexpected end of expression"},
+		{"3 4", 3, `unexpected "4"`},
+		{"1 + 2)", 6, `unexpected ")"`},
+		{"2 * (3 + 4", 11, `expected ")", found end of expression`},
+		{"1 $ 2", 3, `unexpected character '$'`},
+		{". + 1", 1, `unexpected "."`},
+		// positions count characters, not bytes
+		{"é + $", 5, `unexpected character '$'`},
+		{"foo(1)", 1, `unknown function "foo"`},
+		{"1 + sqrt(1, 2)", 5, "sqrt takes 1 argument(s), got 2"},
+		{"max()", 5, `unexpected ")"`},
+		{"min(1 2)", 7, `expected ")", found "2"`},
+		{strings.Repeat("(", 300) + "1" + strings.Repeat(")", 300), 201, "expression nested deeper than 200 levels"},
+		{strings.Repeat("-", 300) + "1", 201, "expression nested deeper than 200 levels"},
+		{strings.Repeat("1", expr.MaxLength+1), expr.MaxLength + 1, "expression longer than 10000 characters"},
+	}
+	for _, tt := range tests {
+		_, err := expr.Parse(tt.s)
+		e, ok := err.(*expr.Error)
+		if !ok || e.Pos != tt.pos || e.Msg != tt.msg {
+			t.Errorf("Parse(%.20q) error = %v, want position %d: %v", tt.s, err, tt.pos, tt.msg)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{
+		"1",
+		"  1.5e-3 ",
+		".5",
+		"x_1 + y",
+		"max(2, -3) ^ 2 + sqrt(16) % 3",
+		"--1",
+		"(((1)))",
+		strings.Repeat("(", 150) + "1" + strings.Repeat(")", 150),
+	} {
+		e, err := expr.Parse(s)
+		if err != nil {
+			t.Errorf("Parse(%.20q): %v", s, err)
+			continue
+		}
+		if e.String() != s {
+			t.Errorf("Parse(%.20q).String() = %.20q", s, e.String())
+		}
+	}
+}
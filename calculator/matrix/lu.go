@@ -0,0 +1,182 @@
+package matrix
+
+import "math"
+
+// LU is the decomposition P A = L U of a square matrix A, with P a
+// permutation, L lower triangular with a unit diagonal and U upper
+// triangular. It is computed by Gaussian elimination with partial
+// pivoting.
+type LU struct {
+	// lu holds L below the diagonal and U on and above it
+	lu *Matrix
+	// perm maps the rows of lu to the rows of A
+	perm []int
+	// sign is the sign of the permutation, for the determinant
+	sign     float64
+	singular bool
+}
+
+// Decompose returns the LU decomposition of a, or ErrNotSquare
+func Decompose(a *Matrix) (*LU, error) {
+	if a.rows != a.cols {
+		return nil, ErrNotSquare
+	}
+	n := a.rows
+	f := &LU{lu: a.clone(), perm: make([]int, n), sign: 1}
+	for i := range f.perm {
+		f.perm[i] = i
+	}
+	tolerance := a.tolerance()
+
+	for k := 0; k < n; k++ {
+		// the largest pivot bounds the multipliers by 1
+		p := k
+		for i := k + 1; i < n; i++ {
+			if math.Abs(f.lu.At(i, k)) > math.Abs(f.lu.At(p, k)) {
+				p = i
+			}
+		}
+		if p != k {
+			rp, rk := f.lu.row(p), f.lu.row(k)
+			for j := range rk {
+				rp[j], rk[j] = rk[j], rp[j]
+			}
+			f.perm[p], f.perm[k] = f.perm[k], f.perm[p]
+			f.sign = -f.sign
+		}
+
+		pivot := f.lu.At(k, k)
+		if math.Abs(pivot) <= tolerance {
+			f.singular = true
+		}
+		if pivot == 0 {
+			// the column is already eliminated
+			continue
+		}
+		rk := f.lu.row(k)
+		for i := k + 1; i < n; i++ {
+			ri := f.lu.row(i)
+			ri[k] /= pivot
+			if l := ri[k]; l != 0 {
+				for j := k + 1; j < n; j++ {
+					ri[j] -= l * rk[j]
+				}
+			}
+		}
+	}
+	return f, nil
+}
+
+// Det returns the determinant of the decomposed matrix
+func (f *LU) Det() float64 {
+	det := f.sign
+	for i := 0; i < f.lu.rows; i++ {
+		det *= f.lu.At(i, i)
+	}
+	return det
+}
+
+// Solve returns x such that A x = b, b may have several columns. It
+// returns ErrDimensions if b does not have as many rows as A, and
+// ErrSingular if A is singular.
+func (f *LU) Solve(b *Matrix) (*Matrix, error) {
+	n := f.lu.rows
+	if b.rows != n {
+		return nil, ErrDimensions
+	}
+	if f.singular {
+		return nil, ErrSingular
+	}
+
+	x := New(n, b.cols)
+	for i, p := range f.perm {
+		copy(x.row(i), b.row(p))
+	}
+	// L y = P b, then U x = y, on all the columns at once
+	for i := 0; i < n; i++ {
+		xi := x.row(i)
+		for k := 0; k < i; k++ {
+			if l := f.lu.At(i, k); l != 0 {
+				for j, v := range x.row(k) {
+					xi[j] -= l * v
+				}
+			}
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		xi := x.row(i)
+		for k := i + 1; k < n; k++ {
+			if u := f.lu.At(i, k); u != 0 {
+				for j, v := range x.row(k) {
+					xi[j] -= u * v
+				}
+			}
+		}
+		pivot := f.lu.At(i, i)
+		for j := range xi {
+			xi[j] /= pivot
+		}
+	}
+	return x, nil
+}
+
+// Det returns the determinant of a, or ErrNotSquare
+func Det(a *Matrix) (float64, error) {
+	f, err := Decompose(a)
+	if err != nil {
+		return 0, err
+	}
+	return f.Det(), nil
+}
+
+// Inverse returns the inverse of a, ErrNotSquare or ErrSingular
+func Inverse(a *Matrix) (*Matrix, error) {
+	f, err := Decompose(a)
+	if err != nil {
+		return nil, err
+	}
+	return f.Solve(Identity(a.rows))
+}
+
+// Solve returns x such that a x = b, see LU.Solve
+func Solve(a, b *Matrix) (*Matrix, error) {
+	f, err := Decompose(a)
+	if err != nil {
+		return nil, err
+	}
+	return f.Solve(b)
+}
+
+// Rank returns the rank of a, the number of pivots of its row echelon
+// form above the rounding errors
+func Rank(a *Matrix) int {
+	m := a.clone()
+	tolerance := a.tolerance()
+	rank := 0
+	for c := 0; c < m.cols && rank < m.rows; c++ {
+		p := rank
+		for i := rank + 1; i < m.rows; i++ {
+			if math.Abs(m.At(i, c)) > math.Abs(m.At(p, c)) {
+				p = i
+			}
+		}
+		if math.Abs(m.At(p, c)) <= tolerance {
+			continue
+		}
+		rp, rr := m.row(p), m.row(rank)
+		for j := range rr {
+			rp[j], rr[j] = rr[j], rp[j]
+		}
+
+		for i := rank + 1; i < m.rows; i++ {
+			ri := m.row(i)
+			if l := ri[c] / rr[c]; l != 0 {
+				for j := c; j < m.cols; j++ {
+					ri[j] -= l * rr[j]
+				}
+			}
+		}
+		rank++
+	}
+	return rank
+}
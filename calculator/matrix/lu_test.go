@@ -0,0 +1,136 @@
+package matrix_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/matrix"
+)
+
+// tolerance is the absolute error allowed on the results of the tests,
+// whose values are of the order of 1
+const tolerance = 1e-9
+
+func fromRows(t *testing.T, rows ...[]float64) *matrix.Matrix {
+	t.Helper()
+	var values []float64
+	for _, row := range rows {
+		values = append(values, row...)
+	}
+	m, err := matrix.FromValues(len(rows), len(rows[0]), values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func equalMatrix(a, b *matrix.Matrix) bool {
+	if a.Rows() != b.Rows() || a.Cols() != b.Cols() {
+		return false
+	}
+	for i, v := range a.Values() {
+		if math.Abs(v-b.Values()[i]) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDet(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]float64
+		want float64
+	}{
+		{"1x1", [][]float64{{-3}}, -3},
+		{"2x2", [][]float64{{1, 2}, {3, 4}}, -2},
+		{"3x3", [][]float64{{6, 1, 1}, {4, -2, 5}, {2, 8, 7}}, -306},
+		{"permutation", [][]float64{{0, 1, 0}, {1, 0, 0}, {0, 0, 1}}, -1},
+		{"zero pivot", [][]float64{{0, 2}, {3, 0}}, -6},
+		{"triangular", [][]float64{{2, 5, 7}, {0, 3, 1}, {0, 0, -4}}, -24},
+		{"singular", [][]float64{{1, 2}, {2, 4}}, 0},
+		{"rank 2", [][]float64{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}, 0},
+	}
+	for _, tt := range tests {
+		det, err := matrix.Det(fromRows(t, tt.rows...))
+		if err != nil || math.Abs(det-tt.want) > tolerance {
+			t.Errorf("%v: Det = %v, %v, want %v", tt.name, det, err, tt.want)
+		}
+	}
+
+	if _, err := matrix.Det(matrix.New(2, 3)); err != matrix.ErrNotSquare {
+		t.Errorf("Det(2x3) error = %v, want ErrNotSquare", err)
+	}
+}
+
+func TestInverse(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]float64
+		want [][]float64
+		err  error
+	}{
+		{"2x2", [][]float64{{4, 7}, {2, 6}}, [][]float64{{0.6, -0.7}, {-0.2, 0.4}}, nil},
+		{"diagonal", [][]float64{{2, 0, 0}, {0, 4, 0}, {0, 0, 8}}, [][]float64{{0.5, 0, 0}, {0, 0.25, 0}, {0, 0, 0.125}}, nil},
+		{"3x3", [][]float64{{1, 2, 3}, {0, 1, 4}, {5, 6, 0}}, [][]float64{{-24, 18, 5}, {20, -15, -4}, {-5, 4, 1}}, nil},
+		{"singular", [][]float64{{1, 2}, {2, 4}}, nil, matrix.ErrSingular},
+		{"rank 2", [][]float64{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}, nil, matrix.ErrSingular},
+		{"zero", [][]float64{{0, 0}, {0, 0}}, nil, matrix.ErrSingular},
+	}
+	for _, tt := range tests {
+		a := fromRows(t, tt.rows...)
+		inverse, err := matrix.Inverse(a)
+		if err != tt.err {
+			t.Errorf("%v: Inverse error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if want := fromRows(t, tt.want...); !equalMatrix(inverse, want) {
+			t.Errorf("%v: Inverse = %v, want %v", tt.name, inverse.Values(), want.Values())
+		}
+		product, _ := matrix.Mul(a, inverse)
+		if !equalMatrix(product, matrix.Identity(a.Rows())) {
+			t.Errorf("%v: A * Inverse(A) = %v, want the identity", tt.name, product.Values())
+		}
+	}
+
+	if _, err := matrix.Inverse(matrix.New(3, 2)); err != matrix.ErrNotSquare {
+		t.Errorf("Inverse(3x2) error = %v, want ErrNotSquare", err)
+	}
+}
+
+func TestSolve(t *testing.T) {
+	a := fromRows(t, []float64{2, 1, -1}, []float64{-3, -1, 2}, []float64{-2, 1, 2})
+	b := fromRows(t, []float64{8, 0}, []float64{-11, -1}, []float64{-3, -7})
+	want := fromRows(t, []float64{2, 4}, []float64{3, -5}, []float64{-1, 3})
+
+	x, err := matrix.Solve(a, b)
+	if err != nil || !equalMatrix(x, want) {
+		t.Errorf("Solve = %v, %v, want %v", x.Values(), err, want.Values())
+	}
+	if _, err := matrix.Solve(a, matrix.New(2, 1)); err != matrix.ErrDimensions {
+		t.Errorf("Solve with 2 rows error = %v, want ErrDimensions", err)
+	}
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]float64
+		want int
+	}{
+		{"zero", [][]float64{{0, 0}, {0, 0}}, 0},
+		{"full", [][]float64{{1, 2}, {3, 4}}, 2},
+		{"rank 2", [][]float64{{1, 2, 3}, {4, 5, 6}, {7, 8, 9}}, 2},
+		{"rank 1", [][]float64{{1, 2, 3, 4}, {2, 4, 6, 8}}, 1},
+		{"wide", [][]float64{{1, 0, 2}, {0, 1, 3}}, 2},
+		{"tall", [][]float64{{1, 2}, {2, 4}, {0, 1}}, 2},
+	}
+	for _, tt := range tests {
+		if got := matrix.Rank(fromRows(t, tt.rows...)); got != tt.want {
+			t.Errorf("%v: Rank = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package matrix implements dense matrices of float64 and the usual linear
+// algebra on them: sums, products, determinants, inverses, linear systems
+// and ranks.
+package matrix
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	// ErrDimensions is returned when the dimensions of the operands do not
+	// fit the operation
+	ErrDimensions = errors.New("dimensions mismatch")
+	// ErrNotSquare is returned when an operation needs a square matrix
+	ErrNotSquare = errors.New("matrix is not square")
+	// ErrSingular is returned when a matrix is not invertible, up to the
+	// rounding errors
+	ErrSingular = errors.New("matrix is singular")
+)
+
+// Matrix is a dense matrix stored in row-major order
+type Matrix struct {
+	rows, cols int
+	data       []float64
+}
+
+// New returns a zero matrix
+func New(rows, cols int) *Matrix {
+	return &Matrix{rows: rows, cols: cols, data: make([]float64, rows*cols)}
+}
+
+// FromValues returns the matrix of the row-major values, which it keeps.
+// It returns ErrDimensions if there are not rows*cols values.
+func FromValues(rows, cols int, values []float64) (*Matrix, error) {
+	if rows < 0 || cols < 0 || len(values) != rows*cols {
+		return nil, ErrDimensions
+	}
+	return &Matrix{rows: rows, cols: cols, data: values}, nil
+}
+
+// Identity returns the n x n identity matrix
+func Identity(n int) *Matrix {
+	m := New(n, n)
+	for i := 0; i < n; i++ {
+		m.data[i*n+i] = 1
+	}
+	return m
+}
+
+// Rows returns the number of rows
+func (m *Matrix) Rows() int {
+	return m.rows
+}
+
+// Cols returns the number of columns
+func (m *Matrix) Cols() int {
+	return m.cols
+}
+
+// At returns the element of row i and column j
+func (m *Matrix) At(i, j int) float64 {
+	return m.data[i*m.cols+j]
+}
+
+// Values returns the elements in row-major order, they are shared with
+// the matrix
+func (m *Matrix) Values() []float64 {
+	return m.data
+}
+
+func (m *Matrix) row(i int) []float64 {
+	return m.data[i*m.cols : (i+1)*m.cols]
+}
+
+func (m *Matrix) clone() *Matrix {
+	c := &Matrix{rows: m.rows, cols: m.cols, data: make([]float64, len(m.data))}
+	copy(c.data, m.data)
+	return c
+}
+
+// maxAbs returns the largest absolute value of the elements
+func (m *Matrix) maxAbs() float64 {
+	max := 0.0
+	for _, v := range m.data {
+		max = math.Max(max, math.Abs(v))
+	}
+	return max
+}
+
+// Add returns a + b
+func Add(a, b *Matrix) (*Matrix, error) {
+	if a.rows != b.rows || a.cols != b.cols {
+		return nil, ErrDimensions
+	}
+	sum := New(a.rows, a.cols)
+	for i := range sum.data {
+		sum.data[i] = a.data[i] + b.data[i]
+	}
+	return sum, nil
+}
+
+// Mul returns the product a b
+func Mul(a, b *Matrix) (*Matrix, error) {
+	if a.cols != b.rows {
+		return nil, ErrDimensions
+	}
+	product := New(a.rows, b.cols)
+	// the i-k-j order walks the rows of b and of the product sequentially
+	for i := 0; i < a.rows; i++ {
+		out := product.row(i)
+		for k, x := range a.row(i) {
+			if x == 0 {
+				continue
+			}
+			for j, y := range b.row(k) {
+				out[j] += x * y
+			}
+		}
+	}
+	return product, nil
+}
+
+// Transpose returns the transpose of m
+func (m *Matrix) Transpose() *Matrix {
+	t := New(m.cols, m.rows)
+	for i := 0; i < m.rows; i++ {
+		for j, v := range m.row(i) {
+			t.data[j*m.rows+i] = v
+		}
+	}
+	return t
+}
+
+// tolerance returns the magnitude under which the pivots of m are
+// considered zero
+func (m *Matrix) tolerance() float64 {
+	n := m.rows
+	if m.cols > n {
+		n = m.cols
+	}
+	return float64(n) * m.maxAbs() * epsilon
+}
+
+// epsilon is the distance from 1 to the next float64
+const epsilon = 2.220446049250313e-16
@@ -0,0 +1,44 @@
+package matrix_test
+
+import (
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/matrix"
+)
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b [][]float64
+		want [][]float64
+		err  error
+	}{
+		{"2x3 by 3x2", [][]float64{{1, 2, 3}, {4, 5, 6}}, [][]float64{{7, 8}, {9, 10}, {11, 12}}, [][]float64{{58, 64}, {139, 154}}, nil},
+		{"row by column", [][]float64{{1, 2, 3}}, [][]float64{{4}, {5}, {6}}, [][]float64{{32}}, nil},
+		{"mismatch", [][]float64{{1, 2}}, [][]float64{{1, 2}}, nil, matrix.ErrDimensions},
+	}
+	for _, tt := range tests {
+		product, err := matrix.Mul(fromRows(t, tt.a...), fromRows(t, tt.b...))
+		if err != tt.err {
+			t.Errorf("%v: Mul error = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && !equalMatrix(product, fromRows(t, tt.want...)) {
+			t.Errorf("%v: Mul = %v, want %v", tt.name, product.Values(), tt.want)
+		}
+	}
+}
+
+func TestAddAndTranspose(t *testing.T) {
+	a := fromRows(t, []float64{1, 2, 3}, []float64{4, 5, 6})
+	sum, err := matrix.Add(a, a)
+	if want := fromRows(t, []float64{2, 4, 6}, []float64{8, 10, 12}); err != nil || !equalMatrix(sum, want) {
+		t.Errorf("Add = %v, %v, want %v", sum.Values(), err, want.Values())
+	}
+	if _, err := matrix.Add(a, a.Transpose()); err != matrix.ErrDimensions {
+		t.Errorf("Add(2x3, 3x2) error = %v, want ErrDimensions", err)
+	}
+	if got, want := a.Transpose(), fromRows(t, []float64{1, 4}, []float64{2, 5}, []float64{3, 6}); !equalMatrix(got, want) {
+		t.Errorf("Transpose = %v, want %v", got.Values(), want.Values())
+	}
+}
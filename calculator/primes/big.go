@@ -0,0 +1,162 @@
+// Package primes factors integers, tests their primality and enumerates
+// primes.
+package primes
+
+import (
+	"context"
+	"math/big"
+)
+
+// millerRabinRounds is the number of random bases ProbablyPrime tries on
+// top of its Baillie-PSW test
+const millerRabinRounds = 20
+
+// checkEvery is the number of iterations run between two checks of the
+// context
+const checkEvery = 128
+
+// smallPrimes are tried by division before Pollard's rho
+var smallPrimes = sieve(1000)
+
+// sieve returns the primes up to limit included
+func sieve(limit int) []int64 {
+	composite := make([]bool, limit+1)
+	var primes []int64
+	for i := 2; i <= limit; i++ {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, int64(i))
+		for j := i * i; j <= limit; j += i {
+			composite[j] = true
+		}
+	}
+	return primes
+}
+
+var bigOne = big.NewInt(1)
+
+// FactorBig calls found with each prime factor of n as soon as it is
+// found, repeated as many times as it divides n. The factors below 1000
+// come first in ascending order, the others in no particular order.
+// Numbers below 2 have none. It stops with the error of found, or the one
+// of ctx when it is done.
+func FactorBig(ctx context.Context, n *big.Int, found func(prime *big.Int) error) error {
+	n = new(big.Int).Set(n)
+	if n.Cmp(bigOne) <= 0 {
+		return nil
+	}
+
+	p, q, r := new(big.Int), new(big.Int), new(big.Int)
+	for _, small := range smallPrimes {
+		p.SetInt64(small)
+		if p.Cmp(n) > 0 {
+			break
+		}
+		for {
+			q.QuoRem(n, p, r)
+			if r.Sign() != 0 {
+				break
+			}
+			if err := found(new(big.Int).Set(p)); err != nil {
+				return err
+			}
+			n.Set(q)
+		}
+	}
+
+	// composites left to split
+	pending := []*big.Int{n}
+	for len(pending) > 0 {
+		n := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if n.Cmp(bigOne) == 0 {
+			continue
+		}
+		if n.ProbablyPrime(millerRabinRounds) {
+			if err := found(n); err != nil {
+				return err
+			}
+			continue
+		}
+
+		d, err := rhoBig(ctx, n)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, d, new(big.Int).Quo(n, d))
+	}
+	return nil
+}
+
+// rhoBig returns a non-trivial divisor of the composite n, using Brent's
+// variant of Pollard's rho. n must not have factors below 1000.
+func rhoBig(ctx context.Context, n *big.Int) (*big.Int, error) {
+	x, y, ys := new(big.Int), new(big.Int), new(big.Int)
+	q, g, diff := new(big.Int), new(big.Int), new(big.Int)
+	c := new(big.Int)
+	// the values are multiplied by batches before taking their gcd
+	const m = checkEvery
+
+	// f(v) = v² + c mod n
+	f := func(v *big.Int) {
+		v.Mul(v, v)
+		v.Add(v, c)
+		v.Mod(v, n)
+	}
+	absDiff := func(a, b *big.Int) *big.Int {
+		diff.Sub(a, b)
+		return diff.Abs(diff)
+	}
+
+	for seed := int64(1); ; seed++ {
+		c.SetInt64(seed)
+		y.SetInt64(2)
+		q.SetInt64(1)
+		g.SetInt64(1)
+
+		for r := 1; g.Cmp(bigOne) == 0; r *= 2 {
+			x.Set(y)
+			for i := 0; i < r; i++ {
+				if i%checkEvery == 0 {
+					if err := ctx.Err(); err != nil {
+						return nil, err
+					}
+				}
+				f(y)
+			}
+			for k := 0; k < r && g.Cmp(bigOne) == 0; k += m {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				ys.Set(y)
+				for i := 0; i < m && i < r-k; i++ {
+					f(y)
+					q.Mul(q, absDiff(x, y))
+					q.Mod(q, n)
+				}
+				g.GCD(nil, nil, q, n)
+			}
+		}
+
+		if g.Cmp(n) == 0 {
+			// the batch overshot, step back one value at a time
+			for i := 0; ; i++ {
+				if i%checkEvery == 0 {
+					if err := ctx.Err(); err != nil {
+						return nil, err
+					}
+				}
+				f(ys)
+				g.GCD(nil, nil, absDiff(x, ys), n)
+				if g.Cmp(bigOne) != 0 {
+					break
+				}
+			}
+		}
+		if g.Cmp(n) != 0 {
+			return g, nil
+		}
+		// the cycle closed without splitting n, try another polynomial
+	}
+}
@@ -0,0 +1,80 @@
+package primes_test
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/primes"
+)
+
+func TestFactorBig(t *testing.T) {
+	tests := []struct {
+		n    string
+		want string
+	}{
+		{"1", ""},
+		{"360", "2^3 3^2 5"},
+		{"18446744073709551617", "274177 67280421310721"},
+		{"170141183460469231731687303715884105727", "170141183460469231731687303715884105727"},
+		{"39614200080929140762156672023663128", "2^3 1000003 2147483647 2305843009213693951"},
+	}
+	for _, tt := range tests {
+		n, _ := new(big.Int).SetString(tt.n, 10)
+		// the factors are repeated, in no particular order
+		counts := map[string]*factor{}
+		var factors []factor
+		err := primes.FactorBig(context.Background(), n, func(prime *big.Int) error {
+			if f, ok := counts[prime.String()]; ok {
+				f.multiplicity++
+			} else {
+				counts[prime.String()] = &factor{prime, 1}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("FactorBig(%v): %v", tt.n, err)
+			continue
+		}
+		for _, f := range counts {
+			factors = append(factors, *f)
+		}
+		if got := formatFactors(factors); got != tt.want {
+			t.Errorf("FactorBig(%v) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFactorBigCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// the product of two 64-bit primes needs Pollard's rho
+	n, _ := new(big.Int).SetString("340282366920938460843936948965011886881", 10)
+	err := primes.FactorBig(ctx, n, func(*big.Int) error { return nil })
+	if err != context.Canceled {
+		t.Errorf("FactorBig with a canceled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+type factor struct {
+	prime        *big.Int
+	multiplicity int
+}
+
+// formatFactors sorts the factors and formats them as "2^2 3"
+func formatFactors(factors []factor) string {
+	sort.Slice(factors, func(i, j int) bool { return factors[i].prime.Cmp(factors[j].prime) < 0 })
+	s := ""
+	for i, f := range factors {
+		if i > 0 {
+			s += " "
+		}
+		s += f.prime.String()
+		if f.multiplicity > 1 {
+			s += fmt.Sprintf("^%d", f.multiplicity)
+		}
+	}
+	return s
+}
@@ -0,0 +1,186 @@
+package primes
+
+import (
+	"context"
+	"math/bits"
+)
+
+// millerRabinBases make Miller-Rabin deterministic below 2^64
+var millerRabinBases = []uint64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37}
+
+// IsPrime reports whether n is prime
+func IsPrime(n uint64) bool {
+	if n < 2 {
+		return false
+	}
+	for _, p := range millerRabinBases {
+		if n%p == 0 {
+			return n == p
+		}
+	}
+
+	// n-1 = d*2^s with d odd
+	d := n - 1
+	s := bits.TrailingZeros64(d)
+	d >>= uint(s)
+
+	for _, a := range millerRabinBases {
+		x := powMod(a, d, n)
+		if x == 1 || x == n-1 {
+			continue
+		}
+		composite := true
+		for i := 1; i < s; i++ {
+			x = mulMod(x, x, n)
+			if x == n-1 {
+				composite = false
+				break
+			}
+		}
+		if composite {
+			return false
+		}
+	}
+	return true
+}
+
+func mulMod(a, b, m uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	// a, b < m so hi < m and the quotient fits
+	_, rem := bits.Div64(hi, lo, m)
+	return rem
+}
+
+func powMod(a, e, m uint64) uint64 {
+	result := uint64(1)
+	a %= m
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result = mulMod(result, a, m)
+		}
+		a = mulMod(a, a, m)
+	}
+	return result
+}
+
+// Factor calls found with each prime factor of n and the number of times
+// it divides n, as soon as it is found. The small factors come first in
+// ascending order, the others in no particular order. It stops with the
+// error of found, or the one of ctx when it is done.
+func Factor(ctx context.Context, n uint64, found func(prime uint64, multiplicity int) error) error {
+	for _, small := range smallPrimes {
+		p := uint64(small)
+		if p*p > n {
+			break
+		}
+		if k := divideOut(&n, p); k > 0 {
+			if err := found(p, k); err != nil {
+				return err
+			}
+		}
+	}
+
+	for n > 1 {
+		p := n
+		// descend to a prime factor of the rest
+		for !IsPrime(p) {
+			d, err := rho(ctx, p)
+			if err != nil {
+				return err
+			}
+			if p/d < d {
+				d = p / d
+			}
+			p = d
+		}
+		if err := found(p, divideOut(&n, p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// divideOut divides n by p as long as it can, and returns how many times
+func divideOut(n *uint64, p uint64) int {
+	k := 0
+	for *n%p == 0 {
+		*n /= p
+		k++
+	}
+	return k
+}
+
+// rho returns a non-trivial divisor of the composite n, using Brent's
+// variant of Pollard's rho. n must not have factors below 1000.
+func rho(ctx context.Context, n uint64) (uint64, error) {
+	// the values are multiplied by batches before taking their gcd
+	const m = checkEvery
+
+	for c := uint64(1); ; c++ {
+		f := func(v uint64) uint64 {
+			v = mulMod(v, v, n) + c
+			if v >= n || v < c {
+				v -= n
+			}
+			return v
+		}
+
+		var x, ys uint64
+		y, q, g := uint64(2), uint64(1), uint64(1)
+		for r := 1; g == 1; r *= 2 {
+			x = y
+			for i := 0; i < r; i++ {
+				if i%checkEvery == 0 {
+					if err := ctx.Err(); err != nil {
+						return 0, err
+					}
+				}
+				y = f(y)
+			}
+			for k := 0; k < r && g == 1; k += m {
+				if err := ctx.Err(); err != nil {
+					return 0, err
+				}
+				ys = y
+				for i := 0; i < m && i < r-k; i++ {
+					y = f(y)
+					q = mulMod(q, absDiff(x, y), n)
+				}
+				g = gcd(q, n)
+			}
+		}
+
+		if g == n {
+			// the batch overshot, step back one value at a time
+			for i := 0; ; i++ {
+				if i%checkEvery == 0 {
+					if err := ctx.Err(); err != nil {
+						return 0, err
+					}
+				}
+				ys = f(ys)
+				if g = gcd(absDiff(x, ys), n); g != 1 {
+					break
+				}
+			}
+		}
+		if g != n {
+			return g, nil
+		}
+		// the cycle closed without splitting n, try another polynomial
+	}
+}
+
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,77 @@
+package primes_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/primes"
+)
+
+func TestIsPrime(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want bool
+	}{
+		{0, false},
+		{1, false},
+		{2, true},
+		{3, true},
+		{4, false},
+		{997, true},
+		{1001, false},
+		// Carmichael number
+		{561, false},
+		// strong pseudoprime to the bases 2, 3, 5 and 7
+		{3215031751, false},
+		{1<<61 - 1, true},
+		{1000006000009, false},
+		{18446744073709551557, true},
+		{18446744073709551615, false},
+	}
+	for _, tt := range tests {
+		if got := primes.IsPrime(tt.n); got != tt.want {
+			t.Errorf("IsPrime(%v) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFactor(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want string
+	}{
+		{1, ""},
+		{2, "2"},
+		{12, "2^2 3"},
+		{12000, "2^5 3 5^3"},
+		{600851475143, "71 839 1471 6857"},
+		{1<<61 - 1, "2305843009213693951"},
+		{1000006000009, "1000003^2"},
+		// the product of two 32-bit primes needs Pollard's rho
+		{18446743979220271189, "4294967279 4294967291"},
+	}
+	for _, tt := range tests {
+		var factors []factor
+		err := primes.Factor(context.Background(), tt.n, func(prime uint64, multiplicity int) error {
+			factors = append(factors, factor{new(big.Int).SetUint64(prime), multiplicity})
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Factor(%v): %v", tt.n, err)
+			continue
+		}
+		if got := formatFactors(factors); got != tt.want {
+			t.Errorf("Factor(%v) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFactorCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := primes.Factor(ctx, 18446743979220271189, func(uint64, int) error { return nil })
+	if err != context.Canceled {
+		t.Errorf("Factor with a canceled context = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,168 @@
+package primes
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+)
+
+// MaxSieve bounds the numbers Primes enumerates, the primes up to its
+// square root are kept in memory
+const MaxSieve = 100000000000000
+
+// MaxNth bounds the primes NthPrime finds, the 10 millionth is 179424673
+const MaxNth = 10000000
+
+// segmentSize is the number of integers sieved at a time
+const segmentSize = 1 << 16
+
+// errFound stops the enumeration of NthPrime
+var errFound = errors.New("found")
+
+// Primes calls found with each prime in [from, to] in ascending order,
+// with a segmented sieve of Eratosthenes. to must not exceed MaxSieve. It
+// stops with the error of found, or the one of ctx when it is done.
+func Primes(ctx context.Context, from, to uint64, found func(p uint64) error) error {
+	if from < 2 {
+		from = 2
+	}
+	if to < from {
+		return nil
+	}
+
+	base := sieve(int(sqrt(to)))
+	composite := make([]bool, segmentSize)
+	for lo := from; lo <= to; lo += segmentSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hi := lo + segmentSize - 1
+		if hi > to {
+			hi = to
+		}
+		segment := composite[:hi-lo+1]
+		for i := range segment {
+			segment[i] = false
+		}
+
+		for _, bp := range base {
+			p := uint64(bp)
+			if p*p > hi {
+				break
+			}
+			// the smaller multiples have a smaller factor
+			start := p * p
+			if start < lo {
+				start = (lo + p - 1) / p * p
+			}
+			for m := start; m <= hi; m += p {
+				segment[m-lo] = true
+			}
+		}
+
+		for i, c := range segment {
+			if !c {
+				if err := found(lo + uint64(i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// sqrt returns the integer square root of n
+func sqrt(n uint64) uint64 {
+	r := uint64(math.Sqrt(float64(n)))
+	for r*r > n {
+		r--
+	}
+	for (r+1)*(r+1) <= n {
+		r++
+	}
+	return r
+}
+
+// NthPrime returns the nth prime, n in [1, MaxNth]. It returns the error
+// of ctx when it is done before.
+func NthPrime(ctx context.Context, n uint64) (uint64, error) {
+	// p_n < n (ln n + ln ln n) for n >= 6, and p_5 = 11
+	limit := uint64(11)
+	if n >= 6 {
+		f := float64(n)
+		limit = uint64(f*(math.Log(f)+math.Log(math.Log(f)))) + 1
+	}
+
+	var count, nth uint64
+	err := Primes(ctx, 2, limit, func(p uint64) error {
+		count++
+		if count == n {
+			nth = p
+			return errFound
+		}
+		return nil
+	})
+	if err != errFound {
+		if err == nil {
+			err = errors.New("nth prime beyond its bound")
+		}
+		return 0, err
+	}
+	return nth, nil
+}
+
+// IsProbablePrime reports whether n is prime. It is exact below 2^64, above
+// it runs a Baillie-PSW test and millerRabinRounds Miller-Rabin rounds with
+// random bases, so a composite passes with a probability below 4^-20 and
+// none is known to pass Baillie-PSW. It returns the error of ctx when it is
+// done before the end of the test.
+func IsProbablePrime(ctx context.Context, n *big.Int) (bool, error) {
+	if n.Sign() <= 0 {
+		return false, nil
+	}
+	if n.IsUint64() {
+		return IsPrime(n.Uint64()), nil
+	}
+	if n.Bit(0) == 0 {
+		return false, nil
+	}
+	if !n.ProbablyPrime(0) {
+		return false, nil
+	}
+
+	// n-1 = d*2^s with d odd
+	nm1 := new(big.Int).Sub(n, bigOne)
+	s := nm1.TrailingZeroBits()
+	d := new(big.Int).Rsh(nm1, s)
+	// the bases are in [2, n-2]
+	span := new(big.Int).Sub(n, big.NewInt(3))
+	two := big.NewInt(2)
+
+	for i := 0; i < millerRabinRounds; i++ {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		a, err := rand.Int(rand.Reader, span)
+		if err != nil {
+			return false, err
+		}
+		x := a.Exp(a.Add(a, two), d, n)
+		if x.Cmp(bigOne) == 0 || x.Cmp(nm1) == 0 {
+			continue
+		}
+		composite := true
+		for j := uint(1); j < s; j++ {
+			x.Mul(x, x).Mod(x, n)
+			if x.Cmp(nm1) == 0 {
+				composite = false
+				break
+			}
+		}
+		if composite {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,101 @@
+package primes_test
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/primes"
+)
+
+func TestPrimesCount(t *testing.T) {
+	// π(x), the number of primes up to x
+	tests := []struct {
+		x    uint64
+		want int
+	}{
+		{1, 0},
+		{2, 1},
+		{10, 4},
+		{100, 25},
+		{1000, 168},
+		{10000, 1229},
+		{100000, 9592},
+		{1000000, 78498},
+		{10000000, 664579},
+	}
+	for _, tt := range tests {
+		count := 0
+		err := primes.Primes(context.Background(), 0, tt.x, func(uint64) error {
+			count++
+			return nil
+		})
+		if err != nil || count != tt.want {
+			t.Errorf("π(%v) = %v, %v, want %v", tt.x, count, err, tt.want)
+		}
+	}
+}
+
+func TestPrimesRange(t *testing.T) {
+	tests := []struct {
+		from, to uint64
+		want     []uint64
+	}{
+		{90, 110, []uint64{97, 101, 103, 107, 109}},
+		{24, 28, nil},
+		{7, 7, []uint64{7}},
+		{10, 2, nil},
+		// across a segment boundary
+		{65521, 65543, []uint64{65521, 65537, 65539, 65543}},
+		{1000000000, 1000000100, []uint64{1000000007, 1000000009, 1000000021, 1000000033, 1000000087, 1000000093, 1000000097}},
+	}
+	for _, tt := range tests {
+		var got []uint64
+		err := primes.Primes(context.Background(), tt.from, tt.to, func(p uint64) error {
+			got = append(got, p)
+			return nil
+		})
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Primes(%v, %v) = %v, %v, want %v", tt.from, tt.to, got, err, tt.want)
+		}
+	}
+}
+
+func TestNthPrime(t *testing.T) {
+	tests := []struct {
+		n, want uint64
+	}{
+		{1, 2},
+		{2, 3},
+		{5, 11},
+		{6, 13},
+		{1000, 7919},
+		{10000, 104729},
+		{1000000, 15485863},
+	}
+	for _, tt := range tests {
+		if got, err := primes.NthPrime(context.Background(), tt.n); err != nil || got != tt.want {
+			t.Errorf("NthPrime(%v) = %v, %v, want %v", tt.n, got, err, tt.want)
+		}
+	}
+}
+
+func TestIsProbablePrime(t *testing.T) {
+	tests := []struct {
+		n    string
+		want bool
+	}{
+		{"0", false},
+		{"97", true},
+		{"18446744073709551617", false},
+		{"170141183460469231731687303715884105727", true},
+		{"170141183460469231731687303715884105729", false},
+	}
+	for _, tt := range tests {
+		n, _ := new(big.Int).SetString(tt.n, 10)
+		if got, err := primes.IsProbablePrime(context.Background(), n); err != nil || got != tt.want {
+			t.Errorf("IsProbablePrime(%v) = %v, %v, want %v", tt.n, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package roots computes nth roots, real or complex, to an arbitrary
+// precision with math/big.
+package roots
+
+import (
+	"math"
+	"math/big"
+)
+
+// guardBits are added to the requested precision, so the last bits are
+// right after the rounding errors of the iterations
+const guardBits = 32
+
+// Root is a possibly complex root
+type Root struct {
+	Real, Imag *big.Float
+}
+
+// Nth returns the principal nth root of a with prec bits of precision,
+// n >= 1. The roots of negative numbers are real for odd degrees and
+// complex for even ones.
+func Nth(a *big.Float, n int, prec uint) Root {
+	work := prec + guardBits
+	abs := new(big.Float).SetPrec(work).Abs(a)
+	magnitude := nthRoot(abs, n, work)
+
+	root := Root{Real: magnitude, Imag: new(big.Float).SetPrec(prec)}
+	if a.Sign() < 0 {
+		switch {
+		case n%2 == 1:
+			root.Real.Neg(root.Real)
+		case n == 2:
+			// exactly imaginary
+			root.Real, root.Imag = root.Imag, root.Real
+		default:
+			// |a|^(1/n) * e^(iπ/n)
+			angle := new(big.Float).SetPrec(work).Quo(pi(work), new(big.Float).SetInt64(int64(n)))
+			sin, cos := sinCos(angle, work)
+			root.Imag.SetPrec(work).Mul(magnitude, sin)
+			root.Real = new(big.Float).SetPrec(work).Mul(magnitude, cos)
+		}
+	}
+
+	root.Real.SetPrec(prec)
+	root.Imag.SetPrec(prec)
+	return root
+}
+
+// nthRoot returns the nth root of a >= 0 with Newton's method
+func nthRoot(a *big.Float, n int, prec uint) *big.Float {
+	if a.Sign() == 0 || n == 1 {
+		return new(big.Float).SetPrec(prec).Set(a)
+	}
+	if n == 2 {
+		return new(big.Float).SetPrec(prec).Sqrt(a)
+	}
+
+	x := guess(a, n).SetPrec(prec)
+	bigN := new(big.Float).SetPrec(prec).SetInt64(int64(n))
+	bigN1 := new(big.Float).SetPrec(prec).SetInt64(int64(n - 1))
+	power, next, delta := new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec), new(big.Float).SetPrec(prec)
+
+	// the guess is right to about 50 bits and each step doubles them, give
+	// up on the last bit oscillating after enough steps
+	for i := 0; i < 64; i++ {
+		// x' = ((n-1)x + a/x^(n-1)) / n
+		pow(power, x, n-1)
+		next.Quo(a, power)
+		power.Mul(bigN1, x)
+		next.Add(next, power)
+		next.Quo(next, bigN)
+
+		delta.Sub(next, x)
+		x, next = next, x
+		if delta.Sign() == 0 || delta.MantExp(nil)+int(prec) < x.MantExp(nil) {
+			break
+		}
+	}
+	return x
+}
+
+// guess returns a float64 approximation of the root of a > 0, even when a
+// does not fit in a float64
+func guess(a *big.Float, n int) *big.Float {
+	mant := new(big.Float)
+	exp := a.MantExp(mant)
+	// a = m * 2^(qn + r) with 0 <= r < n, its root is m^(1/n) 2^(r/n) 2^q
+	q := exp / n
+	r := exp - q*n
+	if r < 0 {
+		q--
+		r += n
+	}
+	m, _ := mant.Float64()
+	g := math.Pow(m, 1/float64(n)) * math.Pow(2, float64(r)/float64(n))
+	return new(big.Float).SetMantExp(big.NewFloat(g), q)
+}
+
+// pow sets z to x^n, n >= 0, and returns it
+func pow(z, x *big.Float, n int) *big.Float {
+	result := new(big.Float).SetPrec(z.Prec()).SetInt64(1)
+	base := new(big.Float).SetPrec(z.Prec()).Set(x)
+	for ; n > 0; n >>= 1 {
+		if n&1 == 1 {
+			result.Mul(result, base)
+		}
+		if n > 1 {
+			base.Mul(base, base)
+		}
+	}
+	return z.Set(result)
+}
+
+// pi returns π with prec bits of precision, using Machin's formula
+// π = 16 atan(1/5) - 4 atan(1/239)
+func pi(prec uint) *big.Float {
+	work := prec + guardBits
+	a := atanInv(5, work)
+	b := atanInv(239, work)
+	a.Mul(a, new(big.Float).SetInt64(16))
+	b.Mul(b, new(big.Float).SetInt64(4))
+	return a.Sub(a, b).SetPrec(prec)
+}
+
+// atanInv returns atan(1/x) with its Taylor series, x > 1
+func atanInv(x int64, prec uint) *big.Float {
+	sum := new(big.Float).SetPrec(prec)
+	x2 := new(big.Float).SetPrec(prec).SetInt64(x * x)
+	// term = 1 / x^(2k+1)
+	term := new(big.Float).SetPrec(prec).Quo(new(big.Float).SetPrec(prec).SetInt64(1), new(big.Float).SetInt64(x))
+	quotient := new(big.Float).SetPrec(prec)
+	for k := int64(0); ; k++ {
+		quotient.Quo(term, new(big.Float).SetInt64(2*k+1))
+		if k%2 == 0 {
+			sum.Add(sum, quotient)
+		} else {
+			sum.Sub(sum, quotient)
+		}
+		term.Quo(term, x2)
+		if term.Sign() == 0 || term.MantExp(nil) < sum.MantExp(nil)-int(prec) {
+			return sum
+		}
+	}
+}
+
+// sinCos returns the sine and cosine of x with prec bits of precision,
+// using their Taylor series. It is meant for |x| <= π.
+func sinCos(x *big.Float, prec uint) (sin, cos *big.Float) {
+	work := prec + guardBits
+	sin = new(big.Float).SetPrec(work)
+	cos = new(big.Float).SetPrec(work)
+
+	// the terms x^k / k!, alternately added to cos and sin
+	term := new(big.Float).SetPrec(work).SetInt64(1)
+	for k := int64(0); ; k++ {
+		target := cos
+		if k%2 == 1 {
+			target = sin
+		}
+		if (k/2)%2 == 0 {
+			target.Add(target, term)
+		} else {
+			target.Sub(target, term)
+		}
+
+		term.Mul(term, x)
+		term.Quo(term, new(big.Float).SetInt64(k+1))
+		if term.Sign() == 0 || term.MantExp(nil) < -int(work) {
+			break
+		}
+	}
+	return sin.SetPrec(prec), cos.SetPrec(prec)
+}
@@ -0,0 +1,68 @@
+package roots_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/roots"
+)
+
+const (
+	sqrt2     = "1.414213562373095048801688724209698078569671875376948073176679737990732"
+	cbrt2     = "1.259921049894873164767210607278228350570251464701507980081975112155300"
+	sqrt6Half = "1.224744871391589049098642037352945695982973740328335064216346283625480"
+	sqrt2Half = "0.707106781186547524400844362104849039284835937688474036588339868995366"
+	root7Of10 = "1.389495494373137637129985217353011622113046714491000204945628679031600"
+)
+
+func TestNth(t *testing.T) {
+	tests := []struct {
+		a          string
+		n          int
+		prec       uint
+		real, imag string
+	}{
+		{"2", 2, 200, sqrt2, "0"},
+		{"2", 3, 200, cbrt2, "0"},
+		{"10", 7, 200, root7Of10, "0"},
+		{"10", 1, 53, "10", "0"},
+		{"0", 5, 53, "0", "0"},
+		{"-27", 3, 64, "-3", "0"},
+		{"-4", 2, 64, "0", "2"},
+		// |a|^(1/n) e^(iπ/n)
+		{"-16", 4, 200, sqrt2, sqrt2},
+		{"-8", 6, 200, sqrt6Half, sqrt2Half},
+		{"0.0625", 4, 100, "0.5", "0"},
+		// far outside the range of float64
+		{"1e3000", 3, 100, "1e1000", "0"},
+		{"1e-3000", 2, 100, "1e-1500", "0"},
+	}
+	for _, tt := range tests {
+		a, _, err := big.ParseFloat(tt.a, 10, tt.prec+64, big.ToNearestEven)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := roots.Nth(a, tt.n, tt.prec)
+		if root.Real.Prec() != tt.prec || root.Imag.Prec() != tt.prec {
+			t.Errorf("Nth(%v, %v, %v) has a precision of %v and %v bits", tt.a, tt.n, tt.prec, root.Real.Prec(), root.Imag.Prec())
+		}
+		if !near(root.Real, tt.real, tt.prec) || !near(root.Imag, tt.imag, tt.prec) {
+			t.Errorf("Nth(%v, %v, %v) = %v + %vi, want %v + %vi", tt.a, tt.n, tt.prec,
+				root.Real.Text('g', 30), root.Imag.Text('g', 30), tt.real, tt.imag)
+		}
+	}
+}
+
+// near reports whether x is want up to the last 2 bits of a precision of
+// prec bits
+func near(x *big.Float, want string, prec uint) bool {
+	w, _, err := big.ParseFloat(want, 10, prec+64, big.ToNearestEven)
+	if err != nil {
+		panic(err)
+	}
+	if w.Sign() == 0 {
+		return x.Sign() == 0
+	}
+	diff := new(big.Float).SetPrec(prec+64).Sub(x, w)
+	return diff.Sign() == 0 || diff.MantExp(nil) <= w.MantExp(nil)-int(prec)+2
+}
@@ -0,0 +1,81 @@
+// Package stats computes descriptive statistics over streams of numbers in
+// bounded memory.
+package stats
+
+import "math"
+
+// Summary keeps the count, sum, extremes, mean and variance of the numbers
+// added to it. The mean and variance use Welford's algorithm, which stays
+// accurate when the variance is small relatively to the mean.
+type Summary struct {
+	count int64
+	// sum is compensated with Neumaier's algorithm
+	sum, compensation float64
+	mean, m2          float64
+	min, max          float64
+}
+
+// Add adds a number to the summary
+func (s *Summary) Add(x float64) {
+	s.count++
+	if s.count == 1 {
+		s.min, s.max = x, x
+	} else {
+		s.min = math.Min(s.min, x)
+		s.max = math.Max(s.max, x)
+	}
+
+	t := s.sum + x
+	if math.Abs(s.sum) >= math.Abs(x) {
+		s.compensation += (s.sum - t) + x
+	} else {
+		s.compensation += (x - t) + s.sum
+	}
+	s.sum = t
+
+	delta := x - s.mean
+	s.mean += delta / float64(s.count)
+	s.m2 += delta * (x - s.mean)
+}
+
+// Count returns the number of numbers added
+func (s *Summary) Count() int64 {
+	return s.count
+}
+
+// Sum returns the sum of the numbers
+func (s *Summary) Sum() float64 {
+	return s.sum + s.compensation
+}
+
+// Mean returns the mean of the numbers, 0 when there are none
+func (s *Summary) Mean() float64 {
+	return s.mean
+}
+
+// Variance returns the population variance of the numbers
+func (s *Summary) Variance() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.m2 / float64(s.count)
+}
+
+// SampleVariance returns the unbiased variance of the numbers as a sample,
+// 0 when there are less than two
+func (s *Summary) SampleVariance() float64 {
+	if s.count < 2 {
+		return 0
+	}
+	return s.m2 / float64(s.count-1)
+}
+
+// Min returns the smallest number, 0 when there are none
+func (s *Summary) Min() float64 {
+	return s.min
+}
+
+// Max returns the largest number, 0 when there are none
+func (s *Summary) Max() float64 {
+	return s.max
+}
@@ -0,0 +1,47 @@
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/stats"
+)
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name                     string
+		values                   []float64
+		sum, mean, variance      float64
+		sampleVariance, min, max float64
+	}{
+		{"empty", nil, 0, 0, 0, 0, 0, 0},
+		{"single", []float64{-3}, -3, -3, 0, 0, -3, -3},
+		{"small", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 40, 5, 4, 32.0 / 7, 2, 9},
+		// the variance is small relatively to the mean
+		{"shifted", []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}, 4e9 + 40, 1e9 + 10, 22.5, 30, 1e9 + 4, 1e9 + 16},
+	}
+	for _, tt := range tests {
+		s := &stats.Summary{}
+		for _, v := range tt.values {
+			s.Add(v)
+		}
+		if s.Count() != int64(len(tt.values)) || s.Sum() != tt.sum || s.Min() != tt.min || s.Max() != tt.max {
+			t.Errorf("%v: count, sum, min, max = %v, %v, %v, %v, want %v, %v, %v, %v",
+				tt.name, s.Count(), s.Sum(), s.Min(), s.Max(), len(tt.values), tt.sum, tt.min, tt.max)
+		}
+		if s.Mean() != tt.mean || s.Variance() != tt.variance || s.SampleVariance() != tt.sampleVariance {
+			t.Errorf("%v: mean, variance, sample variance = %v, %v, %v, want %v, %v, %v",
+				tt.name, s.Mean(), s.Variance(), s.SampleVariance(), tt.mean, tt.variance, tt.sampleVariance)
+		}
+	}
+}
+
+func TestSummarySum(t *testing.T) {
+	s := &stats.Summary{}
+	for _, v := range []float64{1e16, 1, -1e16, 1} {
+		s.Add(v)
+	}
+	// an uncompensated sum loses the first 1
+	if s.Sum() != 2 {
+		t.Errorf("Sum = %v, want 2", s.Sum())
+	}
+}
@@ -0,0 +1,136 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultCompression is the compression of the digests created with 0
+const DefaultCompression = 100
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// Digest estimates the quantiles of a stream with a merging t-digest. It
+// keeps at most about 2*compression centroids whatever the number of values
+// added, and is most accurate at the extreme quantiles.
+type Digest struct {
+	compression float64
+	centroids   []centroid
+	// buffer holds the values added since the last merge
+	buffer   []centroid
+	count    float64
+	min, max float64
+}
+
+// NewDigest returns an empty digest. Higher compressions are more accurate
+// and use more memory.
+func NewDigest(compression int) *Digest {
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
+	return &Digest{
+		compression: float64(compression),
+		buffer:      make([]centroid, 0, 5*compression),
+	}
+}
+
+// Add adds a value to the digest
+func (d *Digest) Add(x float64) {
+	if d.count == 0 {
+		d.min, d.max = x, x
+	} else {
+		d.min = math.Min(d.min, x)
+		d.max = math.Max(d.max, x)
+	}
+	d.count++
+
+	d.buffer = append(d.buffer, centroid{mean: x, weight: 1})
+	if len(d.buffer) == cap(d.buffer) {
+		d.merge()
+	}
+}
+
+// merge folds the buffer into the centroids. Neighbouring centroids are
+// combined as long as the result stays within one unit of the scale
+// function k(q) = compression/2π * asin(2q-1), which keeps the centroids
+// small near the extremes.
+func (d *Digest) merge() {
+	if len(d.buffer) == 0 {
+		return
+	}
+	all := append(d.buffer, d.centroids...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := make([]centroid, 0, len(d.centroids)+1)
+	cumulative := 0.0
+	limit := d.count * d.quantileOf(d.scale(0)+1)
+	current := all[0]
+	for _, c := range all[1:] {
+		if cumulative+current.weight+c.weight <= limit {
+			current.weight += c.weight
+			current.mean += (c.mean - current.mean) * c.weight / current.weight
+			continue
+		}
+		merged = append(merged, current)
+		cumulative += current.weight
+		limit = d.count * d.quantileOf(d.scale(cumulative/d.count)+1)
+		current = c
+	}
+	merged = append(merged, current)
+
+	d.centroids = merged
+	d.buffer = d.buffer[:0]
+}
+
+func (d *Digest) scale(q float64) float64 {
+	return d.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+// quantileOf is the inverse of scale
+func (d *Digest) quantileOf(k float64) float64 {
+	if k >= d.compression/4 {
+		return 1
+	}
+	return (math.Sin(k*2*math.Pi/d.compression) + 1) / 2
+}
+
+// Quantile estimates the value below which lies the fraction q of the
+// values, q in [0, 1]. It interpolates between the centers of the
+// centroids and returns NaN when the digest is empty.
+func (d *Digest) Quantile(q float64) float64 {
+	if d.count == 0 {
+		return math.NaN()
+	}
+	d.merge()
+	if q <= 0 {
+		return d.min
+	}
+	if q >= 1 {
+		return d.max
+	}
+
+	target := q * d.count
+	// the half of a centroid lies on each side of its mean, the extremes
+	// bound the first and last halves
+	prevCenter, prevMean := 0.0, d.min
+	cumulative := 0.0
+	for _, c := range d.centroids {
+		center := cumulative + c.weight/2
+		if target < center {
+			return interpolate(prevMean, c.mean, (target-prevCenter)/(center-prevCenter))
+		}
+		prevCenter, prevMean = center, c.mean
+		cumulative += c.weight
+	}
+	if d.count == prevCenter {
+		return d.max
+	}
+	return interpolate(prevMean, d.max, (target-prevCenter)/(d.count-prevCenter))
+}
+
+func interpolate(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
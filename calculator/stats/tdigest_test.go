@@ -0,0 +1,75 @@
+package stats_test
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/stats"
+)
+
+// TestQuantileError checks the rank of the estimated quantiles of 100000
+// values, the error is allowed to grow towards the median
+func TestQuantileError(t *testing.T) {
+	const n = 100000
+	tests := []struct {
+		name string
+		// value returns the ith value
+		value func(r *rand.Rand, i int) float64
+	}{
+		{"uniform", func(r *rand.Rand, i int) float64 { return r.Float64() }},
+		{"normal", func(r *rand.Rand, i int) float64 { return r.NormFloat64() }},
+		{"exponential", func(r *rand.Rand, i int) float64 { return r.ExpFloat64() }},
+		{"ascending", func(r *rand.Rand, i int) float64 { return float64(i) }},
+		{"descending", func(r *rand.Rand, i int) float64 { return float64(n - i) }},
+	}
+	for _, tt := range tests {
+		r := rand.New(rand.NewSource(1))
+		d := stats.NewDigest(0)
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = tt.value(r, i)
+			d.Add(values[i])
+		}
+		sort.Float64s(values)
+
+		for _, q := range []float64{0.0001, 0.001, 0.01, 0.1, 0.25, 0.5, 0.75, 0.9, 0.99, 0.999, 0.9999} {
+			estimate := d.Quantile(q)
+			rank := float64(sort.SearchFloat64s(values, estimate)) / n
+			if bound := 0.0005 + 0.01*q*(1-q); math.Abs(rank-q) > bound {
+				t.Errorf("%v: Quantile(%v) = %v of rank %v, want a rank within %v", tt.name, q, estimate, rank, bound)
+			}
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		q      float64
+		want   float64
+	}{
+		{"single", []float64{7}, 0.5, 7},
+		{"median", []float64{5, 1, 4, 2, 3}, 0.5, 3},
+		{"below 0", []float64{5, 1, 4}, -1, 1},
+		{"0", []float64{5, 1, 4}, 0, 1},
+		{"1", []float64{5, 1, 4}, 1, 5},
+		{"above 1", []float64{5, 1, 4}, 2, 5},
+		{"repeated", []float64{2, 2, 2, 2}, 0.3, 2},
+	}
+	for _, tt := range tests {
+		d := stats.NewDigest(0)
+		for _, v := range tt.values {
+			d.Add(v)
+		}
+		if got := d.Quantile(tt.q); got != tt.want {
+			t.Errorf("%v: Quantile(%v) = %v, want %v", tt.name, tt.q, got, tt.want)
+		}
+	}
+
+	if got := stats.NewDigest(0).Quantile(0.5); !math.IsNaN(got) {
+		t.Errorf("Quantile of an empty digest = %v, want NaN", got)
+	}
+}
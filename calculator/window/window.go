@@ -0,0 +1,203 @@
+// Package window aggregates the values of a stream over a sliding window,
+// the last N values or the values of the last period of time. Each value
+// is processed in amortized constant time.
+package window
+
+import (
+	"errors"
+	"time"
+)
+
+// Aggregate is the function computed over the window
+type Aggregate int
+
+const (
+	Max Aggregate = iota
+	Min
+	Sum
+	Mean
+)
+
+// MaxValues bounds the number of values a window holds
+const MaxValues = 1 << 20
+
+var (
+	// ErrTooManyValues is returned when a time window would hold more than
+	// MaxValues values
+	ErrTooManyValues = errors.New("too many values in the window")
+	// ErrTimeDecreased is returned when a value is older than the previous
+	// one of a time window
+	ErrTimeDecreased = errors.New("time went backwards")
+)
+
+type entry struct {
+	value float64
+	seq   int64
+	time  time.Time
+}
+
+// deque is a double-ended queue of entries
+type deque struct {
+	entries []entry
+	head    int
+}
+
+func (d *deque) len() int {
+	return len(d.entries) - d.head
+}
+
+func (d *deque) front() entry {
+	return d.entries[d.head]
+}
+
+func (d *deque) back() entry {
+	return d.entries[len(d.entries)-1]
+}
+
+func (d *deque) pushBack(e entry) {
+	// reclaim the popped front once it is the larger part of the slice
+	if d.head > 0 && d.head >= len(d.entries)/2 {
+		n := copy(d.entries, d.entries[d.head:])
+		d.entries = d.entries[:n]
+		d.head = 0
+	}
+	d.entries = append(d.entries, e)
+}
+
+func (d *deque) popFront() {
+	d.head++
+}
+
+func (d *deque) popBack() {
+	d.entries = d.entries[:len(d.entries)-1]
+}
+
+// Window is the state of a sliding window, it is not safe for concurrent
+// use
+type Window struct {
+	aggregate Aggregate
+	// size or period is set
+	size   int64
+	period time.Duration
+
+	seq  int64
+	last time.Time
+	// values holds the whole window for the sums, extremes the candidates
+	// to the min or max in monotonic order
+	values   deque
+	extremes deque
+	// sum is compensated with Neumaier's algorithm
+	sum, compensation float64
+}
+
+// NewCount returns a window over the last size values
+func NewCount(aggregate Aggregate, size int) *Window {
+	return &Window{aggregate: aggregate, size: int64(size)}
+}
+
+// NewTime returns a window over the values of the last period, the
+// values older than the last one by period or more are left out
+func NewTime(aggregate Aggregate, period time.Duration) *Window {
+	return &Window{aggregate: aggregate, period: period}
+}
+
+// Add adds the value received at t and returns the aggregate of the
+// window. t is ignored by count windows.
+func (w *Window) Add(x float64, t time.Time) (float64, error) {
+	if w.period > 0 {
+		if t.Before(w.last) {
+			return 0, ErrTimeDecreased
+		}
+		w.last = t
+	}
+	w.seq++
+	e := entry{value: x, seq: w.seq, time: t}
+
+	switch w.aggregate {
+	case Max, Min:
+		// drop the candidates the new value beats, they cannot become the
+		// extreme before leaving the window
+		for w.extremes.len() > 0 && w.beats(x, w.extremes.back().value) {
+			w.extremes.popBack()
+		}
+		w.extremes.pushBack(e)
+	default:
+		w.values.pushBack(e)
+		w.add(x)
+	}
+
+	w.evict()
+	if w.Len() > MaxValues {
+		return 0, ErrTooManyValues
+	}
+	return w.Value(), nil
+}
+
+func (w *Window) beats(x, y float64) bool {
+	if w.aggregate == Max {
+		return x >= y
+	}
+	return x <= y
+}
+
+// expired reports whether the entry left the window
+func (w *Window) expired(e entry) bool {
+	if w.period > 0 {
+		return !e.time.After(w.last.Add(-w.period))
+	}
+	return e.seq <= w.seq-w.size
+}
+
+func (w *Window) evict() {
+	for w.extremes.len() > 0 && w.expired(w.extremes.front()) {
+		w.extremes.popFront()
+	}
+	for w.values.len() > 0 && w.expired(w.values.front()) {
+		w.add(-w.values.front().value)
+		w.values.popFront()
+	}
+}
+
+func (w *Window) add(x float64) {
+	t := w.sum + x
+	if abs(w.sum) >= abs(x) {
+		w.compensation += (w.sum - t) + x
+	} else {
+		w.compensation += (x - t) + w.sum
+	}
+	w.sum = t
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Len returns the number of values held to compute the aggregate. For min
+// and max it is only the candidates, not every value of the window.
+func (w *Window) Len() int {
+	if w.aggregate == Max || w.aggregate == Min {
+		return w.extremes.len()
+	}
+	return w.values.len()
+}
+
+// Value returns the aggregate of the window
+func (w *Window) Value() float64 {
+	switch w.aggregate {
+	case Max, Min:
+		if w.extremes.len() == 0 {
+			return 0
+		}
+		return w.extremes.front().value
+	case Sum:
+		return w.sum + w.compensation
+	default:
+		if w.values.len() == 0 {
+			return 0
+		}
+		return (w.sum + w.compensation) / float64(w.values.len())
+	}
+}
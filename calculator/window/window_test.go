@@ -0,0 +1,108 @@
+package window_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmlellis/grpc-go-learning/calculator/window"
+)
+
+func TestCountWindow(t *testing.T) {
+	values := []float64{3, 1, 4, 1, 5, 9, 2, 6}
+	tests := []struct {
+		name      string
+		aggregate window.Aggregate
+		size      int
+		want      []float64
+	}{
+		{"max", window.Max, 3, []float64{3, 3, 4, 4, 5, 9, 9, 9}},
+		{"min", window.Min, 3, []float64{3, 1, 1, 1, 1, 1, 2, 2}},
+		{"sum", window.Sum, 3, []float64{3, 4, 8, 6, 10, 15, 16, 17}},
+		{"mean", window.Mean, 2, []float64{3, 2, 2.5, 2.5, 3, 7, 5.5, 4}},
+		{"size 1", window.Max, 1, values},
+	}
+	for _, tt := range tests {
+		w := window.NewCount(tt.aggregate, tt.size)
+		var got []float64
+		for _, v := range values {
+			a, err := w.Add(v, time.Time{})
+			if err != nil {
+				t.Fatalf("%v: Add(%v): %v", tt.name, v, err)
+			}
+			got = append(got, a)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: aggregates = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTimeWindow(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	// the values older than the last one by 10s or more leave the window
+	values := []struct {
+		value   float64
+		seconds int
+	}{
+		{5, 0}, {1, 5}, {3, 10}, {2, 12}, {2, 12}, {0, 30},
+	}
+	tests := []struct {
+		name      string
+		aggregate window.Aggregate
+		want      []float64
+	}{
+		{"max", window.Max, []float64{5, 5, 3, 3, 3, 0}},
+		{"min", window.Min, []float64{5, 1, 1, 1, 1, 0}},
+		{"sum", window.Sum, []float64{5, 6, 4, 6, 8, 0}},
+		{"mean", window.Mean, []float64{5, 3, 2, 2, 2, 0}},
+	}
+	for _, tt := range tests {
+		w := window.NewTime(tt.aggregate, 10*time.Second)
+		var got []float64
+		for _, v := range values {
+			a, err := w.Add(v.value, start.Add(time.Duration(v.seconds)*time.Second))
+			if err != nil {
+				t.Fatalf("%v: Add(%v): %v", tt.name, v.value, err)
+			}
+			got = append(got, a)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: aggregates = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTimeWindowErrors(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	w := window.NewTime(window.Sum, time.Second)
+	if _, err := w.Add(1, start); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add(1, start.Add(-time.Millisecond)); err != window.ErrTimeDecreased {
+		t.Errorf("Add with an older time error = %v, want ErrTimeDecreased", err)
+	}
+
+	var err error
+	for i := 0; i <= window.MaxValues && err == nil; i++ {
+		_, err = w.Add(1, start)
+	}
+	if err != window.ErrTooManyValues {
+		t.Errorf("Add beyond MaxValues error = %v, want ErrTooManyValues", err)
+	}
+}
+
+func TestSumCompensation(t *testing.T) {
+	w := window.NewCount(window.Sum, 3)
+	var sum float64
+	for _, v := range []float64{1e16, 1, -1e16, 1} {
+		sum, _ = w.Add(v, time.Time{})
+	}
+	// 1 - 1e16 + 1, the uncompensated sum loses a 1
+	if want := -1e16 + 2; sum != want {
+		t.Errorf("Sum = %v, want %v", sum, want)
+	}
+	if got := window.NewCount(window.Mean, 3).Value(); got != 0 {
+		t.Errorf("Value of an empty window = %v, want 0", got)
+	}
+}
@@ -5,6 +5,9 @@ go 1.13
 require (
 	github.com/golang/protobuf v1.4.2
 	go.mongodb.org/mongo-driver v1.4.0
+	golang.org/x/text v0.3.3
+	google.golang.org/genproto v0.0.0-20190819201941-24fa4b261c55
 	google.golang.org/grpc v1.30.0
 	google.golang.org/protobuf v1.23.0
+	modernc.org/sqlite v1.10.8
 )